@@ -0,0 +1,43 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/terraform-modules/scripts/go-discovery"
+)
+
+// discoverGroups returns a coverage group for every Go module below root,
+// named by its coverage_groups entry when there is one, like go-unit-test
+// --discover
+func discoverGroups(root string, config *Config) ([]CoverageGroup, error) {
+	dirs, err := discovery.Discover(root, config.GoDiscovery, config.ModuleRoots, config.Scripts.ExcludedDirs)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make(map[string]string, len(config.CoverageGroups))
+	for _, group := range config.CoverageGroups {
+		names[discovery.CleanPath(group.TestPath)] = group.Name
+	}
+	groups := make([]CoverageGroup, 0, len(dirs))
+	for _, dir := range dirs {
+		groups = append(groups, withDefaults(CoverageGroup{Name: names[dir], TestPath: "./" + dir}))
+	}
+	return groups, nil
+}
+
+// withDefaults derives the name and output file of a group from testPath
+// when they are left out, like go-unit-test
+func withDefaults(group CoverageGroup) CoverageGroup {
+	if group.TestPath == "" {
+		return group
+	}
+	dir := discovery.CleanPath(group.TestPath)
+	if group.Name == "" {
+		group.Name = dir
+	}
+	if group.OutputFile == "" {
+		group.OutputFile = strings.ReplaceAll(dir, "/", "-") + ".out"
+	}
+	return group
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// writeLCOV writes the coverage as an LCOV tracefile, with one record per file
+func writeLCOV(filePath string, files []*FileCoverage) error {
+	f, err := os.Create(filePath)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	for _, file := range files {
+		fmt.Fprintln(w, "TN:")
+		fmt.Fprintf(w, "SF:%s\n", file.Path)
+		for _, line := range file.SortedLines() {
+			fmt.Fprintf(w, "DA:%d,%d\n", line, file.Lines[line])
+		}
+		covered, total := file.Counts()
+		fmt.Fprintf(w, "LF:%d\n", total)
+		fmt.Fprintf(w, "LH:%d\n", covered)
+		fmt.Fprintln(w, "end_of_record")
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+type coberturaCoverage struct {
+	XMLName         xml.Name           `xml:"coverage"`
+	LineRate        string             `xml:"line-rate,attr"`
+	BranchRate      string             `xml:"branch-rate,attr"`
+	LinesCovered    int                `xml:"lines-covered,attr"`
+	LinesValid      int                `xml:"lines-valid,attr"`
+	BranchesCovered int                `xml:"branches-covered,attr"`
+	BranchesValid   int                `xml:"branches-valid,attr"`
+	Complexity      string             `xml:"complexity,attr"`
+	Version         string             `xml:"version,attr"`
+	Timestamp       int64              `xml:"timestamp,attr"`
+	Sources         []string           `xml:"sources>source"`
+	Packages        []coberturaPackage `xml:"packages>package"`
+}
+
+type coberturaPackage struct {
+	Name       string           `xml:"name,attr"`
+	LineRate   string           `xml:"line-rate,attr"`
+	BranchRate string           `xml:"branch-rate,attr"`
+	Complexity string           `xml:"complexity,attr"`
+	Classes    []coberturaClass `xml:"classes>class"`
+}
+
+type coberturaClass struct {
+	Name       string          `xml:"name,attr"`
+	Filename   string          `xml:"filename,attr"`
+	LineRate   string          `xml:"line-rate,attr"`
+	BranchRate string          `xml:"branch-rate,attr"`
+	Complexity string          `xml:"complexity,attr"`
+	Methods    struct{}        `xml:"methods"`
+	Lines      []coberturaLine `xml:"lines>line"`
+}
+
+type coberturaLine struct {
+	Number int `xml:"number,attr"`
+	Hits   int `xml:"hits,attr"`
+}
+
+// newCobertura groups the files into one package per directory. Branch
+// coverage is not measured and reported as zero.
+func newCobertura(files []*FileCoverage, now time.Time) coberturaCoverage {
+	report := coberturaCoverage{
+		BranchRate: "0",
+		Complexity: "0",
+		Version:    "coverage-export",
+		Timestamp:  now.Unix(),
+		Sources:    []string{"."},
+	}
+
+	byDir := make(map[string][]*FileCoverage)
+	for _, file := range files {
+		dir := path.Dir(file.Path)
+		byDir[dir] = append(byDir[dir], file)
+	}
+	dirs := make([]string, 0, len(byDir))
+	for dir := range byDir {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+
+	for _, dir := range dirs {
+		pkg := coberturaPackage{Name: dir, BranchRate: "0", Complexity: "0"}
+		pkgCovered, pkgTotal := 0, 0
+		for _, file := range byDir[dir] {
+			covered, total := file.Counts()
+			pkgCovered += covered
+			pkgTotal += total
+
+			class := coberturaClass{
+				Name:       path.Base(file.Path),
+				Filename:   file.Path,
+				LineRate:   rate(covered, total),
+				BranchRate: "0",
+				Complexity: "0",
+			}
+			for _, line := range file.SortedLines() {
+				class.Lines = append(class.Lines, coberturaLine{Number: line, Hits: file.Lines[line]})
+			}
+			pkg.Classes = append(pkg.Classes, class)
+		}
+		pkg.LineRate = rate(pkgCovered, pkgTotal)
+		report.LinesCovered += pkgCovered
+		report.LinesValid += pkgTotal
+		report.Packages = append(report.Packages, pkg)
+	}
+	report.LineRate = rate(report.LinesCovered, report.LinesValid)
+	return report
+}
+
+// writeCobertura writes the coverage as Cobertura XML
+func writeCobertura(filePath string, files []*FileCoverage, now time.Time) error {
+	data, err := xml.MarshalIndent(newCobertura(files, now), "", "  ")
+	if err != nil {
+		return err
+	}
+	data = append([]byte(xml.Header), data...)
+	return os.WriteFile(filePath, append(data, '\n'), 0644)
+}
+
+// rate formats covered/total as a Cobertura rate between 0 and 1
+func rate(covered, total int) string {
+	if total == 0 {
+		return "0"
+	}
+	return strconv.FormatFloat(float64(covered)/float64(total), 'f', 4, 64)
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestNewCobertura(t *testing.T) {
+	files := []*FileCoverage{
+		{Path: "a/x.go", Language: "go", Lines: map[int]int{1: 1, 2: 0}},
+		{Path: "a/y.go", Language: "go", Lines: map[int]int{1: 3}},
+		{Path: "b/z.rego", Language: "rego", Lines: map[int]int{4: 0}},
+	}
+	report := newCobertura(files, time.Unix(100, 0))
+
+	if report.LineRate != "0.5000" || report.LinesCovered != 2 || report.LinesValid != 4 || report.Timestamp != 100 {
+		t.Errorf("Unexpected totals %+v", report)
+	}
+	if len(report.Packages) != 2 || report.Packages[0].Name != "a" || report.Packages[0].LineRate != "0.6667" {
+		t.Fatalf("Unexpected packages %+v", report.Packages)
+	}
+	if class := report.Packages[0].Classes[0]; class.Name != "x.go" || len(class.Lines) != 2 || class.Lines[1].Hits != 0 {
+		t.Errorf("Unexpected class %+v", class)
+	}
+	if rate(0, 0) != "0" {
+		t.Errorf("Expected an empty file to have rate 0")
+	}
+}
+
+func TestSourceLinesMissing(t *testing.T) {
+	file := &FileCoverage{Path: "gone.go", Lines: map[int]int{7: 1, 3: 0}}
+	lines, missing := sourceLines(file, filepath.Join(t.TempDir(), "nowhere"))
+	if !missing {
+		t.Errorf("Expected the source to be reported missing")
+	}
+	if len(lines) != 2 || lines[0].Number != 3 || lines[0].Class != "uncovered" || lines[1].Class != "covered" {
+		t.Errorf("Unexpected lines %+v", lines)
+	}
+}
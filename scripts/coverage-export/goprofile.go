@@ -0,0 +1,253 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// blockKey identifies a block of a Go cover profile. The same block is
+// reported by every group whose coverPkg includes its package.
+type blockKey struct {
+	File      string // Import path of the file
+	StartLine int
+	StartCol  int
+	EndLine   int
+	EndCol    int
+}
+
+// profileBlock holds the statement count and execution count of a block
+type profileBlock struct {
+	NumStmt int
+	Count   int
+}
+
+// Profile is a set of Go cover profiles merged into one
+type Profile struct {
+	Mode   string // set, count or atomic
+	Blocks map[blockKey]profileBlock
+}
+
+// NewProfile returns an empty profile
+func NewProfile() *Profile {
+	return &Profile{Blocks: make(map[blockKey]profileBlock)}
+}
+
+// mergeGroupProfiles merges the profile of every coverage group. Groups
+// without a profile, for example because their tests failed, are skipped
+// with a warning.
+func mergeGroupProfiles(groups []CoverageGroup, coverageDir string) (*Profile, error) {
+	profile := NewProfile()
+	for _, group := range groups {
+		if group.OutputFile == "" {
+			continue
+		}
+		path := filepath.Join(coverageDir, group.OutputFile)
+		f, err := os.Open(path)
+		if os.IsNotExist(err) {
+			fmt.Fprintf(os.Stderr, "  ⚠️  No profile for %s (%s), skipping\n", group.Name, path)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		err = profile.Add(f)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("error reading profile %s: %w", path, err)
+		}
+	}
+	return profile, nil
+}
+
+// Add merges a cover profile. Blocks already present are deduplicated: in
+// set mode a block is covered if any profile covered it, in count and
+// atomic mode the counts are added.
+func (p *Profile) Add(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		if mode, ok := strings.CutPrefix(line, "mode: "); ok {
+			if p.Mode != "" && p.Mode != mode {
+				return fmt.Errorf("line %d: cannot merge %s mode into %s mode profiles", lineNo, mode, p.Mode)
+			}
+			p.Mode = mode
+			continue
+		}
+
+		key, block, err := parseProfileLine(line)
+		if err != nil {
+			return fmt.Errorf("line %d: %w", lineNo, err)
+		}
+		if existing, ok := p.Blocks[key]; ok {
+			if p.Mode == "set" {
+				block.Count = max(existing.Count, block.Count)
+			} else {
+				block.Count += existing.Count
+			}
+		}
+		p.Blocks[key] = block
+	}
+	return scanner.Err()
+}
+
+// parseProfileLine parses a block line: file:startLine.startCol,endLine.endCol numStmt count
+func parseProfileLine(line string) (blockKey, profileBlock, error) {
+	var key blockKey
+	var block profileBlock
+
+	colon := strings.LastIndex(line, ":")
+	if colon < 0 {
+		return key, block, fmt.Errorf("malformed block %q", line)
+	}
+	key.File = line[:colon]
+
+	fields := strings.Fields(line[colon+1:])
+	if len(fields) != 3 {
+		return key, block, fmt.Errorf("malformed block %q", line)
+	}
+	if _, err := fmt.Sscanf(fields[0], "%d.%d,%d.%d", &key.StartLine, &key.StartCol, &key.EndLine, &key.EndCol); err != nil {
+		return key, block, fmt.Errorf("malformed position in %q", line)
+	}
+	var err error
+	if block.NumStmt, err = strconv.Atoi(fields[1]); err != nil {
+		return key, block, fmt.Errorf("malformed statement count in %q", line)
+	}
+	if block.Count, err = strconv.Atoi(fields[2]); err != nil {
+		return key, block, fmt.Errorf("malformed count in %q", line)
+	}
+	return key, block, nil
+}
+
+// sortedKeys returns the blocks ordered by file and position
+func (p *Profile) sortedKeys() []blockKey {
+	keys := make([]blockKey, 0, len(p.Blocks))
+	for key := range p.Blocks {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := keys[i], keys[j]
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		if a.StartLine != b.StartLine {
+			return a.StartLine < b.StartLine
+		}
+		if a.StartCol != b.StartCol {
+			return a.StartCol < b.StartCol
+		}
+		if a.EndLine != b.EndLine {
+			return a.EndLine < b.EndLine
+		}
+		return a.EndCol < b.EndCol
+	})
+	return keys
+}
+
+// Write writes the merged profile in the cover profile format
+func (p *Profile) Write(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	mode := p.Mode
+	if mode == "" {
+		mode = "set"
+	}
+	fmt.Fprintf(bw, "mode: %s\n", mode)
+	for _, key := range p.sortedKeys() {
+		block := p.Blocks[key]
+		fmt.Fprintf(bw, "%s:%d.%d,%d.%d %d %d\n", key.File, key.StartLine, key.StartCol, key.EndLine, key.EndCol, block.NumStmt, block.Count)
+	}
+	return bw.Flush()
+}
+
+// WriteFile writes the merged profile to path
+func (p *Profile) WriteFile(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := p.Write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// FileCoverage converts the blocks into per-line hit counts, with file
+// paths resolved to the repository through dirs. A line is covered when any
+// block on it ran.
+func (p *Profile) FileCoverage(dirs map[string]string) []*FileCoverage {
+	byFile := make(map[string]*FileCoverage)
+	var files []*FileCoverage
+	for _, key := range p.sortedKeys() {
+		file, ok := byFile[key.File]
+		if !ok {
+			file = &FileCoverage{Path: resolveImportPath(key.File, dirs), Language: "go", Lines: make(map[int]int)}
+			byFile[key.File] = file
+			files = append(files, file)
+		}
+		count := p.Blocks[key].Count
+		for line := key.StartLine; line <= key.EndLine; line++ {
+			file.Lines[line] = max(file.Lines[line], count)
+		}
+	}
+	return files
+}
+
+// moduleDirs maps the module path of each group's go.mod to the module
+// directory, relative to root
+func moduleDirs(groups []CoverageGroup, root string) map[string]string {
+	dirs := make(map[string]string)
+	for _, group := range groups {
+		if group.TestPath == "" {
+			continue
+		}
+		dir := filepath.Clean(group.TestPath)
+		modulePath := readModulePath(filepath.Join(root, dir, "go.mod"))
+		if modulePath != "" {
+			dirs[modulePath] = filepath.ToSlash(dir)
+		}
+	}
+	return dirs
+}
+
+// readModulePath returns the module path declared by a go.mod file
+func readModulePath(path string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if modulePath, ok := strings.CutPrefix(strings.TrimSpace(line), "module "); ok {
+			return strings.Trim(strings.TrimSpace(modulePath), `"`)
+		}
+	}
+	return ""
+}
+
+// resolveImportPath turns the import path of a file into a path relative to
+// the repository, using the longest matching module. Files outside the known
+// modules keep their import path.
+func resolveImportPath(file string, dirs map[string]string) string {
+	best := ""
+	for modulePath := range dirs {
+		if strings.HasPrefix(file, modulePath+"/") && len(modulePath) > len(best) {
+			best = modulePath
+		}
+	}
+	if best == "" {
+		return file
+	}
+	return dirs[best] + strings.TrimPrefix(file, best)
+}
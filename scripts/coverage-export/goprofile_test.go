@@ -0,0 +1,83 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestProfileAdd(t *testing.T) {
+	profile := NewProfile()
+	first := "mode: set\nexample.com/a/main.go:10.2,12.3 2 1\nexample.com/a/main.go:14.2,14.10 1 0\n"
+	// The same package covered by a second group, with a duplicated block
+	second := "mode: set\nexample.com/a/main.go:10.2,12.3 2 0\nexample.com/a/main.go:14.2,14.10 1 1\nexample.com/b/b.go:3.1,3.5 1 0\n"
+	for _, data := range []string{first, second} {
+		if err := profile.Add(strings.NewReader(data)); err != nil {
+			t.Fatalf("Add() error = %v", err)
+		}
+	}
+
+	if len(profile.Blocks) != 3 {
+		t.Fatalf("Expected 3 deduplicated blocks, got %d", len(profile.Blocks))
+	}
+	for key, block := range profile.Blocks {
+		if key.File == "example.com/a/main.go" && block.Count != 1 {
+			t.Errorf("Expected block %+v to be covered in set mode, got count %d", key, block.Count)
+		}
+	}
+
+	var out strings.Builder
+	if err := profile.Write(&out); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	expected := "mode: set\nexample.com/a/main.go:10.2,12.3 2 1\nexample.com/a/main.go:14.2,14.10 1 1\nexample.com/b/b.go:3.1,3.5 1 0\n"
+	if out.String() != expected {
+		t.Errorf("Write() =\n%s\nwant\n%s", out.String(), expected)
+	}
+}
+
+func TestProfileAddCountMode(t *testing.T) {
+	profile := NewProfile()
+	for i := 0; i < 2; i++ {
+		if err := profile.Add(strings.NewReader("mode: count\nexample.com/a/main.go:1.1,2.2 1 3\n")); err != nil {
+			t.Fatalf("Add() error = %v", err)
+		}
+	}
+	for _, block := range profile.Blocks {
+		if block.Count != 6 {
+			t.Errorf("Expected counts to be added, got %d", block.Count)
+		}
+	}
+
+	if err := profile.Add(strings.NewReader("mode: set\n")); err == nil {
+		t.Errorf("Expected an error when merging set mode into count mode")
+	}
+	if err := NewProfile().Add(strings.NewReader("mode: set\nexample.com/a/main.go:1.1 1\n")); err == nil {
+		t.Errorf("Expected an error for a malformed block")
+	}
+}
+
+func TestProfileFileCoverage(t *testing.T) {
+	profile := NewProfile()
+	data := "mode: set\nexample.com/a/main.go:10.2,12.3 2 1\nexample.com/a/main.go:12.5,13.2 1 0\nother.org/x/x.go:1.1,1.9 1 0\n"
+	if err := profile.Add(strings.NewReader(data)); err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+
+	files := profile.FileCoverage(map[string]string{"example.com/a": "scripts/a", "example.com": "elsewhere"})
+	if len(files) != 2 {
+		t.Fatalf("Expected 2 files, got %d", len(files))
+	}
+	if files[0].Path != "scripts/a/main.go" {
+		t.Errorf("Expected the longest module to win, got %s", files[0].Path)
+	}
+	// Line 12 is shared by a covered and an uncovered block
+	expected := map[int]int{10: 1, 11: 1, 12: 1, 13: 0}
+	for line, hits := range expected {
+		if files[0].Lines[line] != hits {
+			t.Errorf("Line %d: expected %d hits, got %d", line, hits, files[0].Lines[line])
+		}
+	}
+	if files[1].Path != "other.org/x/x.go" {
+		t.Errorf("Expected unknown modules to keep the import path, got %s", files[1].Path)
+	}
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// htmlLine is one source line of a file page
+type htmlLine struct {
+	Number int
+	Text   string
+	Class  string // covered, uncovered or empty for lines without code
+}
+
+// htmlFile is one file of the report
+type htmlFile struct {
+	ID       string
+	Path     string
+	Language string
+	Covered  int
+	Total    int
+	Percent  float64
+	Lines    []htmlLine
+	Missing  bool // Source could not be read
+}
+
+// htmlTotal is a summary row
+type htmlTotal struct {
+	Name    string
+	Covered int
+	Total   int
+	Percent float64
+}
+
+var htmlTemplate = template.Must(template.New("coverage").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Coverage Report</title>
+<style>
+body { font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", sans-serif; margin: 2em; color: #24292f; }
+table { border-collapse: collapse; }
+th, td { padding: 0.3em 0.8em; text-align: left; border-bottom: 1px solid #d0d7de; }
+td.num { text-align: right; font-variant-numeric: tabular-nums; }
+.bar { width: 120px; height: 0.8em; background: #ffd8d3; display: inline-block; }
+.bar span { height: 100%; background: #2da44e; display: block; }
+.page { display: none; }
+.page:target { display: block; }
+body:has(.page:target) #index { display: none; }
+.src { font-family: ui-monospace, SFMono-Regular, Menlo, monospace; font-size: 0.85em; border-collapse: collapse; }
+.src td { border: none; padding: 0 0.6em; white-space: pre; }
+.src td.ln { color: #8c959f; text-align: right; user-select: none; }
+.covered { background: #dafbe1; }
+.uncovered { background: #ffebe9; }
+</style>
+</head>
+<body>
+<h1>Coverage Report</h1>
+<div id="index">
+<table>
+<tr><th></th><th>Coverage</th><th></th><th>Lines</th></tr>
+{{- range .Totals}}
+<tr><th>{{.Name}}</th><td class="num">{{printf "%.1f%%" .Percent}}</td><td><div class="bar"><span style="width: {{printf "%.0f" .Percent}}%"></span></div></td><td class="num">{{.Covered}} / {{.Total}}</td></tr>
+{{- end}}
+</table>
+<h2>Files</h2>
+<table>
+<tr><th>File</th><th>Language</th><th>Coverage</th><th></th><th>Lines</th></tr>
+{{- range .Files}}
+<tr><td><a href="#{{.ID}}">{{.Path}}</a></td><td>{{.Language}}</td><td class="num">{{printf "%.1f%%" .Percent}}</td><td><div class="bar"><span style="width: {{printf "%.0f" .Percent}}%"></span></div></td><td class="num">{{.Covered}} / {{.Total}}</td></tr>
+{{- end}}
+</table>
+</div>
+{{- range .Files}}
+<div class="page" id="{{.ID}}">
+<h2>{{.Path}}</h2>
+<p><a href="#index">Back to the file list</a> · {{printf "%.1f%%" .Percent}} of {{.Total}} lines covered</p>
+{{- if .Missing}}
+<p>Source not available. Uncovered lines are highlighted by number only.</p>
+{{- end}}
+<table class="src">
+{{- range .Lines}}
+<tr class="{{.Class}}"><td class="ln">{{.Number}}</td><td>{{.Text}}</td></tr>
+{{- end}}
+</table>
+</div>
+{{- end}}
+</body>
+</html>
+`))
+
+// writeHTML writes a single self-contained HTML report. The index lists every
+// file, and each file links to a page with its source, where covered and
+// uncovered lines are highlighted. The pages are switched with CSS :target,
+// so the report works without scripts or a server.
+func writeHTML(filePath string, files []*FileCoverage, root string) error {
+	totals := map[string]*htmlTotal{"go": {Name: "Go"}, "rego": {Name: "Rego"}}
+	all := &htmlTotal{Name: "Total"}
+
+	pages := make([]htmlFile, 0, len(files))
+	for i, file := range files {
+		covered, total := file.Counts()
+		page := htmlFile{
+			ID:       fmt.Sprintf("file-%d", i+1),
+			Path:     file.Path,
+			Language: file.Language,
+			Covered:  covered,
+			Total:    total,
+			Percent:  percent(covered, total),
+		}
+		page.Lines, page.Missing = sourceLines(file, root)
+		pages = append(pages, page)
+
+		if t, ok := totals[file.Language]; ok {
+			t.Covered += covered
+			t.Total += total
+		}
+		all.Covered += covered
+		all.Total += total
+	}
+
+	var rows []htmlTotal
+	for _, t := range []*htmlTotal{totals["go"], totals["rego"], all} {
+		if t.Total == 0 && t != all {
+			continue
+		}
+		t.Percent = percent(t.Covered, t.Total)
+		rows = append(rows, *t)
+	}
+
+	f, err := os.Create(filePath)
+	if err != nil {
+		return err
+	}
+	if err := htmlTemplate.Execute(f, map[string]interface{}{"Totals": rows, "Files": pages}); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// sourceLines returns the annotated source of a file. When the source cannot
+// be read, only the lines with code are returned, without text.
+func sourceLines(file *FileCoverage, root string) ([]htmlLine, bool) {
+	class := func(line int) string {
+		hits, ok := file.Lines[line]
+		switch {
+		case !ok:
+			return ""
+		case hits > 0:
+			return "covered"
+		default:
+			return "uncovered"
+		}
+	}
+
+	data, err := os.ReadFile(filepath.Join(root, filepath.FromSlash(file.Path)))
+	if err != nil {
+		var lines []htmlLine
+		for _, line := range file.SortedLines() {
+			lines = append(lines, htmlLine{Number: line, Class: class(line)})
+		}
+		return lines, true
+	}
+
+	text := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	lines := make([]htmlLine, 0, len(text))
+	for i, t := range text {
+		lines = append(lines, htmlLine{Number: i + 1, Text: strings.ReplaceAll(t, "\t", "    "), Class: class(i + 1)})
+	}
+	return lines, false
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/terraform-modules/scripts/go-discovery"
+)
+
+// Output files written to the coverage directory
+const (
+	MergedProfileFile = "go-coverage.out"
+	LCOVFile          = "coverage.lcov"
+	CoberturaFile     = "coverage.cobertura.xml"
+	HTMLFile          = "coverage.html"
+)
+
+// CoverageGroup is a coverage_groups entry of monorepo-config.json
+type CoverageGroup struct {
+	Name       string `json:"name"`
+	OutputFile string `json:"outputFile"`
+	TestPath   string `json:"testPath"`
+}
+
+// Config holds the parts of monorepo-config.json used by the export
+type Config struct {
+	CoverageGroups []CoverageGroup  `json:"coverage_groups"`
+	GoDiscovery    discovery.Config `json:"go_discovery"`
+	ModuleRoots    []string         `json:"module_roots"`
+	Scripts        struct {
+		ExcludedDirs []string `json:"excluded_dirs"`
+	} `json:"scripts"`
+}
+
+// FileCoverage holds the line hit counts of one source file
+type FileCoverage struct {
+	Path     string      // Relative to the repository root
+	Language string      // go or rego
+	Lines    map[int]int // Line number to hit count, for lines with code
+}
+
+// Counts returns the number of covered lines and lines with code
+func (f *FileCoverage) Counts() (covered, total int) {
+	for _, hits := range f.Lines {
+		total++
+		if hits > 0 {
+			covered++
+		}
+	}
+	return covered, total
+}
+
+// SortedLines returns the line numbers with code in ascending order
+func (f *FileCoverage) SortedLines() []int {
+	lines := make([]int, 0, len(f.Lines))
+	for line := range f.Lines {
+		lines = append(lines, line)
+	}
+	sort.Ints(lines)
+	return lines
+}
+
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// run merges the Go and Rego coverage and writes all export formats
+func run(args []string) error {
+	fs := flag.NewFlagSet("coverage-export", flag.ContinueOnError)
+	coverageDir := fs.String("coverage-dir", filepath.Join("tmp", "coverage"), "Directory holding the Go profiles and Rego coverage, and receiving the exports")
+	root := fs.String("root", ".", "Repository root that coverage paths are resolved against")
+	discover := fs.Bool("discover", false, "Read the profiles of every Go module below --root, as written by go-unit-test --discover")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() < 1 {
+		return fmt.Errorf("config file path is required")
+	}
+
+	config, err := readConfig(fs.Arg(0))
+	if err != nil {
+		return fmt.Errorf("error reading config file: %w", err)
+	}
+	var groups []CoverageGroup
+	if *discover {
+		if groups, err = discoverGroups(*root, config); err != nil {
+			return fmt.Errorf("error discovering Go modules: %w", err)
+		}
+	} else {
+		for _, group := range config.CoverageGroups {
+			groups = append(groups, withDefaults(group))
+		}
+	}
+	if err := checkOutputFiles(groups); err != nil {
+		return err
+	}
+
+	// Go profiles, merged across groups
+	profile, err := mergeGroupProfiles(groups, *coverageDir)
+	if err != nil {
+		return err
+	}
+	files := profile.FileCoverage(moduleDirs(groups, *root))
+
+	// Rego coverage written by rego-unit-test
+	regoFiles, err := readRegoCoverage(*coverageDir, *root)
+	if err != nil {
+		return err
+	}
+	files = append(files, regoFiles...)
+
+	if len(files) == 0 {
+		return fmt.Errorf("no coverage data found in %s; run make go-unit-test-coverage and make rego-unit-test-coverage first", *coverageDir)
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+
+	// Write every format
+	outputs := []struct {
+		name  string
+		write func(path string) error
+	}{
+		{MergedProfileFile, profile.WriteFile},
+		{LCOVFile, func(path string) error { return writeLCOV(path, files) }},
+		{CoberturaFile, func(path string) error { return writeCobertura(path, files, time.Now()) }},
+		{HTMLFile, func(path string) error { return writeHTML(path, files, *root) }},
+	}
+	for _, output := range outputs {
+		if output.name == MergedProfileFile && len(profile.Blocks) == 0 {
+			continue
+		}
+		path := filepath.Join(*coverageDir, output.name)
+		if err := output.write(path); err != nil {
+			return fmt.Errorf("error writing %s: %w", path, err)
+		}
+		fmt.Fprintf(os.Stderr, "  ✅ Wrote %s\n", path)
+	}
+
+	covered, total := 0, 0
+	for _, file := range files {
+		c, t := file.Counts()
+		covered += c
+		total += t
+	}
+	fmt.Fprintf(os.Stderr, "✅ Exported coverage for %d files: %.1f%% of %d lines\n", len(files), percent(covered, total), total)
+	return nil
+}
+
+// readConfig loads the coverage groups from monorepo-config.json
+func readConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var config Config
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, err
+	}
+	return &config, nil
+}
+
+// checkOutputFiles refuses coverage groups that write the same profile, as
+// the later group would overwrite the earlier one's coverage. The export
+// files are reserved as well.
+func checkOutputFiles(groups []CoverageGroup) error {
+	owners := make(map[string]string)
+	for _, name := range []string{MergedProfileFile, LCOVFile, CoberturaFile, HTMLFile} {
+		owners[name] = "coverage-export"
+	}
+	var collisions []string
+	for _, group := range groups {
+		if group.OutputFile == "" {
+			continue
+		}
+		key := filepath.Clean(group.OutputFile)
+		if owner, ok := owners[key]; ok {
+			collisions = append(collisions, fmt.Sprintf("%q and %q both write %s", owner, group.Name, group.OutputFile))
+			continue
+		}
+		owners[key] = group.Name
+	}
+	if len(collisions) > 0 {
+		return fmt.Errorf("coverage_groups output file collision: %s", strings.Join(collisions, "; "))
+	}
+	return nil
+}
+
+// percent returns part of total as a percentage, 0 for an empty total
+func percent(part, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(part) / float64(total) * 100
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCheckOutputFiles(t *testing.T) {
+	tests := []struct {
+		name    string
+		groups  []CoverageGroup
+		wantErr string
+	}{
+		{
+			name:   "unique outputs",
+			groups: []CoverageGroup{{Name: "A", OutputFile: "a.out"}, {Name: "B", OutputFile: "b.out"}, {Name: "C"}},
+		},
+		{
+			name:    "shared output",
+			groups:  []CoverageGroup{{Name: "A", OutputFile: "a.out"}, {Name: "B", OutputFile: "./a.out"}},
+			wantErr: `"A" and "B" both write ./a.out`,
+		},
+		{
+			name:    "reserved export file",
+			groups:  []CoverageGroup{{Name: "A", OutputFile: LCOVFile}},
+			wantErr: `"coverage-export" and "A" both write coverage.lcov`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkOutputFiles(tt.groups)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("checkOutputFiles() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("checkOutputFiles() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRun(t *testing.T) {
+	root := t.TempDir()
+	coverageDir := filepath.Join(root, "tmp", "coverage")
+	write := func(path, content string) {
+		t.Helper()
+		path = filepath.Join(root, path)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write("scripts/tool/go.mod", "module example.com/tool\n\ngo 1.23\n")
+	write("scripts/tool/main.go", "package main\n\nfunc main() {\n\tprintln(\"<hi>\")\n}\n")
+	write("policies/p.rego", "package p\n\nallow if true\n")
+	write("tmp/coverage/tool.out", "mode: set\nexample.com/tool/main.go:3.13,5.2 1 0\n")
+	write("tmp/coverage/other.out", "mode: set\nexample.com/tool/main.go:3.13,5.2 1 1\n")
+	write("tmp/coverage/rego-coverage-policies.json", `{"files":{"`+filepath.ToSlash(filepath.Join(root, "policies", "p.rego"))+`":{"covered":[{"start":{"row":3},"end":{"row":3}}]}}}`)
+	write("monorepo-config.json", `{"coverage_groups":[
+		{"name":"Tool","outputFile":"tool.out","testPath":"./scripts/tool"},
+		{"name":"Other","outputFile":"other.out","testPath":"./scripts/tool"},
+		{"name":"Missing","outputFile":"missing.out","testPath":"./scripts/missing"}]}`)
+
+	err := run([]string{"--coverage-dir", coverageDir, "--root", root, filepath.Join(root, "monorepo-config.json")})
+	if err != nil {
+		t.Fatalf("run() error = %v", err)
+	}
+
+	merged, err := os.ReadFile(filepath.Join(coverageDir, MergedProfileFile))
+	if err != nil {
+		t.Fatalf("Expected merged profile: %v", err)
+	}
+	if string(merged) != "mode: set\nexample.com/tool/main.go:3.13,5.2 1 1\n" {
+		t.Errorf("Unexpected merged profile:\n%s", merged)
+	}
+
+	lcov, err := os.ReadFile(filepath.Join(coverageDir, LCOVFile))
+	if err != nil {
+		t.Fatalf("Expected LCOV report: %v", err)
+	}
+	expected := "TN:\nSF:policies/p.rego\nDA:3,1\nLF:1\nLH:1\nend_of_record\n" +
+		"TN:\nSF:scripts/tool/main.go\nDA:3,1\nDA:4,1\nDA:5,1\nLF:3\nLH:3\nend_of_record\n"
+	if string(lcov) != expected {
+		t.Errorf("Unexpected LCOV report:\n%s", lcov)
+	}
+
+	data, err := os.ReadFile(filepath.Join(coverageDir, CoberturaFile))
+	if err != nil {
+		t.Fatalf("Expected Cobertura report: %v", err)
+	}
+	var cobertura coberturaCoverage
+	if err := xml.Unmarshal(data, &cobertura); err != nil {
+		t.Fatalf("Failed to parse Cobertura report: %v", err)
+	}
+	if cobertura.LinesValid != 4 || cobertura.LinesCovered != 4 || len(cobertura.Packages) != 2 {
+		t.Errorf("Unexpected Cobertura totals %+v", cobertura)
+	}
+
+	html, err := os.ReadFile(filepath.Join(coverageDir, HTMLFile))
+	if err != nil {
+		t.Fatalf("Expected HTML report: %v", err)
+	}
+	for _, want := range []string{`id="file-1"`, `id="file-2"`, `href="#file-2"`, "&lt;hi&gt;", `<tr class="covered"><td class="ln">4</td>`} {
+		if !strings.Contains(string(html), want) {
+			t.Errorf("Expected HTML report to contain %q", want)
+		}
+	}
+}
+
+func TestRunRefusesCollisions(t *testing.T) {
+	dir := t.TempDir()
+	config := filepath.Join(dir, "monorepo-config.json")
+	os.WriteFile(config, []byte(`{"coverage_groups":[{"name":"A","outputFile":"x.out"},{"name":"B","outputFile":"x.out"}]}`), 0644)
+
+	err := run([]string{"--coverage-dir", dir, config})
+	if err == nil || !strings.Contains(err.Error(), "collision") {
+		t.Errorf("Expected a collision error, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, LCOVFile)); !os.IsNotExist(err) {
+		t.Errorf("Expected no reports after a collision")
+	}
+}
+
+func TestRunDiscover(t *testing.T) {
+	root := t.TempDir()
+	coverageDir := filepath.Join(root, "tmp", "coverage")
+	files := map[string]string{
+		"scripts/tool/go.mod":                "module example.com/tool\n",
+		"scripts/tool/main.go":               "package main\n\nfunc main() {\n}\n",
+		"skeletons/generic/go.mod":           "module example.com/generic\n",
+		"tmp/coverage/scripts-tool.out":      "mode: set\nexample.com/tool/main.go:3.13,4.2 1 1\n",
+		"tmp/coverage/skeletons-generic.out": "mode: set\nexample.com/generic/x.go:1.1,1.2 1 0\n",
+		"monorepo-config.json":               `{"module_roots":["skeletons/"],"coverage_groups":[{"name":"Tool","testPath":"./scripts/tool"}]}`,
+	}
+	for path, content := range files {
+		path = filepath.Join(root, filepath.FromSlash(path))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err := run([]string{"--discover", "--coverage-dir", coverageDir, "--root", root, filepath.Join(root, "monorepo-config.json")})
+	if err != nil {
+		t.Fatalf("run() error = %v", err)
+	}
+	lcov, err := os.ReadFile(filepath.Join(coverageDir, LCOVFile))
+	if err != nil {
+		t.Fatalf("Expected LCOV report: %v", err)
+	}
+	if string(lcov) != "TN:\nSF:scripts/tool/main.go\nDA:3,1\nDA:4,1\nLF:2\nLH:2\nend_of_record\n" {
+		t.Errorf("Expected only the discovered module, got:\n%s", lcov)
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// regoCoverage is the part of `opa test --coverage --format=json` output
+// used by the export, as saved by rego-unit-test
+type regoCoverage struct {
+	Files map[string]struct {
+		Covered    []regoRange `json:"covered"`
+		NotCovered []regoRange `json:"not_covered"`
+	} `json:"files"`
+}
+
+type regoRange struct {
+	Start struct {
+		Row int `json:"row"`
+	} `json:"start"`
+	End struct {
+		Row int `json:"row"`
+	} `json:"end"`
+}
+
+// readRegoCoverage merges the rego-coverage-* files of every rego_tests
+// directory. A policy loaded by several directories, such as the helpers,
+// has a line covered if any directory covered it.
+func readRegoCoverage(coverageDir, root string) ([]*FileCoverage, error) {
+	var paths []string
+	for _, pattern := range []string{"rego-coverage-*.json", "rego-coverage-*.txt"} {
+		matches, err := filepath.Glob(filepath.Join(coverageDir, pattern))
+		if err != nil {
+			return nil, err
+		}
+		paths = append(paths, matches...)
+	}
+
+	byFile := make(map[string]*FileCoverage)
+	var files []*FileCoverage
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		var coverage regoCoverage
+		if err := json.Unmarshal(data, &coverage); err != nil {
+			return nil, fmt.Errorf("error parsing Rego coverage %s: %w", path, err)
+		}
+
+		for name, report := range coverage.Files {
+			rel := repoRelative(name, root)
+			file, ok := byFile[rel]
+			if !ok {
+				file = &FileCoverage{Path: rel, Language: "rego", Lines: make(map[int]int)}
+				byFile[rel] = file
+				files = append(files, file)
+			}
+			for _, r := range report.NotCovered {
+				for line := r.Start.Row; line <= r.End.Row; line++ {
+					if _, ok := file.Lines[line]; !ok {
+						file.Lines[line] = 0
+					}
+				}
+			}
+			for _, r := range report.Covered {
+				for line := r.Start.Row; line <= r.End.Row; line++ {
+					file.Lines[line] = 1
+				}
+			}
+		}
+	}
+	return files, nil
+}
+
+// repoRelative returns path relative to root when it lies inside it.
+// rego-unit-test reports the helpers with absolute paths.
+func repoRelative(path, root string) string {
+	if !filepath.IsAbs(path) {
+		return filepath.ToSlash(filepath.Clean(path))
+	}
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return filepath.ToSlash(path)
+	}
+	rel, err := filepath.Rel(absRoot, path)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return filepath.ToSlash(path)
+	}
+	return filepath.ToSlash(rel)
+}
@@ -4,7 +4,6 @@ import (
 	"encoding/json"
 	"flag"
 	"fmt"
-	"io/ioutil"
 	"os"
 	"strings"
 )
@@ -26,7 +25,11 @@ func main() {
 	}
 
 	// Get changed files
-	changedFiles := getChangedFiles(config)
+	changedFiles, err := getChangedFiles(config)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
 	if len(changedFiles) == 0 {
 		fmt.Println("Error: No changed files provided in test_changed_files")
 		os.Exit(1)
@@ -93,7 +96,7 @@ func main() {
 
 // loadConfig loads the configuration from a JSON file
 func loadConfig(path string) (map[string]interface{}, error) {
-	data, err := ioutil.ReadFile(path)
+	data, err := os.ReadFile(path)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read config file: %w", err)
 	}
@@ -107,20 +110,18 @@ func loadConfig(path string) (map[string]interface{}, error) {
 }
 
 // getChangedFiles gets the list of changed files from the configuration
-func getChangedFiles(config map[string]interface{}) []string {
+func getChangedFiles(config map[string]interface{}) ([]string, error) {
 	// For testing, use files from config if provided
 	if files, ok := config["test_changed_files"].([]interface{}); ok {
 		changedFiles := make([]string, len(files))
 		for i, file := range files {
 			changedFiles[i] = file.(string)
 		}
-		return changedFiles
+		return changedFiles, nil
 	}
 
 	// If test_changed_files is not provided, this is an error
-	fmt.Println("Error: test_changed_files not found in config")
-	os.Exit(1)
-	return []string{}
+	return nil, fmt.Errorf("test_changed_files not found in config")
 }
 
 // detectModuleChanges determines if changes are in modules and returns the module paths and types
@@ -1,7 +1,6 @@
 package main
 
 import (
-	"io/ioutil"
 	"os"
 	"reflect"
 	"sort"
@@ -18,7 +17,7 @@ func TestLoadConfig(t *testing.T) {
 		}
 	}`
 
-	tmpFile, err := ioutil.TempFile("", "config-*.json")
+	tmpFile, err := os.CreateTemp("", "config-*.json")
 	if err != nil {
 		t.Fatalf("Failed to create temp file: %v", err)
 	}
@@ -72,7 +71,10 @@ func TestGetChangedFiles(t *testing.T) {
 		"modules/data/other/file.tf",
 	}
 
-	files := getChangedFiles(config)
+	files, err := getChangedFiles(config)
+	if err != nil {
+		t.Fatalf("getChangedFiles() error = %v", err)
+	}
 	if !reflect.DeepEqual(files, expected) {
 		t.Errorf("getChangedFiles() = %v, want %v", files, expected)
 	}
@@ -81,10 +83,15 @@ func TestGetChangedFiles(t *testing.T) {
 	config = map[string]interface{}{
 		"test_changed_files": []interface{}{},
 	}
-	files = getChangedFiles(config)
+	files, _ = getChangedFiles(config)
 	if len(files) != 0 {
 		t.Errorf("getChangedFiles() with empty array should return empty slice")
 	}
+
+	// Test without files in config
+	if _, err := getChangedFiles(map[string]interface{}{}); err == nil {
+		t.Errorf("getChangedFiles() without test_changed_files should return an error")
+	}
 }
 
 func TestMatchesPattern(t *testing.T) {
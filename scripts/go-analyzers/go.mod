@@ -0,0 +1,10 @@
+module github.com/terraform-modules/scripts/go-analyzers
+
+go 1.23.9
+
+require golang.org/x/tools v0.33.0
+
+require (
+	golang.org/x/mod v0.24.0 // indirect
+	golang.org/x/sync v0.16.0 // indirect
+)
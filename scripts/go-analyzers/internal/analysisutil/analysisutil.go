@@ -0,0 +1,10 @@
+// Package analysisutil holds helpers shared by the repository's analyzers.
+package analysisutil
+
+import "go/types"
+
+// IsFunc reports whether obj is the package-level function pkg.name
+func IsFunc(obj types.Object, pkg, name string) bool {
+	fn, ok := obj.(*types.Func)
+	return ok && fn.Pkg() != nil && fn.Pkg().Path() == pkg && fn.Name() == name && fn.Type().(*types.Signature).Recv() == nil
+}
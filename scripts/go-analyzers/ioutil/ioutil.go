@@ -0,0 +1,55 @@
+// Package ioutil defines an Analyzer that reports uses of the deprecated
+// io/ioutil package.
+package ioutil
+
+import (
+	"go/ast"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+// Analyzer reports uses of the deprecated io/ioutil package
+var Analyzer = &analysis.Analyzer{
+	Name: "ioutil",
+	Doc: `report uses of the deprecated io/ioutil package
+
+Since Go 1.16 every io/ioutil function is a wrapper around its io or os
+equivalent. ReadDir is the exception in behavior: os.ReadDir returns
+fs.DirEntry values instead of fs.FileInfo.`,
+	Run: run,
+}
+
+// replacements maps the io/ioutil functions and variables to their
+// replacements
+var replacements = map[string]string{
+	"Discard":   "io.Discard",
+	"NopCloser": "io.NopCloser",
+	"ReadAll":   "io.ReadAll",
+	"ReadDir":   "os.ReadDir",
+	"ReadFile":  "os.ReadFile",
+	"TempDir":   "os.MkdirTemp",
+	"TempFile":  "os.CreateTemp",
+	"WriteFile": "os.WriteFile",
+}
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	for _, file := range pass.Files {
+		ast.Inspect(file, func(n ast.Node) bool {
+			sel, ok := n.(*ast.SelectorExpr)
+			if !ok {
+				return true
+			}
+			obj := pass.TypesInfo.Uses[sel.Sel]
+			if obj == nil || obj.Pkg() == nil || obj.Pkg().Path() != "io/ioutil" {
+				return true
+			}
+			if replacement, ok := replacements[obj.Name()]; ok {
+				pass.Reportf(sel.Pos(), "ioutil.%s is deprecated: use %s", obj.Name(), replacement)
+			} else {
+				pass.Reportf(sel.Pos(), "io/ioutil is deprecated: use the io and os packages")
+			}
+			return true
+		})
+	}
+	return nil, nil
+}
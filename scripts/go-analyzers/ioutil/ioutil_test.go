@@ -0,0 +1,13 @@
+package ioutil_test
+
+import (
+	"testing"
+
+	"golang.org/x/tools/go/analysis/analysistest"
+
+	"github.com/terraform-modules/scripts/go-analyzers/ioutil"
+)
+
+func TestAnalyzer(t *testing.T) {
+	analysistest.Run(t, analysistest.TestData(), ioutil.Analyzer, "./...")
+}
@@ -0,0 +1,3 @@
+module example.com/ioutil
+
+go 1.23
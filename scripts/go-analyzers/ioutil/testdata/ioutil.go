@@ -0,0 +1,24 @@
+package ioutil
+
+import (
+	"io"
+	"io/ioutil"
+	legacy "io/ioutil"
+	"os"
+)
+
+func read(path string) ([]byte, error) {
+	return ioutil.ReadFile(path) // want `ioutil.ReadFile is deprecated: use os.ReadFile`
+}
+
+func write(path string, data []byte) error {
+	return legacy.WriteFile(path, data, 0644) // want `ioutil.WriteFile is deprecated: use os.WriteFile`
+}
+
+func discard() io.Writer {
+	return ioutil.Discard // want `ioutil.Discard is deprecated: use io.Discard`
+}
+
+func current(path string) ([]byte, error) {
+	return os.ReadFile(path)
+}
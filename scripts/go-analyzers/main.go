@@ -0,0 +1,26 @@
+// go-analyzers checks Go packages for the conventions of this repository.
+// go-lint runs it on each package after go vet, and it can be run directly
+// on package patterns:
+//
+//	go-analyzers ./...
+//
+// It is a standard multichecker: each analyzer can be turned off with
+// -<name>=false, and the exit status is 3 when an analyzer reports a
+// diagnostic.
+package main
+
+import (
+	"golang.org/x/tools/go/analysis/multichecker"
+
+	"github.com/terraform-modules/scripts/go-analyzers/ioutil"
+	"github.com/terraform-modules/scripts/go-analyzers/osexit"
+	"github.com/terraform-modules/scripts/go-analyzers/shellcmd"
+)
+
+func main() {
+	multichecker.Main(
+		osexit.Analyzer,
+		ioutil.Analyzer,
+		shellcmd.Analyzer,
+	)
+}
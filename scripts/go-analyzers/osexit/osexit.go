@@ -0,0 +1,51 @@
+// Package osexit defines an Analyzer that reports os.Exit calls outside
+// the main function.
+package osexit
+
+import (
+	"go/ast"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/types/typeutil"
+
+	"github.com/terraform-modules/scripts/go-analyzers/internal/analysisutil"
+)
+
+// Analyzer reports os.Exit calls outside the main function
+var Analyzer = &analysis.Analyzer{
+	Name: "osexit",
+	Doc: `report os.Exit calls outside the main function
+
+A function that exits cannot be tested and skips the deferred calls of its
+callers. Return an error to main and exit there. TestMain may exit with the
+result of m.Run.`,
+	Run: run,
+}
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	for _, file := range pass.Files {
+		testFile := strings.HasSuffix(pass.Fset.File(file.Pos()).Name(), "_test.go")
+		for _, decl := range file.Decls {
+			if fn, ok := decl.(*ast.FuncDecl); ok && fn.Recv == nil {
+				if fn.Name.Name == "main" && pass.Pkg.Name() == "main" {
+					continue
+				}
+				if fn.Name.Name == "TestMain" && testFile {
+					continue
+				}
+			}
+			ast.Inspect(decl, func(n ast.Node) bool {
+				call, ok := n.(*ast.CallExpr)
+				if !ok {
+					return true
+				}
+				if analysisutil.IsFunc(typeutil.Callee(pass.TypesInfo, call), "os", "Exit") {
+					pass.Reportf(call.Pos(), "os.Exit outside of main: return an error and exit in main")
+				}
+				return true
+			})
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,13 @@
+package osexit_test
+
+import (
+	"testing"
+
+	"golang.org/x/tools/go/analysis/analysistest"
+
+	"github.com/terraform-modules/scripts/go-analyzers/osexit"
+)
+
+func TestAnalyzer(t *testing.T) {
+	analysistest.Run(t, analysistest.TestData(), osexit.Analyzer, "./...")
+}
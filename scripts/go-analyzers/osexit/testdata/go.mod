@@ -0,0 +1,3 @@
+module example.com/osexit
+
+go 1.23
@@ -0,0 +1,7 @@
+package lib
+
+import "os"
+
+func main() {
+	os.Exit(1) // want "os.Exit outside of main"
+}
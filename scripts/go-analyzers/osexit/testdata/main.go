@@ -0,0 +1,32 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// exit can be replaced in tests
+var exit = os.Exit
+
+func main() {
+	if err := run(); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+}
+
+func run() error {
+	if len(os.Args) > 2 {
+		os.Exit(2) // want "os.Exit outside of main: return an error and exit in main"
+	}
+	defer func() {
+		os.Exit(0) // want "os.Exit outside of main"
+	}()
+	return nil
+}
+
+type command struct{}
+
+func (command) main() {
+	os.Exit(1) // want "os.Exit outside of main"
+}
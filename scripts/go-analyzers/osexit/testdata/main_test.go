@@ -0,0 +1,16 @@
+package main
+
+import (
+	"os"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	os.Exit(m.Run())
+}
+
+func TestRun(t *testing.T) {
+	if run() != nil {
+		os.Exit(1) // want "os.Exit outside of main"
+	}
+}
@@ -0,0 +1,81 @@
+// Package shellcmd defines an Analyzer that reports commands run through
+// a shell with -c.
+package shellcmd
+
+import (
+	"go/ast"
+	"go/constant"
+	"path"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/types/typeutil"
+
+	"github.com/terraform-modules/scripts/go-analyzers/internal/analysisutil"
+)
+
+// Analyzer reports commands run through a shell with -c
+var Analyzer = &analysis.Analyzer{
+	Name: "shellcmd",
+	Doc: `report commands run through a shell with -c
+
+exec.Command("bash", "-c", line) leaves quoting, word splitting and
+injection to the shell, and hides the failure of all but the last command
+of a pipeline. Run the program with its arguments, and pipe or redirect
+in Go.`,
+	Run: run,
+}
+
+// shells are the programs whose -c option runs a command line
+var shells = map[string]bool{"bash": true, "sh": true, "zsh": true, "dash": true, "ksh": true}
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	for _, file := range pass.Files {
+		ast.Inspect(file, func(n ast.Node) bool {
+			call, ok := n.(*ast.CallExpr)
+			if !ok {
+				return true
+			}
+			callee := typeutil.Callee(pass.TypesInfo, call)
+			args := call.Args
+			switch {
+			case analysisutil.IsFunc(callee, "os/exec", "Command"):
+			case analysisutil.IsFunc(callee, "os/exec", "CommandContext") && len(args) > 0:
+				args = args[1:]
+			default:
+				return true
+			}
+			if len(args) < 2 {
+				return true
+			}
+
+			shell, ok := stringConstant(pass, args[0])
+			if !ok || !shells[path.Base(shell)] {
+				return true
+			}
+			for _, arg := range args[1:] {
+				if option, ok := stringConstant(pass, arg); ok && isCommandOption(option) {
+					pass.Reportf(call.Pos(), "command run through %s %s: run the program with its arguments instead", path.Base(shell), option)
+					break
+				}
+			}
+			return true
+		})
+	}
+	return nil, nil
+}
+
+// isCommandOption reports whether a shell option includes -c, as in -c,
+// -ec or -lc
+func isCommandOption(option string) bool {
+	return strings.HasPrefix(option, "-") && !strings.HasPrefix(option, "--") && strings.Contains(option, "c")
+}
+
+// stringConstant returns the value of a constant string expression
+func stringConstant(pass *analysis.Pass, expr ast.Expr) (string, bool) {
+	tv, ok := pass.TypesInfo.Types[expr]
+	if !ok || tv.Value == nil || tv.Value.Kind() != constant.String {
+		return "", false
+	}
+	return constant.StringVal(tv.Value), true
+}
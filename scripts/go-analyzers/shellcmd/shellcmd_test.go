@@ -0,0 +1,20 @@
+package shellcmd
+
+import (
+	"testing"
+
+	"golang.org/x/tools/go/analysis/analysistest"
+)
+
+func TestAnalyzer(t *testing.T) {
+	analysistest.Run(t, analysistest.TestData(), Analyzer, "./...")
+}
+
+func TestIsCommandOption(t *testing.T) {
+	tests := map[string]bool{"-c": true, "-ec": true, "-lc": true, "-e": false, "--command": false, "c": false}
+	for option, expected := range tests {
+		if got := isCommandOption(option); got != expected {
+			t.Errorf("isCommandOption(%q) = %v, want %v", option, got, expected)
+		}
+	}
+}
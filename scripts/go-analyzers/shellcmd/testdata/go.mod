@@ -0,0 +1,3 @@
+module example.com/shellcmd
+
+go 1.23
@@ -0,0 +1,20 @@
+package shellcmd
+
+import (
+	"context"
+	"os/exec"
+)
+
+const shell = "zsh"
+
+func commands(ctx context.Context, line, script string) []*exec.Cmd {
+	return []*exec.Cmd{
+		exec.Command("bash", "-c", line),                 // want "command run through bash -c: run the program with its arguments instead"
+		exec.CommandContext(ctx, "/bin/sh", "-ec", line), // want "command run through sh -ec"
+		exec.Command(shell, "-l", "-c", line),            // want "command run through zsh -c"
+		exec.Command("bash", script),
+		exec.Command("bash", "--norc", script),
+		exec.Command("grep", "-c", line),
+		exec.Command(line, "-c"),
+	}
+}
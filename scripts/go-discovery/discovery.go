@@ -0,0 +1,73 @@
+// Package discovery finds the Go modules of the repository for the
+// --discover modes of go-unit-test, go-lint and coverage-export, so the
+// tools agree on which modules exist and how go_discovery is applied.
+package discovery
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Config is the go_discovery section of monorepo-config.json
+type Config struct {
+	Exclude []string `json:"exclude"` // Paths not to search, relative to the repository root
+}
+
+// Discover returns the Go modules below root outside the Terraform modules:
+// the config exclude paths and the moduleRoots are skipped, along with what
+// Modules always skips
+func Discover(root string, config Config, moduleRoots, excludedNames []string) ([]string, error) {
+	exclude := append([]string{}, config.Exclude...)
+	exclude = append(exclude, moduleRoots...)
+	return Modules(root, exclude, excludedNames)
+}
+
+// Modules returns the directories below root holding a go.mod, relative to
+// root in slash form and sorted. Hidden directories, testdata, vendor,
+// excludedNames at any depth and the exclude paths are skipped.
+func Modules(root string, exclude, excludedNames []string) ([]string, error) {
+	skipNames := map[string]bool{"testdata": true, "vendor": true}
+	for _, name := range excludedNames {
+		skipNames[name] = true
+	}
+	skipPaths := make(map[string]bool, len(exclude))
+	for _, path := range exclude {
+		skipPaths[CleanPath(path)] = true
+	}
+
+	var dirs []string
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if rel != "." && (strings.HasPrefix(d.Name(), ".") || skipNames[d.Name()] || skipPaths[rel]) {
+			return filepath.SkipDir
+		}
+		if _, err := os.Stat(filepath.Join(path, "go.mod")); rel != "." && err == nil {
+			dirs = append(dirs, rel)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(dirs)
+	return dirs, nil
+}
+
+// CleanPath normalizes a config path such as ./scripts/go-lint/ to
+// scripts/go-lint
+func CleanPath(path string) string {
+	return filepath.ToSlash(filepath.Clean(strings.TrimSuffix(path, "/")))
+}
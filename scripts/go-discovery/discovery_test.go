@@ -0,0 +1,72 @@
+package discovery
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeModules creates a go.mod in each directory below root
+func writeModules(t *testing.T, root string, dirs ...string) {
+	t.Helper()
+	for _, dir := range dirs {
+		path := filepath.Join(root, filepath.FromSlash(dir))
+		if err := os.MkdirAll(path, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(path, "go.mod"), []byte("module example.com/"+dir+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestModules(t *testing.T) {
+	root := t.TempDir()
+	writeModules(t, root,
+		"scripts/b",
+		"scripts/a",
+		"scripts/a/nested",
+		"scripts/a/testdata/fixture",
+		"scripts/vendor/dep",
+		"scripts/.cache/x",
+		"scripts/node_modules/x",
+		"tests/opa/test-fixture/module",
+		"providers/aws/primitives/s3/tests",
+	)
+
+	dirs, err := Modules(root, []string{"tests/opa/test-fixture", "providers/aws/primitives/"}, []string{"node_modules"})
+	if err != nil {
+		t.Fatalf("Modules() error = %v", err)
+	}
+	if got := strings.Join(dirs, ","); got != "scripts/a,scripts/a/nested,scripts/b" {
+		t.Errorf("Modules() = %s", got)
+	}
+}
+
+func TestDiscover(t *testing.T) {
+	root := t.TempDir()
+	writeModules(t, root, "scripts/go-lint", "skeletons/generic/tests", "tests/opa/fixture")
+
+	dirs, err := Discover(root, Config{Exclude: []string{"./tests/opa/"}}, []string{"skeletons/"}, nil)
+	if err != nil {
+		t.Fatalf("Discover() error = %v", err)
+	}
+	if got := strings.Join(dirs, ","); got != "scripts/go-lint" {
+		t.Errorf("Discover() = %s", got)
+	}
+}
+
+func TestCleanPath(t *testing.T) {
+	tests := map[string]string{
+		"./scripts/go-lint/": "scripts/go-lint",
+		"scripts/go-lint":    "scripts/go-lint",
+		"./":                 ".",
+		"a//b/../c":          "a/c",
+	}
+	for path, expected := range tests {
+		if got := CleanPath(path); got != expected {
+			t.Errorf("CleanPath(%q) = %q, expected %q", path, got, expected)
+		}
+	}
+}
@@ -0,0 +1,3 @@
+module github.com/terraform-modules/scripts/go-discovery
+
+go 1.23.9
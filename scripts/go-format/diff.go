@@ -0,0 +1,210 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// diffContext is the number of unchanged lines shown around each change
+const diffContext = 3
+
+// diffOp is one line of an edit script: ' ' kept, '-' removed or '+' added
+type diffOp struct {
+	kind byte
+	line string
+}
+
+// unifiedDiff returns the changes from a to b in the unified format of
+// `diff -u`, with name as the file name, or "" when they are equal
+func unifiedDiff(name string, a, b []byte) string {
+	ops := diffLines(splitLines(string(a)), splitLines(string(b)))
+
+	// Line numbers in a and b before each op
+	aLine := make([]int, len(ops)+1)
+	bLine := make([]int, len(ops)+1)
+	for i, op := range ops {
+		aLine[i+1], bLine[i+1] = aLine[i], bLine[i]
+		if op.kind != '+' {
+			aLine[i+1]++
+		}
+		if op.kind != '-' {
+			bLine[i+1]++
+		}
+	}
+
+	var sb strings.Builder
+	for start := 0; start < len(ops); {
+		for start < len(ops) && ops[start].kind == ' ' {
+			start++
+		}
+		if start == len(ops) {
+			break
+		}
+
+		// A hunk ends once more unchanged lines follow its last change
+		// than the context of two hunks would show
+		end := start + 1
+		for i := start; i < len(ops) && i-end <= 2*diffContext; i++ {
+			if ops[i].kind != ' ' {
+				end = i + 1
+			}
+		}
+		first := max(start-diffContext, 0)
+		last := min(end+diffContext, len(ops))
+
+		if sb.Len() == 0 {
+			fmt.Fprintf(&sb, "--- %s.orig\n+++ %s\n", name, name)
+		}
+		fmt.Fprintf(&sb, "@@ -%s +%s @@\n", hunkRange(aLine[first], aLine[last]), hunkRange(bLine[first], bLine[last]))
+		for _, op := range ops[first:last] {
+			sb.WriteByte(op.kind)
+			sb.WriteString(op.line)
+			if !strings.HasSuffix(op.line, "\n") {
+				sb.WriteString("\n\\ No newline at end of file\n")
+			}
+		}
+		start = last
+	}
+	return sb.String()
+}
+
+// hunkRange formats the lines from, exclusive, to to, inclusive, as the
+// start,length of a hunk header
+func hunkRange(from, to int) string {
+	if from == to {
+		return fmt.Sprintf("%d,0", from)
+	}
+	if to-from == 1 {
+		return fmt.Sprintf("%d", from+1)
+	}
+	return fmt.Sprintf("%d,%d", from+1, to-from)
+}
+
+// diffLines returns the shortest edit script from a to b, with removals
+// before additions. It uses the linear-space variant of the Myers diff, so
+// that a large file with a few changes diffs in memory proportional to its
+// length.
+func diffLines(a, b []string) []diffOp {
+	ops := appendEdits(nil, a, b)
+
+	// Order each run of changes as removals then additions
+	for start := 0; start < len(ops); {
+		if ops[start].kind == ' ' {
+			start++
+			continue
+		}
+		end := start
+		for end < len(ops) && ops[end].kind != ' ' {
+			end++
+		}
+		changes := ops[start:end]
+		sort.SliceStable(changes, func(i, j int) bool {
+			return changes[i].kind == '-' && changes[j].kind == '+'
+		})
+		start = end
+	}
+	return ops
+}
+
+// appendEdits appends the edit script from a to b, splitting the problem
+// at the middle snake of an optimal path
+func appendEdits(ops []diffOp, a, b []string) []diffOp {
+	// Common prefix and suffix, where gofmt changes usually leave most lines
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+	for _, line := range a[:prefix] {
+		ops = append(ops, diffOp{' ', line})
+	}
+	middleA, middleB := a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]
+
+	switch {
+	case len(middleA) == 0:
+		for _, line := range middleB {
+			ops = append(ops, diffOp{'+', line})
+		}
+	case len(middleB) == 0:
+		for _, line := range middleA {
+			ops = append(ops, diffOp{'-', line})
+		}
+	default:
+		// Both halves have fewer edits than the whole, as the trimmed
+		// problem needs at least two
+		x, y, u, v := middleSnake(middleA, middleB)
+		ops = appendEdits(ops, middleA[:x], middleB[:y])
+		for _, line := range middleA[x:u] {
+			ops = append(ops, diffOp{' ', line})
+		}
+		ops = appendEdits(ops, middleA[u:], middleB[v:])
+	}
+
+	for _, line := range a[len(a)-suffix:] {
+		ops = append(ops, diffOp{' ', line})
+	}
+	return ops
+}
+
+// middleSnake returns the start (x, y) and end (u, v) of the snake in the
+// middle of a shortest edit path from a to b, found by searching forward
+// from the start and backward from the end at once
+func middleSnake(a, b []string) (x, y, u, v int) {
+	n, m := len(a), len(b)
+	delta := n - m
+	odd := delta%2 != 0
+	offset := n + m + 1
+	// Furthest x reached on each diagonal k = x - y, forward, and backward
+	// on the reversed sequences
+	forward := make([]int, 2*offset+1)
+	backward := make([]int, 2*offset+1)
+
+	for d := 0; d <= (n+m+1)/2; d++ {
+		for k := -d; k <= d; k += 2 {
+			x := forward[offset+k-1] + 1
+			if k == -d || (k != d && forward[offset+k-1] < forward[offset+k+1]) {
+				x = forward[offset+k+1]
+			}
+			y := x - k
+			startX, startY := x, y
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			forward[offset+k] = x
+			if reverse := delta - k; odd && reverse >= -(d-1) && reverse <= d-1 && x+backward[offset+reverse] >= n {
+				return startX, startY, x, y
+			}
+		}
+		for k := -d; k <= d; k += 2 {
+			x := backward[offset+k-1] + 1
+			if k == -d || (k != d && backward[offset+k-1] < backward[offset+k+1]) {
+				x = backward[offset+k+1]
+			}
+			y := x - k
+			startX, startY := x, y
+			for x < n && y < m && a[n-1-x] == b[m-1-y] {
+				x++
+				y++
+			}
+			backward[offset+k] = x
+			if reverse := delta - k; !odd && reverse >= -d && reverse <= d && forward[offset+reverse]+x >= n {
+				return n - x, m - y, n - startX, m - startY
+			}
+		}
+	}
+	panic("no middle snake between two sequences")
+}
+
+// splitLines splits s after each newline
+func splitLines(s string) []string {
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
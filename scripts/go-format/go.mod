@@ -1,3 +1,13 @@
 module github.com/terraform-modules/scripts/go-format
 
-go 1.21
\ No newline at end of file
+go 1.23.9
+
+require golang.org/x/tools v0.33.0
+
+require (
+	github.com/terraform-modules/scripts/go-discovery v0.0.0-00010101000000-000000000000
+	golang.org/x/mod v0.24.0 // indirect
+	golang.org/x/sync v0.14.0 // indirect
+)
+
+replace github.com/terraform-modules/scripts/go-discovery => ../go-discovery
@@ -1,12 +1,19 @@
 package main
 
 import (
+	"bytes"
 	"encoding/json"
 	"flag"
 	"fmt"
+	"go/format"
+	"io/fs"
 	"os"
-	"os/exec"
+	"path/filepath"
 	"strings"
+
+	"golang.org/x/tools/imports"
+
+	"github.com/terraform-modules/scripts/go-discovery"
 )
 
 var (
@@ -18,30 +25,49 @@ type Config struct {
 	Scripts struct {
 		LintDirectories []string `json:"lint_directories"`
 	} `json:"scripts"`
+	GoFormat GoFormat `json:"go_format"`
+}
+
+// GoFormat configures the formatting of Go code
+type GoFormat struct {
+	LocalPrefix string   `json:"local_prefix"` // Comma-separated import path prefixes grouped after the third-party imports
+	IgnoredDirs []string `json:"ignored_dirs"` // Paths not to format, relative to the repository root
 }
 
 func main() {
 	configPath := flag.String("config", "", "Path to config JSON file")
 	pathFlag := flag.String("path", "", "Direct path to format (bypasses config)")
+	checkFlag := flag.Bool("check", false, "Print the diff of each file needing formatting and fail instead of rewriting it")
+	localPrefixFlag := flag.String("local-prefix", "", "Comma-separated import path prefixes to group after the third-party imports (overrides go_format.local_prefix)")
+	ignoreFlag := flag.String("ignore", "", "Comma-separated paths not to format, added to go_format.ignored_dirs")
 	flag.Parse()
 
+	// The config is optional with --path, and then only provides the
+	// go_format settings
+	var config *Config
+	if *configPath != "" {
+		var err error
+		config, err = loadConfig(*configPath)
+		if err != nil {
+			fmt.Printf("Error loading config file: %v\n", err)
+			os.Exit(1)
+		}
+		for _, dir := range config.GoFormat.IgnoredDirs {
+			ignoredDirs = append(ignoredDirs, discovery.CleanPath(dir))
+		}
+		imports.LocalPrefix = config.GoFormat.LocalPrefix
+	}
+
 	if *pathFlag != "" {
 		// Direct mode - use specified path
 		dirsToFormat = []string{*pathFlag}
 	} else {
 		// Config mode - require config file
-		if *configPath == "" {
+		if config == nil {
 			fmt.Println("Error: --config flag is required when --path is not specified")
 			os.Exit(1)
 		}
 
-		// Load config file
-		config, err := loadConfig(*configPath)
-		if err != nil {
-			fmt.Printf("Error loading config file: %v\n", err)
-			os.Exit(1)
-		}
-
 		// Set directories to format
 		dirsToFormat = config.Scripts.LintDirectories
 		if len(dirsToFormat) == 0 {
@@ -50,63 +76,136 @@ func main() {
 		}
 	}
 
-	os.Setenv("GOGC", "off")
+	for _, dir := range strings.Split(*ignoreFlag, ",") {
+		if dir = strings.TrimSpace(dir); dir != "" {
+			ignoredDirs = append(ignoredDirs, discovery.CleanPath(dir))
+		}
+	}
+	if *localPrefixFlag != "" {
+		imports.LocalPrefix = *localPrefixFlag
+	}
 
-	fmt.Println("Formatting Go code...")
-	fixGoFormatting()
+	if *checkFlag {
+		fmt.Println("Checking Go code formatting...")
+	} else {
+		fmt.Println("Formatting Go code...")
+	}
+	os.Exit(formatGoFiles(*checkFlag))
 }
 
-func fixGoFormatting() {
-	filesFixed := 0
+// formatGoFiles formats the Go files of the directories to format, or with
+// check prints the diff of those needing it, and returns the exit code
+func formatGoFiles(check bool) int {
+	filesChanged := 0
 	exitCode := 0
 
 	for _, dir := range dirsToFormat {
-		// Find files that need formatting in this directory
-		cmd := exec.Command("gofmt", "-l", dir)
-		output, err := cmd.Output()
+		files, err := findGoFiles(dir)
 		if err != nil {
-			fmt.Printf("Error running gofmt on %s: %v\n", dir, err)
-			os.Exit(1)
-		}
-
-		files := strings.TrimSpace(string(output))
-		if files == "" {
-			fmt.Printf("✅ All files in %s already properly formatted\n", dir)
-			continue
+			fmt.Printf("Error walking %s directory: %v\n", dir, err)
+			return 1
 		}
 
-		// Format each file that needs it
-		for _, file := range strings.Split(files, "\n") {
-			if file == "" {
+		dirChanged := 0
+		for _, file := range files {
+			src, err := os.ReadFile(file)
+			if err != nil {
+				fmt.Printf("❌ Error reading %s: %v\n", file, err)
+				exitCode = 1
+				continue
+			}
+			formatted, err := formatSource(file, src)
+			if err != nil {
+				fmt.Printf("❌ Error formatting %s: %v\n", file, err)
+				exitCode = 1
+				continue
+			}
+			if bytes.Equal(src, formatted) {
 				continue
 			}
-			if shouldIgnoreFile(file) {
+			dirChanged++
+			filesChanged++
+
+			if check {
+				fmt.Printf("❌ %s needs formatting\n", file)
+				fmt.Print(unifiedDiff(filepath.ToSlash(file), src, formatted))
 				continue
 			}
 
-			// Format the file
-			cmd := exec.Command("gofmt", "-w", file)
-			_, err := cmd.CombinedOutput()
+			info, err := os.Stat(file)
+			if err == nil {
+				err = os.WriteFile(file, formatted, info.Mode().Perm())
+			}
 			if err != nil {
 				fmt.Printf("❌ Error formatting %s: %v\n", file, err)
 				exitCode = 1
 				continue
 			}
-
 			fmt.Printf("Fixed: %s\n", file)
-			filesFixed++
+		}
+
+		if dirChanged == 0 {
+			fmt.Printf("✅ All files in %s already properly formatted\n", dir)
 		}
 	}
 
-	if filesFixed > 0 {
-		fmt.Printf("\n✅ Formatting complete: fixed %d file(s)\n", filesFixed)
-	} else {
+	switch {
+	case check && filesChanged > 0:
+		fmt.Printf("\n❌ Formatting check failed: %d file(s) need formatting, run make go-format\n", filesChanged)
+		exitCode = 1
+	case check:
+		fmt.Println("\n✅ All files properly formatted")
+	case filesChanged > 0:
+		fmt.Printf("\n✅ Formatting complete: fixed %d file(s)\n", filesChanged)
+	default:
 		fmt.Println("\n✅ No files needed formatting")
 	}
 
-	if exitCode != 0 {
-		os.Exit(exitCode)
+	return exitCode
+}
+
+// formatSource formats src like gofmt, then sorts and groups its imports
+// like goimports: standard library, third-party, then the local prefix.
+// Imports are not added or removed.
+func formatSource(filename string, src []byte) ([]byte, error) {
+	formatted, err := format.Source(src)
+	if err != nil {
+		return nil, err
 	}
+	return imports.Process(filename, formatted, &imports.Options{
+		Comments:   true,
+		TabIndent:  true,
+		TabWidth:   8,
+		FormatOnly: true,
+	})
+}
+
+// findGoFiles returns the Go files below dir, skipping hidden directories
+// and the ignored directories
+func findGoFiles(dir string) ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if shouldIgnoreFile(filepath.ToSlash(path)) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			if path != dir && strings.HasPrefix(d.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if strings.HasSuffix(path, ".go") && !strings.HasPrefix(d.Name(), ".") {
+			files = append(files, path)
+		}
+		return nil
+	})
+	return files, err
 }
 
 func shouldIgnoreFile(filePath string) bool {
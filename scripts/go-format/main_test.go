@@ -0,0 +1,220 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/tools/imports"
+)
+
+func TestFormatSource(t *testing.T) {
+	imports.LocalPrefix = "github.com/terraform-modules"
+	defer func() { imports.LocalPrefix = "" }()
+
+	src := "package main\n\nimport (\n\t\"github.com/terraform-modules/scripts/x\"\n\t\"os\"\n\t\"golang.org/x/tools/imports\"\n\t\"fmt\"\n)\n\nfunc main()  {\nfmt.Println(os.Args, x.Y, imports.LocalPrefix)\n}\n"
+	expected := "package main\n\nimport (\n\t\"fmt\"\n\t\"os\"\n\n\t\"golang.org/x/tools/imports\"\n\n\t\"github.com/terraform-modules/scripts/x\"\n)\n\nfunc main() {\n\tfmt.Println(os.Args, x.Y, imports.LocalPrefix)\n}\n"
+
+	got, err := formatSource("main.go", []byte(src))
+	if err != nil {
+		t.Fatalf("formatSource() error = %v", err)
+	}
+	if string(got) != expected {
+		t.Errorf("formatSource() =\n%s\nwant\n%s", got, expected)
+	}
+
+	if _, err := formatSource("main.go", []byte("package main\n\nfunc {")); err == nil {
+		t.Errorf("Expected an error for invalid Go code")
+	}
+}
+
+func TestFormatGoFiles(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		"pkg/ok.go":             "package pkg\n",
+		"pkg/bad.go":            "package pkg\nvar  x = 1\n",
+		"pkg/generated/gen.go":  "package generated\nvar  y = 2\n",
+		"pkg/.hidden/hidden.go": "package hidden\nvar  z = 3\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	read := func(name string) string {
+		data, err := os.ReadFile(filepath.Join(root, filepath.FromSlash(name)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(data)
+	}
+
+	dirsToFormat = []string{filepath.Join(root, "pkg")}
+	ignoredDirs = []string{filepath.ToSlash(filepath.Join(root, "pkg/generated"))}
+	defer func() { dirsToFormat, ignoredDirs = nil, nil }()
+
+	// The check leaves the files alone and fails
+	if code := formatGoFiles(true); code != 1 {
+		t.Errorf("Expected the check to fail, got %d", code)
+	}
+	if read("pkg/bad.go") != files["pkg/bad.go"] {
+		t.Errorf("Expected the check not to rewrite files")
+	}
+
+	if code := formatGoFiles(false); code != 0 {
+		t.Errorf("Expected formatting to succeed, got %d", code)
+	}
+	if got := read("pkg/bad.go"); got != "package pkg\n\nvar x = 1\n" {
+		t.Errorf("Unexpected formatted file %q", got)
+	}
+	for _, name := range []string{"pkg/generated/gen.go", "pkg/.hidden/hidden.go"} {
+		if read(name) != files[name] {
+			t.Errorf("Expected %s to be skipped", name)
+		}
+	}
+
+	if code := formatGoFiles(true); code != 0 {
+		t.Errorf("Expected the check to pass after formatting, got %d", code)
+	}
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	a := "a\nb\nc\nd\ne\nf\ng\nh\ni\nj\nk\nl\nm\nn\no\n"
+	b := "a\nB\nc\nd\ne\nf\ng\nh\ni\nj\nk\nl\nm\nn\no\np\n"
+	expected := strings.Join([]string{
+		"--- x.go.orig",
+		"+++ x.go",
+		"@@ -1,5 +1,5 @@",
+		" a",
+		"-b",
+		"+B",
+		" c",
+		" d",
+		" e",
+		"@@ -13,3 +13,4 @@",
+		" m",
+		" n",
+		" o",
+		"+p",
+		"",
+	}, "\n")
+	if got := unifiedDiff("x.go", []byte(a), []byte(b)); got != expected {
+		t.Errorf("unifiedDiff() =\n%s\nwant\n%s", got, expected)
+	}
+
+	// Changes with few lines between them share a hunk
+	got := unifiedDiff("x.go", []byte("a\nb\nc\nd\ne\nf\ng\nh\n"), []byte("A\nb\nc\nd\ne\nf\ng\nH\n"))
+	if strings.Count(got, "@@ -") != 1 || !strings.Contains(got, "@@ -1,8 +1,8 @@") {
+		t.Errorf("Expected a single hunk, got:\n%s", got)
+	}
+
+	if got := unifiedDiff("x.go", []byte("same\n"), []byte("same\n")); got != "" {
+		t.Errorf("Expected no diff for equal files, got %q", got)
+	}
+	if got := unifiedDiff("x.go", []byte("a"), []byte("a\n")); !strings.Contains(got, "-a\n\\ No newline at end of file\n+a\n") {
+		t.Errorf("Expected the missing newline to be marked, got:\n%s", got)
+	}
+}
+
+func TestDiffLines(t *testing.T) {
+	// lcsLength is the length of the longest common subsequence, which
+	// a shortest edit script keeps
+	lcsLength := func(a, b []string) int {
+		lcs := make([][]int, len(a)+1)
+		for i := range lcs {
+			lcs[i] = make([]int, len(b)+1)
+		}
+		for i := len(a) - 1; i >= 0; i-- {
+			for j := len(b) - 1; j >= 0; j-- {
+				if a[i] == b[j] {
+					lcs[i][j] = lcs[i+1][j+1] + 1
+				} else {
+					lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+				}
+			}
+		}
+		return lcs[0][0]
+	}
+
+	rng := rand.New(rand.NewSource(1))
+	randomLines := func() []string {
+		lines := make([]string, rng.Intn(12))
+		for i := range lines {
+			lines[i] = string(rune('a' + rng.Intn(4)))
+		}
+		return lines
+	}
+
+	for i := 0; i < 500; i++ {
+		a, b := randomLines(), randomLines()
+		ops := diffLines(a, b)
+
+		var gotA, gotB []string
+		kept := 0
+		for j, op := range ops {
+			if op.kind != '+' {
+				gotA = append(gotA, op.line)
+			}
+			if op.kind != '-' {
+				gotB = append(gotB, op.line)
+			}
+			if op.kind == ' ' {
+				kept++
+			}
+			if op.kind == '-' && j > 0 && ops[j-1].kind == '+' {
+				t.Errorf("diffLines(%q, %q) adds before removing: %v", a, b, ops)
+			}
+		}
+		if strings.Join(gotA, "") != strings.Join(a, "") || strings.Join(gotB, "") != strings.Join(b, "") {
+			t.Fatalf("diffLines(%q, %q) = %v, does not turn a into b", a, b, ops)
+		}
+		if expected := lcsLength(a, b); kept != expected {
+			t.Fatalf("diffLines(%q, %q) keeps %d lines, want %d", a, b, kept, expected)
+		}
+	}
+}
+
+func TestDiffLinesLargeFile(t *testing.T) {
+	// A large file changed at both ends diffs without a table of its size
+	a := make([]string, 50000)
+	for i := range a {
+		a[i] = fmt.Sprintf("line %d\n", i)
+	}
+	b := append([]string{"first\n"}, a...)
+	b[len(b)-1] = "last\n"
+
+	ops := diffLines(a, b)
+	changes := 0
+	for _, op := range ops {
+		if op.kind != ' ' {
+			changes++
+		}
+	}
+	if changes != 3 {
+		t.Errorf("diffLines() = %d changes, want 3", changes)
+	}
+}
+
+func TestShouldIgnoreFile(t *testing.T) {
+	ignoredDirs = []string{"scripts/generated", ""}
+	defer func() { ignoredDirs = nil }()
+
+	tests := map[string]bool{
+		"scripts/generated":         true,
+		"scripts/generated/a.go":    true,
+		"scripts/generated-other/a": false,
+		"scripts/go-format/main.go": false,
+	}
+	for path, expected := range tests {
+		if got := shouldIgnoreFile(path); got != expected {
+			t.Errorf("shouldIgnoreFile(%q) = %v, want %v", path, got, expected)
+		}
+	}
+}
@@ -1,3 +1,7 @@
 module github.com/terraform-modules/scripts/lint
 
 go 1.23.9
+
+require github.com/terraform-modules/scripts/go-discovery v0.0.0-00010101000000-000000000000
+
+replace github.com/terraform-modules/scripts/go-discovery => ../go-discovery
@@ -1,47 +1,111 @@
 package main
 
 import (
+	"bytes"
 	"encoding/json"
 	"flag"
 	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"sort"
 	"strings"
+
+	"github.com/terraform-modules/scripts/go-discovery"
 )
 
 var (
-	ignoredDirs []string
-	skipPrefix  string
-	dirsToLint  []string
+	ignoredDirs       []string
+	skipPrefix        string
+	dirsToLint        []string
+	disabledAnalyzers []string
+
+	// moduleFailures lists the failed checks of each directory to lint
+	moduleFailures = map[string][]string{}
 )
 
 type Config struct {
 	Scripts struct {
 		LintDirectories []string `json:"lint_directories"`
+		ExcludedDirs    []string `json:"excluded_dirs"`
 	} `json:"scripts"`
+	GoDiscovery discovery.Config `json:"go_discovery"`
+	GoLint      GoLint           `json:"go_lint"`
+	ModuleRoots []string         `json:"module_roots"`
+}
+
+// GoLint configures the checks of go-lint
+type GoLint struct {
+	IgnoredDirs       []string `json:"ignored_dirs"`       // Paths not to check, relative to the repository root
+	DisabledAnalyzers []string `json:"disabled_analyzers"` // go-analyzers checks not to run
+}
+
+// goPackage is a package found in one of the directories to lint
+type goPackage struct {
+	ImportPath string
+	Dir        string // Directory the package was listed from, where go commands run
 }
 
 func main() {
 	configPath := flag.String("config", "", "Path to config JSON file")
 	skipPrefixFlag := flag.String("skip-prefix", "", "Package prefix to skip during linting")
 	pathFlag := flag.String("path", "", "Direct path to lint (bypasses config)")
+	discoverFlag := flag.Bool("discover", false, "Lint every Go module found below the current directory")
+	analyzersFlag := flag.String("analyzers", "", "Path to the go-analyzers binary to run on each package")
+	terraformModulesFlag := flag.Bool("terraform-modules", false, "Lint the Go modules of the Terraform modules below module_roots")
+	moduleFlag := flag.String("module", "", "Lint only the Go modules of this Terraform module, such as the MODULE_PATH of the change detector")
 	flag.Parse()
 
+	// The config is optional with --path and --discover, and then only
+	// provides the go_lint settings and the discovery exclusions
+	var config *Config
+	if *configPath != "" {
+		var err error
+		config, err = loadConfig(*configPath)
+		if err != nil {
+			fmt.Printf("Error loading config file: %v\n", err)
+			os.Exit(1)
+		}
+		for _, dir := range config.GoLint.IgnoredDirs {
+			ignoredDirs = append(ignoredDirs, discovery.CleanPath(dir))
+		}
+		disabledAnalyzers = config.GoLint.DisabledAnalyzers
+	}
+
 	if *pathFlag != "" {
 		// Direct mode - use specified path
 		dirsToLint = []string{*pathFlag}
-	} else {
-		// Config mode - require config file
-		if *configPath == "" {
-			fmt.Println("Error: --config flag is required when --path is not specified")
+	} else if *terraformModulesFlag || *moduleFlag != "" {
+		// Terraform module mode - the module tests have their own go.mod
+		if config == nil && *moduleFlag == "" {
+			fmt.Println("Error: --config flag is required with --terraform-modules")
 			os.Exit(1)
 		}
-
-		// Load config file
-		config, err := loadConfig(*configPath)
+		dirs, err := discoverTerraformModules(".", config, *moduleFlag)
 		if err != nil {
-			fmt.Printf("Error loading config file: %v\n", err)
+			fmt.Printf("Error discovering Go modules: %v\n", err)
+			os.Exit(1)
+		}
+		if len(dirs) == 0 {
+			fmt.Println("Error: No Go modules found in the Terraform modules")
+			os.Exit(1)
+		}
+		dirsToLint = dirs
+	} else if *discoverFlag {
+		dirs, err := discoverDirs(".", config)
+		if err != nil {
+			fmt.Printf("Error discovering Go modules: %v\n", err)
+			os.Exit(1)
+		}
+		if len(dirs) == 0 {
+			fmt.Println("Error: No Go modules found")
+			os.Exit(1)
+		}
+		dirsToLint = dirs
+	} else {
+		// Config mode - require config file
+		if config == nil {
+			fmt.Println("Error: --config flag is required when --path is not specified")
 			os.Exit(1)
 		}
 
@@ -55,6 +119,16 @@ func main() {
 
 	skipPrefix = *skipPrefixFlag
 
+	analyzersPath := *analyzersFlag
+	if strings.ContainsRune(analyzersPath, filepath.Separator) {
+		// go commands run in each module directory
+		var err error
+		if analyzersPath, err = filepath.Abs(analyzersPath); err != nil {
+			fmt.Printf("Error resolving %s: %v\n", *analyzersFlag, err)
+			os.Exit(1)
+		}
+	}
+
 	os.Setenv("GOGC", "off")
 
 	exitCode := 0
@@ -66,14 +140,31 @@ func main() {
 	}
 
 	fmt.Println("Step 2: Running go vet checks...")
-	goVetResult := runGoVetChecks()
+	pkgs, listResult := listPackages()
+	goVetResult := runGoVetChecks(pkgs) | listResult
 	if goVetResult != 0 {
 		exitCode = 1
 	}
 
+	analyzersResult := 0
+	if analyzersPath != "" {
+		fmt.Println("Step 3: Running repository analyzers...")
+		analyzersResult = runAnalyzerChecks(pkgs, analyzersPath)
+		if analyzersResult != 0 {
+			exitCode = 1
+		}
+	}
+
+	printModuleSummary()
+
 	fmt.Println("\n=== Lint Summary ===")
 	fmt.Printf("gofmt checks: %s\n", formatStatus(gofmtResult == 0, "violates code formatting policy"))
 	fmt.Printf("go vet checks: %s\n", formatStatus(goVetResult == 0, "violates code correctness policy"))
+	if analyzersPath != "" {
+		fmt.Printf("analyzer checks: %s\n", formatStatus(analyzersResult == 0, "violates repository conventions"))
+	} else {
+		fmt.Println("analyzer checks: SKIPPED (no --analyzers)")
+	}
 
 	os.Exit(exitCode)
 }
@@ -118,6 +209,7 @@ func runGofmtChecks() int {
 					continue
 				}
 				fmt.Printf("❌ %s\n", file)
+				recordFailure(dir, "gofmt")
 				failedFiles++
 			}
 		}
@@ -131,46 +223,116 @@ func runGofmtChecks() int {
 	return 0
 }
 
-func runGoVetChecks() int {
-	govetExit := 0
+// listPackages returns the packages of the directories to lint, leaving
+// out those under the ignored directories and those with the skip prefix.
+// A directory whose packages cannot be listed fails, and the exit code is 1.
+func listPackages() ([]goPackage, int) {
+	listExit := 0
+	wd, _ := os.Getwd()
 
+	var pkgs []goPackage
 	for _, dir := range dirsToLint {
-		err := filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
-			if err != nil {
-				return err
+		cmd := exec.Command("go", "list", "-f", "{{.ImportPath}}\t{{.Dir}}", "./...")
+		cmd.Dir = dir
+		var stderr bytes.Buffer
+		cmd.Stderr = &stderr
+		output, err := cmd.Output()
+		if err != nil {
+			fmt.Printf("❌ %s (go list failed)\n", dir)
+			printLines(stderr.Bytes())
+			recordFailure(dir, "go list")
+			listExit = 1
+			continue
+		}
+
+		for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+			importPath, pkgDir, ok := strings.Cut(line, "\t")
+			if !ok {
+				continue
 			}
-			if d == nil || d.IsDir() || !strings.HasSuffix(path, ".go") {
-				return nil
+			if skipPrefix != "" && strings.HasPrefix(importPath, skipPrefix) {
+				continue
 			}
-			if shouldIgnoreFile(path) {
-				return nil
+			if rel, err := filepath.Rel(wd, pkgDir); err == nil && shouldIgnoreFile(filepath.ToSlash(rel)) {
+				continue
 			}
+			pkgs = append(pkgs, goPackage{ImportPath: importPath, Dir: dir})
+		}
+	}
+	return pkgs, listExit
+}
 
-			cmd := exec.Command("go", "vet", path)
-			output, err := cmd.CombinedOutput()
-			if err != nil {
-				// For test files, show as checked but don't fail the build
-				if strings.HasSuffix(path, "_test.go") {
-					fmt.Printf("✅ %s (test file - checked)\n", path)
-				} else {
-					fmt.Printf("❌ %s (violates go vet policy)\n", path)
-					printLines(output)
-					govetExit = 1
-				}
-			} else {
-				fmt.Printf("✅ %s\n", path)
-			}
-			return nil
-		})
+// runGoVetChecks runs go vet on each package, with its test files
+func runGoVetChecks(pkgs []goPackage) int {
+	govetExit := 0
+
+	for _, pkg := range pkgs {
+		cmd := exec.Command("go", "vet", pkg.ImportPath)
+		cmd.Dir = pkg.Dir
+		output, err := cmd.CombinedOutput()
 		if err != nil {
-			fmt.Printf("Error walking %s directory: %v\n", dir, err)
+			fmt.Printf("❌ %s (violates go vet policy)\n", pkg.ImportPath)
+			printLines(output)
+			recordFailure(pkg.Dir, "go vet")
 			govetExit = 1
+		} else {
+			fmt.Printf("✅ %s\n", pkg.ImportPath)
 		}
 	}
 
 	return govetExit
 }
 
+// runAnalyzerChecks runs the repository's analyzers on each package, less
+// the disabled ones
+func runAnalyzerChecks(pkgs []goPackage, analyzersPath string) int {
+	analyzersExit := 0
+
+	var args []string
+	for _, name := range disabledAnalyzers {
+		args = append(args, "-"+name+"=false")
+	}
+
+	for _, pkg := range pkgs {
+		cmd := exec.Command(analyzersPath, append(args, pkg.ImportPath)...)
+		cmd.Dir = pkg.Dir
+		output, err := cmd.CombinedOutput()
+		if err != nil {
+			fmt.Printf("❌ %s (violates repository conventions)\n", pkg.ImportPath)
+			printLines(output)
+			recordFailure(pkg.Dir, "analyzers")
+			analyzersExit = 1
+		} else {
+			fmt.Printf("✅ %s\n", pkg.ImportPath)
+		}
+	}
+
+	return analyzersExit
+}
+
+// recordFailure notes that check failed for the directory to lint dir
+func recordFailure(dir, check string) {
+	for _, failed := range moduleFailures[dir] {
+		if failed == check {
+			return
+		}
+	}
+	moduleFailures[dir] = append(moduleFailures[dir], check)
+}
+
+// printModuleSummary prints whether each directory to lint passed, and
+// which checks failed
+func printModuleSummary() {
+	fmt.Println("\n=== Module Summary ===")
+	for _, dir := range dirsToLint {
+		if failed := moduleFailures[dir]; len(failed) > 0 {
+			fmt.Printf("❌ %s (%s)\n", dir, strings.Join(failed, ", "))
+		} else {
+			fmt.Printf("✅ %s\n", dir)
+		}
+	}
+}
+
 func shouldIgnoreFile(filePath string) bool {
 	for _, dir := range ignoredDirs {
 		if dir == "" {
@@ -198,3 +360,64 @@ func formatStatus(success bool, failMessage string) string {
 	}
 	return fmt.Sprintf("FAIL ❌ (%s)", failMessage)
 }
+
+// discoverDirs returns the Go modules to lint below root, skipping the
+// configured exclusions and the Terraform module roots
+func discoverDirs(root string, config *Config) ([]string, error) {
+	if config == nil {
+		return discovery.Modules(root, nil, nil)
+	}
+	return discovery.Discover(root, config.GoDiscovery, config.ModuleRoots, config.Scripts.ExcludedDirs)
+}
+
+// discoverTerraformModules returns the directories holding a go.mod in the
+// Terraform modules below the module_roots, or only in the module at only
+// when it is set. The paths are relative to root in slash form and sorted.
+func discoverTerraformModules(root string, config *Config, only string) ([]string, error) {
+	var bases, exclude, excludedNames []string
+	if config != nil {
+		bases = config.ModuleRoots
+		exclude = config.GoDiscovery.Exclude
+		excludedNames = config.Scripts.ExcludedDirs
+	}
+	if only != "" {
+		bases = []string{only}
+	}
+
+	var dirs []string
+	for _, base := range bases {
+		base = discovery.CleanPath(base)
+		baseDir := filepath.Join(root, filepath.FromSlash(base))
+		info, err := os.Stat(baseDir)
+		if os.IsNotExist(err) && only == "" {
+			// A module root without modules yet
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			return nil, fmt.Errorf("%s is not a directory", base)
+		}
+
+		// The exclusions are relative to the repository root
+		var baseExclude []string
+		for _, path := range exclude {
+			if rel, ok := strings.CutPrefix(discovery.CleanPath(path), base+"/"); ok {
+				baseExclude = append(baseExclude, rel)
+			}
+		}
+		if _, err := os.Stat(filepath.Join(baseDir, "go.mod")); err == nil {
+			dirs = append(dirs, base)
+		}
+		found, err := discovery.Modules(baseDir, baseExclude, excludedNames)
+		if err != nil {
+			return nil, err
+		}
+		for _, dir := range found {
+			dirs = append(dirs, base+"/"+dir)
+		}
+	}
+	sort.Strings(dirs)
+	return dirs, nil
+}
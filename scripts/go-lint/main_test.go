@@ -1,7 +1,12 @@
 package main
 
 import (
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+
+	"github.com/terraform-modules/scripts/go-discovery"
 )
 
 func TestShouldIgnoreFile(t *testing.T) {
@@ -109,3 +114,149 @@ func TestPrintLines(t *testing.T) {
 	// We can't easily test the output since it prints to stdout
 	printLines([]byte("line1\nline2\n"))
 }
+
+func TestDiscoverDirs(t *testing.T) {
+	root := t.TempDir()
+	for _, dir := range []string{"scripts/a", "scripts/b/testdata/x", "scripts/.git/x", "tests/opa/test-fixture/m", "skeletons/generic", "scripts/.terragrunt-cache/m"} {
+		path := filepath.Join(root, filepath.FromSlash(dir))
+		if err := os.MkdirAll(path, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(path, "go.mod"), []byte("module x\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	config := &Config{GoDiscovery: discovery.Config{Exclude: []string{"./tests/opa/test-fixture"}}, ModuleRoots: []string{"skeletons/"}}
+	dirs, err := discoverDirs(root, config)
+	if err != nil {
+		t.Fatalf("discoverDirs() error = %v", err)
+	}
+	if got := strings.Join(dirs, ","); got != "scripts/a" {
+		t.Errorf("discoverDirs() = %s", got)
+	}
+
+	// Without a config only the built-in directories are skipped
+	dirs, err = discoverDirs(root, nil)
+	if err != nil {
+		t.Fatalf("discoverDirs() error = %v", err)
+	}
+	if got := strings.Join(dirs, ","); got != "scripts/a,skeletons/generic,tests/opa/test-fixture/m" {
+		t.Errorf("discoverDirs() without config = %s", got)
+	}
+}
+
+func TestListPackages(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		"mod/go.mod":              "module example.com/mod\n\ngo 1.23\n",
+		"mod/main.go":             "package main\n\nfunc main() {}\n",
+		"mod/internal/a/a.go":     "package a\n",
+		"mod/generated/b/b.go":    "package b\n",
+		"mod/experimental/c/c.go": "package c\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(root); err != nil {
+		t.Fatal(err)
+	}
+	dirsToLint = []string{"mod"}
+	ignoredDirs = []string{"mod/generated"}
+	skipPrefix = "example.com/mod/experimental"
+	defer func() {
+		os.Chdir(wd)
+		dirsToLint, ignoredDirs, skipPrefix = nil, nil, ""
+	}()
+
+	pkgs, code := listPackages()
+	if code != 0 {
+		t.Fatalf("listPackages() failed")
+	}
+	var got []string
+	for _, pkg := range pkgs {
+		if pkg.Dir != "mod" {
+			t.Errorf("Expected %s to be listed from mod, got %s", pkg.ImportPath, pkg.Dir)
+		}
+		got = append(got, pkg.ImportPath)
+	}
+	if strings.Join(got, ",") != "example.com/mod,example.com/mod/internal/a" {
+		t.Errorf("listPackages() = %v", got)
+	}
+
+	// A directory that is not a module fails on its own
+	dirsToLint = []string{"missing", "mod"}
+	defer func() { moduleFailures = map[string][]string{} }()
+	pkgs, code = listPackages()
+	if code != 1 || len(pkgs) != 2 || strings.Join(moduleFailures["missing"], ",") != "go list" {
+		t.Errorf("Expected only missing to fail, got %d packages and failures %v", len(pkgs), moduleFailures)
+	}
+}
+
+func TestDiscoverTerraformModules(t *testing.T) {
+	root := t.TempDir()
+	for _, dir := range []string{"skeletons/generic", "providers/aws/primitives/s3/tests", "providers/aws/primitives/s3/tests/testdata/m", "providers/aws/primitives/vpc/.terraform/m", "providers/aws/primitives/old", "scripts/a"} {
+		path := filepath.Join(root, filepath.FromSlash(dir))
+		if err := os.MkdirAll(path, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(path, "go.mod"), []byte("module x\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	config := &Config{
+		ModuleRoots: []string{"skeletons/", "providers/aws/primitives/", "providers/github/primitives/"},
+		GoDiscovery: discovery.Config{Exclude: []string{"providers/aws/primitives/old"}},
+	}
+	config.Scripts.ExcludedDirs = []string{".terraform"}
+	dirs, err := discoverTerraformModules(root, config, "")
+	if err != nil {
+		t.Fatalf("discoverTerraformModules() error = %v", err)
+	}
+	if got := strings.Join(dirs, ","); got != "providers/aws/primitives/s3/tests,skeletons/generic" {
+		t.Errorf("discoverTerraformModules() = %s", got)
+	}
+
+	// Only the module of the change detector
+	dirs, err = discoverTerraformModules(root, config, "./skeletons/generic/")
+	if err != nil {
+		t.Fatalf("discoverTerraformModules() error = %v", err)
+	}
+	if got := strings.Join(dirs, ","); got != "skeletons/generic" {
+		t.Errorf("discoverTerraformModules() for one module = %s", got)
+	}
+
+	if _, err := discoverTerraformModules(root, config, "providers/aws/primitives/missing"); err == nil {
+		t.Errorf("Expected an error for a missing module")
+	}
+}
+
+func TestRecordFailure(t *testing.T) {
+	dirsToLint = []string{"scripts/a", "scripts/b"}
+	defer func() {
+		dirsToLint = nil
+		moduleFailures = map[string][]string{}
+	}()
+
+	recordFailure("scripts/b", "gofmt")
+	recordFailure("scripts/b", "go vet")
+	recordFailure("scripts/b", "gofmt")
+	if got := strings.Join(moduleFailures["scripts/b"], ", "); got != "gofmt, go vet" {
+		t.Errorf("Unexpected failures for scripts/b: %s", got)
+	}
+	if len(moduleFailures["scripts/a"]) != 0 {
+		t.Errorf("Expected no failures for scripts/a")
+	}
+	printModuleSummary()
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/terraform-modules/scripts/go-discovery"
+)
+
+// AffectedTests configures the selection of coverage groups by a change set
+type AffectedTests struct {
+	GoAlwaysRun []string `json:"go_always_run"` // Files or directories whose change runs every group
+}
+
+// affectedGroup is a coverage group selected by a change set
+type affectedGroup struct {
+	Group   CoverageGroup
+	Trigger string // First changed file in the group
+}
+
+// readChangedFiles returns the changed files listed in path, one per line,
+// or those git reports against base when path is empty
+func readChangedFiles(path, base string) ([]string, error) {
+	var data []byte
+	var err error
+	if path != "" {
+		data, err = os.ReadFile(path)
+	} else {
+		// Against the merge base, including uncommitted changes such as a
+		// merge made with --no-commit
+		data, err = execCommandContext(context.Background(), "git", "diff", "--name-only", "--merge-base", base).Output()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	for _, line := range strings.Split(string(data), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			files = append(files, discovery.CleanPath(line))
+		}
+	}
+	return files, nil
+}
+
+// selectAffected returns the groups holding a changed file. When a changed
+// file is under one of the alwaysRun paths, every group is returned along
+// with that file.
+func selectAffected(groups []CoverageGroup, changed, alwaysRun []string) ([]affectedGroup, string) {
+	for _, file := range changed {
+		for _, path := range alwaysRun {
+			if underPath(file, discovery.CleanPath(path)) {
+				selected := make([]affectedGroup, 0, len(groups))
+				for _, group := range groups {
+					selected = append(selected, affectedGroup{Group: group, Trigger: file})
+				}
+				return selected, file
+			}
+		}
+	}
+
+	var selected []affectedGroup
+	for _, group := range groups {
+		dir := discovery.CleanPath(group.TestPath)
+		for _, file := range changed {
+			if underPath(file, dir) {
+				selected = append(selected, affectedGroup{Group: group, Trigger: file})
+				break
+			}
+		}
+	}
+	return selected, ""
+}
+
+// underPath reports whether file is dir or inside it
+func underPath(file, dir string) bool {
+	return dir == "." || file == dir || strings.HasPrefix(file, dir+"/")
+}
+
+// printAffected lists the selected groups and what selected them
+func printAffected(selected []affectedGroup, shared string, total, changed int) {
+	if shared != "" {
+		fmt.Fprintf(stderr, "🔎 Shared file %s changed, testing all %d coverage groups\n", shared, total)
+		return
+	}
+	fmt.Fprintf(stderr, "🔎 %d changed files affect %d of %d coverage groups:\n", changed, len(selected), total)
+	for _, affected := range selected {
+		fmt.Fprintf(stderr, "  %s %s (%s)\n", affected.Group.Emoji, affected.Group.Name, affected.Trigger)
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestReadChangedFiles(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "changed.txt")
+	os.WriteFile(path, []byte("scripts/go-lint/main.go\n\n  ./Makefile  \nscripts/monorepo/\n"), 0644)
+
+	files, err := readChangedFiles(path, "")
+	if err != nil {
+		t.Fatalf("readChangedFiles() error = %v", err)
+	}
+	expected := []string{"scripts/go-lint/main.go", "Makefile", "scripts/monorepo"}
+	if !reflect.DeepEqual(files, expected) {
+		t.Errorf("Expected %v, got %v", expected, files)
+	}
+
+	if _, err := readChangedFiles(filepath.Join(t.TempDir(), "missing.txt"), ""); err == nil {
+		t.Errorf("Expected an error for a missing file")
+	}
+}
+
+func TestSelectAffected(t *testing.T) {
+	groups := []CoverageGroup{
+		{Name: "Lint", TestPath: "./scripts/go-lint"},
+		{Name: "Format", TestPath: "./scripts/go-format/"},
+		{Name: "Monorepo", TestPath: "scripts/monorepo"},
+	}
+	alwaysRun := []string{"monorepo-config.json", "scripts/go-unit-test/"}
+
+	tests := []struct {
+		name     string
+		changed  []string
+		expected []string
+		shared   string
+	}{
+		{
+			name:     "one module",
+			changed:  []string{"scripts/go-lint/main.go", "README.md"},
+			expected: []string{"Lint"},
+		},
+		{
+			name:     "several modules",
+			changed:  []string{"scripts/monorepo/cmd/root.go", "scripts/go-format/go.mod"},
+			expected: []string{"Format", "Monorepo"},
+		},
+		{
+			name:     "prefix of another directory",
+			changed:  []string{"scripts/go-lint-extra/main.go"},
+			expected: nil,
+		},
+		{
+			name:     "shared config",
+			changed:  []string{"scripts/go-lint/main.go", "monorepo-config.json"},
+			expected: []string{"Lint", "Format", "Monorepo"},
+			shared:   "monorepo-config.json",
+		},
+		{
+			name:     "shared directory",
+			changed:  []string{"scripts/go-unit-test/runner.go"},
+			expected: []string{"Lint", "Format", "Monorepo"},
+			shared:   "scripts/go-unit-test/runner.go",
+		},
+		{
+			name:     "nothing changed",
+			expected: nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			selected, shared := selectAffected(groups, tt.changed, alwaysRun)
+			var names []string
+			for _, affected := range selected {
+				names = append(names, affected.Group.Name)
+			}
+			if !reflect.DeepEqual(names, tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, names)
+			}
+			if shared != tt.shared {
+				t.Errorf("Expected shared file %q, got %q", tt.shared, shared)
+			}
+		})
+	}
+
+	selected, _ := selectAffected(groups, []string{"scripts/go-format/main.go", "scripts/go-format/main_test.go"}, nil)
+	if len(selected) != 1 || selected[0].Trigger != "scripts/go-format/main.go" {
+		t.Errorf("Expected Format triggered by its first changed file, got %+v", selected)
+	}
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"io/fs"
+	"math"
+	"os"
+	"strings"
+)
+
+// ModuleDelta is the coverage change of one module against the baseline
+type ModuleDelta struct {
+	Name     string
+	Baseline float64
+	Current  float64
+	New      bool // Not in the baseline
+	Removed  bool // Only in the baseline
+}
+
+// CoverageDelta compares a coverage summary with a baseline summary
+type CoverageDelta struct {
+	Modules  []ModuleDelta
+	Baseline float64 // Baseline total
+	Current  float64 // Current total
+}
+
+// Drop returns how many points the total coverage dropped, rounded to one
+// decimal like the reports. A negative drop is an increase.
+func (d CoverageDelta) Drop() float64 {
+	return math.Round((d.Baseline-d.Current)*10) / 10
+}
+
+// readBaseline loads a coverage summary written by --coverage-json
+func readBaseline(path string) (*CoverageSummary, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var baseline CoverageSummary
+	if err := json.Unmarshal(data, &baseline); err != nil {
+		return nil, fmt.Errorf("error parsing baseline %s: %w", path, err)
+	}
+	return &baseline, nil
+}
+
+// compareCoverage computes the per-module and total deltas. Modules are
+// listed in the current order, followed by the modules that were removed.
+func compareCoverage(baseline, current CoverageSummary) CoverageDelta {
+	delta := CoverageDelta{Baseline: baseline.Total, Current: current.Total}
+
+	baseModules := make(map[string]float64, len(baseline.Modules))
+	for _, module := range baseline.Modules {
+		baseModules[module.Name] = module.Coverage
+	}
+	seen := make(map[string]bool, len(current.Modules))
+	for _, module := range current.Modules {
+		seen[module.Name] = true
+		base, ok := baseModules[module.Name]
+		delta.Modules = append(delta.Modules, ModuleDelta{Name: module.Name, Baseline: base, Current: module.Coverage, New: !ok})
+	}
+	for _, module := range baseline.Modules {
+		if !seen[module.Name] {
+			delta.Modules = append(delta.Modules, ModuleDelta{Name: module.Name, Baseline: module.Coverage, Removed: true})
+		}
+	}
+	return delta
+}
+
+// restrictBaseline keeps the baseline modules that are in the summary and
+// recomputes the total over them, weighted by statements like the summary
+func restrictBaseline(baseline, summary CoverageSummary) CoverageSummary {
+	ran := make(map[string]bool, len(summary.Modules))
+	for _, module := range summary.Modules {
+		ran[module.Name] = true
+	}
+	restricted := CoverageSummary{Errors: baseline.Errors}
+	var covered float64
+	var statements int
+	for _, module := range baseline.Modules {
+		if !ran[module.Name] {
+			continue
+		}
+		restricted.Modules = append(restricted.Modules, module)
+		covered += float64(module.Statements) * module.Coverage / 100
+		statements += module.Statements
+	}
+	if statements > 0 {
+		restricted.Total = covered / float64(statements) * 100
+	}
+	return restricted
+}
+
+// compareBaseline compares the summary with the baseline, writes the
+// Markdown table if requested and enforces the maximum drop. A missing
+// baseline, as on the first run, is reported but not an error. When only
+// the affected groups ran, the baseline is restricted to them.
+func compareBaseline(summary CoverageSummary, baselinePath, markdownPath string, maxDrop *float64, partial bool) bool {
+	baseline, err := readBaseline(baselinePath)
+	if errors.Is(err, fs.ErrNotExist) {
+		fmt.Fprintf(stderr, "⚠️  Coverage baseline %s not found, skipping comparison\n", baselinePath)
+		return true
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "Error reading coverage baseline: %v\n", err)
+		return false
+	}
+
+	if partial {
+		*baseline = restrictBaseline(*baseline, summary)
+	}
+	delta := compareCoverage(*baseline, summary)
+	ok := true
+	if markdownPath != "" {
+		if err := writeDeltaMarkdown(markdownPath, "Go coverage", delta, maxDrop); err != nil {
+			fmt.Fprintf(stderr, "Error writing coverage delta %s: %v\n", markdownPath, err)
+			ok = false
+		}
+	}
+
+	fmt.Fprintf(stderr, "\nCoverage change against %s: %.1f%% -> %.1f%% (%s)\n", baselinePath, delta.Baseline, delta.Current, formatDelta(-delta.Drop()))
+	if maxDrop != nil {
+		if delta.Drop() > *maxDrop {
+			fmt.Fprintf(stderr, "❌ Total coverage dropped by %.1f points, more than the allowed %.1f\n", delta.Drop(), *maxDrop)
+			return false
+		}
+		fmt.Fprintf(stderr, "✅ Total coverage did not drop by more than %.1f points\n", *maxDrop)
+	}
+	return ok
+}
+
+// writeDeltaMarkdown writes the deltas as a Markdown table for a PR comment
+func writeDeltaMarkdown(path, title string, delta CoverageDelta, maxDrop *float64) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "### %s\n\n", title)
+	b.WriteString("| Module | Baseline | Current | Change |\n")
+	b.WriteString("|--------|---------:|--------:|-------:|\n")
+	for _, module := range delta.Modules {
+		switch {
+		case module.New:
+			fmt.Fprintf(&b, "| %s | – | %.1f%% | new |\n", module.Name, module.Current)
+		case module.Removed:
+			fmt.Fprintf(&b, "| %s | %.1f%% | – | removed |\n", module.Name, module.Baseline)
+		default:
+			fmt.Fprintf(&b, "| %s | %.1f%% | %.1f%% | %s |\n", module.Name, module.Baseline, module.Current, formatDelta(module.Current-module.Baseline))
+		}
+	}
+	fmt.Fprintf(&b, "| **Total** | **%.1f%%** | **%.1f%%** | **%s** |\n", delta.Baseline, delta.Current, formatDelta(-delta.Drop()))
+
+	if maxDrop != nil {
+		b.WriteString("\n")
+		if delta.Drop() > *maxDrop {
+			fmt.Fprintf(&b, "❌ Total coverage dropped by %.1f points, more than the allowed %.1f.\n", delta.Drop(), *maxDrop)
+		} else {
+			fmt.Fprintf(&b, "✅ Total coverage did not drop by more than %.1f points.\n", *maxDrop)
+		}
+	}
+	return writeReportFile(path, []byte(strings.TrimSuffix(b.String(), "\n")))
+}
+
+// formatDelta formats a change in points with a sign and a marker
+func formatDelta(points float64) string {
+	points = math.Round(points*10) / 10
+	switch {
+	case points > 0:
+		return fmt.Sprintf("+%.1f 🟢", points)
+	case points < 0:
+		return fmt.Sprintf("%.1f 🔴", points)
+	default:
+		return "0.0"
+	}
+}
+
+// badgeColor returns the badge color for a coverage percentage
+func badgeColor(coverage float64) string {
+	switch {
+	case coverage >= 90:
+		return "#4c1"
+	case coverage >= 80:
+		return "#97ca00"
+	case coverage >= 70:
+		return "#a4a61d"
+	case coverage >= 60:
+		return "#dfb317"
+	case coverage >= 50:
+		return "#fe7d37"
+	default:
+		return "#e05d44"
+	}
+}
+
+// writeBadge writes a flat SVG coverage badge. Text widths are estimated,
+// which is close enough for the short label and value.
+func writeBadge(path, label string, coverage float64) error {
+	value := fmt.Sprintf("%.1f%%", coverage)
+	labelWidth := 7*len(label) + 10
+	valueWidth := 7*len(value) + 10
+	width := labelWidth + valueWidth
+	label, value = html.EscapeString(label), html.EscapeString(value)
+
+	svg := fmt.Sprintf(`<svg xmlns="http://www.w3.org/2000/svg" width="%[1]d" height="20" role="img" aria-label="%[4]s: %[5]s">
+<title>%[4]s: %[5]s</title>
+<linearGradient id="s" x2="0" y2="100%%"><stop offset="0" stop-color="#bbb" stop-opacity=".1"/><stop offset="1" stop-opacity=".1"/></linearGradient>
+<clipPath id="r"><rect width="%[1]d" height="20" rx="3" fill="#fff"/></clipPath>
+<g clip-path="url(#r)"><rect width="%[2]d" height="20" fill="#555"/><rect x="%[2]d" width="%[3]d" height="20" fill="%[6]s"/><rect width="%[1]d" height="20" fill="url(#s)"/></g>
+<g fill="#fff" text-anchor="middle" font-family="Verdana,Geneva,DejaVu Sans,sans-serif" font-size="11"><text x="%[7]d" y="14">%[4]s</text><text x="%[8]d" y="14">%[5]s</text></g>
+</svg>`, width, labelWidth, valueWidth, label, value, badgeColor(coverage), labelWidth/2, labelWidth+valueWidth/2)
+	return writeReportFile(path, []byte(svg))
+}
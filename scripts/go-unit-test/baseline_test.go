@@ -0,0 +1,161 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCompareCoverage(t *testing.T) {
+	baseline := CoverageSummary{
+		Modules: []CoverageResult{{Name: "Lint", Coverage: 80}, {Name: "Old", Coverage: 40}},
+		Total:   60,
+	}
+	current := CoverageSummary{
+		Modules: []CoverageResult{{Name: "Lint", Coverage: 75.5}, {Name: "New", Coverage: 30}},
+		Total:   58.04,
+	}
+
+	delta := compareCoverage(baseline, current)
+	if delta.Drop() != 2 {
+		t.Errorf("Expected a rounded drop of 2.0, got %v", delta.Drop())
+	}
+	expected := []ModuleDelta{
+		{Name: "Lint", Baseline: 80, Current: 75.5},
+		{Name: "New", Current: 30, New: true},
+		{Name: "Old", Baseline: 40, Removed: true},
+	}
+	if len(delta.Modules) != len(expected) {
+		t.Fatalf("Expected %d modules, got %+v", len(expected), delta.Modules)
+	}
+	for i := range expected {
+		if delta.Modules[i] != expected[i] {
+			t.Errorf("Module %d: expected %+v, got %+v", i, expected[i], delta.Modules[i])
+		}
+	}
+}
+
+func TestCompareBaseline(t *testing.T) {
+	dir := t.TempDir()
+	baselinePath := filepath.Join(dir, "baseline.json")
+	os.WriteFile(baselinePath, []byte(`{"modules":[{"name":"Lint","coverage":80,"statements":10}],"total":80,"errors":0}`), 0644)
+	current := CoverageSummary{Modules: []CoverageResult{{Name: "Lint", Coverage: 78.5}}, Total: 78.5}
+
+	allowed := 2.0
+	tight := 1.0
+	tests := []struct {
+		name     string
+		baseline string
+		maxDrop  *float64
+		expected bool
+	}{
+		{name: "no limit", baseline: baselinePath, expected: true},
+		{name: "within limit", baseline: baselinePath, maxDrop: &allowed, expected: true},
+		{name: "over limit", baseline: baselinePath, maxDrop: &tight, expected: false},
+		{name: "missing baseline", baseline: filepath.Join(dir, "missing.json"), maxDrop: &tight, expected: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := compareBaseline(current, tt.baseline, "", tt.maxDrop, false); got != tt.expected {
+				t.Errorf("compareBaseline() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+
+	os.WriteFile(baselinePath, []byte("not json"), 0644)
+	if compareBaseline(current, baselinePath, "", nil, false) {
+		t.Errorf("Expected an unreadable baseline to fail")
+	}
+}
+
+func TestRestrictBaseline(t *testing.T) {
+	baseline := CoverageSummary{
+		Modules: []CoverageResult{
+			{Name: "Lint", Coverage: 80, Statements: 100},
+			{Name: "Format", Coverage: 20, Statements: 300},
+			{Name: "Monorepo", Coverage: 50, Statements: 100},
+		},
+		Total: 32,
+	}
+	current := CoverageSummary{Modules: []CoverageResult{{Name: "Lint"}, {Name: "Monorepo"}, {Name: "New"}}}
+
+	restricted := restrictBaseline(baseline, current)
+	if len(restricted.Modules) != 2 || restricted.Modules[0].Name != "Lint" || restricted.Modules[1].Name != "Monorepo" {
+		t.Fatalf("Expected Lint and Monorepo, got %+v", restricted.Modules)
+	}
+	if restricted.Total != 65 {
+		t.Errorf("Expected a total of 65, got %v", restricted.Total)
+	}
+
+	// Against the full baseline, the partial run would look like a rise
+	dir := t.TempDir()
+	baselinePath := filepath.Join(dir, "baseline.json")
+	os.WriteFile(baselinePath, []byte(`{"modules":[{"name":"Lint","coverage":90,"statements":10},{"name":"Format","coverage":10,"statements":90}],"total":18,"errors":0}`), 0644)
+	partial := CoverageSummary{Modules: []CoverageResult{{Name: "Lint", Coverage: 85, Statements: 10}}, Total: 85}
+	maxDrop := 1.0
+	if !compareBaseline(partial, baselinePath, "", &maxDrop, false) {
+		t.Errorf("Expected the full comparison to pass")
+	}
+	if compareBaseline(partial, baselinePath, "", &maxDrop, true) {
+		t.Errorf("Expected the restricted comparison to fail")
+	}
+}
+
+func TestWriteDeltaMarkdown(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "delta", "go.md")
+	delta := CoverageDelta{
+		Modules: []ModuleDelta{
+			{Name: "Lint", Baseline: 80, Current: 82.5},
+			{Name: "Monorepo CLI", Baseline: 50, Current: 45},
+			{Name: "New", Current: 30, New: true},
+			{Name: "Old", Baseline: 40, Removed: true},
+		},
+		Baseline: 60,
+		Current:  57.5,
+	}
+	maxDrop := 1.0
+	if err := writeDeltaMarkdown(path, "Go coverage", delta, &maxDrop); err != nil {
+		t.Fatalf("writeDeltaMarkdown() error = %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read Markdown: %v", err)
+	}
+	for _, want := range []string{
+		"### Go coverage\n",
+		"| Lint | 80.0% | 82.5% | +2.5 🟢 |",
+		"| Monorepo CLI | 50.0% | 45.0% | -5.0 🔴 |",
+		"| New | – | 30.0% | new |",
+		"| Old | 40.0% | – | removed |",
+		"| **Total** | **60.0%** | **57.5%** | **-2.5 🔴** |",
+		"❌ Total coverage dropped by 2.5 points, more than the allowed 1.0.",
+	} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("Expected Markdown to contain %q, got:\n%s", want, data)
+		}
+	}
+}
+
+func TestWriteBadge(t *testing.T) {
+	tests := []struct {
+		coverage float64
+		color    string
+	}{
+		{95.1, "#4c1"},
+		{52.8, "#fe7d37"},
+		{10, "#e05d44"},
+	}
+	for _, tt := range tests {
+		path := filepath.Join(t.TempDir(), "badge.svg")
+		if err := writeBadge(path, "go coverage", tt.coverage); err != nil {
+			t.Fatalf("writeBadge() error = %v", err)
+		}
+		data, _ := os.ReadFile(path)
+		svg := string(data)
+		if !strings.HasPrefix(svg, "<svg ") || !strings.Contains(svg, `fill="`+tt.color+`"`) || !strings.Contains(svg, ">go coverage<") {
+			t.Errorf("Unexpected badge for %.1f%%:\n%s", tt.coverage, svg)
+		}
+	}
+}
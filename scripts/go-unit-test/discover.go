@@ -0,0 +1,66 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/terraform-modules/scripts/go-discovery"
+)
+
+// Default emoji of discovered groups without an override
+const defaultEmoji = "🧪"
+
+// discoverGroups finds every Go module below root and returns a coverage
+// group for each. coverage_groups entries only rename a discovered module,
+// matched by testPath; entries matching no module are returned as unused.
+func discoverGroups(root string, config Config) ([]CoverageGroup, []CoverageGroup, error) {
+	dirs, err := discovery.Discover(root, config.GoDiscovery, config.ModuleRoots, config.Scripts.ExcludedDirs)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	overrides := make(map[string]CoverageGroup, len(config.CoverageGroups))
+	for _, group := range config.CoverageGroups {
+		overrides[discovery.CleanPath(group.TestPath)] = group
+	}
+
+	groups := make([]CoverageGroup, 0, len(dirs))
+	for _, dir := range dirs {
+		group := CoverageGroup{TestPath: "./" + dir}
+		if override, ok := overrides[dir]; ok {
+			group.Name = override.Name
+			group.Emoji = override.Emoji
+			delete(overrides, dir)
+		}
+		groups = append(groups, withDefaults(group))
+	}
+
+	var unused []CoverageGroup
+	for _, group := range config.CoverageGroups {
+		if _, ok := overrides[discovery.CleanPath(group.TestPath)]; ok {
+			unused = append(unused, group)
+		}
+	}
+	return groups, unused, nil
+}
+
+// withDefaults fills in the fields a coverage group may leave out: the
+// name, emoji and output file are derived from testPath.
+func withDefaults(group CoverageGroup) CoverageGroup {
+	if group.TestPath == "" {
+		return group
+	}
+	dir := discovery.CleanPath(group.TestPath)
+	if group.Name == "" {
+		group.Name = dir
+	}
+	if group.Emoji == "" {
+		group.Emoji = defaultEmoji
+	}
+	if group.OutputFile == "" {
+		group.OutputFile = strings.ReplaceAll(dir, "/", "-") + ".out"
+	}
+	if group.CoverPkg == "" {
+		group.CoverPkg = group.TestPath
+	}
+	return group
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeModules creates a go.mod in each directory below root
+func writeModules(t *testing.T, root string, dirs ...string) {
+	t.Helper()
+	for _, dir := range dirs {
+		path := filepath.Join(root, filepath.FromSlash(dir))
+		if err := os.MkdirAll(path, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(path, "go.mod"), []byte("module example.com/"+dir+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestDiscoverGroups(t *testing.T) {
+	root := t.TempDir()
+	writeModules(t, root, "scripts/go-lint", "scripts/new-tool", "skeletons/generic")
+
+	config := Config{
+		CoverageGroups: []CoverageGroup{
+			{Name: "Lint", Emoji: "🧹", OutputFile: "lint.out", TestPath: "./scripts/go-lint"},
+			{Name: "Removed", TestPath: "./scripts/removed"},
+		},
+		ModuleRoots: []string{"skeletons/"},
+	}
+	groups, unused, err := discoverGroups(root, config)
+	if err != nil {
+		t.Fatalf("discoverGroups() error = %v", err)
+	}
+
+	expected := []CoverageGroup{
+		{Name: "Lint", Emoji: "🧹", OutputFile: "scripts-go-lint.out", TestPath: "./scripts/go-lint", CoverPkg: "./scripts/go-lint"},
+		{Name: "scripts/new-tool", Emoji: defaultEmoji, OutputFile: "scripts-new-tool.out", TestPath: "./scripts/new-tool", CoverPkg: "./scripts/new-tool"},
+	}
+	if len(groups) != len(expected) {
+		t.Fatalf("Expected %d groups, got %+v", len(expected), groups)
+	}
+	for i := range expected {
+		if groups[i] != expected[i] {
+			t.Errorf("Group %d: expected %+v, got %+v", i, expected[i], groups[i])
+		}
+	}
+	if len(unused) != 1 || unused[0].Name != "Removed" {
+		t.Errorf("Expected the Removed entry to be unused, got %+v", unused)
+	}
+}
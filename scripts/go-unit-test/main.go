@@ -7,14 +7,16 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
-	"regexp"
-	"strconv"
+	"runtime"
 	"strings"
+	"time"
+
+	"github.com/terraform-modules/scripts/go-discovery"
 )
 
 // For testing purposes
 var coverageDir = "tmp/coverage"
-var execCommand = exec.Command
+var execCommandContext = exec.CommandContext
 var stdout = os.Stdout
 var stderr = os.Stderr
 
@@ -29,7 +31,15 @@ type CoverageGroup struct {
 
 // Config represents the structure of the monorepo-config.json file
 type Config struct {
-	CoverageGroups []CoverageGroup `json:"coverage_groups"`
+	CoverageGroups  []CoverageGroup   `json:"coverage_groups"`
+	MaxCoverageDrop *float64          `json:"max_coverage_drop"` // Allowed drop of the total against a baseline, in points
+	GoDiscovery     discovery.Config  `json:"go_discovery"`
+	AffectedTests   AffectedTests     `json:"affected_tests"`
+	Quarantine      []QuarantinedTest `json:"quarantine"`   // Known flaky tests whose failures do not fail the run
+	ModuleRoots     []string          `json:"module_roots"` // Terraform modules, skipped by discovery
+	Scripts         struct {
+		ExcludedDirs []string `json:"excluded_dirs"` // Directory names skipped by discovery
+	} `json:"scripts"`
 }
 
 // CoverageResult represents the coverage result for a module
@@ -52,6 +62,17 @@ func main() {
 	noCoverage := flag.Bool("no-coverage", false, "Run tests without collecting coverage data")
 	coverageText := flag.Bool("coverage-text", false, "Output coverage as text")
 	coverageJSON := flag.Bool("coverage-json", false, "Output coverage as JSON")
+	junitPath := flag.String("junit", "", "Write per-test results as JUnit XML to this file")
+	resultsJSONPath := flag.String("results-json", "", "Write per-test results as JSON to this file")
+	baselinePath := flag.String("baseline", "", "Compare coverage with a --coverage-json summary, typically from the main branch")
+	deltaMarkdownPath := flag.String("delta-markdown", "", "Write the coverage change against --baseline as a Markdown table to this file")
+	badgePath := flag.String("badge", "", "Write an SVG coverage badge to this file")
+	jobs := flag.Int("jobs", runtime.NumCPU(), "Number of coverage groups to test concurrently")
+	timeout := flag.Duration("timeout", 10*time.Minute, "Time limit for the tests of each coverage group, 0 for none")
+	discover := flag.Bool("discover", false, "Test every Go module found below the current directory, using coverage_groups only for names")
+	changedFilesPath := flag.String("changed-files", "", "Only test the coverage groups affected by the files listed in this file, one per line")
+	baseRef := flag.String("base", "", "Only test the coverage groups affected by the changes against this git ref")
+	retries := flag.Int("retries", 0, "Re-run failed tests up to this many times, marking those that pass as flaky")
 	flag.Parse()
 
 	// Get JSON file path from command line
@@ -76,65 +97,83 @@ func main() {
 		os.Exit(1)
 	}
 
-	groups := config.CoverageGroups
+	var groups []CoverageGroup
+	if *discover {
+		discovered, unused, err := discoverGroups(".", config)
+		if err != nil {
+			fmt.Fprintf(stderr, "Error discovering Go modules: %v\n", err)
+			os.Exit(1)
+		}
+		for _, group := range unused {
+			fmt.Fprintf(stderr, "⚠️  coverage_groups entry %q matches no discovered module (%s), ignoring\n", group.Name, group.TestPath)
+		}
+		groups = discovered
+	} else {
+		for _, group := range config.CoverageGroups {
+			groups = append(groups, withDefaults(group))
+		}
+	}
 	if len(groups) == 0 {
 		fmt.Fprintln(stderr, "Error: No coverage groups provided in the config file")
 		os.Exit(1)
 	}
 
+	// Keep the groups affected by the change set, if given
+	partial := false
+	if *changedFilesPath != "" || *baseRef != "" {
+		changed, err := readChangedFiles(*changedFilesPath, *baseRef)
+		if err != nil && *changedFilesPath != "" {
+			fmt.Fprintf(stderr, "Error reading changed files: %v\n", err)
+			os.Exit(1)
+		}
+		if err != nil {
+			// Testing too much beats missing a broken test
+			fmt.Fprintf(stderr, "⚠️  Could not list the files changed against %s (%v), testing all coverage groups\n", *baseRef, err)
+		} else {
+			alwaysRun := append([]string{jsonFilePath}, config.AffectedTests.GoAlwaysRun...)
+			selected, shared := selectAffected(groups, changed, alwaysRun)
+			if len(selected) == 0 {
+				fmt.Fprintf(stderr, "✅ None of the %d changed files affect a coverage group, nothing to test\n", len(changed))
+				return
+			}
+			printAffected(selected, shared, len(groups), len(changed))
+			partial = len(selected) < len(groups)
+			groups = groups[:0]
+			for _, affected := range selected {
+				groups = append(groups, affected.Group)
+			}
+		}
+	}
+
 	// Create coverage directory if collecting coverage
 	if !*noCoverage {
+		if err := checkOutputFiles(groups); err != nil {
+			fmt.Fprintf(stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
 		if err := os.MkdirAll(coverageDir, 0755); err != nil {
 			fmt.Fprintf(stderr, "Error creating coverage directory: %v\n", err)
 			os.Exit(1)
 		}
 	}
 
-	// Run tests for each group
+	// Run the groups concurrently
 	moduleResults := make([]CoverageResult, 0, len(groups))
+	var testResults []SuiteReport
 	errorCount := 0
 
-	for _, group := range groups {
-		fmt.Fprintf(stderr, "%s Running tests for %s...\n", group.Emoji, group.Name)
-
-		var coverage float64
-		var statements int
-		var testErr error
-
-		if *noCoverage {
-			// Just run the tests without coverage
-			testErr = runTest(group.TestPath)
-		} else {
-			// Run tests with coverage
-			coverage, statements, testErr = runTestWithCoverage(group.OutputFile, group.TestPath)
-		}
-
-		result := CoverageResult{
-			Name:       group.Name,
-			Coverage:   coverage,
-			Statements: statements,
-		}
-
-		if testErr != nil {
+	opts := runOptions{Coverage: !*noCoverage, CoverageText: *coverageText, Timeout: *timeout, Retries: *retries, Quarantine: config.Quarantine}
+	for _, run := range runGroups(groups, opts, *jobs, stderr) {
+		testResults = append(testResults, run.Suites...)
+		if run.Result.Error != "" {
 			errorCount++
-			errorMsg := fmt.Sprintf("Error running tests: %v", testErr)
-			result.Error = errorMsg
-
-			fmt.Fprintf(stderr, "❌ %s\n", errorMsg)
 		}
+		moduleResults = append(moduleResults, run.Result)
+	}
 
-		moduleResults = append(moduleResults, result)
-
-		// If text output and collecting coverage, print coverage details
-		if *coverageText && !*noCoverage {
-			outputPath := filepath.Join(coverageDir, group.OutputFile)
-			if fileExists(outputPath) {
-				coverageCmd := execCommand("bash", "-c", fmt.Sprintf("go tool cover -func=../../%s", outputPath))
-				coverageCmd.Dir = group.TestPath
-				coverageOutput, _ := coverageCmd.CombinedOutput()
-				fmt.Fprintf(stderr, "\nCoverage for %s:\n%s\n", group.Name, string(coverageOutput))
-			}
-		}
+	// Write per-test results if requested
+	if !writeResults(*junitPath, *resultsJSONPath, buildResultsReport(testResults)) {
+		errorCount++
 	}
 
 	// If not collecting coverage, we're done
@@ -195,120 +234,65 @@ func main() {
 		fmt.Fprintln(stdout, string(outputJSON))
 	}
 
-	// Exit with error if any test failed
-	if errorCount > 0 {
-		os.Exit(1)
-	}
-}
-
-func runTest(testPath string) error {
-	// Check if the directory exists
-	if _, err := os.Stat(testPath); os.IsNotExist(err) {
-		return fmt.Errorf("directory %s does not exist", testPath)
-	}
-
-	// Run the tests
-	cmd := execCommand("bash", "-c", fmt.Sprintf("cd %s && go test -v", testPath))
-	cmd.Stdout = stderr
-	cmd.Stderr = stderr
-
-	return cmd.Run()
-}
-
-func runTestWithCoverage(outputFile, testPath string) (float64, int, error) {
-	outputPath := filepath.Join(coverageDir, outputFile)
-
-	// Check if the directory exists
-	if _, err := os.Stat(testPath); os.IsNotExist(err) {
-		return 0.0, 0, fmt.Errorf("directory %s does not exist", testPath)
+	// Write the badge and compare with the baseline
+	coverageOK := true
+	if *badgePath != "" && partial {
+		fmt.Fprintf(stderr, "⚠️  Only the affected coverage groups ran, not writing the coverage badge\n")
+	} else if *badgePath != "" {
+		if err := writeBadge(*badgePath, "go coverage", averageCoverage); err != nil {
+			fmt.Fprintf(stderr, "Error writing coverage badge %s: %v\n", *badgePath, err)
+			coverageOK = false
+		}
 	}
-
-	// First run go test with -count=1 to get accurate statement count
-	cmdCount := execCommand("bash", "-c", fmt.Sprintf("cd %s && go test -cover -count=1", testPath))
-	outputCount, err := cmdCount.CombinedOutput()
-	outputCountStr := string(outputCount)
-
-	// If there was an error in the first run, return it
-	if err != nil {
-		return 0.0, 0, fmt.Errorf("%v: %s", err, outputCountStr)
+	if *baselinePath != "" && !compareBaseline(summary, *baselinePath, *deltaMarkdownPath, config.MaxCoverageDrop, partial) {
+		coverageOK = false
 	}
 
-	// Extract statement count
-	statements := extractStatementCount(outputCountStr)
-
-	// Now run with coverage profile
-	cmd := execCommand("bash", "-c", fmt.Sprintf("cd %s && go test -cover -coverprofile=../../%s", testPath, outputPath))
-	output, cmdErr := cmd.CombinedOutput()
-	outputStr := string(output)
-
-	// Extract coverage percentage
-	coverageStr := extractCoveragePercentage(outputStr)
-	coverage := parseCoveragePercentage(coverageStr)
-
-	// If we couldn't get statement count from first run, try from second run
-	if statements == 0 {
-		statements = extractStatementCount(outputStr)
+	// Exit with error if any test failed or coverage dropped too far
+	if errorCount > 0 || !coverageOK {
+		os.Exit(1)
 	}
+}
 
-	// If we still don't have statement count, get it from the coverage file
-	if statements == 0 && fileExists(outputPath) {
-		statements = countLinesInFile(outputPath) - 1 // Subtract 1 for the header line
+// checkOutputFiles refuses coverage groups that write the same profile, as
+// the later group would overwrite the earlier one's coverage
+func checkOutputFiles(groups []CoverageGroup) error {
+	owners := make(map[string]string)
+	var collisions []string
+	for _, group := range groups {
+		key := filepath.Clean(group.OutputFile)
+		if owner, ok := owners[key]; ok {
+			collisions = append(collisions, fmt.Sprintf("%q and %q both write %s", owner, group.Name, group.OutputFile))
+			continue
+		}
+		owners[key] = group.Name
 	}
-
-	// If statements is still 0, set it to 1 to avoid division by zero
-	if statements == 0 {
-		statements = 1
+	if len(collisions) > 0 {
+		return fmt.Errorf("coverage_groups output file collision: %s", strings.Join(collisions, "; "))
 	}
-
-	return coverage, statements, cmdErr
+	return nil
 }
 
-func extractCoveragePercentage(output string) string {
-	// Look for coverage: XX.X% of statements
-	lines := strings.Split(output, "\n")
-	for _, line := range lines {
-		if strings.Contains(line, "coverage:") {
-			parts := strings.Fields(line)
-			for _, part := range parts {
-				if strings.HasSuffix(part, "%") {
-					return part
-				}
-			}
+// writeResults writes the requested result files and reports whether all
+// of them were written
+func writeResults(junitPath, resultsJSONPath string, report ResultsReport) bool {
+	ok := true
+	if junitPath != "" {
+		if err := writeJUnit(junitPath, "go-unit-test", report); err != nil {
+			fmt.Fprintf(stderr, "Error writing JUnit report %s: %v\n", junitPath, err)
+			ok = false
 		}
 	}
-	return "0.0%"
-}
-
-func parseCoveragePercentage(coverageStr string) float64 {
-	// Remove % sign and convert to float
-	coverageStr = strings.TrimSuffix(coverageStr, "%")
-	var coverage float64
-	fmt.Sscanf(coverageStr, "%f", &coverage)
-	return coverage
-}
-
-func extractStatementCount(output string) int {
-	// Look for "of X statements"
-	re := regexp.MustCompile(`of (\d+) statements`)
-	matches := re.FindStringSubmatch(output)
-	if len(matches) > 1 {
-		count, err := strconv.Atoi(matches[1])
-		if err == nil {
-			return count
+	if resultsJSONPath != "" {
+		if err := writeResultsJSON(resultsJSONPath, report); err != nil {
+			fmt.Fprintf(stderr, "Error writing test results %s: %v\n", resultsJSONPath, err)
+			ok = false
 		}
 	}
-	return 0
+	return ok
 }
 
 func fileExists(path string) bool {
 	_, err := os.Stat(path)
 	return err == nil
 }
-
-func countLinesInFile(path string) int {
-	data, err := os.ReadFile(path)
-	if err != nil {
-		return 0
-	}
-	return len(strings.Split(string(data), "\n"))
-}
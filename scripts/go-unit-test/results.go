@@ -0,0 +1,409 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Test statuses
+const (
+	StatusPass = "pass"
+	StatusFail = "fail"
+	StatusSkip = "skip"
+)
+
+// TestEvent is one line of `go test -json` output (see `go doc test2json`)
+type TestEvent struct {
+	Action      string  `json:"Action"`
+	Package     string  `json:"Package"`
+	ImportPath  string  `json:"ImportPath"` // Set on build-output and build-fail events
+	Test        string  `json:"Test"`
+	Elapsed     float64 `json:"Elapsed"`
+	Output      string  `json:"Output"`
+	FailedBuild string  `json:"FailedBuild"` // ImportPath of the build that made the package fail
+}
+
+// ResultCounts holds the number of tests per outcome
+type ResultCounts struct {
+	Tests       int `json:"tests"`
+	Passed      int `json:"passed"`
+	Failed      int `json:"failed"`
+	Errors      int `json:"errors"` // Packages that failed outside of any test, e.g. to build
+	Skipped     int `json:"skipped"`
+	Flaky       int `json:"flaky"`       // Passed on a retry, also counted as passed
+	Quarantined int `json:"quarantined"` // Failed but quarantined, not counted as failed
+}
+
+// add counts one test with the given status
+func (c *ResultCounts) add(status string) {
+	c.Tests++
+	switch status {
+	case StatusPass:
+		c.Passed++
+	case StatusFail:
+		c.Failed++
+	case StatusSkip:
+		c.Skipped++
+	}
+}
+
+// ResultsReport is the --results-json document
+type ResultsReport struct {
+	ResultCounts
+	Duration float64       `json:"duration"`
+	Suites   []SuiteReport `json:"suites"`
+}
+
+// SuiteReport holds the results of one Go package
+type SuiteReport struct {
+	Name  string `json:"name"`  // Package import path
+	Group string `json:"group"` // Coverage group the package belongs to
+	ResultCounts
+	Duration float64      `json:"duration"`
+	Error    string       `json:"error,omitempty"` // Build or package failure outside of any test
+	Cases    []CaseReport `json:"cases"`
+}
+
+// CaseReport holds the result of one test or subtest
+type CaseReport struct {
+	Classname   string  `json:"classname"` // Package import path
+	Name        string  `json:"name"`      // Test name, with subtests as Parent/child
+	Status      string  `json:"status"`
+	Duration    float64 `json:"duration"`
+	Output      string  `json:"output,omitempty"`
+	Attempts    int     `json:"attempts,omitempty"`    // Runs including retries, when retried
+	Flaky       bool    `json:"flaky,omitempty"`       // Failed, then passed on a retry
+	Quarantined bool    `json:"quarantined,omitempty"` // Failed, but in the quarantine list
+}
+
+// recount recomputes the counts of a suite from its cases, after retries
+// or the quarantine changed them
+func (s *SuiteReport) recount() {
+	counts := ResultCounts{Errors: s.Errors}
+	for _, c := range s.Cases {
+		if c.Quarantined {
+			counts.Tests++
+			counts.Quarantined++
+			continue
+		}
+		counts.add(c.Status)
+		if c.Flaky {
+			counts.Flaky++
+		}
+	}
+	s.ResultCounts = counts
+}
+
+// testEventWriter decodes `go test -json` output as it is written. The
+// plain test output is collected and echoed, and the events are collected
+// into per-package results. Lines that are not JSON, such as errors from
+// the go command itself, are treated as plain output.
+type testEventWriter struct {
+	group    string
+	echo     io.Writer
+	text     bytes.Buffer
+	partial  []byte
+	suites   []*SuiteReport
+	byName   map[string]*SuiteReport
+	cases    map[string]int // Index into the package's Cases
+	pkgOut   map[string]*strings.Builder
+	buildOut map[string]*strings.Builder
+}
+
+func newTestEventWriter(group string, echo io.Writer) *testEventWriter {
+	return &testEventWriter{
+		group:    group,
+		echo:     echo,
+		byName:   make(map[string]*SuiteReport),
+		cases:    make(map[string]int),
+		pkgOut:   make(map[string]*strings.Builder),
+		buildOut: make(map[string]*strings.Builder),
+	}
+}
+
+func (w *testEventWriter) Write(p []byte) (int, error) {
+	w.partial = append(w.partial, p...)
+	for {
+		i := bytes.IndexByte(w.partial, '\n')
+		if i < 0 {
+			break
+		}
+		w.handleLine(w.partial[:i+1])
+		w.partial = w.partial[i+1:]
+	}
+	return len(p), nil
+}
+
+// Close handles a final line without a newline
+func (w *testEventWriter) Close() error {
+	if len(w.partial) > 0 {
+		w.handleLine(append(w.partial, '\n'))
+		w.partial = nil
+	}
+	return nil
+}
+
+// Output returns the plain text output, as `go test -v` would print it
+func (w *testEventWriter) Output() string {
+	return w.text.String()
+}
+
+// Suites returns the package results in the order the packages started
+func (w *testEventWriter) Suites() []SuiteReport {
+	suites := make([]SuiteReport, 0, len(w.suites))
+	for _, suite := range w.suites {
+		suites = append(suites, *suite)
+	}
+	return suites
+}
+
+func (w *testEventWriter) writeText(s string) {
+	w.text.WriteString(s)
+	if w.echo != nil {
+		io.WriteString(w.echo, s)
+	}
+}
+
+func (w *testEventWriter) handleLine(line []byte) {
+	var event TestEvent
+	if len(bytes.TrimSpace(line)) == 0 || line[0] != '{' || json.Unmarshal(line, &event) != nil {
+		w.writeText(string(line))
+		return
+	}
+
+	if event.Action == "build-output" || event.Action == "build-fail" {
+		if event.Output != "" {
+			w.writeText(event.Output)
+			w.builder(w.buildOut, event.ImportPath).WriteString(event.Output)
+		}
+		return
+	}
+
+	suite := w.suite(event.Package)
+	if event.Output != "" {
+		w.writeText(event.Output)
+	}
+
+	if event.Test == "" {
+		switch event.Action {
+		case "output":
+			w.builder(w.pkgOut, event.Package).WriteString(event.Output)
+		case "pass", "skip":
+			suite.Duration = event.Elapsed
+		case "fail":
+			suite.Duration = event.Elapsed
+			if suite.Failed == 0 {
+				suite.Error = w.failureOutput(event)
+				suite.Errors = 1
+			}
+		}
+		return
+	}
+
+	key := event.Package + "\x00" + event.Test
+	i, ok := w.cases[key]
+	if !ok {
+		suite.Cases = append(suite.Cases, CaseReport{Classname: event.Package, Name: event.Test})
+		i = len(suite.Cases) - 1
+		w.cases[key] = i
+	}
+	c := &suite.Cases[i]
+	switch event.Action {
+	case "output":
+		c.Output += event.Output
+	case "pass", "fail", "skip":
+		c.Status = event.Action
+		c.Duration = event.Elapsed
+		suite.add(event.Action)
+	}
+}
+
+// suite returns the results of a package, creating them on first use
+func (w *testEventWriter) suite(pkg string) *SuiteReport {
+	if suite, ok := w.byName[pkg]; ok {
+		return suite
+	}
+	suite := &SuiteReport{Name: pkg, Group: w.group, Cases: []CaseReport{}}
+	w.byName[pkg] = suite
+	w.suites = append(w.suites, suite)
+	return suite
+}
+
+// failureOutput explains a package failure that no test accounts for,
+// usually a build error
+func (w *testEventWriter) failureOutput(event TestEvent) string {
+	var out string
+	if b, ok := w.buildOut[event.FailedBuild]; ok {
+		out += b.String()
+	}
+	if b, ok := w.pkgOut[event.Package]; ok {
+		out += b.String()
+	}
+	if out = strings.TrimSpace(out); out == "" {
+		out = "package failed"
+	}
+	return out
+}
+
+func (w *testEventWriter) builder(m map[string]*strings.Builder, key string) *strings.Builder {
+	b, ok := m[key]
+	if !ok {
+		b = &strings.Builder{}
+		m[key] = b
+	}
+	return b
+}
+
+// groupFailure reports a coverage group that failed before or outside of
+// any package, as a failed package named after the test path
+func groupFailure(group, testPath, message string) SuiteReport {
+	return SuiteReport{Name: testPath, Group: group, ResultCounts: ResultCounts{Errors: 1}, Error: message, Cases: []CaseReport{}}
+}
+
+// buildResultsReport totals the package results of all groups
+func buildResultsReport(suites []SuiteReport) ResultsReport {
+	report := ResultsReport{Suites: suites}
+	if report.Suites == nil {
+		report.Suites = []SuiteReport{}
+	}
+	for _, suite := range suites {
+		report.Tests += suite.Tests
+		report.Passed += suite.Passed
+		report.Failed += suite.Failed
+		report.Skipped += suite.Skipped
+		report.Flaky += suite.Flaky
+		report.Quarantined += suite.Quarantined
+		report.Errors += suite.Errors
+		report.Duration += suite.Duration
+	}
+	return report
+}
+
+// writeResultsJSON writes the report as indented JSON
+func writeResultsJSON(path string, report ResultsReport) error {
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error marshaling test results: %w", err)
+	}
+	return writeReportFile(path, data)
+}
+
+// writeReportFile writes a report, creating its directory if needed
+func writeReportFile(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Errors   int              `xml:"errors,attr"`
+	Skipped  int              `xml:"skipped,attr"`
+	Time     string           `xml:"time,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name     string          `xml:"name,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Errors   int             `xml:"errors,attr"`
+	Skipped  int             `xml:"skipped,attr"`
+	Time     string          `xml:"time,attr"`
+	Cases    []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Classname string        `xml:"classname,attr"`
+	Name      string        `xml:"name,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Error     *junitMessage `xml:"error,omitempty"`
+	Skipped   *junitMessage `xml:"skipped,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr,omitempty"`
+	Body    string `xml:",chardata"`
+}
+
+// newJUnitReport converts a report into JUnit XML elements. A package that
+// failed outside of any test is reported as a single errored test named
+// after the package.
+func newJUnitReport(name string, report ResultsReport) junitTestSuites {
+	junit := junitTestSuites{Name: name, Time: junitTime(report.Duration)}
+	for _, suite := range report.Suites {
+		js := junitTestSuite{
+			Name:     suite.Name,
+			Tests:    suite.Tests,
+			Failures: suite.Failed,
+			Errors:   suite.Errors,
+			Skipped:  suite.Skipped + suite.Quarantined,
+			Time:     junitTime(suite.Duration),
+		}
+		for _, c := range suite.Cases {
+			jc := junitTestCase{Classname: c.Classname, Name: c.Name, Time: junitTime(c.Duration)}
+			switch {
+			case c.Quarantined:
+				// Reported, without failing the consumers of the report
+				jc.Skipped = &junitMessage{Message: "quarantined test failed"}
+			case c.Status == StatusFail:
+				jc.Failure = &junitMessage{Message: "test failed", Body: c.Output}
+			case c.Status == StatusSkip:
+				jc.Skipped = &junitMessage{}
+			}
+			if jc.Failure == nil {
+				jc.SystemOut = c.Output
+			}
+			js.Cases = append(js.Cases, jc)
+		}
+		if suite.Error != "" {
+			js.Tests++
+			js.Cases = append(js.Cases, junitTestCase{
+				Classname: suite.Name,
+				Name:      suite.Name,
+				Time:      junitTime(suite.Duration),
+				Error:     &junitMessage{Message: firstLine(suite.Error), Body: suite.Error},
+			})
+		}
+		junit.Tests += js.Tests
+		junit.Failures += js.Failures
+		junit.Errors += js.Errors
+		junit.Skipped += js.Skipped
+		junit.Suites = append(junit.Suites, js)
+	}
+	return junit
+}
+
+// writeJUnit writes the report as a JUnit XML file
+func writeJUnit(path, name string, report ResultsReport) error {
+	data, err := xml.MarshalIndent(newJUnitReport(name, report), "", "  ")
+	if err != nil {
+		return fmt.Errorf("error marshaling JUnit report: %w", err)
+	}
+	return writeReportFile(path, append([]byte(xml.Header), data...))
+}
+
+// junitTime formats seconds the way JUnit consumers expect
+func junitTime(seconds float64) string {
+	return fmt.Sprintf("%.3f", seconds)
+}
+
+// firstLine returns the first line of s
+func firstLine(s string) string {
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		return s[:i]
+	}
+	return s
+}
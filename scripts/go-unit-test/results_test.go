@@ -0,0 +1,136 @@
+package main
+
+import (
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testEventStream = `{"Action":"start","Package":"example/ok"}
+{"Action":"run","Package":"example/ok","Test":"TestPass"}
+{"Action":"output","Package":"example/ok","Test":"TestPass","Output":"=== RUN   TestPass\n"}
+{"Action":"output","Package":"example/ok","Test":"TestPass","Output":"--- PASS: TestPass (0.01s)\n"}
+{"Action":"pass","Package":"example/ok","Test":"TestPass","Elapsed":0.01}
+{"Action":"run","Package":"example/ok","Test":"TestFail"}
+{"Action":"run","Package":"example/ok","Test":"TestFail/sub"}
+{"Action":"output","Package":"example/ok","Test":"TestFail/sub","Output":"    ok_test.go:12: boom\n"}
+{"Action":"fail","Package":"example/ok","Test":"TestFail/sub","Elapsed":0.02}
+{"Action":"fail","Package":"example/ok","Test":"TestFail","Elapsed":0.03}
+{"Action":"run","Package":"example/ok","Test":"TestSkip"}
+{"Action":"skip","Package":"example/ok","Test":"TestSkip","Elapsed":0}
+{"Action":"output","Package":"example/ok","Output":"FAIL\n"}
+{"Action":"fail","Package":"example/ok","Elapsed":0.5}
+{"ImportPath":"example/broken [example/broken.test]","Action":"build-output","Output":"./b_test.go:3:2: undefined: x\n"}
+{"ImportPath":"example/broken [example/broken.test]","Action":"build-fail"}
+{"Action":"start","Package":"example/broken"}
+{"Action":"output","Package":"example/broken","Output":"FAIL\texample/broken [build failed]\n"}
+{"Action":"fail","Package":"example/broken","Elapsed":0,"FailedBuild":"example/broken [example/broken.test]"}
+go: warning: not JSON
+`
+
+func TestTestEventWriter(t *testing.T) {
+	var echo strings.Builder
+	w := newTestEventWriter("Example", &echo)
+
+	// Write in uneven chunks, as the go command does
+	for i := 0; i < len(testEventStream); i += 37 {
+		end := i + 37
+		if end > len(testEventStream) {
+			end = len(testEventStream)
+		}
+		w.Write([]byte(testEventStream[i:end]))
+	}
+	w.Close()
+
+	if !strings.Contains(w.Output(), "    ok_test.go:12: boom\n") || !strings.HasSuffix(w.Output(), "go: warning: not JSON\n") {
+		t.Errorf("Unexpected text output:\n%s", w.Output())
+	}
+	if echo.String() != w.Output() {
+		t.Errorf("Expected echoed output to match collected output")
+	}
+
+	suites := w.Suites()
+	if len(suites) != 2 {
+		t.Fatalf("Expected 2 packages, got %d", len(suites))
+	}
+
+	ok := suites[0]
+	expected := ResultCounts{Tests: 4, Passed: 1, Failed: 2, Skipped: 1}
+	if ok.Name != "example/ok" || ok.Group != "Example" || ok.ResultCounts != expected || ok.Error != "" {
+		t.Errorf("Unexpected results for example/ok: %+v", ok)
+	}
+	if ok.Duration != 0.5 {
+		t.Errorf("Expected package duration 0.5, got %v", ok.Duration)
+	}
+	names := make([]string, 0, len(ok.Cases))
+	for _, c := range ok.Cases {
+		names = append(names, c.Name+":"+c.Status)
+	}
+	if got := strings.Join(names, ","); got != "TestPass:pass,TestFail:fail,TestFail/sub:fail,TestSkip:skip" {
+		t.Errorf("Unexpected cases %s", got)
+	}
+	if ok.Cases[2].Output != "    ok_test.go:12: boom\n" || ok.Cases[2].Duration != 0.02 {
+		t.Errorf("Unexpected subtest result %+v", ok.Cases[2])
+	}
+
+	broken := suites[1]
+	if broken.Errors != 1 || !strings.Contains(broken.Error, "undefined: x") || !strings.Contains(broken.Error, "[build failed]") {
+		t.Errorf("Expected build failure with compiler output, got %+v", broken)
+	}
+}
+
+func TestBuildResultsReport(t *testing.T) {
+	report := buildResultsReport([]SuiteReport{
+		{Name: "a", ResultCounts: ResultCounts{Tests: 2, Passed: 1, Failed: 1}, Duration: 1},
+		groupFailure("B", "./scripts/b", "directory ./scripts/b does not exist"),
+	})
+
+	expected := ResultCounts{Tests: 2, Passed: 1, Failed: 1, Errors: 1}
+	if report.ResultCounts != expected || report.Duration != 1 {
+		t.Errorf("Unexpected report totals %+v", report)
+	}
+	if report := buildResultsReport(nil); report.Suites == nil {
+		t.Errorf("Expected an empty suite list rather than null")
+	}
+}
+
+func TestWriteResults(t *testing.T) {
+	dir := t.TempDir()
+	w := newTestEventWriter("Example", nil)
+	w.Write([]byte(testEventStream))
+	report := buildResultsReport(w.Suites())
+
+	junitPath := filepath.Join(dir, "reports", "junit.xml")
+	jsonPath := filepath.Join(dir, "reports", "results.json")
+	if !writeResults(junitPath, jsonPath, report) {
+		t.Fatalf("Expected results to be written")
+	}
+	if _, err := os.Stat(jsonPath); err != nil {
+		t.Errorf("Expected results JSON to be written: %v", err)
+	}
+
+	data, err := os.ReadFile(junitPath)
+	if err != nil {
+		t.Fatalf("Failed to read JUnit report: %v", err)
+	}
+	var junit junitTestSuites
+	if err := xml.Unmarshal(data, &junit); err != nil {
+		t.Fatalf("Failed to parse JUnit report: %v", err)
+	}
+	// The build failure adds one errored test
+	if junit.Tests != 5 || junit.Failures != 2 || junit.Errors != 1 || junit.Skipped != 1 {
+		t.Errorf("Unexpected JUnit totals %+v", junit)
+	}
+	if c := junit.Suites[0].Cases[2]; c.Failure == nil || !strings.Contains(c.Failure.Body, "boom") || c.SystemOut != "" {
+		t.Errorf("Expected failure with output, got %+v", c)
+	}
+	if c := junit.Suites[1].Cases[0]; c.Error == nil || c.Name != "example/broken" {
+		t.Errorf("Expected errored test for the build failure, got %+v", c)
+	}
+
+	if writeResults(filepath.Join(junitPath, "junit.xml"), "", report) {
+		t.Errorf("Expected writing below a file to fail")
+	}
+}
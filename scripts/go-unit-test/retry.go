@@ -0,0 +1,224 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// QuarantinedTest is a known flaky test whose failures do not fail the run
+type QuarantinedTest struct {
+	Test    string `json:"test"`    // Test name, matching its subtests too
+	Package string `json:"package"` // Package import path, any package if empty
+	Reason  string `json:"reason"`  // Why the test is quarantined, such as an issue link
+}
+
+// matches reports whether the quarantine entry covers a test of a package
+func (q QuarantinedTest) matches(pkg, test string) bool {
+	if q.Package != "" && q.Package != pkg {
+		return false
+	}
+	return test == q.Test || strings.HasPrefix(test, q.Test+"/")
+}
+
+// onlyTestFailures reports whether the suites failed because of failing
+// tests alone, which a retry or the quarantine can explain
+func onlyTestFailures(suites []SuiteReport) bool {
+	failed := false
+	for _, suite := range suites {
+		if suite.Errors > 0 {
+			return false
+		}
+		if suite.Failed > 0 {
+			failed = true
+		}
+	}
+	return failed
+}
+
+// hasFailures reports whether a suite still has failing tests or errors
+func hasFailures(suites []SuiteReport) bool {
+	for _, suite := range suites {
+		if suite.Failed > 0 || suite.Errors > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// quarantineFailures marks the failed tests covered by the quarantine. A
+// parent test whose failed subtests are all quarantined is quarantined too,
+// as it only failed because of them.
+func quarantineFailures(suites []SuiteReport, quarantine []QuarantinedTest, out io.Writer) {
+	if len(quarantine) == 0 {
+		return
+	}
+	for s := range suites {
+		suite := &suites[s]
+		reasons := make(map[string]string)
+		for i := range suite.Cases {
+			c := &suite.Cases[i]
+			if c.Status != StatusFail {
+				continue
+			}
+			for _, q := range quarantine {
+				if q.matches(suite.Name, c.Name) {
+					c.Quarantined = true
+					reasons[c.Name] = q.Reason
+					break
+				}
+			}
+		}
+
+		// Deepest subtests first, so a parent sees its children settled
+		order := make([]int, len(suite.Cases))
+		for i := range order {
+			order[i] = i
+		}
+		sort.SliceStable(order, func(a, b int) bool {
+			return strings.Count(suite.Cases[order[a]].Name, "/") > strings.Count(suite.Cases[order[b]].Name, "/")
+		})
+		for _, i := range order {
+			c := &suite.Cases[i]
+			if c.Status != StatusFail || c.Quarantined {
+				continue
+			}
+			children, quarantined := 0, 0
+			for _, child := range suite.Cases {
+				if child.Status == StatusFail && isChild(c.Name, child.Name) {
+					children++
+					if child.Quarantined {
+						quarantined++
+					}
+				}
+			}
+			if children > 0 && children == quarantined {
+				c.Quarantined = true
+			}
+		}
+
+		for _, c := range suite.Cases {
+			if c.Quarantined && !strings.Contains(c.Name, "/") {
+				fmt.Fprintf(out, "⚠️  Quarantined test %s.%s failed, ignoring", suite.Name, c.Name)
+				if reason := reasons[c.Name]; reason != "" {
+					fmt.Fprintf(out, " (%s)", reason)
+				}
+				fmt.Fprintln(out)
+			}
+		}
+		suite.recount()
+	}
+}
+
+// isChild reports whether child is a direct subtest of parent
+func isChild(parent, child string) bool {
+	rest, ok := strings.CutPrefix(child, parent+"/")
+	return ok && !strings.Contains(rest, "/")
+}
+
+// retryFailures re-runs the failed top-level tests of each package, up to
+// opts.Retries times, merging the results. Tests that pass on a retry are
+// marked as flaky.
+func retryFailures(ctx context.Context, group CoverageGroup, opts runOptions, suites []SuiteReport, out io.Writer) {
+	var echo io.Writer
+	if !opts.Coverage {
+		echo = out
+	}
+	for s := range suites {
+		suite := &suites[s]
+		for attempt := 1; attempt <= opts.Retries; attempt++ {
+			tests := failedTests(*suite)
+			if len(tests) == 0 || ctx.Err() != nil {
+				break
+			}
+			fmt.Fprintf(out, "🔁 Retrying %d failed tests in %s (retry %d of %d): %s\n", len(tests), suite.Name, attempt, opts.Retries, strings.Join(tests, ", "))
+
+			retried, _, _ := runGoTestJSON(ctx, group.Name, group.TestPath, opts.Timeout, echo, "-count=1", "-run", runPattern(tests), suite.Name)
+			if !mergeRetry(suite, retried, attempt+1) {
+				fmt.Fprintf(out, "❌ Retry of %s did not run its tests, giving up\n", suite.Name)
+				break
+			}
+		}
+
+		for _, c := range suite.Cases {
+			if c.Flaky && !strings.Contains(c.Name, "/") {
+				fmt.Fprintf(out, "⚠️  Flaky test %s.%s passed on attempt %d\n", suite.Name, c.Name, c.Attempts)
+			}
+		}
+	}
+}
+
+// failedTests returns the top-level tests with a failure that is not
+// quarantined, in the order they ran
+func failedTests(suite SuiteReport) []string {
+	seen := make(map[string]bool)
+	var tests []string
+	for _, c := range suite.Cases {
+		if c.Status != StatusFail || c.Quarantined {
+			continue
+		}
+		name, _, _ := strings.Cut(c.Name, "/")
+		if !seen[name] {
+			seen[name] = true
+			tests = append(tests, name)
+		}
+	}
+	return tests
+}
+
+// runPattern returns a -run pattern matching exactly the given top-level
+// tests, and with them all of their subtests
+func runPattern(tests []string) string {
+	quoted := make([]string, len(tests))
+	for i, test := range tests {
+		quoted[i] = regexp.QuoteMeta(test)
+	}
+	return "^(" + strings.Join(quoted, "|") + ")$"
+}
+
+// mergeRetry updates the failed cases of a suite with the results of a
+// retry, the given attempt. It reports whether the retry ran the package.
+func mergeRetry(suite *SuiteReport, retried []SuiteReport, attempt int) bool {
+	var result *SuiteReport
+	for i := range retried {
+		if retried[i].Name == suite.Name {
+			result = &retried[i]
+		}
+	}
+	if result == nil || result.Errors > 0 {
+		return false
+	}
+
+	index := make(map[string]int, len(suite.Cases))
+	for i, c := range suite.Cases {
+		index[c.Name] = i
+	}
+	for _, r := range result.Cases {
+		i, ok := index[r.Name]
+		if !ok {
+			// A subtest that did not run the first time
+			r.Attempts = attempt
+			suite.Cases = append(suite.Cases, r)
+			continue
+		}
+		c := &suite.Cases[i]
+		if c.Status != StatusFail {
+			continue
+		}
+		c.Attempts = attempt
+		c.Duration = r.Duration
+		if r.Status == StatusFail {
+			// Keep the latest failure
+			c.Output = r.Output
+			continue
+		}
+		// The output of the failed attempt explains the flakiness
+		c.Status = r.Status
+		c.Flaky = r.Status == StatusPass
+	}
+	suite.recount()
+	return true
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunPattern(t *testing.T) {
+	if got := runPattern([]string{"TestA", "TestB.x"}); got != `^(TestA|TestB\.x)$` {
+		t.Errorf("Unexpected pattern %q", got)
+	}
+}
+
+func TestQuarantineFailures(t *testing.T) {
+	suites := []SuiteReport{{
+		Name: "example/ok",
+		Cases: []CaseReport{
+			{Name: "TestFlaky", Status: StatusFail},
+			{Name: "TestParent", Status: StatusFail},
+			{Name: "TestParent/known", Status: StatusFail},
+			{Name: "TestParent/fine", Status: StatusPass},
+			{Name: "TestBroken", Status: StatusFail},
+			{Name: "TestOther", Status: StatusFail},
+		},
+	}}
+	suites[0].recount()
+	quarantine := []QuarantinedTest{
+		{Test: "TestFlaky", Reason: "network"},
+		{Test: "TestParent/known", Package: "example/ok"},
+		{Test: "TestOther", Package: "example/other"},
+	}
+
+	var out strings.Builder
+	quarantineFailures(suites, quarantine, &out)
+	quarantined := map[string]bool{}
+	for _, c := range suites[0].Cases {
+		quarantined[c.Name] = c.Quarantined
+	}
+	expected := map[string]bool{"TestFlaky": true, "TestParent": true, "TestParent/known": true, "TestParent/fine": false, "TestBroken": false, "TestOther": false}
+	for name, want := range expected {
+		if quarantined[name] != want {
+			t.Errorf("%s: expected quarantined %v, got %v", name, want, quarantined[name])
+		}
+	}
+	if suites[0].Failed != 2 || suites[0].Quarantined != 3 || suites[0].Tests != 6 {
+		t.Errorf("Unexpected counts %+v", suites[0].ResultCounts)
+	}
+	if !strings.Contains(out.String(), "Quarantined test example/ok.TestFlaky failed, ignoring (network)") {
+		t.Errorf("Expected the quarantined failure to be reported, got:\n%s", out.String())
+	}
+
+	// JUnit consumers see quarantined failures as skipped
+	junit := newJUnitReport("go-unit-test", buildResultsReport(suites))
+	if junit.Failures != 2 || junit.Skipped != 3 || junit.Suites[0].Cases[0].Skipped == nil {
+		t.Errorf("Unexpected JUnit report %+v", junit)
+	}
+}
+
+func TestMergeRetry(t *testing.T) {
+	suite := SuiteReport{
+		Name: "example/ok",
+		Cases: []CaseReport{
+			{Name: "TestPass", Status: StatusPass},
+			{Name: "TestFlaky", Status: StatusFail, Output: "first failure\n"},
+			{Name: "TestBroken", Status: StatusFail, Output: "first failure\n"},
+		},
+	}
+	suite.recount()
+	retried := []SuiteReport{{
+		Name: "example/ok",
+		Cases: []CaseReport{
+			{Name: "TestFlaky", Status: StatusPass},
+			{Name: "TestBroken", Status: StatusFail, Output: "second failure\n"},
+		},
+	}}
+
+	if !mergeRetry(&suite, retried, 2) {
+		t.Fatalf("Expected the retry to merge")
+	}
+	flaky, broken := suite.Cases[1], suite.Cases[2]
+	if flaky.Status != StatusPass || !flaky.Flaky || flaky.Attempts != 2 || flaky.Output != "first failure\n" {
+		t.Errorf("Unexpected flaky case %+v", flaky)
+	}
+	if broken.Status != StatusFail || broken.Flaky || broken.Output != "second failure\n" {
+		t.Errorf("Unexpected broken case %+v", broken)
+	}
+	if suite.Passed != 2 || suite.Failed != 1 || suite.Flaky != 1 {
+		t.Errorf("Unexpected counts %+v", suite.ResultCounts)
+	}
+	if got := failedTests(suite); len(got) != 1 || got[0] != "TestBroken" {
+		t.Errorf("Expected TestBroken to remain failed, got %v", got)
+	}
+
+	if mergeRetry(&suite, []SuiteReport{{Name: "example/ok", ResultCounts: ResultCounts{Errors: 1}}}, 3) {
+		t.Errorf("Expected a retry that failed to build not to merge")
+	}
+}
+
+func TestRunGroupsRetries(t *testing.T) {
+	root := t.TempDir()
+	// TestFlaky fails until it has left a marker, TestBroken always fails
+	writeTestModule(t, filepath.Join(root, "flaky"), map[string]string{
+		"flaky_test.go": "package flaky\n\nimport (\n\t\"os\"\n\t\"testing\"\n)\n\n" +
+			"func TestFlaky(t *testing.T) {\n\tif _, err := os.Stat(\"ran\"); err != nil {\n\t\tos.WriteFile(\"ran\", nil, 0644)\n\t\tt.Fatal(\"first run\")\n\t}\n}\n\n" +
+			"func TestStable(t *testing.T) {}\n",
+	})
+	writeTestModule(t, filepath.Join(root, "broken"), map[string]string{
+		"broken_test.go": "package broken\n\nimport \"testing\"\n\nfunc TestBroken(t *testing.T) {\n\tt.Fatal(\"always\")\n}\n",
+	})
+	groups := []CoverageGroup{
+		{Name: "Flaky", TestPath: filepath.Join(root, "flaky")},
+		{Name: "Broken", TestPath: filepath.Join(root, "broken")},
+	}
+
+	var out strings.Builder
+	runs := runGroups(groups, runOptions{Retries: 2, Timeout: time.Minute}, 2, &out)
+	if runs[0].Result.Error != "" {
+		t.Errorf("Expected the flaky group to pass, got %q", runs[0].Result.Error)
+	}
+	if suite := runs[0].Suites[0]; suite.Flaky != 1 || suite.Passed != 2 || suite.Failed != 0 {
+		t.Errorf("Unexpected flaky counts %+v", suite.ResultCounts)
+	}
+	if runs[1].Result.Error == "" || runs[1].Suites[0].Failed != 1 || runs[1].Suites[0].Cases[0].Attempts != 3 {
+		t.Errorf("Expected the broken group to fail after 2 retries, got %+v", runs[1])
+	}
+	for _, want := range []string{"Retrying 1 failed tests", "TestFlaky passed on attempt 2", "retry 2 of 2"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("Expected output to contain %q, got:\n%s", want, out.String())
+		}
+	}
+
+	// The quarantine lets the group pass without retries
+	runs = runGroups(groups[1:], runOptions{Quarantine: []QuarantinedTest{{Test: "TestBroken"}}, Timeout: time.Minute}, 1, &out)
+	if runs[0].Result.Error != "" || runs[0].Suites[0].Quarantined != 1 {
+		t.Errorf("Expected the quarantined failure to pass the group, got %+v", runs[0])
+	}
+}
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// runOptions controls how each coverage group is run
+type runOptions struct {
+	Coverage     bool          // Write a coverage profile per group
+	CoverageText bool          // Print the per-function coverage of each group
+	Timeout      time.Duration // Limit for each group, 0 for none
+	Retries      int           // Times to re-run failed tests
+	Quarantine   []QuarantinedTest
+}
+
+// groupRun is the outcome of one coverage group
+type groupRun struct {
+	Result CoverageResult
+	Suites []SuiteReport
+}
+
+// runGroups runs the coverage groups, at most jobs at a time. The output of
+// each group is buffered and written to out in one piece when the group
+// finishes, so concurrent groups do not interleave. The results keep the
+// order of groups.
+func runGroups(groups []CoverageGroup, opts runOptions, jobs int, out io.Writer) []groupRun {
+	if jobs < 1 {
+		jobs = 1
+	}
+
+	runs := make([]groupRun, len(groups))
+	sem := make(chan struct{}, jobs)
+	var outMu sync.Mutex
+	var wg sync.WaitGroup
+	for i, group := range groups {
+		wg.Add(1)
+		go func(i int, group CoverageGroup) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			var buf bytes.Buffer
+			runs[i] = runGroup(group, opts, &buf)
+
+			outMu.Lock()
+			out.Write(buf.Bytes())
+			outMu.Unlock()
+		}(i, group)
+	}
+	wg.Wait()
+	return runs
+}
+
+// runGroup runs the tests of one group, writing its output to out
+func runGroup(group CoverageGroup, opts runOptions, out io.Writer) groupRun {
+	fmt.Fprintf(out, "%s Running tests for %s...\n", group.Emoji, group.Name)
+
+	ctx := context.Background()
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+
+	run := groupRun{Result: CoverageResult{Name: group.Name}}
+	var err error
+	if opts.Coverage {
+		run.Result.Coverage, run.Result.Statements, run.Suites, err = runTestWithCoverage(ctx, group.Name, group.OutputFile, group.TestPath, opts.Timeout)
+	} else {
+		// Print the output as `go test -v` would
+		run.Suites, err = runTest(ctx, group.Name, group.TestPath, opts.Timeout, out)
+	}
+	// Failures of quarantined tests, and of tests that pass on a retry, do
+	// not fail the group
+	if err != nil && ctx.Err() == nil && onlyTestFailures(run.Suites) {
+		quarantineFailures(run.Suites, opts.Quarantine, out)
+		if opts.Retries > 0 {
+			retryFailures(ctx, group, opts, run.Suites, out)
+		}
+		if !hasFailures(run.Suites) {
+			err = nil
+		}
+	}
+	if err != nil {
+		run.Result.Error = fmt.Sprintf("Error running tests: %v", err)
+		fmt.Fprintf(out, "❌ %s\n", run.Result.Error)
+	}
+
+	// Print the per-function coverage if requested
+	if opts.Coverage && opts.CoverageText {
+		outputPath, _ := filepath.Abs(filepath.Join(coverageDir, group.OutputFile))
+		if fileExists(outputPath) {
+			cmd := execCommandContext(ctx, "go", "tool", "cover", "-func="+outputPath)
+			cmd.Dir = group.TestPath
+			coverageOutput, _ := cmd.CombinedOutput()
+			fmt.Fprintf(out, "\nCoverage for %s:\n%s\n", group.Name, string(coverageOutput))
+		}
+	}
+	return run
+}
+
+func runTest(ctx context.Context, group, testPath string, timeout time.Duration, out io.Writer) ([]SuiteReport, error) {
+	// Check if the directory exists
+	if _, err := os.Stat(testPath); os.IsNotExist(err) {
+		err = fmt.Errorf("directory %s does not exist", testPath)
+		return []SuiteReport{groupFailure(group, testPath, err.Error())}, err
+	}
+
+	suites, _, err := runGoTestJSON(ctx, group, testPath, timeout, out)
+	return suites, err
+}
+
+// runTestWithCoverage runs the tests once, uncached, writing the profile.
+// The coverage and statement count are read from the profile.
+func runTestWithCoverage(ctx context.Context, group, outputFile, testPath string, timeout time.Duration) (float64, int, []SuiteReport, error) {
+	// Check if the directory exists
+	if _, err := os.Stat(testPath); os.IsNotExist(err) {
+		err = fmt.Errorf("directory %s does not exist", testPath)
+		return 0.0, 0, []SuiteReport{groupFailure(group, testPath, err.Error())}, err
+	}
+
+	// The test runs in testPath, so the profile path must be absolute
+	outputPath, err := filepath.Abs(filepath.Join(coverageDir, outputFile))
+	if err != nil {
+		return 0.0, 0, nil, err
+	}
+	os.Remove(outputPath)
+
+	suites, output, err := runGoTestJSON(ctx, group, testPath, timeout, nil, "-count=1", "-coverprofile="+outputPath)
+	if err != nil {
+		err = fmt.Errorf("%v: %s", err, output)
+	}
+
+	coverage, statements, profileErr := profileCoverage(outputPath)
+	if profileErr != nil && !os.IsNotExist(profileErr) && err == nil {
+		err = fmt.Errorf("error reading coverage profile: %v", profileErr)
+	}
+	return coverage, statements, suites, err
+}
+
+// runGoTestJSON runs `go test -json` in testPath and returns the per-package
+// results and the plain text output, which is also echoed to echo if set.
+// A failure that no package accounts for is reported as a failed package
+// named after the test path.
+func runGoTestJSON(ctx context.Context, group, testPath string, timeout time.Duration, echo io.Writer, args ...string) ([]SuiteReport, string, error) {
+	events := newTestEventWriter(group, echo)
+	goArgs := []string{"test", "-json"}
+	if timeout > 0 {
+		// Let the test binary panic with its goroutines before the group is
+		// killed, which explains more than the kill itself
+		goArgs = append(goArgs, "-timeout="+timeout.String())
+	}
+	cmd := execCommandContext(ctx, "go", append(goArgs, args...)...)
+	cmd.Dir = testPath
+	cmd.Stdout = events
+	cmd.Stderr = events
+	// The go command does not pass the kill on to the test binary, which
+	// would keep the output open
+	cmd.WaitDelay = 5 * time.Second
+	err := cmd.Run()
+	events.Close()
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		err = fmt.Errorf("timed out after %s", timeout)
+	}
+
+	suites := events.Suites()
+	if err != nil {
+		accounted := false
+		for _, suite := range suites {
+			if suite.Failed > 0 || suite.Errors > 0 {
+				accounted = true
+			}
+		}
+		if !accounted {
+			message := strings.TrimSpace(events.Output())
+			if message == "" {
+				message = err.Error()
+			} else if ctx.Err() != nil {
+				message = err.Error() + "\n" + message
+			}
+			suites = append(suites, groupFailure(group, testPath, message))
+		}
+	}
+	return suites, events.Output(), err
+}
+
+// profileCoverage returns the statement coverage of a cover profile, as
+// `go tool cover -func` totals it. Blocks listed more than once count once.
+func profileCoverage(path string) (float64, int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer f.Close()
+
+	type block struct {
+		statements int
+		covered    bool
+	}
+	blocks := make(map[string]block)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "mode:") {
+			continue
+		}
+		// file:startLine.startCol,endLine.endCol numStmt count
+		fields := strings.Fields(line)
+		if len(fields) != 3 {
+			return 0, 0, fmt.Errorf("malformed profile line %q", line)
+		}
+		statements, err := strconv.Atoi(fields[1])
+		if err != nil {
+			return 0, 0, fmt.Errorf("malformed profile line %q", line)
+		}
+		count, err := strconv.Atoi(fields[2])
+		if err != nil {
+			return 0, 0, fmt.Errorf("malformed profile line %q", line)
+		}
+		b := blocks[fields[0]]
+		b.statements = statements
+		b.covered = b.covered || count > 0
+		blocks[fields[0]] = b
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, 0, err
+	}
+
+	var covered, total int
+	for _, b := range blocks {
+		total += b.statements
+		if b.covered {
+			covered += b.statements
+		}
+	}
+	if total == 0 {
+		return 0, 0, nil
+	}
+	return float64(covered) / float64(total) * 100, total, nil
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeTestModule creates a module in dir with the given files
+func writeTestModule(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	files["go.mod"] = "module example.com/" + filepath.Base(dir) + "\n\ngo 1.21\n"
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestProfileCoverage(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "c.out")
+	profile := "mode: set\n" +
+		"example.com/a/a.go:3.13,5.2 2 1\n" +
+		"example.com/a/a.go:7.13,9.2 3 0\n" +
+		"example.com/a/a.go:7.13,9.2 3 1\n" +
+		"example.com/a/b.go:1.1,2.2 5 0\n"
+	os.WriteFile(path, []byte(profile), 0644)
+
+	coverage, statements, err := profileCoverage(path)
+	if err != nil {
+		t.Fatalf("profileCoverage() error = %v", err)
+	}
+	if statements != 10 || coverage != 50 {
+		t.Errorf("Expected 50%% of 10 statements, got %.1f%% of %d", coverage, statements)
+	}
+
+	os.WriteFile(path, []byte("mode: set\nbroken\n"), 0644)
+	if _, _, err := profileCoverage(path); err == nil {
+		t.Errorf("Expected an error for a malformed profile")
+	}
+}
+
+func TestRunGroups(t *testing.T) {
+	root := t.TempDir()
+	writeTestModule(t, filepath.Join(root, "pass"), map[string]string{
+		"pass.go":      "package pass\n\nfunc Add(a, b int) int {\n\treturn a + b\n}\n\nfunc Sub(a, b int) int {\n\treturn a - b\n}\n",
+		"pass_test.go": "package pass\n\nimport \"testing\"\n\nfunc TestAdd(t *testing.T) {\n\tif Add(1, 2) != 3 {\n\t\tt.Fatal(\"add\")\n\t}\n}\n",
+	})
+	writeTestModule(t, filepath.Join(root, "fail"), map[string]string{
+		"fail_test.go": "package fail\n\nimport \"testing\"\n\nfunc TestFail(t *testing.T) {\n\tt.Fatal(\"boom\")\n}\n",
+	})
+
+	oldCoverageDir := coverageDir
+	coverageDir = filepath.Join(root, "coverage")
+	defer func() { coverageDir = oldCoverageDir }()
+	os.MkdirAll(coverageDir, 0755)
+
+	groups := []CoverageGroup{
+		{Name: "Pass", Emoji: "✅", OutputFile: "pass.out", TestPath: filepath.Join(root, "pass")},
+		{Name: "Fail", Emoji: "❌", OutputFile: "fail.out", TestPath: filepath.Join(root, "fail")},
+		{Name: "Missing", Emoji: "❓", OutputFile: "missing.out", TestPath: filepath.Join(root, "missing")},
+	}
+
+	var out strings.Builder
+	runs := runGroups(groups, runOptions{Coverage: true, Timeout: time.Minute}, 4, &out)
+	if len(runs) != len(groups) {
+		t.Fatalf("Expected %d results, got %d", len(groups), len(runs))
+	}
+	for i, run := range runs {
+		if run.Result.Name != groups[i].Name {
+			t.Errorf("Result %d: expected %s, got %s", i, groups[i].Name, run.Result.Name)
+		}
+	}
+
+	// Statements and coverage come from the single profile run
+	if pass := runs[0].Result; pass.Error != "" || pass.Statements != 2 || pass.Coverage != 50 {
+		t.Errorf("Unexpected result for Pass: %+v", pass)
+	}
+	if !strings.Contains(runs[1].Result.Error, "boom") || runs[1].Suites[0].Failed != 1 {
+		t.Errorf("Expected Fail to report the failing test, got %+v", runs[1])
+	}
+	if !strings.Contains(runs[2].Result.Error, "does not exist") {
+		t.Errorf("Expected Missing to fail, got %q", runs[2].Result.Error)
+	}
+
+	// Each group's output is written in one piece
+	text := out.String()
+	for _, group := range groups {
+		header := group.Emoji + " Running tests for " + group.Name + "..."
+		start := strings.Index(text, header)
+		if start < 0 {
+			t.Fatalf("Expected output for %s", group.Name)
+		}
+		block := text[start+len(header):]
+		if next := strings.Index(block, " Running tests for "); next >= 0 {
+			block = block[:next]
+		}
+		if !strings.Contains(block, "Error running tests") && group.Name != "Pass" {
+			t.Errorf("Expected the error of %s within its own output, got:\n%s", group.Name, block)
+		}
+	}
+}
+
+func TestRunGroupsNoCoverage(t *testing.T) {
+	root := t.TempDir()
+	writeTestModule(t, filepath.Join(root, "pass"), map[string]string{
+		"pass_test.go": "package pass\n\nimport \"testing\"\n\nfunc TestOK(t *testing.T) {}\n",
+	})
+
+	var out strings.Builder
+	runs := runGroups([]CoverageGroup{{Name: "Pass", Emoji: "✅", TestPath: filepath.Join(root, "pass")}}, runOptions{}, 0, &out)
+	if runs[0].Result.Error != "" || runs[0].Suites[0].Passed != 1 {
+		t.Errorf("Unexpected result %+v", runs[0])
+	}
+	if !strings.Contains(out.String(), "--- PASS: TestOK") {
+		t.Errorf("Expected verbose test output, got:\n%s", out.String())
+	}
+}
+
+func TestRunGroupsTimeout(t *testing.T) {
+	root := t.TempDir()
+	writeTestModule(t, filepath.Join(root, "slow"), map[string]string{
+		"slow_test.go": "package slow\n\nimport (\n\t\"testing\"\n\t\"time\"\n)\n\nfunc TestSlow(t *testing.T) {\n\ttime.Sleep(time.Minute)\n}\n",
+	})
+
+	var out strings.Builder
+	start := time.Now()
+	runs := runGroups([]CoverageGroup{{Name: "Slow", TestPath: filepath.Join(root, "slow")}}, runOptions{Timeout: 3 * time.Second}, 1, &out)
+	if !strings.Contains(runs[0].Result.Error, "timed out after 3s") {
+		t.Errorf("Expected the group to time out, got %q", runs[0].Result.Error)
+	}
+	if elapsed := time.Since(start); elapsed > 30*time.Second {
+		t.Errorf("Expected the group to stop soon after the timeout, took %s", elapsed)
+	}
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"text/tabwriter"
+)
+
+// Tool check statuses
+const (
+	statusOK       = "OK"
+	statusMismatch = "MISMATCH"
+	statusMissing  = "MISSING"
+)
+
+// toolVersion is a tool pinned in .tool-versions
+type toolVersion struct {
+	Name    string
+	Version string
+}
+
+// toolStatus is the result of checking one pinned tool
+type toolStatus struct {
+	toolVersion
+	Installed string // Version found on PATH, "" when missing or unknown
+	Path      string // Binary found on PATH
+	Status    string
+}
+
+// versionCommands are the commands printing the version of each tool, by
+// asdf plugin name. Other tools are checked with `<name> --version`.
+var versionCommands = map[string][]string{
+	"golang":         {"go", "version"},
+	"golangci-lint":  {"golangci-lint", "--version"},
+	"jq":             {"jq", "--version"},
+	"opa":            {"opa", "version"},
+	"pre-commit":     {"pre-commit", "--version"},
+	"python":         {"python", "--version"},
+	"terraform":      {"terraform", "version"},
+	"terraform-docs": {"terraform-docs", "--version"},
+	"tflint":         {"tflint", "--version"},
+	"tfsec":          {"tfsec", "--version"},
+}
+
+// versionPattern matches the first version number in a version command
+// output, such as 1.12.1 in "Terraform v1.12.1"
+var versionPattern = regexp.MustCompile(`\d+\.\d+(\.\d+)?`)
+
+// Replaced by the tests
+var (
+	lookPath         = exec.LookPath
+	toolOutput       = runToolOutput
+	toolVersionsFile = ".tool-versions"
+)
+
+// doctor checks that the tools on PATH have the versions pinned in
+// .tool-versions, prints a table of the results with how to fix them, and
+// returns an error when any tool is missing or has another version
+func doctor() error {
+	content, err := os.ReadFile(toolVersionsFile)
+	if err != nil {
+		return fmt.Errorf("failed to read .tool-versions file: %v", err)
+	}
+
+	fmt.Println("Checking installed tools against .tool-versions...")
+	statuses := checkTools(parseToolVersions(string(content)))
+	printToolStatuses(statuses)
+
+	failed := 0
+	for _, status := range statuses {
+		if status.Status != statusOK {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d tool(s) do not match .tool-versions", failed)
+	}
+	fmt.Println("\n✅ All tools match .tool-versions")
+	return nil
+}
+
+// parseToolVersions returns the tools of a .tool-versions file, with the
+// first version of each when several are listed
+func parseToolVersions(content string) []toolVersion {
+	var tools []toolVersion
+	for _, line := range strings.Split(content, "\n") {
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		parts := strings.Fields(line)
+		if len(parts) < 2 {
+			continue
+		}
+		tools = append(tools, toolVersion{Name: parts[0], Version: parts[1]})
+	}
+	return tools
+}
+
+// checkTools runs the version command of each tool and compares its
+// version with the pinned one
+func checkTools(tools []toolVersion) []toolStatus {
+	var statuses []toolStatus
+	for _, tool := range tools {
+		command, ok := versionCommands[tool.Name]
+		if !ok {
+			command = []string{tool.Name, "--version"}
+		}
+
+		status := toolStatus{toolVersion: tool}
+		path, err := lookPath(command[0])
+		if err != nil {
+			status.Status = statusMissing
+			statuses = append(statuses, status)
+			continue
+		}
+		status.Path = path
+
+		output, err := toolOutput(path, command[1:]...)
+		if err == nil {
+			status.Installed = versionPattern.FindString(output)
+		}
+		switch {
+		case status.Installed == "":
+			status.Status = statusMismatch
+		case tool.Version == "system" || strings.TrimPrefix(tool.Version, "v") == status.Installed:
+			status.Status = statusOK
+		default:
+			status.Status = statusMismatch
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+// printToolStatuses prints a table of the tool checks, followed by how to
+// fix each tool that failed
+func printToolStatuses(statuses []toolStatus) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "\nTOOL\tPINNED\tINSTALLED\tSTATUS")
+	for _, status := range statuses {
+		installed := status.Installed
+		switch {
+		case status.Status == statusMissing:
+			installed = "-"
+		case installed == "":
+			installed = "unknown"
+		}
+		icon := "✅"
+		if status.Status != statusOK {
+			icon = "❌"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s %s\n", status.Name, status.Version, installed, icon, status.Status)
+	}
+	w.Flush()
+
+	var hints []string
+	for _, status := range statuses {
+		if hint := remediationHint(status); hint != "" {
+			hints = append(hints, hint)
+		}
+	}
+	if len(hints) > 0 {
+		fmt.Println("\nTo fix:")
+		for _, hint := range hints {
+			fmt.Printf("  - %s\n", hint)
+		}
+	}
+}
+
+// remediationHint explains how to install the pinned version of a tool
+// that failed the check
+func remediationHint(status toolStatus) string {
+	install := fmt.Sprintf("asdf plugin add %s && asdf install %s %s", status.Name, status.Name, status.Version)
+	switch {
+	case status.Status == statusMissing:
+		return fmt.Sprintf("%s: not found on PATH, run `make install-tools` or `%s`", status.Name, install)
+	case status.Status == statusMismatch && status.Installed == "":
+		return fmt.Sprintf("%s: could not read the version of %s, run `%s`", status.Name, status.Path, install)
+	case status.Status == statusMismatch:
+		return fmt.Sprintf("%s: %s has version %s, run `%s` and make sure ~/.asdf/shims comes first on PATH",
+			status.Name, status.Path, status.Installed, install)
+	}
+	return ""
+}
+
+// runToolOutput runs a version command and returns its combined output
+func runToolOutput(path string, args ...string) (string, error) {
+	output, err := exec.Command(path, args...).CombinedOutput()
+	return string(output), err
+}
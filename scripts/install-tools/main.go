@@ -1,53 +1,135 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"runtime"
 	"strings"
 )
 
 const maxAsdfVersion = "v0.15.0"
 
 func main() {
-	updateOnly := false
+	updateOnly := flag.Bool("update", false, "Only update existing tools, don't perform a full installation")
+	doctorOnly := flag.Bool("doctor", false, "Only check that the installed tools match .tool-versions")
+	requestedVersion := flag.String("asdf-version", maxAsdfVersion, "asdf version to install")
+	native := flag.Bool("native", false, "Install the manifest tools into ./bin instead of using asdf")
+	manifestPath := flag.String("manifest", "tools-manifest.json", "Path to the tool manifest of the native installer")
+	offline := flag.Bool("offline", false, "Never download from the upstream URLs, only use the cache and the mirror")
+	mirror := flag.String("mirror", "", "Directory or http(s) URL to download the tools from, laid out as <name>/<version>/<file>")
+	generate := flag.Bool("generate-manifest", false, "Update the tool manifest from .tool-versions, with the checksums of the downloads")
+	flag.Parse()
+
 	asdfVersion := maxAsdfVersion
+	if compareVersions(*requestedVersion, maxAsdfVersion) <= 0 {
+		asdfVersion = *requestedVersion
+	} else {
+		fmt.Printf("Warning: Requested asdf version %s is higher than maximum allowed %s. Using %s instead.\n",
+			*requestedVersion, maxAsdfVersion, maxAsdfVersion)
+	}
+
+	if *native || *generate {
+		if err := runNative(*manifestPath, *mirror, *offline, *generate); err != nil {
+			fmt.Println("Error:", err)
+			os.Exit(1)
+		}
+		return
+	}
 
-	for _, arg := range os.Args[1:] {
-		if arg == "--update" {
-			updateOnly = true
-		} else if strings.HasPrefix(arg, "--asdf-version=") {
-			requestedVersion := strings.TrimPrefix(arg, "--asdf-version=")
-			if compareVersions(requestedVersion, maxAsdfVersion) <= 0 {
-				asdfVersion = requestedVersion
-			} else {
-				fmt.Printf("Warning: Requested asdf version %s is higher than maximum allowed %s. Using %s instead.\n",
-					requestedVersion, maxAsdfVersion, maxAsdfVersion)
-			}
+	if *doctorOnly {
+		if err := doctor(); err != nil {
+			fmt.Println("Error:", err)
+			os.Exit(1)
 		}
+		return
 	}
 
-	if updateOnly {
-		updateTools()
+	if *updateOnly {
+		if err := updateTools(); err != nil {
+			fmt.Println("Error:", err)
+			os.Exit(1)
+		}
 		return
 	}
 
 	if os.Getenv("DEVCONTAINER") == "true" {
 		fmt.Println("Caylent Devcontainer detected. Tools already installed.")
 		fmt.Println("Running update-tools to ensure everything is up to date...")
-		updateTools()
+		if err := updateTools(); err != nil {
+			fmt.Println("Error:", err)
+			os.Exit(1)
+		}
+		if err := doctor(); err != nil {
+			fmt.Println("Error:", err)
+			os.Exit(1)
+		}
 		return
 	}
 
 	if _, err := exec.LookPath("asdf"); err != nil {
 		fmt.Printf("Installing asdf version %s...\n", asdfVersion)
-		installAsdf(asdfVersion)
+		if err := installAsdf(asdfVersion); err != nil {
+			fmt.Println("Error:", err)
+			os.Exit(1)
+		}
 	} else {
 		fmt.Println("asdf already installed.")
 	}
 
-	installPlugins()
+	if err := installPlugins(); err != nil {
+		fmt.Println("Error:", err)
+		os.Exit(1)
+	}
+}
+
+// runNative installs the tools of the manifest into ./bin, or with
+// generate updates the manifest from .tool-versions
+func runNative(manifestPath, mirror string, offline, generate bool) error {
+	manifest, err := loadManifest(manifestPath)
+	if err != nil {
+		return err
+	}
+	cacheDir, err := toolCacheDir()
+	if err != nil {
+		return err
+	}
+	installer := &nativeInstaller{
+		manifest: manifest,
+		binDir:   "bin",
+		cacheDir: cacheDir,
+		mirror:   mirror,
+		offline:  offline,
+		goos:     runtime.GOOS,
+		goarch:   runtime.GOARCH,
+		client:   httpClient,
+	}
+
+	if generate {
+		if offline && mirror == "" {
+			return fmt.Errorf("--generate-manifest needs to download the tools, pass --mirror with --offline")
+		}
+		content, err := os.ReadFile(toolVersionsFile)
+		if err != nil {
+			return fmt.Errorf("failed to read .tool-versions file: %v", err)
+		}
+		if err := installer.generateManifest(parseToolVersions(string(content))); err != nil {
+			return err
+		}
+		if err := saveManifest(manifestPath, manifest); err != nil {
+			return err
+		}
+		fmt.Printf("✅ Updated %s\n", manifestPath)
+		return nil
+	}
+
+	if err := installer.install(); err != nil {
+		return err
+	}
+	fmt.Println("\nAll manifest tools are installed. Add ./bin to the front of PATH to use them.")
+	return nil
 }
 
 func compareVersions(v1, v2 string) int {
@@ -75,11 +157,10 @@ func compareVersions(v1, v2 string) int {
 	return 0
 }
 
-func installAsdf(version string) {
+func installAsdf(version string) error {
 	homeDir, err := os.UserHomeDir()
 	if err != nil {
-		fmt.Println("Error getting home directory:", err)
-		os.Exit(1)
+		return fmt.Errorf("failed to get home directory: %v", err)
 	}
 
 	asdfDir := filepath.Join(homeDir, ".asdf")
@@ -87,21 +168,20 @@ func installAsdf(version string) {
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 	if err := cmd.Run(); err != nil {
-		fmt.Println("Error cloning asdf repository:", err)
-		os.Exit(1)
+		return fmt.Errorf("failed to clone asdf repository: %v", err)
 	}
 
 	asdfBin := filepath.Join(asdfDir, "bin")
 	asdfShims := filepath.Join(asdfDir, "shims")
 	path := os.Getenv("PATH")
 	os.Setenv("PATH", fmt.Sprintf("%s:%s:%s", asdfBin, asdfShims, path))
+	return nil
 }
 
-func installPlugins() {
+func installPlugins() error {
 	content, err := os.ReadFile(".tool-versions")
 	if err != nil {
-		fmt.Println("Error reading .tool-versions file:", err)
-		os.Exit(1)
+		return fmt.Errorf("failed to read .tool-versions file: %v", err)
 	}
 
 	lines := strings.Split(string(content), "\n")
@@ -127,31 +207,28 @@ func installPlugins() {
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 	if err := cmd.Run(); err != nil {
-		fmt.Println("Error installing tools:", err)
-		os.Exit(1)
+		return fmt.Errorf("failed to install tools: %v", err)
 	}
 
 	cmd = exec.Command("asdf", "reshim")
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 	if err := cmd.Run(); err != nil {
-		fmt.Println("Error reshimming:", err)
-		os.Exit(1)
+		return fmt.Errorf("failed to reshim: %v", err)
 	}
+	return nil
 }
 
-func updateTools() {
+func updateTools() error {
 	if _, err := exec.LookPath("asdf"); err != nil {
-		fmt.Println("asdf not found. Please run 'make install-tools' first.")
-		os.Exit(1)
+		return fmt.Errorf("asdf not found, run 'make install-tools' first")
 	}
 
 	fmt.Println("Checking and updating asdf tools...")
 
 	content, err := os.ReadFile(".tool-versions")
 	if err != nil {
-		fmt.Println("Error reading .tool-versions file:", err)
-		os.Exit(1)
+		return fmt.Errorf("failed to read .tool-versions file: %v", err)
 	}
 
 	lines := strings.Split(string(content), "\n")
@@ -175,17 +252,16 @@ func updateTools() {
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 	if err := cmd.Run(); err != nil {
-		fmt.Println("Error installing tools:", err)
-		os.Exit(1)
+		return fmt.Errorf("failed to install tools: %v", err)
 	}
 
 	cmd = exec.Command("asdf", "reshim")
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 	if err := cmd.Run(); err != nil {
-		fmt.Println("Error reshimming:", err)
-		os.Exit(1)
+		return fmt.Errorf("failed to reshim: %v", err)
 	}
 
 	fmt.Println("All tools are up to date.")
+	return nil
 }
@@ -1,6 +1,11 @@
 package main
 
 import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -33,3 +38,123 @@ func TestCompareVersions(t *testing.T) {
 		})
 	}
 }
+
+func TestParseToolVersions(t *testing.T) {
+	content := `# Pinned tools
+golang 1.23.9
+terraform 1.12.1 # comment
+
+python 3.12.9 3.11.7
+incomplete
+`
+	expected := []toolVersion{
+		{"golang", "1.23.9"},
+		{"terraform", "1.12.1"},
+		{"python", "3.12.9"},
+	}
+
+	tools := parseToolVersions(content)
+	if !reflect.DeepEqual(tools, expected) {
+		t.Errorf("parseToolVersions() = %v, want %v", tools, expected)
+	}
+}
+
+func TestCheckTools(t *testing.T) {
+	outputs := map[string]string{
+		"/bin/go":        "go version go1.23.9 linux/amd64",
+		"/bin/terraform": "Terraform v1.5.7\non linux_amd64",
+		"/bin/opa":       "Version: 1.5.1\nBuild Commit: 123",
+		"/bin/tflint":    "",
+		"/bin/custom":    "custom 2.0",
+	}
+	defer func(l func(string) (string, error), o func(string, ...string) (string, error)) {
+		lookPath, toolOutput = l, o
+	}(lookPath, toolOutput)
+	lookPath = func(name string) (string, error) {
+		if _, ok := outputs["/bin/"+name]; !ok {
+			return "", exec.ErrNotFound
+		}
+		return "/bin/" + name, nil
+	}
+	var commands []string
+	toolOutput = func(path string, args ...string) (string, error) {
+		commands = append(commands, strings.Join(append([]string{path}, args...), " "))
+		return outputs[path], nil
+	}
+
+	statuses := checkTools([]toolVersion{
+		{"golang", "1.23.9"},
+		{"terraform", "1.12.1"},
+		{"opa", "v1.5.1"},
+		{"tflint", "0.58.0"},
+		{"tfsec", "1.28.14"},
+		{"custom", "system"},
+	})
+
+	expected := []toolStatus{
+		{toolVersion{"golang", "1.23.9"}, "1.23.9", "/bin/go", statusOK},
+		{toolVersion{"terraform", "1.12.1"}, "1.5.7", "/bin/terraform", statusMismatch},
+		{toolVersion{"opa", "v1.5.1"}, "1.5.1", "/bin/opa", statusOK},
+		{toolVersion{"tflint", "0.58.0"}, "", "/bin/tflint", statusMismatch},
+		{toolVersion{"tfsec", "1.28.14"}, "", "", statusMissing},
+		{toolVersion{"custom", "system"}, "2.0", "/bin/custom", statusOK},
+	}
+	if !reflect.DeepEqual(statuses, expected) {
+		t.Errorf("checkTools() = %v, want %v", statuses, expected)
+	}
+
+	expectedCommands := []string{"/bin/go version", "/bin/terraform version", "/bin/opa version", "/bin/tflint --version", "/bin/custom --version"}
+	if !reflect.DeepEqual(commands, expectedCommands) {
+		t.Errorf("commands = %v, want %v", commands, expectedCommands)
+	}
+}
+
+func TestRemediationHint(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   toolStatus
+		expected string
+	}{
+		{"OK", toolStatus{toolVersion{"opa", "1.5.1"}, "1.5.1", "/bin/opa", statusOK}, ""},
+		{"Missing", toolStatus{toolVersion{"opa", "1.5.1"}, "", "", statusMissing}, "make install-tools"},
+		{"Unknown version", toolStatus{toolVersion{"opa", "1.5.1"}, "", "/bin/opa", statusMismatch}, "could not read the version of /bin/opa"},
+		{"Mismatch", toolStatus{toolVersion{"opa", "1.5.1"}, "0.58.0", "/bin/opa", statusMismatch}, "asdf install opa 1.5.1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hint := remediationHint(tt.status)
+			if tt.expected == "" && hint != "" || !strings.Contains(hint, tt.expected) {
+				t.Errorf("remediationHint() = %q, want it to contain %q", hint, tt.expected)
+			}
+		})
+	}
+}
+
+func TestDoctor(t *testing.T) {
+	dir := t.TempDir()
+	defer func(l func(string) (string, error), o func(string, ...string) (string, error), f string) {
+		lookPath, toolOutput, toolVersionsFile = l, o, f
+	}(lookPath, toolOutput, toolVersionsFile)
+	lookPath = func(name string) (string, error) { return "/bin/" + name, nil }
+	toolOutput = func(path string, args ...string) (string, error) { return "jq-1.7.1", nil }
+	toolVersionsFile = filepath.Join(dir, ".tool-versions")
+
+	if err := doctor(); err == nil {
+		t.Error("doctor() without .tool-versions succeeded, want an error")
+	}
+
+	if err := os.WriteFile(toolVersionsFile, []byte("jq 1.7.1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := doctor(); err != nil {
+		t.Errorf("doctor() with matching versions failed: %v", err)
+	}
+
+	if err := os.WriteFile(toolVersionsFile, []byte("jq 1.7.1\nopa 1.5.1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := doctor(); err == nil || !strings.Contains(err.Error(), "1 tool(s)") {
+		t.Errorf("doctor() with a mismatch = %v, want 1 tool(s) not matching", err)
+	}
+}
@@ -0,0 +1,361 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Manifest pins the download and checksum of each tool installed by the
+// native installer. It is generated from .tool-versions with
+// `make tools-manifest`.
+type Manifest struct {
+	Platforms []string       `json:"platforms"` // <os>_<arch> pairs to generate checksums for
+	Tools     []ManifestTool `json:"tools"`
+}
+
+// ManifestTool is one tool of the manifest. The URL and Binary templates
+// may use {version}, {os} and {arch}.
+type ManifestTool struct {
+	Name      string            `json:"name"`
+	Version   string            `json:"version"`
+	URL       string            `json:"url"`
+	URLs      map[string]string `json:"urls,omitempty"`       // URL templates of the platforms whose download is named differently
+	Archive   string            `json:"archive,omitempty"`    // "zip", "tar.gz", or empty for a plain binary
+	Binary    string            `json:"binary,omitempty"`     // Path of the binary in the archive
+	OSNames   map[string]string `json:"os_names,omitempty"`   // Names used in the URL instead of GOOS
+	ArchNames map[string]string `json:"arch_names,omitempty"` // Names used in the URL instead of GOARCH
+	SHA256    map[string]string `json:"sha256"`               // Checksums of the downloads by <os>_<arch>
+}
+
+// nativeInstaller installs the manifest tools into binDir, with the
+// downloads kept in a cache addressed by their SHA-256
+type nativeInstaller struct {
+	manifest *Manifest
+	binDir   string
+	cacheDir string
+	mirror   string // Directory or http(s) URL laid out as <name>/<version>/<file>
+	offline  bool   // Never download from the upstream URLs
+	goos     string
+	goarch   string
+	client   *http.Client
+}
+
+var httpClient = &http.Client{Timeout: 5 * time.Minute}
+
+func loadManifest(path string) (*Manifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest: %v", err)
+	}
+
+	var manifest Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse manifest: %v", err)
+	}
+	return &manifest, nil
+}
+
+// toolCacheDir returns the directory of the download cache, below
+// $XDG_CACHE_HOME or ~/.cache
+func toolCacheDir() (string, error) {
+	if dir := os.Getenv("XDG_CACHE_HOME"); dir != "" {
+		return filepath.Join(dir, "terraform-modules", "tools"), nil
+	}
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %v", err)
+	}
+	return filepath.Join(homeDir, ".cache", "terraform-modules", "tools"), nil
+}
+
+// expand fills in the {version}, {os} and {arch} of a template
+func (t ManifestTool) expand(template, goos, goarch string) string {
+	osName, archName := goos, goarch
+	if name, ok := t.OSNames[goos]; ok {
+		osName = name
+	}
+	if name, ok := t.ArchNames[goarch]; ok {
+		archName = name
+	}
+	return strings.NewReplacer("{version}", t.Version, "{os}", osName, "{arch}", archName).Replace(template)
+}
+
+// downloadURL returns the upstream URL of the tool for a platform
+func (t ManifestTool) downloadURL(goos, goarch string) string {
+	if url, ok := t.URLs[goos+"_"+goarch]; ok {
+		return t.expand(url, goos, goarch)
+	}
+	return t.expand(t.URL, goos, goarch)
+}
+
+// mirrorSource returns where a mirror keeps the download of a tool
+func mirrorSource(mirror string, tool ManifestTool, url string) string {
+	file := path.Base(url)
+	if isURL(mirror) {
+		return strings.TrimSuffix(mirror, "/") + "/" + path.Join(tool.Name, tool.Version, file)
+	}
+	return filepath.Join(mirror, tool.Name, tool.Version, file)
+}
+
+// install installs every tool of the manifest, and returns an error
+// naming the tools that failed
+func (n *nativeInstaller) install() error {
+	platform := n.goos + "_" + n.goarch
+	var failed []string
+	for _, tool := range n.manifest.Tools {
+		fmt.Printf("Installing %s %s (%s)...\n", tool.Name, tool.Version, platform)
+		target, err := n.installTool(tool)
+		if err != nil {
+			fmt.Printf("❌ %s: %v\n", tool.Name, err)
+			failed = append(failed, tool.Name)
+			continue
+		}
+		fmt.Printf("✅ %s %s installed to %s\n", tool.Name, tool.Version, target)
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("failed to install %s", strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// installTool verifies the download of a tool and writes its binary to
+// binDir, returning the path of the binary
+func (n *nativeInstaller) installTool(tool ManifestTool) (string, error) {
+	platform := n.goos + "_" + n.goarch
+	sum := strings.ToLower(tool.SHA256[platform])
+	if sum == "" {
+		return "", fmt.Errorf("no SHA-256 for %s in the manifest, run `make tools-manifest`", platform)
+	}
+
+	data, err := n.fetchArtifact(tool, n.goos, n.goarch, sum)
+	if err != nil {
+		return "", err
+	}
+	binary, err := extractBinary(data, tool.Archive, tool.expand(tool.Binary, n.goos, n.goarch))
+	if err != nil {
+		return "", fmt.Errorf("failed to extract %s: %v", tool.Name, err)
+	}
+
+	target := filepath.Join(n.binDir, tool.Name)
+	if err := writeFileAtomic(target, binary, 0755); err != nil {
+		return "", fmt.Errorf("failed to install %s: %v", target, err)
+	}
+	return target, nil
+}
+
+// fetchArtifact returns the verified download of a tool for a platform,
+// from the cache, the mirror or the upstream URL, in that order. Downloads
+// are added to the cache once verified.
+func (n *nativeInstaller) fetchArtifact(tool ManifestTool, goos, goarch, sum string) ([]byte, error) {
+	cached := filepath.Join(n.cacheDir, "sha256", sum)
+	if data, err := os.ReadFile(cached); err == nil {
+		if verifyChecksum(data, sum) == nil {
+			return data, nil
+		}
+		// A corrupted cache entry is downloaded again
+		os.Remove(cached)
+	}
+
+	url := tool.downloadURL(goos, goarch)
+	source := url
+	switch {
+	case n.mirror != "":
+		source = mirrorSource(n.mirror, tool, url)
+	case n.offline:
+		return nil, fmt.Errorf("%s is not in the cache at %s, and --offline forbids downloading it; pass --mirror", path.Base(url), n.cacheDir)
+	}
+
+	data, err := n.fetch(source)
+	if err != nil {
+		return nil, err
+	}
+	if err := verifyChecksum(data, sum); err != nil {
+		return nil, fmt.Errorf("%s: %v", source, err)
+	}
+	if err := writeFileAtomic(cached, data, 0644); err != nil {
+		return nil, fmt.Errorf("failed to cache %s: %v", source, err)
+	}
+	return data, nil
+}
+
+// fetch reads a file path or downloads an http(s) URL
+func (n *nativeInstaller) fetch(source string) ([]byte, error) {
+	if !isURL(source) {
+		data, err := os.ReadFile(source)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %v", source, err)
+		}
+		return data, nil
+	}
+
+	resp, err := n.client.Get(source)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s: %v", source, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to download %s: %s", source, resp.Status)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s: %v", source, err)
+	}
+	return data, nil
+}
+
+func verifyChecksum(data []byte, sum string) error {
+	if got := sha256Hex(data); got != sum {
+		return fmt.Errorf("checksum mismatch: got sha256 %s, want %s", got, sum)
+	}
+	return nil
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// extractBinary returns the file at name in a zip or tar.gz archive, or
+// data itself for a plain binary
+func extractBinary(data []byte, archive, name string) ([]byte, error) {
+	switch archive {
+	case "":
+		return data, nil
+	case "zip":
+		reader, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			return nil, err
+		}
+		file, err := reader.Open(name)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		return io.ReadAll(file)
+	case "tar.gz":
+		gz, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		reader := tar.NewReader(gz)
+		for {
+			header, err := reader.Next()
+			if errors.Is(err, io.EOF) {
+				return nil, fmt.Errorf("%s not found in archive", name)
+			}
+			if err != nil {
+				return nil, err
+			}
+			if path.Clean(header.Name) == name && header.Typeflag == tar.TypeReg {
+				return io.ReadAll(reader)
+			}
+		}
+	}
+	return nil, fmt.Errorf("unsupported archive type %q", archive)
+}
+
+// writeFileAtomic writes data to a temporary file and renames it to path,
+// so that an interrupted install never leaves a partial file
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), perm); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// generateManifest sets the version of each manifest tool from
+// .tool-versions and records the checksum of its download for every
+// platform of the manifest that has none. Pins of an unchanged version are
+// kept and verified against the download, so a release replaced upstream
+// fails with a checksum mismatch instead of being pinned again.
+func (n *nativeInstaller) generateManifest(toolVersions []toolVersion) error {
+	versions := make(map[string]string, len(toolVersions))
+	for _, tool := range toolVersions {
+		versions[tool.Name] = strings.TrimPrefix(tool.Version, "v")
+	}
+
+	for i := range n.manifest.Tools {
+		tool := &n.manifest.Tools[i]
+		if version, ok := versions[tool.Name]; ok && version != tool.Version {
+			tool.Version = version
+			tool.SHA256 = nil
+		}
+		if tool.SHA256 == nil {
+			tool.SHA256 = make(map[string]string)
+		}
+
+		for _, platform := range n.manifest.Platforms {
+			goos, goarch, ok := strings.Cut(platform, "_")
+			if !ok {
+				return fmt.Errorf("invalid platform %q, want <os>_<arch>", platform)
+			}
+			if sum := strings.ToLower(tool.SHA256[platform]); sum != "" {
+				fmt.Printf("Verifying %s %s (%s)...\n", tool.Name, tool.Version, platform)
+				if _, err := n.fetchArtifact(*tool, goos, goarch, sum); err != nil {
+					return fmt.Errorf("%s %s (%s): %v", tool.Name, tool.Version, platform, err)
+				}
+				continue
+			}
+
+			url := tool.downloadURL(goos, goarch)
+			source := url
+			if n.mirror != "" {
+				source = mirrorSource(n.mirror, *tool, url)
+			}
+			fmt.Printf("Fetching %s %s (%s)...\n", tool.Name, tool.Version, platform)
+			data, err := n.fetch(source)
+			if err != nil {
+				return err
+			}
+			if _, err := extractBinary(data, tool.Archive, tool.expand(tool.Binary, goos, goarch)); err != nil {
+				return fmt.Errorf("%s: %v", source, err)
+			}
+			tool.SHA256[platform] = sha256Hex(data)
+		}
+	}
+	return nil
+}
+
+func saveManifest(path string, manifest *Manifest) error {
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode manifest: %v", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write manifest: %v", err)
+	}
+	return nil
+}
+
+func isURL(source string) bool {
+	return strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://")
+}
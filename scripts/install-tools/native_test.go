@@ -0,0 +1,393 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func zipArchive(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for name, content := range files {
+		f, err := w.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Write([]byte(content))
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func tarGzArchive(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	w := tar.NewWriter(gz)
+	for name, content := range files {
+		if err := w.WriteHeader(&tar.Header{Name: name, Mode: 0755, Size: int64(len(content)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(content))
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func writeTestFile(t *testing.T, path string, data []byte) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestExtractBinary(t *testing.T) {
+	zipData := zipArchive(t, map[string]string{"README.md": "readme", "terraform": "terraform binary"})
+	tarData := tarGzArchive(t, map[string]string{"golangci-lint-2.1.6-linux-amd64/golangci-lint": "golangci-lint binary"})
+
+	tests := []struct {
+		name     string
+		data     []byte
+		archive  string
+		binary   string
+		expected string
+		wantErr  bool
+	}{
+		{"Plain binary", []byte("tfsec binary"), "", "", "tfsec binary", false},
+		{"Zip", zipData, "zip", "terraform", "terraform binary", false},
+		{"Zip without binary", zipData, "zip", "tflint", "", true},
+		{"Tar.gz", tarData, "tar.gz", "golangci-lint-2.1.6-linux-amd64/golangci-lint", "golangci-lint binary", false},
+		{"Tar.gz without binary", tarData, "tar.gz", "golangci-lint", "", true},
+		{"Corrupted archive", []byte("not an archive"), "zip", "terraform", "", true},
+		{"Unsupported archive", zipData, "rar", "terraform", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			binary, err := extractBinary(tt.data, tt.archive, tt.binary)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("extractBinary() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if string(binary) != tt.expected {
+				t.Errorf("extractBinary() = %q, want %q", binary, tt.expected)
+			}
+		})
+	}
+}
+
+func TestDownloadURL(t *testing.T) {
+	tool := ManifestTool{
+		Name:      "jq",
+		Version:   "1.7.1",
+		URL:       "https://example.com/jq-{version}/jq-{os}-{arch}",
+		URLs:      map[string]string{"linux_arm64": "https://example.com/jq-{version}/jq-{os}-{arch}-static"},
+		OSNames:   map[string]string{"darwin": "macos"},
+		ArchNames: map[string]string{"amd64": "x86_64"},
+	}
+
+	tests := []struct {
+		goos, goarch string
+		expected     string
+	}{
+		{"linux", "amd64", "https://example.com/jq-1.7.1/jq-linux-x86_64"},
+		{"darwin", "arm64", "https://example.com/jq-1.7.1/jq-macos-arm64"},
+		{"linux", "arm64", "https://example.com/jq-1.7.1/jq-linux-arm64-static"},
+	}
+
+	for _, tt := range tests {
+		if url := tool.downloadURL(tt.goos, tt.goarch); url != tt.expected {
+			t.Errorf("downloadURL(%s, %s) = %s, want %s", tt.goos, tt.goarch, url, tt.expected)
+		}
+	}
+}
+
+func TestMirrorSource(t *testing.T) {
+	tool := ManifestTool{Name: "tfsec", Version: "1.28.14"}
+	url := "https://github.com/aquasecurity/tfsec/releases/download/v1.28.14/tfsec-linux-amd64"
+
+	if source := mirrorSource("http://mirror.local/tools/", tool, url); source != "http://mirror.local/tools/tfsec/1.28.14/tfsec-linux-amd64" {
+		t.Errorf("mirrorSource() with a URL = %s", source)
+	}
+	if source := mirrorSource("/srv/mirror", tool, url); source != filepath.Join("/srv/mirror", "tfsec", "1.28.14", "tfsec-linux-amd64") {
+		t.Errorf("mirrorSource() with a directory = %s", source)
+	}
+}
+
+func TestNativeInstall(t *testing.T) {
+	artifact := zipArchive(t, map[string]string{"terraform": "terraform binary"})
+	sum := sha256Hex(artifact)
+
+	// Upstream and mirror are served like release downloads
+	upstream := t.TempDir()
+	writeTestFile(t, filepath.Join(upstream, "1.12.1", "terraform_linux_amd64.zip"), artifact)
+	upstreamServer := httptest.NewServer(http.FileServer(http.Dir(upstream)))
+	defer upstreamServer.Close()
+	mirror := t.TempDir()
+	writeTestFile(t, filepath.Join(mirror, "terraform", "1.12.1", "terraform_linux_amd64.zip"), artifact)
+	mirrorServer := httptest.NewServer(http.FileServer(http.Dir(mirror)))
+	defer mirrorServer.Close()
+
+	newInstaller := func(sum, mirror string, offline bool) *nativeInstaller {
+		return &nativeInstaller{
+			manifest: &Manifest{Tools: []ManifestTool{{
+				Name:    "terraform",
+				Version: "1.12.1",
+				URL:     upstreamServer.URL + "/{version}/terraform_{os}_{arch}.zip",
+				Archive: "zip",
+				Binary:  "terraform",
+				SHA256:  map[string]string{"linux_amd64": sum},
+			}}},
+			binDir:   filepath.Join(t.TempDir(), "bin"),
+			cacheDir: t.TempDir(),
+			mirror:   mirror,
+			offline:  offline,
+			goos:     "linux",
+			goarch:   "amd64",
+			client:   upstreamServer.Client(),
+		}
+	}
+	assertInstalled := func(t *testing.T, n *nativeInstaller) {
+		t.Helper()
+		binary, err := os.ReadFile(filepath.Join(n.binDir, "terraform"))
+		if err != nil {
+			t.Fatalf("terraform not installed: %v", err)
+		}
+		if string(binary) != "terraform binary" {
+			t.Errorf("installed terraform = %q", binary)
+		}
+		info, _ := os.Stat(filepath.Join(n.binDir, "terraform"))
+		if info.Mode().Perm()&0100 == 0 {
+			t.Errorf("installed terraform is not executable: %v", info.Mode())
+		}
+		if _, err := os.Stat(filepath.Join(n.cacheDir, "sha256", sum)); err != nil {
+			t.Errorf("download not cached: %v", err)
+		}
+	}
+
+	t.Run("Upstream", func(t *testing.T) {
+		n := newInstaller(sum, "", false)
+		if err := n.install(); err != nil {
+			t.Fatalf("install() failed: %v", err)
+		}
+		assertInstalled(t, n)
+	})
+
+	t.Run("Mirror directory", func(t *testing.T) {
+		n := newInstaller(sum, mirror, true)
+		if err := n.install(); err != nil {
+			t.Fatalf("install() failed: %v", err)
+		}
+		assertInstalled(t, n)
+	})
+
+	t.Run("Mirror URL", func(t *testing.T) {
+		n := newInstaller(sum, mirrorServer.URL, true)
+		if err := n.install(); err != nil {
+			t.Fatalf("install() failed: %v", err)
+		}
+		assertInstalled(t, n)
+	})
+
+	t.Run("Offline from cache", func(t *testing.T) {
+		n := newInstaller(sum, "", true)
+		writeTestFile(t, filepath.Join(n.cacheDir, "sha256", sum), artifact)
+		if err := n.install(); err != nil {
+			t.Fatalf("install() failed: %v", err)
+		}
+		assertInstalled(t, n)
+	})
+
+	t.Run("Offline without cache", func(t *testing.T) {
+		n := newInstaller(sum, "", true)
+		if err := n.install(); err == nil {
+			t.Fatal("install() succeeded, want an error")
+		}
+		if _, err := os.Stat(filepath.Join(n.binDir, "terraform")); err == nil {
+			t.Error("terraform installed without a download")
+		}
+	})
+
+	t.Run("Corrupted cache", func(t *testing.T) {
+		n := newInstaller(sum, "", false)
+		writeTestFile(t, filepath.Join(n.cacheDir, "sha256", sum), []byte("corrupted"))
+		if err := n.install(); err != nil {
+			t.Fatalf("install() failed: %v", err)
+		}
+		assertInstalled(t, n)
+		cached, _ := os.ReadFile(filepath.Join(n.cacheDir, "sha256", sum))
+		if !bytes.Equal(cached, artifact) {
+			t.Error("corrupted cache entry not replaced")
+		}
+	})
+
+	t.Run("Checksum mismatch", func(t *testing.T) {
+		wrongSum := sha256Hex([]byte("another artifact"))
+		n := newInstaller(wrongSum, "", false)
+		_, err := n.installTool(n.manifest.Tools[0])
+		if err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+			t.Fatalf("installTool() error = %v, want a checksum mismatch", err)
+		}
+		if _, err := os.Stat(filepath.Join(n.cacheDir, "sha256", wrongSum)); err == nil {
+			t.Error("download with a wrong checksum was cached")
+		}
+		if _, err := os.Stat(filepath.Join(n.binDir, "terraform")); err == nil {
+			t.Error("terraform installed from a download with a wrong checksum")
+		}
+	})
+
+	t.Run("Missing checksum", func(t *testing.T) {
+		n := newInstaller("", "", false)
+		_, err := n.installTool(n.manifest.Tools[0])
+		if err == nil || !strings.Contains(err.Error(), "no SHA-256 for linux_amd64") {
+			t.Fatalf("installTool() error = %v, want a missing checksum", err)
+		}
+	})
+}
+
+func TestGenerateManifest(t *testing.T) {
+	upstream := t.TempDir()
+	for _, platform := range []string{"linux_amd64", "darwin_arm64"} {
+		writeTestFile(t, filepath.Join(upstream, "v1.28.14", "tfsec-"+platform), []byte("tfsec "+platform))
+	}
+	server := httptest.NewServer(http.FileServer(http.Dir(upstream)))
+	defer server.Close()
+
+	manifest := &Manifest{
+		Platforms: []string{"linux_amd64", "darwin_arm64"},
+		Tools: []ManifestTool{
+			{
+				Name:    "tfsec",
+				Version: "1.28.13",
+				URL:     server.URL + "/v{version}/tfsec-{os}_{arch}",
+				SHA256:  map[string]string{"linux_amd64": "outdated", "windows_amd64": "outdated"},
+			},
+		},
+	}
+	n := &nativeInstaller{manifest: manifest, cacheDir: t.TempDir(), client: server.Client()}
+
+	if err := n.generateManifest([]toolVersion{{"tfsec", "v1.28.14"}, {"golang", "1.23.9"}}); err != nil {
+		t.Fatalf("generateManifest() failed: %v", err)
+	}
+
+	tool := manifest.Tools[0]
+	if tool.Version != "1.28.14" {
+		t.Errorf("version = %s, want 1.28.14", tool.Version)
+	}
+	expected := map[string]string{
+		"linux_amd64":  sha256Hex([]byte("tfsec linux_amd64")),
+		"darwin_arm64": sha256Hex([]byte("tfsec darwin_arm64")),
+	}
+	if !reflect.DeepEqual(tool.SHA256, expected) {
+		t.Errorf("sha256 = %v, want %v", tool.SHA256, expected)
+	}
+
+	// Pins of an unchanged version are kept, and only missing ones are added
+	delete(tool.SHA256, "darwin_arm64")
+	if err := n.generateManifest([]toolVersion{{"tfsec", "1.28.14"}}); err != nil {
+		t.Fatalf("generateManifest() of an unchanged version failed: %v", err)
+	}
+	if !reflect.DeepEqual(tool.SHA256, expected) {
+		t.Errorf("sha256 = %v, want %v", tool.SHA256, expected)
+	}
+
+	// A download that is not found fails the generation
+	manifest.Platforms = append(manifest.Platforms, "linux_arm64")
+	if err := n.generateManifest(nil); err == nil {
+		t.Error("generateManifest() with a missing download succeeded, want an error")
+	}
+}
+
+func TestGenerateManifestChecksumMismatch(t *testing.T) {
+	upstream := t.TempDir()
+	download := filepath.Join(upstream, "v1.28.14", "tfsec-linux_amd64")
+	writeTestFile(t, download, []byte("tfsec linux_amd64"))
+	server := httptest.NewServer(http.FileServer(http.Dir(upstream)))
+	defer server.Close()
+
+	pinned := sha256Hex([]byte("tfsec linux_amd64"))
+	manifest := &Manifest{
+		Platforms: []string{"linux_amd64"},
+		Tools: []ManifestTool{
+			{
+				Name:    "tfsec",
+				Version: "1.28.14",
+				URL:     server.URL + "/v{version}/tfsec-{os}_{arch}",
+				SHA256:  map[string]string{"linux_amd64": pinned},
+			},
+		},
+	}
+	n := &nativeInstaller{manifest: manifest, cacheDir: t.TempDir(), client: server.Client()}
+
+	// The release is replaced upstream with different bytes
+	writeTestFile(t, download, []byte("tampered tfsec linux_amd64"))
+
+	err := n.generateManifest([]toolVersion{{"tfsec", "v1.28.14"}})
+	if err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Errorf("generateManifest() = %v, want a checksum mismatch", err)
+	}
+	if sum := manifest.Tools[0].SHA256["linux_amd64"]; sum != pinned {
+		t.Errorf("sha256 = %s, want the existing pin %s to be kept", sum, pinned)
+	}
+}
+
+// TestCommittedManifest fails until `make tools-manifest` has pinned the
+// checksum of every tool for every platform, as installs refuse tools
+// without one
+func TestCommittedManifest(t *testing.T) {
+	manifest, err := loadManifest(filepath.Join("..", "..", "tools-manifest.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(manifest.Platforms) == 0 || len(manifest.Tools) == 0 {
+		t.Fatalf("manifest has %d platforms and %d tools, want both", len(manifest.Platforms), len(manifest.Tools))
+	}
+
+	for _, tool := range manifest.Tools {
+		for _, platform := range manifest.Platforms {
+			sum := tool.SHA256[platform]
+			if _, err := hex.DecodeString(sum); err != nil || len(sum) != 64 {
+				t.Errorf("%s %s has sha256 %q for %s, want a SHA-256 from `make tools-manifest`", tool.Name, tool.Version, sum, platform)
+			}
+		}
+	}
+}
+
+func TestSaveManifest(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tools-manifest.json")
+	manifest := &Manifest{
+		Platforms: []string{"linux_amd64"},
+		Tools:     []ManifestTool{{Name: "opa", Version: "1.5.1", URL: "https://example.com/opa", SHA256: map[string]string{"linux_amd64": "abc"}}},
+	}
+
+	if err := saveManifest(path, manifest); err != nil {
+		t.Fatalf("saveManifest() failed: %v", err)
+	}
+	loaded, err := loadManifest(path)
+	if err != nil {
+		t.Fatalf("loadManifest() failed: %v", err)
+	}
+	if !reflect.DeepEqual(loaded, manifest) {
+		t.Errorf("loadManifest() = %v, want %v", loaded, manifest)
+	}
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// WorkflowRun is the part of a GitHub Actions workflow run used to follow it
+type WorkflowRun struct {
+	ID           int64     `json:"id"`
+	DisplayTitle string    `json:"display_title"`
+	Status       string    `json:"status"`     // queued, in_progress, waiting, completed, ...
+	Conclusion   string    `json:"conclusion"` // success, failure, cancelled, ... once completed
+	HTMLURL      string    `json:"html_url"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// WorkflowJob is the part of a job of a workflow run shown in the report
+type WorkflowJob struct {
+	ID         int64  `json:"id"`
+	Name       string `json:"name"`       // Display name of the job
+	Status     string `json:"status"`     // queued, in_progress, waiting, completed, ...
+	Conclusion string `json:"conclusion"` // success, failure, skipped, ... once completed
+}
+
+// PendingDeployment is an environment a run waits on for approval
+type PendingDeployment struct {
+	Environment struct {
+		Name string `json:"name"`
+	} `json:"environment"`
+	CurrentUserCanApprove bool `json:"current_user_can_approve"`
+}
+
+// WorkflowDispatcher triggers workflow runs and follows them
+type WorkflowDispatcher interface {
+	// Dispatch triggers a workflow_dispatch run of workflow on ref
+	Dispatch(ctx context.Context, workflow, ref string, inputs map[string]string) error
+	// FindRunByInput returns the run of workflow created since the given
+	// time whose run-name shows value, a unique input of the dispatch, or
+	// errRunNotFound
+	FindRunByInput(ctx context.Context, workflow, value string, since time.Time) (*WorkflowRun, error)
+	// RunStatus returns the current state of a run
+	RunStatus(ctx context.Context, runID int64) (*WorkflowRun, error)
+	// RunJobs returns the jobs of a run, in workflow order
+	RunJobs(ctx context.Context, runID int64) ([]WorkflowJob, error)
+	// PendingDeployments returns the environments a run waits on for approval
+	PendingDeployments(ctx context.Context, runID int64) ([]PendingDeployment, error)
+	// Cancel cancels a run
+	Cancel(ctx context.Context, runID int64) error
+}
+
+// errRunNotFound is returned by FindRunByInput before GitHub lists the run
+var errRunNotFound = errors.New("workflow run not found")
+
+// githubClient implements WorkflowDispatcher with the GitHub REST API
+type githubClient struct {
+	baseURL    string
+	token      string
+	repository string // owner/repo
+	http       *http.Client
+}
+
+// newGitHubClient returns a client for repository, authenticated with
+// the GITHUB_TOKEN or GH_TOKEN environment variable. GITHUB_API_URL
+// overrides the API URL, as on GitHub Enterprise Server.
+func newGitHubClient(repository string) (*githubClient, error) {
+	token := os.Getenv("GITHUB_TOKEN")
+	if token == "" {
+		token = os.Getenv("GH_TOKEN")
+	}
+	if token == "" {
+		return nil, &configError{
+			message: "No GitHub token found in GITHUB_TOKEN or GH_TOKEN",
+			hint:    "Please export a token allowed to run workflows, for example: export GITHUB_TOKEN=$(gh auth token)",
+		}
+	}
+
+	baseURL := os.Getenv("GITHUB_API_URL")
+	if baseURL == "" {
+		baseURL = "https://api.github.com"
+	}
+	return &githubClient{
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		token:      token,
+		repository: repository,
+		http:       &http.Client{Timeout: 30 * time.Second},
+	}, nil
+}
+
+// CheckAccess verifies that the token can read the repository
+func (c *githubClient) CheckAccess(ctx context.Context) error {
+	return c.do(ctx, http.MethodGet, "", nil, nil)
+}
+
+func (c *githubClient) Dispatch(ctx context.Context, workflow, ref string, inputs map[string]string) error {
+	body := map[string]interface{}{"ref": ref, "inputs": inputs}
+	return c.do(ctx, http.MethodPost, "/actions/workflows/"+url.PathEscape(workflow)+"/dispatches", body, nil)
+}
+
+func (c *githubClient) FindRunByInput(ctx context.Context, workflow, value string, since time.Time) (*WorkflowRun, error) {
+	query := url.Values{}
+	query.Set("event", "workflow_dispatch")
+	// Runs may be stamped slightly before the local clock
+	query.Set("created", ">="+since.Add(-time.Minute).UTC().Format(time.RFC3339))
+	query.Set("per_page", "100")
+
+	var result struct {
+		WorkflowRuns []WorkflowRun `json:"workflow_runs"`
+	}
+	path := "/actions/workflows/" + url.PathEscape(workflow) + "/runs?" + query.Encode()
+	if err := c.do(ctx, http.MethodGet, path, nil, &result); err != nil {
+		return nil, err
+	}
+	for i, run := range result.WorkflowRuns {
+		if strings.Contains(run.DisplayTitle, value) {
+			return &result.WorkflowRuns[i], nil
+		}
+	}
+	return nil, errRunNotFound
+}
+
+func (c *githubClient) RunStatus(ctx context.Context, runID int64) (*WorkflowRun, error) {
+	var run WorkflowRun
+	if err := c.do(ctx, http.MethodGet, fmt.Sprintf("/actions/runs/%d", runID), nil, &run); err != nil {
+		return nil, err
+	}
+	return &run, nil
+}
+
+func (c *githubClient) RunJobs(ctx context.Context, runID int64) ([]WorkflowJob, error) {
+	var result struct {
+		Jobs []WorkflowJob `json:"jobs"`
+	}
+	if err := c.do(ctx, http.MethodGet, fmt.Sprintf("/actions/runs/%d/jobs?per_page=100", runID), nil, &result); err != nil {
+		return nil, err
+	}
+	return result.Jobs, nil
+}
+
+func (c *githubClient) PendingDeployments(ctx context.Context, runID int64) ([]PendingDeployment, error) {
+	var deployments []PendingDeployment
+	if err := c.do(ctx, http.MethodGet, fmt.Sprintf("/actions/runs/%d/pending_deployments", runID), nil, &deployments); err != nil {
+		return nil, err
+	}
+	return deployments, nil
+}
+
+func (c *githubClient) Cancel(ctx context.Context, runID int64) error {
+	return c.do(ctx, http.MethodPost, fmt.Sprintf("/actions/runs/%d/cancel", runID), nil, nil)
+}
+
+// do sends a request for path below the repository and decodes the JSON
+// response into result when it is not nil
+func (c *githubClient) do(ctx context.Context, method, path string, body, result interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to encode request: %w", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+"/repos/"+c.repository+path, reader)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s %s failed: %w", method, req.URL.Path, err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("%s %s failed: %w", method, req.URL.Path, err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		var apiError struct {
+			Message string `json:"message"`
+		}
+		if json.Unmarshal(data, &apiError) == nil && apiError.Message != "" {
+			return fmt.Errorf("%s %s failed: %s: %s", method, req.URL.Path, resp.Status, apiError.Message)
+		}
+		return fmt.Errorf("%s %s failed: %s", method, req.URL.Path, resp.Status)
+	}
+
+	if result != nil {
+		if err := json.Unmarshal(data, result); err != nil {
+			return fmt.Errorf("failed to parse the response of %s %s: %w", method, req.URL.Path, err)
+		}
+	}
+	return nil
+}
+
+// findDispatchedRun polls FindRunByInput until GitHub lists the run, as
+// it is created shortly after the dispatch
+func findDispatchedRun(ctx context.Context, d WorkflowDispatcher, workflow, value string, since time.Time, timeout, interval time.Duration) (*WorkflowRun, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	for {
+		run, err := d.FindRunByInput(ctx, workflow, value, since)
+		if !errors.Is(err, errRunNotFound) {
+			return run, err
+		}
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("no run showing '%s' after %s: %w", value, timeout, errRunNotFound)
+		case <-time.After(interval):
+		}
+	}
+}
@@ -0,0 +1,391 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeGitHub is a fake GitHub API for one repository, creating a run for
+// each dispatch
+type fakeGitHub struct {
+	mu         sync.Mutex
+	dispatches []map[string]interface{}
+	runs       []WorkflowRun
+	cancelled  []int64
+	failNames  map[string]bool // Variation names whose dispatch fails
+	hideRuns   bool            // Never list the dispatched runs
+	// States a run moves through, one per status request, as status or
+	// status:conclusion
+	progress map[int64][]string
+	jobs     map[int64][]WorkflowJob
+	pending  map[int64][]PendingDeployment
+}
+
+func (f *fakeGitHub) handler(t *testing.T) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/repos/owner/repo", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"full_name":"owner/repo"}`)
+	})
+	mux.HandleFunc("/repos/owner/repo/actions/workflows/main-validation.yml/dispatches", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			t.Errorf("dispatch method = %s, want POST", r.Method)
+		}
+		var body map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("failed to decode dispatch: %v", err)
+		}
+		inputs, _ := body["inputs"].(map[string]interface{})
+		title, _ := inputs["pr_title"].(string)
+
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		for name := range f.failNames {
+			if strings.Contains(title, name) {
+				w.WriteHeader(http.StatusUnprocessableEntity)
+				fmt.Fprint(w, `{"message":"Unexpected inputs provided"}`)
+				return
+			}
+		}
+		f.dispatches = append(f.dispatches, body)
+		id := int64(len(f.dispatches))
+		f.runs = append(f.runs, WorkflowRun{
+			ID:           id,
+			DisplayTitle: fmt.Sprintf("Main Validation (PR %s)", inputs["pr_number"]),
+			Status:       "queued",
+			HTMLURL:      fmt.Sprintf("https://github.com/owner/repo/actions/runs/%d", id),
+			CreatedAt:    time.Now(),
+		})
+		w.WriteHeader(http.StatusNoContent)
+	})
+	mux.HandleFunc("/repos/owner/repo/actions/workflows/main-validation.yml/runs", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("event") != "workflow_dispatch" || !strings.HasPrefix(r.URL.Query().Get("created"), ">=") {
+			t.Errorf("runs query = %s, want workflow_dispatch runs created since a time", r.URL.RawQuery)
+		}
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		runs := f.runs
+		if f.hideRuns {
+			runs = nil
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"total_count": len(runs), "workflow_runs": runs})
+	})
+	mux.HandleFunc("/repos/owner/repo/actions/runs/", func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		id, action, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/repos/owner/repo/actions/runs/"), "/")
+		for i, run := range f.runs {
+			if fmt.Sprint(run.ID) != id {
+				continue
+			}
+			switch action {
+			case "cancel":
+				f.cancelled = append(f.cancelled, run.ID)
+				f.runs[i].Status = "completed"
+				f.runs[i].Conclusion = "cancelled"
+				w.WriteHeader(http.StatusAccepted)
+			case "jobs":
+				json.NewEncoder(w).Encode(map[string]interface{}{"total_count": len(f.jobs[run.ID]), "jobs": f.jobs[run.ID]})
+			case "pending_deployments":
+				json.NewEncoder(w).Encode(f.pending[run.ID])
+			default:
+				if states := f.progress[run.ID]; len(states) > 0 {
+					f.runs[i].Status, f.runs[i].Conclusion, _ = strings.Cut(states[0], ":")
+					if len(states) > 1 {
+						f.progress[run.ID] = states[1:]
+					}
+				}
+				json.NewEncoder(w).Encode(f.runs[i])
+			}
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, `{"message":"Not Found"}`)
+	})
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer test-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(w, `{"message":"Bad credentials"}`)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
+
+// newTestClient returns a client of a fake GitHub API
+func newTestClient(t *testing.T, fake *fakeGitHub) *githubClient {
+	server := httptest.NewServer(fake.handler(t))
+	t.Cleanup(server.Close)
+
+	t.Setenv("GITHUB_TOKEN", "test-token")
+	t.Setenv("GITHUB_API_URL", server.URL)
+	client, err := newGitHubClient("owner/repo")
+	if err != nil {
+		t.Fatalf("newGitHubClient() failed: %v", err)
+	}
+	return client
+}
+
+// withFastPolling removes the pauses of the dispatch for a test
+func withFastPolling(t *testing.T) {
+	pause, timeout, interval := triggerPause, runLookupTimeout, pollInterval
+	triggerPause, runLookupTimeout, pollInterval = 0, 200*time.Millisecond, 10*time.Millisecond
+	t.Cleanup(func() {
+		triggerPause, runLookupTimeout, pollInterval = pause, timeout, interval
+	})
+}
+
+func TestNewGitHubClient(t *testing.T) {
+	t.Setenv("GITHUB_TOKEN", "")
+	t.Setenv("GH_TOKEN", "")
+	_, err := newGitHubClient("owner/repo")
+	var cfgErr *configError
+	if !errors.As(err, &cfgErr) || cfgErr.hint == "" {
+		t.Errorf("newGitHubClient() without a token = %v, want a configError with a hint", err)
+	}
+
+	t.Setenv("GH_TOKEN", "gh-token")
+	t.Setenv("GITHUB_API_URL", "https://github.example.com/api/v3/")
+	client, err := newGitHubClient("owner/repo")
+	if err != nil {
+		t.Fatalf("newGitHubClient() failed: %v", err)
+	}
+	if client.token != "gh-token" || client.baseURL != "https://github.example.com/api/v3" {
+		t.Errorf("newGitHubClient() = token %q, base URL %q", client.token, client.baseURL)
+	}
+}
+
+func TestGitHubClient(t *testing.T) {
+	fake := &fakeGitHub{}
+	client := newTestClient(t, fake)
+	ctx := context.Background()
+
+	if err := client.CheckAccess(ctx); err != nil {
+		t.Fatalf("CheckAccess() failed: %v", err)
+	}
+
+	since := time.Now()
+	inputs := map[string]string{"pr_number": "test1-20240101-120000", "pr_title": "TEST"}
+	if err := client.Dispatch(ctx, "main-validation.yml", "feature", inputs); err != nil {
+		t.Fatalf("Dispatch() failed: %v", err)
+	}
+	if len(fake.dispatches) != 1 || fake.dispatches[0]["ref"] != "feature" {
+		t.Fatalf("dispatches = %v, want one on ref feature", fake.dispatches)
+	}
+
+	run, err := client.FindRunByInput(ctx, "main-validation.yml", "test1-20240101-120000", since)
+	if err != nil {
+		t.Fatalf("FindRunByInput() failed: %v", err)
+	}
+	if run.ID != 1 || run.HTMLURL == "" {
+		t.Errorf("FindRunByInput() = %+v, want run 1", run)
+	}
+	if _, err := client.FindRunByInput(ctx, "main-validation.yml", "test2-20240101-120000", since); !errors.Is(err, errRunNotFound) {
+		t.Errorf("FindRunByInput() of another input = %v, want errRunNotFound", err)
+	}
+
+	fake.jobs = map[int64][]WorkflowJob{1: {{ID: 10, Name: "Merge Approval Routing", Status: "completed", Conclusion: "success"}}}
+	jobs, err := client.RunJobs(ctx, run.ID)
+	if err != nil || len(jobs) != 1 || jobs[0].Conclusion != "success" {
+		t.Errorf("RunJobs() = %+v, %v, want one successful job", jobs, err)
+	}
+	var deployment PendingDeployment
+	deployment.Environment.Name = "merge-approval"
+	fake.pending = map[int64][]PendingDeployment{1: {deployment}}
+	deployments, err := client.PendingDeployments(ctx, run.ID)
+	if err != nil || len(deployments) != 1 || deployments[0].Environment.Name != "merge-approval" {
+		t.Errorf("PendingDeployments() = %+v, %v, want merge-approval", deployments, err)
+	}
+
+	if err := client.Cancel(ctx, run.ID); err != nil {
+		t.Fatalf("Cancel() failed: %v", err)
+	}
+	status, err := client.RunStatus(ctx, run.ID)
+	if err != nil {
+		t.Fatalf("RunStatus() failed: %v", err)
+	}
+	if status.Status != "completed" || status.Conclusion != "cancelled" {
+		t.Errorf("RunStatus() = %s/%s, want completed/cancelled", status.Status, status.Conclusion)
+	}
+
+	_, err = client.RunStatus(ctx, 42)
+	if err == nil || !strings.Contains(err.Error(), "Not Found") {
+		t.Errorf("RunStatus() of a missing run = %v, want the API message", err)
+	}
+
+	client.token = "wrong"
+	if err := client.CheckAccess(ctx); err == nil || !strings.Contains(err.Error(), "Bad credentials") {
+		t.Errorf("CheckAccess() with a wrong token = %v, want the API message", err)
+	}
+}
+
+func TestFindDispatchedRun(t *testing.T) {
+	fake := &fakeGitHub{hideRuns: true}
+	client := newTestClient(t, fake)
+	ctx := context.Background()
+
+	_, err := findDispatchedRun(ctx, client, "main-validation.yml", "test1", time.Now(), 50*time.Millisecond, 10*time.Millisecond)
+	if !errors.Is(err, errRunNotFound) {
+		t.Errorf("findDispatchedRun() of a hidden run = %v, want errRunNotFound", err)
+	}
+
+	// The run appears while polling
+	if err := client.Dispatch(ctx, "main-validation.yml", "main", map[string]string{"pr_number": "test1"}); err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		time.Sleep(30 * time.Millisecond)
+		fake.mu.Lock()
+		fake.hideRuns = false
+		fake.mu.Unlock()
+	}()
+	run, err := findDispatchedRun(ctx, client, "main-validation.yml", "test1", time.Now(), time.Second, 10*time.Millisecond)
+	if err != nil {
+		t.Fatalf("findDispatchedRun() failed: %v", err)
+	}
+	if run.ID != 1 {
+		t.Errorf("findDispatchedRun() = run %d, want 1", run.ID)
+	}
+}
+
+func testWorkflowConfig() *WorkflowTestConfig {
+	return &WorkflowTestConfig{
+		TestModule:     "modules/example",
+		TestModuleType: "utility",
+		Repository:     "owner/repo",
+		Variations: []WorkflowVariation{
+			{Name: "Internal self-approval", ChangeType: "terraform", ContributorType: "Internal", CanSelfApprove: "true"},
+			{Name: "External", ChangeType: "terraform", ContributorType: "External", CanSelfApprove: "false", Inputs: map[string]string{"dryrun": "false"}},
+			{Name: "Non-terraform", ChangeType: "non-terraform", ContributorType: "Internal", CanSelfApprove: "false"},
+		},
+		DefaultInputs: map[string]string{"dryrun": "true"},
+	}
+}
+
+func TestSelectVariations(t *testing.T) {
+	config := testWorkflowConfig()
+	if err := selectVariations(config, ""); err != nil || len(config.Variations) != 3 {
+		t.Errorf("selectVariations() without --only = %v, %d variations, want all 3", err, len(config.Variations))
+	}
+
+	config = testWorkflowConfig()
+	if err := selectVariations(config, "Non-terraform, External"); err != nil {
+		t.Fatalf("selectVariations() failed: %v", err)
+	}
+	if len(config.Variations) != 2 || config.Variations[0].Name != "Non-terraform" || config.Variations[1].Name != "External" {
+		t.Errorf("selectVariations() = %v, want Non-terraform and External", config.Variations)
+	}
+
+	config = testWorkflowConfig()
+	err := selectVariations(config, "Internal")
+	var cfgErr *configError
+	if !errors.As(err, &cfgErr) || !strings.Contains(cfgErr.hint, "Internal self-approval") {
+		t.Errorf("selectVariations() with an unknown name = %v, want a configError listing the variations", err)
+	}
+}
+
+func TestTriggerVariations(t *testing.T) {
+	withFastPolling(t)
+	fake := &fakeGitHub{failNames: map[string]bool{"External": true}}
+	client := newTestClient(t, fake)
+
+	config := testWorkflowConfig()
+	if err := selectVariations(config, "External,Non-terraform"); err != nil {
+		t.Fatal(err)
+	}
+	runs := triggerVariations(context.Background(), client, config, "feature", "20240101-120000")
+
+	if len(runs) != 1 {
+		t.Fatalf("triggerVariations() = %d runs, want 1 as External fails", len(runs))
+	}
+	run := runs[0]
+	// The pr_number keeps the position of the variation in the configuration
+	if run.Variation.Name != "Non-terraform" || run.PRNumber != "test3-20240101-120000" {
+		t.Errorf("triggerVariations() = %s with %s, want Non-terraform with test3-20240101-120000", run.Variation.Name, run.PRNumber)
+	}
+	if run.Run == nil || run.Run.ID != 1 {
+		t.Errorf("triggerVariations() run = %+v, want run 1", run.Run)
+	}
+
+	inputs := fake.dispatches[0]["inputs"].(map[string]interface{})
+	expected := map[string]string{
+		"change_type":      "non-terraform",
+		"contributor_type": "Internal",
+		"can_self_approve": "false",
+		"dryrun":           "true",
+		"pr_number":        "test3-20240101-120000",
+		"pr_html_url":      "https://github.com/owner/repo/pull/test3-20240101-120000",
+		"module_config":    `{"path":"modules/example","type":"utility"}`,
+	}
+	for key, value := range expected {
+		if inputs[key] != value {
+			t.Errorf("input %s = %v, want %s", key, inputs[key], value)
+		}
+	}
+}
+
+func TestTriggerWorkflowRunNotListed(t *testing.T) {
+	withFastPolling(t)
+	fake := &fakeGitHub{hideRuns: true}
+	client := newTestClient(t, fake)
+
+	config := testWorkflowConfig()
+	run, err := triggerWorkflow(context.Background(), client, config.Variations[0], config, "20240101-120000", 1, "main")
+	if err != nil {
+		t.Fatalf("triggerWorkflow() failed: %v", err)
+	}
+	if run.Run != nil || run.PRNumber != "test1-20240101-120000" {
+		t.Errorf("triggerWorkflow() = %+v, want a dispatch without a run", run)
+	}
+
+	config.DefaultInputs[""] = "value"
+	if _, err := triggerWorkflow(context.Background(), client, config.Variations[0], config, "20240101-120000", 1, "main"); err == nil {
+		t.Error("triggerWorkflow() with an empty input key succeeded, want an error")
+	}
+}
+
+func TestGetCurrentBranch(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := getCurrentBranch(dir); err == nil {
+		t.Error("getCurrentBranch() outside a repository succeeded, want an error")
+	}
+
+	gitDir := filepath.Join(dir, ".git")
+	writeFile(t, filepath.Join(gitDir, "HEAD"), "ref: refs/heads/feature/api\n")
+	if branch, err := getCurrentBranch(dir); err != nil || branch != "feature/api" {
+		t.Errorf("getCurrentBranch() = %q, %v, want feature/api", branch, err)
+	}
+
+	writeFile(t, filepath.Join(gitDir, "HEAD"), "4b825dc642cb6eb9a060e54bf8d69288fbee4904\n")
+	if _, err := getCurrentBranch(dir); err == nil {
+		t.Error("getCurrentBranch() in detached HEAD succeeded, want an error")
+	}
+
+	// A worktree has a .git file pointing to its git directory
+	worktree := t.TempDir()
+	writeFile(t, filepath.Join(worktree, ".git"), "gitdir: "+filepath.Join(gitDir, "worktrees", "wt")+"\n")
+	writeFile(t, filepath.Join(gitDir, "worktrees", "wt", "HEAD"), "ref: refs/heads/wt-branch\n")
+	if branch, err := getCurrentBranch(worktree); err != nil || branch != "wt-branch" {
+		t.Errorf("getCurrentBranch() of a worktree = %q, %v, want wt-branch", branch, err)
+	}
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
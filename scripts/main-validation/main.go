@@ -2,11 +2,16 @@ package main
 
 import (
 	"bufio"
+	"context"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
 	"os"
-	"os/exec"
+	"os/signal"
+	"path/filepath"
 	"strings"
+	"syscall"
 	"time"
 )
 
@@ -37,6 +42,8 @@ type WorkflowTestConfig struct {
 	Repository     string              `json:"repository"`
 	Variations     []WorkflowVariation `json:"variations"`
 	DefaultInputs  map[string]string   `json:"default_inputs"`
+
+	allVariations []WorkflowVariation // Variations before --only
 }
 
 // WorkflowVariation represents a test variation configuration
@@ -47,127 +54,201 @@ type WorkflowVariation struct {
 	CanSelfApprove  string            `json:"can_self_approve"`
 	Description     string            `json:"description"`
 	Inputs          map[string]string `json:"inputs,omitempty"`
+	Expected        *RoutingOutcome   `json:"expected,omitempty"`
 }
 
-func main() {
-	fmt.Printf("%s=== Triggering All 6 Merge Approval Job Variations ===%s\n\n", Blue, NC)
+// mainValidationWorkflow is the workflow file triggered for each variation
+const mainValidationWorkflow = "main-validation.yml"
 
-	// Check if GitHub CLI is available first
-	if !isGitHubCLIAvailable() {
-		fmt.Printf("%s❌ ERROR: GitHub CLI (gh) is not available%s\n", Red, NC)
-		fmt.Printf("%sPlease install GitHub CLI: https://cli.github.com/%s\n", Yellow, NC)
-		os.Exit(1)
-	}
+// Pauses of the workflow dispatch, replaced by the tests
+var (
+	triggerPause     = 2 * time.Second  // Between two dispatches
+	runLookupTimeout = 30 * time.Second // For GitHub to list a dispatched run
+	pollInterval     = 5 * time.Second  // Between two requests for a run
+)
 
-	// Check if git is available
-	if !isGitAvailable() {
-		fmt.Printf("%s❌ ERROR: Git is not available%s\n", Red, NC)
-		fmt.Printf("%sPlease install Git%s\n", Yellow, NC)
-		os.Exit(1)
+func main() {
+	configPath := flag.String("config", "monorepo-config.json", "Path to config JSON file")
+	simulate := flag.Bool("simulate", false, "Check the merge approval routing of every variation locally instead of triggering workflows")
+	workflowPath := flag.String("workflow", ".github/workflows/main-validation.yml", "Path to the workflow file checked by --simulate")
+	only := flag.String("only", "", "Comma-separated names of the variations to run (default: all)")
+	ref := flag.String("ref", "", "Branch to run the workflows on (default: the current branch)")
+	var yes bool
+	flag.BoolVar(&yes, "yes", false, "Trigger the workflows without asking for confirmation")
+	flag.BoolVar(&yes, "non-interactive", false, "Same as --yes")
+	wait := flag.Bool("wait", false, "Wait for the triggered runs to complete and report the status of their jobs")
+	timeout := flag.Duration("timeout", 2*time.Hour, "Maximum time to wait for the runs with --wait")
+	cancelOnExit := flag.Bool("cancel-on-exit", false, "Cancel the runs still in progress when --wait stops on timeout or interrupt")
+	flag.Parse()
+
+	if !*wait {
+		flag.Visit(func(f *flag.Flag) {
+			if f.Name == "timeout" || f.Name == "cancel-on-exit" {
+				fmt.Printf("%s❌ ERROR: --%s requires --wait%s\n", Red, f.Name, NC)
+				os.Exit(1)
+			}
+		})
+	}
+
+	if *simulate {
+		fmt.Printf("%s=== Simulating Merge Approval Routing ===%s\n\n", Blue, NC)
+	} else {
+		fmt.Printf("%s=== Triggering Merge Approval Job Variations ===%s\n\n", Blue, NC)
 	}
 
 	// Load configuration with strict validation
-	config, err := loadConfig("monorepo-config.json")
+	config, err := loadConfig(*configPath)
 	if err != nil {
 		fmt.Printf("%s❌ Configuration Error: %v%s\n", Red, err, NC)
 		os.Exit(1)
 	}
 
 	// Set up workflow test configuration with strict validation
-	workflowConfig := setupWorkflowConfig(config)
+	workflowConfig, err := setupWorkflowConfig(config)
+	if err == nil {
+		err = selectVariations(workflowConfig, *only)
+	}
+	if err != nil {
+		printError(err)
+		os.Exit(1)
+	}
+
+	if *simulate {
+		failed, err := simulateVariations(workflowConfig, *workflowPath)
+		if err != nil {
+			fmt.Printf("%s❌ ERROR: %v%s\n", Red, err, NC)
+			os.Exit(1)
+		}
+		if failed > 0 {
+			fmt.Printf("\n%s❌ %d out of %d variations do not match their expected routing%s\n", Red, failed, len(workflowConfig.Variations), NC)
+			fmt.Printf("%sUpdate the decision table in scripts/main-validation/routing.go or the 'expected' outcomes in %s%s\n", Yellow, *configPath, NC)
+			os.Exit(1)
+		}
+		fmt.Printf("\n%s🎉 All %d variations match their expected routing%s\n", Green, len(workflowConfig.Variations), NC)
+		return
+	}
 
 	fmt.Printf("%s📦 Using test module: %s (type: %s)%s\n", Blue, workflowConfig.TestModule, workflowConfig.TestModuleType, NC)
 	fmt.Printf("%s🏢 Repository: %s%s\n", Blue, workflowConfig.Repository, NC)
 
-	// Check authentication status and prompt for login if needed
-	if !checkAndEnsureAuth() {
-		fmt.Printf("%s❌ ERROR: GitHub CLI authentication failed%s\n", Red, NC)
-		fmt.Printf("%sPlease authenticate with GitHub CLI using: gh auth login%s\n", Yellow, NC)
+	// Check that the token can access the repository
+	client, err := newGitHubClient(workflowConfig.Repository)
+	if err != nil {
+		printError(err)
+		os.Exit(1)
+	}
+	ctx := context.Background()
+	if err := client.CheckAccess(ctx); err != nil {
+		fmt.Printf("%s❌ ERROR: GitHub authentication failed: %v%s\n", Red, err, NC)
+		fmt.Printf("%sPlease check that the token in GITHUB_TOKEN or GH_TOKEN can access %s%s\n", Yellow, workflowConfig.Repository, NC)
 		os.Exit(1)
 	}
 
 	// Get current branch
-	currentBranch, err := getCurrentBranch()
-	if err != nil {
-		fmt.Printf("%s❌ ERROR: Could not determine current git branch: %v%s\n", Red, err, NC)
-		fmt.Printf("%sPlease ensure you are in a git repository%s\n", Yellow, NC)
-		os.Exit(1)
+	currentBranch := *ref
+	if currentBranch == "" {
+		currentBranch, err = getCurrentBranch(".")
+		if err != nil {
+			fmt.Printf("%s❌ ERROR: Could not determine current git branch: %v%s\n", Red, err, NC)
+			fmt.Printf("%sPlease run from the root of the repository, or pass the branch with --ref%s\n", Yellow, NC)
+			os.Exit(1)
+		}
 	}
 
-	fmt.Printf("%s✅ GitHub CLI is available and authenticated%s\n", Green, NC)
+	fmt.Printf("%s✅ Authenticated with the GitHub API%s\n", Green, NC)
 	fmt.Printf("%s📍 Current branch: %s%s\n", Blue, currentBranch, NC)
 
 	// Validate that we have the expected number of variations
 	expectedVariations := 6
-	if len(workflowConfig.Variations) != expectedVariations {
+	if *only == "" && len(workflowConfig.Variations) != expectedVariations {
 		fmt.Printf("%s⚠️  WARNING: Expected %d variations but found %d in configuration%s\n",
 			Yellow, expectedVariations, len(workflowConfig.Variations), NC)
 	}
 
 	// Confirm before proceeding
-	fmt.Printf("\n%sThis script will trigger all %d variations in dry run mode.%s\n", Yellow, len(workflowConfig.Variations), NC)
+	fmt.Printf("\n%sThis script will trigger %d variations in dry run mode.%s\n", Yellow, len(workflowConfig.Variations), NC)
 	fmt.Printf("%sEach will require manual approval in the GitHub UI.%s\n", Yellow, NC)
 	fmt.Printf("%sWorkflows will be triggered on branch: %s%s%s\n", Yellow, Blue, currentBranch, NC)
-	fmt.Printf("%sPress Enter to continue or Ctrl+C to cancel...%s\n", Yellow, NC)
+	if !yes {
+		fmt.Printf("%sPress Enter to continue or Ctrl+C to cancel...%s\n", Yellow, NC)
+		reader := bufio.NewReader(os.Stdin)
+		_, _ = reader.ReadString('\n')
+	}
 
-	reader := bufio.NewReader(os.Stdin)
-	_, _ = reader.ReadString('\n')
+	if *wait {
+		// An interrupt stops the wait like the timeout, to report and cancel the runs
+		var stop context.CancelFunc
+		ctx, stop = signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+		defer stop()
+	}
 
 	// Trigger all variations
-	successCount := 0
 	timestamp := time.Now().Format("20060102-150405")
-
-	for i, variation := range workflowConfig.Variations {
-		fmt.Printf("%s=== Triggering: %s ===%s\n", Cyan, variation.Name, NC)
-		fmt.Printf("Description: %s\n", variation.Description)
-		fmt.Printf("Inputs: change_type=%s, contributor_type=%s, can_self_approve=%s\n",
-			variation.ChangeType, variation.ContributorType, variation.CanSelfApprove)
-
-		if triggerWorkflow(variation, workflowConfig, timestamp, i+1, currentBranch) {
-			fmt.Printf("%s✅ Successfully triggered: %s%s\n", Green, variation.Name, NC)
-			fmt.Printf("%sCheck GitHub Actions UI for manual approval%s\n", Blue, NC)
-			successCount++
-		} else {
-			fmt.Printf("%s❌ Failed to trigger: %s%s\n", Red, variation.Name, NC)
-		}
-
-		fmt.Printf("\n---\n\n")
-		time.Sleep(2 * time.Second) // Brief pause between triggers
-	}
+	runs := triggerVariations(ctx, client, workflowConfig, currentBranch, timestamp)
 
 	// Summary
-	fmt.Printf("%s🎉 Triggered %d out of %d variations!%s\n\n", Green, successCount, len(workflowConfig.Variations), NC)
+	fmt.Printf("%s🎉 Triggered %d out of %d variations!%s\n\n", Green, len(runs), len(workflowConfig.Variations), NC)
 
-	if successCount == 0 {
+	if len(runs) == 0 {
 		fmt.Printf("%s❌ No workflows were triggered successfully%s\n", Red, NC)
 		os.Exit(1)
 	}
 
-	printNextSteps(workflowConfig)
-}
+	if !*wait {
+		printNextSteps(workflowConfig)
+		return
+	}
 
-// isGitHubCLIAvailable checks if the gh command is available
-func isGitHubCLIAvailable() bool {
-	_, err := exec.LookPath("gh")
-	return err == nil
+	err = waitAndReport(ctx, client, runs, *timeout, *cancelOnExit)
+	if err == nil && len(runs) < len(workflowConfig.Variations) {
+		err = fmt.Errorf("%d variations could not be triggered", len(workflowConfig.Variations)-len(runs))
+	}
+	if err != nil {
+		fmt.Printf("\n%s❌ %v%s\n", Red, err, NC)
+		os.Exit(1)
+	}
+	fmt.Printf("\n%s🎉 All %d runs succeeded%s\n", Green, len(runs), NC)
 }
 
-// isGitAvailable checks if the git command is available
-func isGitAvailable() bool {
-	_, err := exec.LookPath("git")
-	return err == nil
+// printError prints an error, with the hint of a configuration error
+func printError(err error) {
+	fmt.Printf("%s❌ ERROR: %v%s\n", Red, err, NC)
+	var cfgErr *configError
+	if errors.As(err, &cfgErr) && cfgErr.hint != "" {
+		fmt.Printf("%s%s%s\n", Yellow, cfgErr.hint, NC)
+	}
 }
 
-// getCurrentBranch gets the current git branch name with validation
-func getCurrentBranch() (string, error) {
-	cmd := exec.Command("git", "branch", "--show-current")
-	output, err := cmd.Output()
+// getCurrentBranch reads the current branch from the HEAD of the git
+// repository at dir
+func getCurrentBranch(dir string) (string, error) {
+	gitDir := filepath.Join(dir, ".git")
+	info, err := os.Stat(gitDir)
 	if err != nil {
-		return "", fmt.Errorf("failed to get current branch (ensure you're in a git repository): %w", err)
+		return "", fmt.Errorf("no git repository at '%s': %w", dir, err)
+	}
+	if !info.IsDir() {
+		// Worktrees and submodules have a .git file pointing to their git directory
+		data, err := os.ReadFile(gitDir)
+		if err != nil {
+			return "", fmt.Errorf("failed to read '%s': %w", gitDir, err)
+		}
+		path, ok := strings.CutPrefix(strings.TrimSpace(string(data)), "gitdir:")
+		if !ok {
+			return "", fmt.Errorf("'%s' does not point to a git directory", gitDir)
+		}
+		gitDir = strings.TrimSpace(path)
+		if !filepath.IsAbs(gitDir) {
+			gitDir = filepath.Join(dir, gitDir)
+		}
 	}
 
-	branch := strings.TrimSpace(string(output))
-	if branch == "" {
+	head, err := os.ReadFile(filepath.Join(gitDir, "HEAD"))
+	if err != nil {
+		return "", fmt.Errorf("failed to read the git HEAD: %w", err)
+	}
+	branch, ok := strings.CutPrefix(strings.TrimSpace(string(head)), "ref: refs/heads/")
+	if !ok || branch == "" {
 		return "", fmt.Errorf("current branch name is empty (you might be in detached HEAD state)")
 	}
 
@@ -204,92 +285,82 @@ func loadConfig(configPath string) (*Config, error) {
 }
 
 // setupWorkflowConfig sets up the workflow test configuration with strict validation
-func setupWorkflowConfig(config *Config) *WorkflowTestConfig {
+func setupWorkflowConfig(config *Config) (*WorkflowTestConfig, error) {
 	// Check if workflow_tests configuration exists
 	if config.WorkflowTests == nil {
-		fmt.Printf("%s❌ ERROR: Missing 'workflow_tests' configuration in monorepo-config.json%s\n", Red, NC)
-		fmt.Printf("%sPlease add a 'workflow_tests' section with the following required fields:%s\n", Yellow, NC)
-		fmt.Printf("  - test_module: path to the test module (e.g., 'skeletons/generic-skeleton')\n")
-		fmt.Printf("  - test_module_type: type of the test module (e.g., 'skeleton')\n")
-		fmt.Printf("  - repository: GitHub repository (e.g., 'owner/repo')\n")
-		fmt.Printf("  - default_inputs: map of default workflow inputs\n")
-		fmt.Printf("  - variations: array of test variations\n")
-		os.Exit(1)
+		return nil, &configError{
+			message: "Missing 'workflow_tests' configuration in monorepo-config.json",
+			hint: "Please add a 'workflow_tests' section with the following required fields:\n" +
+				"  - test_module: path to the test module (e.g., 'skeletons/generic-skeleton')\n" +
+				"  - test_module_type: type of the test module (e.g., 'skeleton')\n" +
+				"  - repository: GitHub repository (e.g., 'owner/repo')\n" +
+				"  - default_inputs: map of default workflow inputs\n" +
+				"  - variations: array of test variations",
+		}
 	}
 
 	workflowConfig := config.WorkflowTests
 
 	// Validate required fields
 	if workflowConfig.TestModule == "" {
-		fmt.Printf("%s❌ ERROR: 'workflow_tests.test_module' is required but not specified%s\n", Red, NC)
-		os.Exit(1)
+		return nil, &configError{message: "'workflow_tests.test_module' is required but not specified"}
 	}
 
 	if workflowConfig.TestModuleType == "" {
-		fmt.Printf("%s❌ ERROR: 'workflow_tests.test_module_type' is required but not specified%s\n", Red, NC)
-		os.Exit(1)
+		return nil, &configError{message: "'workflow_tests.test_module_type' is required but not specified"}
 	}
 
 	if workflowConfig.Repository == "" {
-		fmt.Printf("%s❌ ERROR: 'workflow_tests.repository' is required but not specified%s\n", Red, NC)
-		os.Exit(1)
+		return nil, &configError{message: "'workflow_tests.repository' is required but not specified"}
 	}
 
 	if len(workflowConfig.Variations) == 0 {
-		fmt.Printf("%s❌ ERROR: 'workflow_tests.variations' is required but empty or not specified%s\n", Red, NC)
-		fmt.Printf("%sAt least one test variation must be defined%s\n", Yellow, NC)
-		os.Exit(1)
+		return nil, &configError{
+			message: "'workflow_tests.variations' is required but empty or not specified",
+			hint:    "At least one test variation must be defined",
+		}
 	}
 
 	// Validate test module exists
 	if _, err := os.Stat(workflowConfig.TestModule); os.IsNotExist(err) {
-		fmt.Printf("%s❌ ERROR: Test module '%s' does not exist%s\n", Red, workflowConfig.TestModule, NC)
-		fmt.Printf("%sPlease ensure the test module path is correct and the directory exists%s\n", Yellow, NC)
-		os.Exit(1)
+		return nil, &configError{
+			message: fmt.Sprintf("Test module '%s' does not exist", workflowConfig.TestModule),
+			hint:    "Please ensure the test module path is correct and the directory exists",
+		}
 	}
 
 	// Validate test module type exists in module_types configuration
 	if _, exists := config.ModuleTypes[workflowConfig.TestModuleType]; !exists {
-		fmt.Printf("%s❌ ERROR: Test module type '%s' is not defined in module_types configuration%s\n",
-			Red, workflowConfig.TestModuleType, NC)
-		fmt.Printf("%sAvailable module types: %s%s\n", Yellow, getAvailableModuleTypes(config), NC)
-		os.Exit(1)
+		return nil, &configError{
+			message: fmt.Sprintf("Test module type '%s' is not defined in module_types configuration", workflowConfig.TestModuleType),
+			hint:    fmt.Sprintf("Available module types: %s", getAvailableModuleTypes(config)),
+		}
 	}
 
 	// Validate each variation has required fields
 	for i, variation := range workflowConfig.Variations {
 		if variation.Name == "" {
-			fmt.Printf("%s❌ ERROR: Variation %d is missing required field 'name'%s\n", Red, i+1, NC)
-			os.Exit(1)
+			return nil, &configError{message: fmt.Sprintf("Variation %d is missing required field 'name'", i+1)}
 		}
 		if variation.ChangeType == "" {
-			fmt.Printf("%s❌ ERROR: Variation '%s' is missing required field 'change_type'%s\n", Red, variation.Name, NC)
-			os.Exit(1)
+			return nil, &configError{message: fmt.Sprintf("Variation '%s' is missing required field 'change_type'", variation.Name)}
 		}
 		if variation.ContributorType == "" {
-			fmt.Printf("%s❌ ERROR: Variation '%s' is missing required field 'contributor_type'%s\n", Red, variation.Name, NC)
-			os.Exit(1)
+			return nil, &configError{message: fmt.Sprintf("Variation '%s' is missing required field 'contributor_type'", variation.Name)}
 		}
 		if variation.CanSelfApprove == "" {
-			fmt.Printf("%s❌ ERROR: Variation '%s' is missing required field 'can_self_approve'%s\n", Red, variation.Name, NC)
-			os.Exit(1)
+			return nil, &configError{message: fmt.Sprintf("Variation '%s' is missing required field 'can_self_approve'", variation.Name)}
 		}
 
 		// Validate field values
 		if variation.ChangeType != "terraform" && variation.ChangeType != "non-terraform" {
-			fmt.Printf("%s❌ ERROR: Variation '%s' has invalid change_type '%s'. Must be 'terraform' or 'non-terraform'%s\n",
-				Red, variation.Name, variation.ChangeType, NC)
-			os.Exit(1)
+			return nil, &configError{message: fmt.Sprintf("Variation '%s' has invalid change_type '%s'. Must be 'terraform' or 'non-terraform'", variation.Name, variation.ChangeType)}
 		}
 		if variation.ContributorType != "Internal" && variation.ContributorType != "External" {
-			fmt.Printf("%s❌ ERROR: Variation '%s' has invalid contributor_type '%s'. Must be 'Internal' or 'External'%s\n",
-				Red, variation.Name, variation.ContributorType, NC)
-			os.Exit(1)
+			return nil, &configError{message: fmt.Sprintf("Variation '%s' has invalid contributor_type '%s'. Must be 'Internal' or 'External'", variation.Name, variation.ContributorType)}
 		}
 		if variation.CanSelfApprove != "true" && variation.CanSelfApprove != "false" {
-			fmt.Printf("%s❌ ERROR: Variation '%s' has invalid can_self_approve '%s'. Must be 'true' or 'false'%s\n",
-				Red, variation.Name, variation.CanSelfApprove, NC)
-			os.Exit(1)
+			return nil, &configError{message: fmt.Sprintf("Variation '%s' has invalid can_self_approve '%s'. Must be 'true' or 'false'", variation.Name, variation.CanSelfApprove)}
 		}
 	}
 
@@ -298,7 +369,18 @@ func setupWorkflowConfig(config *Config) *WorkflowTestConfig {
 		workflowConfig.DefaultInputs = make(map[string]string)
 	}
 
-	return workflowConfig
+	return workflowConfig, nil
+}
+
+// configError is an invalid workflow test configuration, with a hint on
+// how to fix it
+type configError struct {
+	message string
+	hint    string
+}
+
+func (e *configError) Error() string {
+	return e.message
 }
 
 // getAvailableModuleTypes returns a comma-separated list of available module types
@@ -310,123 +392,156 @@ func getAvailableModuleTypes(config *Config) string {
 	return strings.Join(types, ", ")
 }
 
-// checkAndEnsureAuth checks if user is authenticated and prompts for login if needed
-func checkAndEnsureAuth() bool {
-	// Check current auth status
-	cmd := exec.Command("gh", "auth", "status")
-	cmd.Env = append(os.Environ(), "GH_PAGER=cat")
-	err := cmd.Run()
-
-	if err == nil {
-		fmt.Printf("%s✅ Already authenticated with GitHub%s\n", Green, NC)
-		return true
+// selectVariations keeps the variations named in only, a comma-separated
+// list, or all of them when it is empty
+func selectVariations(config *WorkflowTestConfig, only string) error {
+	config.allVariations = config.Variations
+	if only == "" {
+		return nil
+	}
+
+	byName := make(map[string]WorkflowVariation, len(config.Variations))
+	var names []string
+	for _, variation := range config.Variations {
+		byName[variation.Name] = variation
+		names = append(names, variation.Name)
+	}
+
+	var selected []WorkflowVariation
+	for _, name := range strings.Split(only, ",") {
+		name = strings.TrimSpace(name)
+		variation, ok := byName[name]
+		if !ok {
+			return &configError{
+				message: fmt.Sprintf("Unknown variation '%s' in --only", name),
+				hint:    fmt.Sprintf("Available variations: %s", strings.Join(names, ", ")),
+			}
+		}
+		selected = append(selected, variation)
 	}
+	config.Variations = selected
+	return nil
+}
 
-	// Not authenticated, prompt for login
-	fmt.Printf("%s⚠️  GitHub CLI is not authenticated%s\n", Yellow, NC)
-	fmt.Printf("%sStarting interactive authentication...%s\n", Blue, NC)
+// dispatchedRun is a variation triggered by this script
+type dispatchedRun struct {
+	Variation WorkflowVariation
+	Number    int          // Position of the variation in the configuration, from 1
+	PRNumber  string       // Unique pr_number input of the dispatch
+	Since     time.Time    // When the run was dispatched
+	Run       *WorkflowRun // nil when GitHub did not list the run in time
+	Jobs      []WorkflowJob
+}
 
-	// Run interactive auth login
-	cmd = exec.Command("gh", "auth", "login")
-	cmd.Env = append(os.Environ(), "GH_PAGER=cat")
-	cmd.Stdin = os.Stdin
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
+// triggerVariations dispatches a run for each variation and looks up the
+// run it created, returning the variations triggered successfully
+func triggerVariations(ctx context.Context, d WorkflowDispatcher, config *WorkflowTestConfig, branch, timestamp string) []dispatchedRun {
+	var runs []dispatchedRun
+	for i, variation := range config.Variations {
+		if ctx.Err() != nil {
+			fmt.Printf("%s⚠️  Interrupted, %d variations not triggered%s\n\n", Yellow, len(config.Variations)-i, NC)
+			break
+		}
+		if i > 0 {
+			time.Sleep(triggerPause) // Brief pause between triggers
+		}
 
-	err = cmd.Run()
-	if err != nil {
-		fmt.Printf("%s❌ Authentication failed: %v%s\n", Red, err, NC)
-		fmt.Printf("%sPlease run 'gh auth login' manually to authenticate%s\n", Yellow, NC)
-		return false
-	}
+		fmt.Printf("%s=== Triggering: %s ===%s\n", Cyan, variation.Name, NC)
+		fmt.Printf("Description: %s\n", variation.Description)
+		fmt.Printf("Inputs: change_type=%s, contributor_type=%s, can_self_approve=%s\n",
+			variation.ChangeType, variation.ContributorType, variation.CanSelfApprove)
 
-	// Verify authentication worked
-	cmd = exec.Command("gh", "auth", "status")
-	cmd.Env = append(os.Environ(), "GH_PAGER=cat")
-	err = cmd.Run()
-	if err != nil {
-		fmt.Printf("%s❌ Authentication verification failed: %v%s\n", Red, err, NC)
-		fmt.Printf("%sPlease run 'gh auth status' to check your authentication%s\n", Yellow, NC)
-		return false
+		run, err := triggerWorkflow(ctx, d, variation, config, timestamp, variationNumber(config, variation), branch)
+		if err != nil {
+			fmt.Printf("%s❌ Failed to trigger: %s: %v%s\n", Red, variation.Name, err, NC)
+			fmt.Printf("\n---\n\n")
+			continue
+		}
+		fmt.Printf("%s✅ Successfully triggered: %s%s\n", Green, variation.Name, NC)
+		if run.Run != nil {
+			fmt.Printf("%s🔗 Run: %s%s\n", Blue, run.Run.HTMLURL, NC)
+		}
+		runs = append(runs, *run)
+		fmt.Printf("\n---\n\n")
 	}
-
-	fmt.Printf("%s✅ Successfully authenticated with GitHub%s\n", Green, NC)
-	return true
+	return runs
 }
 
-// triggerWorkflow triggers a single workflow variation with strict validation
-func triggerWorkflow(variation WorkflowVariation, config *WorkflowTestConfig, timestamp string, testNumber int, branch string) bool {
-	// Validate required configuration
-	if config.TestModule == "" || config.TestModuleType == "" || config.Repository == "" {
-		fmt.Printf("%s❌ ERROR: Missing required workflow configuration%s\n", Red, NC)
-		return false
+// variationNumber returns the position of a variation in the configuration,
+// from 1, so that --only keeps the pr_number of each variation
+func variationNumber(config *WorkflowTestConfig, variation WorkflowVariation) int {
+	all := config.allVariations
+	if all == nil {
+		all = config.Variations
 	}
+	for i, v := range all {
+		if v.Name == variation.Name {
+			return i + 1
+		}
+	}
+	return 0
+}
 
-	// Build module configuration JSON
-	moduleConfig := fmt.Sprintf(`{"path":"%s","type":"%s"}`, config.TestModule, config.TestModuleType)
-
-	// Generate test-specific values
-	prNumber := fmt.Sprintf("test%d-%s", testNumber, timestamp)
-	contributorUsername := fmt.Sprintf("test-user-%s", timestamp)
-	prTitle := fmt.Sprintf("TEST: %s - %s", variation.Name, timestamp)
-	prURL := fmt.Sprintf("https://github.com/%s/pull/%s", config.Repository, prNumber)
-
-	fmt.Printf("%sRunning gh workflow run command...%s\n", Yellow, NC)
-
-	// Build arguments dynamically - all values come from configuration
-	args := []string{
-		"workflow", "run", "main-validation.yml",
-		"--ref", branch,
-		"--field", fmt.Sprintf("change_type=%s", variation.ChangeType),
-		"--field", fmt.Sprintf("contributor_type=%s", variation.ContributorType),
-		"--field", fmt.Sprintf("contributor_username=%s", contributorUsername),
-		"--field", fmt.Sprintf("can_self_approve=%s", variation.CanSelfApprove),
-		"--field", fmt.Sprintf("pr_number=%s", prNumber),
-		"--field", fmt.Sprintf("pr_title=%s", prTitle),
-		"--field", fmt.Sprintf("pr_html_url=%s", prURL),
-		"--field", fmt.Sprintf("module_config=%s", moduleConfig),
+// workflowInputs returns the inputs of the dispatch of a variation
+func workflowInputs(variation WorkflowVariation, config *WorkflowTestConfig, prNumber, timestamp string) (map[string]string, error) {
+	inputs := map[string]string{
+		"change_type":          variation.ChangeType,
+		"contributor_type":     variation.ContributorType,
+		"contributor_username": fmt.Sprintf("test-user-%s", timestamp),
+		"can_self_approve":     variation.CanSelfApprove,
+		"pr_number":            prNumber,
+		"pr_title":             fmt.Sprintf("TEST: %s - %s", variation.Name, timestamp),
+		"pr_html_url":          fmt.Sprintf("https://github.com/%s/pull/%s", config.Repository, prNumber),
+		"module_config":        fmt.Sprintf(`{"path":"%s","type":"%s"}`, config.TestModule, config.TestModuleType),
 	}
 
 	// Add default inputs from configuration
 	for key, value := range config.DefaultInputs {
 		if key == "" {
-			fmt.Printf("%s❌ ERROR: Empty key found in default_inputs configuration%s\n", Red, NC)
-			return false
+			return nil, fmt.Errorf("empty key found in default_inputs configuration")
 		}
-		args = append(args, "--field", fmt.Sprintf("%s=%s", key, value))
+		inputs[key] = value
 	}
 
 	// Add variation-specific inputs from configuration
 	for key, value := range variation.Inputs {
 		if key == "" {
-			fmt.Printf("%s❌ ERROR: Empty key found in variation '%s' inputs%s\n", Red, variation.Name, NC)
-			return false
+			return nil, fmt.Errorf("empty key found in variation '%s' inputs", variation.Name)
 		}
-		args = append(args, "--field", fmt.Sprintf("%s=%s", key, value))
+		inputs[key] = value
 	}
+	return inputs, nil
+}
 
-	// Debug: Print the exact command being run
-	fmt.Printf("%sDebug - Command: gh %s%s\n", Blue, strings.Join(args, " "), NC)
-
-	// Execute the command
-	cmd := exec.Command("gh", args...)
-	// Disable pager to ensure output goes to stdout/stderr
-	cmd.Env = append(os.Environ(), "GH_PAGER=cat")
-	output, err := cmd.CombinedOutput()
+// triggerWorkflow dispatches a single workflow variation and looks up the
+// run it created
+func triggerWorkflow(ctx context.Context, d WorkflowDispatcher, variation WorkflowVariation, config *WorkflowTestConfig, timestamp string, testNumber int, branch string) (*dispatchedRun, error) {
+	// Validate required configuration
+	if config.TestModule == "" || config.TestModuleType == "" || config.Repository == "" {
+		return nil, fmt.Errorf("missing required workflow configuration")
+	}
 
+	// The unique pr_number identifies the run
+	prNumber := fmt.Sprintf("test%d-%s", testNumber, timestamp)
+	inputs, err := workflowInputs(variation, config, prNumber, timestamp)
 	if err != nil {
-		fmt.Printf("%s❌ GitHub CLI command failed: %v%s\n", Red, err, NC)
-		if len(output) > 0 {
-			fmt.Printf("%sOutput: %s%s\n", Red, string(output), NC)
-		}
-		return false
+		return nil, err
 	}
 
-	if len(output) > 0 {
-		fmt.Printf("%s✅ Success: %s%s\n", Green, string(output), NC)
+	fmt.Printf("%sDispatching %s on %s with pr_number=%s...%s\n", Yellow, mainValidationWorkflow, branch, prNumber, NC)
+	since := time.Now()
+	if err := d.Dispatch(ctx, mainValidationWorkflow, branch, inputs); err != nil {
+		return nil, err
 	}
 
-	return true
+	dispatched := &dispatchedRun{Variation: variation, Number: testNumber, PRNumber: prNumber, Since: since}
+	run, err := findDispatchedRun(ctx, d, mainValidationWorkflow, prNumber, since, runLookupTimeout, pollInterval)
+	if err != nil {
+		fmt.Printf("%s⚠️  Could not find the run yet: %v%s\n", Yellow, err, NC)
+		return dispatched, nil
+	}
+	dispatched.Run = run
+	return dispatched, nil
 }
 
 // printNextSteps prints instructions for the user
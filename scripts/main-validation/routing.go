@@ -0,0 +1,222 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+// RoutingOutcome is what a main-validation.yml run does for a variation,
+// assuming every approval is given and every job succeeds
+type RoutingOutcome struct {
+	Jobs         []string `json:"jobs"`          // Jobs that run, in workflow order
+	Environments []string `json:"environments"`  // Protected environments waiting for approval, in order
+	SelfApproval bool     `json:"self_approval"` // The contributor may approve the merge environment
+
+	mergeJob string
+}
+
+// mergeRoute is one row of the merge approval decision table
+type mergeRoute struct {
+	changeType      string
+	contributorType string
+	canSelfApprove  string // "true", "false", or "" for either
+	job             string
+	environment     string
+	selfApproval    bool
+}
+
+// mergeRoutes mirrors the conditions of the merge approval jobs in
+// main-validation.yml. External contributors never self-approve.
+var mergeRoutes = []mergeRoute{
+	{"non-terraform", "Internal", "true", "merge-self-approval-non-terraform-internal", "merge-approval", true},
+	{"non-terraform", "Internal", "false", "merge-approval-non-terraform-internal", "merge-approval", false},
+	{"non-terraform", "External", "", "merge-approval-non-terraform-external", "external-contributor-merge-approval", false},
+	{"terraform", "Internal", "true", "merge-self-approval-terraform-internal", "merge-approval", true},
+	{"terraform", "Internal", "false", "merge-approval-terraform-internal", "merge-approval", false},
+	{"terraform", "External", "", "merge-approval-terraform-external", "external-contributor-merge-approval", false},
+}
+
+// releaseEnvironment is the environment of the release approval jobs
+const releaseEnvironment = "qa-certification"
+
+// simulateRouting returns the outcome of a main-validation.yml run with
+// the given workflow inputs
+func simulateRouting(inputs map[string]string) (*RoutingOutcome, error) {
+	changeType := inputs["change_type"]
+	contributorType := inputs["contributor_type"]
+	canSelfApprove := inputs["can_self_approve"]
+
+	var route *mergeRoute
+	for i, r := range mergeRoutes {
+		if r.changeType == changeType && r.contributorType == contributorType &&
+			(r.canSelfApprove == "" || r.canSelfApprove == canSelfApprove) {
+			route = &mergeRoutes[i]
+			break
+		}
+	}
+	if route == nil {
+		return nil, fmt.Errorf("no merge approval job runs for change_type=%s, contributor_type=%s, can_self_approve=%s",
+			changeType, contributorType, canSelfApprove)
+	}
+
+	releaseJob := "release-approval"
+	if inputs["dryrun"] == "true" {
+		releaseJob = "release-approval-dry-run"
+	}
+
+	return &RoutingOutcome{
+		Jobs: []string{
+			"merge-approval-routing",
+			"debug-routing-output",
+			route.job,
+			"merge-approval-routing-" + changeType,
+			"post-merge-revalidation-" + changeType,
+			"qa-validation-complete",
+			releaseJob,
+			"release-successfully-triggered",
+		},
+		Environments: []string{route.environment, releaseEnvironment},
+		SelfApproval: route.selfApproval,
+		mergeJob:     route.job,
+	}, nil
+}
+
+// variationInputs returns the routing inputs of a variation, with the
+// default inputs overridden by those of the variation
+func variationInputs(variation WorkflowVariation, config *WorkflowTestConfig) map[string]string {
+	inputs := make(map[string]string)
+	for key, value := range config.DefaultInputs {
+		inputs[key] = value
+	}
+	for key, value := range variation.Inputs {
+		inputs[key] = value
+	}
+	inputs["change_type"] = variation.ChangeType
+	inputs["contributor_type"] = variation.ContributorType
+	inputs["can_self_approve"] = variation.CanSelfApprove
+	return inputs
+}
+
+// workflowJobPattern matches a job key of a workflow, indented by two spaces
+var workflowJobPattern = regexp.MustCompile(`^  ([A-Za-z0-9_-]+):\s*$`)
+
+// workflowEnvironments returns the jobs of a workflow file with the
+// environment of each, "" for jobs without one
+func workflowEnvironments(path string) (map[string]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read workflow '%s': %w", path, err)
+	}
+	defer file.Close()
+
+	jobs := make(map[string]string)
+	inJobs := false
+	job := ""
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "jobs:" {
+			inJobs = true
+			continue
+		}
+		if !inJobs {
+			continue
+		}
+		switch {
+		case line != "" && !strings.HasPrefix(line, " ") && !strings.HasPrefix(line, "#"):
+			// The next top-level key ends the jobs
+			inJobs = false
+		case workflowJobPattern.MatchString(line):
+			job = workflowJobPattern.FindStringSubmatch(line)[1]
+			jobs[job] = ""
+		case job != "" && strings.HasPrefix(line, "    environment:"):
+			jobs[job] = strings.Trim(strings.TrimSpace(strings.TrimPrefix(line, "    environment:")), `"'`)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read workflow '%s': %w", path, err)
+	}
+	return jobs, nil
+}
+
+// checkOutcomeAgainstWorkflow returns the differences between an outcome
+// and the jobs of the workflow, such as a renamed job
+func checkOutcomeAgainstWorkflow(outcome *RoutingOutcome, jobs map[string]string) []string {
+	var problems []string
+	var environments []string
+	for _, job := range outcome.Jobs {
+		environment, ok := jobs[job]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("job '%s' is not in the workflow", job))
+			continue
+		}
+		if environment != "" {
+			environments = append(environments, environment)
+		}
+	}
+	if len(problems) == 0 && !reflect.DeepEqual(environments, outcome.Environments) {
+		problems = append(problems, fmt.Sprintf("the workflow jobs use environments %v, the decision table %v", environments, outcome.Environments))
+	}
+	return problems
+}
+
+// compareOutcomes returns the differences between an expected and an
+// actual outcome
+func compareOutcomes(expected, actual *RoutingOutcome) []string {
+	var differences []string
+	if !reflect.DeepEqual(expected.Jobs, actual.Jobs) {
+		differences = append(differences, fmt.Sprintf("jobs: expected %v, got %v", expected.Jobs, actual.Jobs))
+	}
+	if !reflect.DeepEqual(expected.Environments, actual.Environments) {
+		differences = append(differences, fmt.Sprintf("environments: expected %v, got %v", expected.Environments, actual.Environments))
+	}
+	if expected.SelfApproval != actual.SelfApproval {
+		differences = append(differences, fmt.Sprintf("self_approval: expected %t, got %t", expected.SelfApproval, actual.SelfApproval))
+	}
+	return differences
+}
+
+// simulateVariations evaluates the routing of every variation, compares
+// it with the expected outcome of the variation and with the workflow
+// file, and returns the number of variations that do not match
+func simulateVariations(config *WorkflowTestConfig, workflowPath string) (int, error) {
+	jobs, err := workflowEnvironments(workflowPath)
+	if err != nil {
+		return 0, err
+	}
+
+	failed := 0
+	for _, variation := range config.Variations {
+		var problems []string
+		outcome, err := simulateRouting(variationInputs(variation, config))
+		switch {
+		case err != nil:
+			problems = append(problems, err.Error())
+		case variation.Expected == nil:
+			problems = append(problems, "no 'expected' outcome in the variation")
+		default:
+			problems = append(problems, compareOutcomes(variation.Expected, outcome)...)
+			problems = append(problems, checkOutcomeAgainstWorkflow(outcome, jobs)...)
+		}
+
+		if len(problems) > 0 {
+			failed++
+			fmt.Printf("%s❌ %s%s\n", Red, variation.Name, NC)
+			for _, problem := range problems {
+				fmt.Printf("   %s\n", problem)
+			}
+			continue
+		}
+		approver := "a reviewer"
+		if outcome.SelfApproval {
+			approver = "the contributor"
+		}
+		fmt.Printf("%s✅ %s%s → %s (environments: %s, merge approved by %s)\n", Green, variation.Name, NC,
+			outcome.mergeJob, strings.Join(outcome.Environments, ", "), approver)
+	}
+	return failed, nil
+}
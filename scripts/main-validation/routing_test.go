@@ -0,0 +1,233 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+const testWorkflow = `name: Test
+
+on:
+  workflow_dispatch:
+    inputs:
+      dryrun:
+        type: boolean
+
+jobs:
+  build:
+    runs-on: ubuntu-24.04
+    steps:
+      - name: Build
+        run: |
+          echo "environment: none"
+  deploy:
+    needs: [build]
+    # Protected
+    environment: "production"
+    steps:
+      - run: echo deploy
+`
+
+func TestSimulateRouting(t *testing.T) {
+	tests := []struct {
+		name            string
+		changeType      string
+		contributorType string
+		canSelfApprove  string
+		dryrun          string
+		mergeJob        string
+		environment     string
+		selfApproval    bool
+	}{
+		{"Internal non-terraform self-approval", "non-terraform", "Internal", "true", "true", "merge-self-approval-non-terraform-internal", "merge-approval", true},
+		{"Internal non-terraform manual approval", "non-terraform", "Internal", "false", "true", "merge-approval-non-terraform-internal", "merge-approval", false},
+		{"External non-terraform", "non-terraform", "External", "false", "true", "merge-approval-non-terraform-external", "external-contributor-merge-approval", false},
+		{"External non-terraform cannot self-approve", "non-terraform", "External", "true", "true", "merge-approval-non-terraform-external", "external-contributor-merge-approval", false},
+		{"Internal terraform self-approval", "terraform", "Internal", "true", "true", "merge-self-approval-terraform-internal", "merge-approval", true},
+		{"Internal terraform manual approval", "terraform", "Internal", "false", "false", "merge-approval-terraform-internal", "merge-approval", false},
+		{"External terraform", "terraform", "External", "false", "false", "merge-approval-terraform-external", "external-contributor-merge-approval", false},
+		{"External terraform cannot self-approve", "terraform", "External", "true", "true", "merge-approval-terraform-external", "external-contributor-merge-approval", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			outcome, err := simulateRouting(map[string]string{
+				"change_type":      tt.changeType,
+				"contributor_type": tt.contributorType,
+				"can_self_approve": tt.canSelfApprove,
+				"dryrun":           tt.dryrun,
+			})
+			if err != nil {
+				t.Fatalf("simulateRouting() failed: %v", err)
+			}
+
+			releaseJob := "release-approval"
+			if tt.dryrun == "true" {
+				releaseJob = "release-approval-dry-run"
+			}
+			expected := &RoutingOutcome{
+				Jobs: []string{
+					"merge-approval-routing",
+					"debug-routing-output",
+					tt.mergeJob,
+					"merge-approval-routing-" + tt.changeType,
+					"post-merge-revalidation-" + tt.changeType,
+					"qa-validation-complete",
+					releaseJob,
+					"release-successfully-triggered",
+				},
+				Environments: []string{tt.environment, "qa-certification"},
+				SelfApproval: tt.selfApproval,
+			}
+			if differences := compareOutcomes(expected, outcome); len(differences) > 0 {
+				t.Errorf("simulateRouting() differs: %v", differences)
+			}
+		})
+	}
+
+	if _, err := simulateRouting(map[string]string{"change_type": "docs", "contributor_type": "Internal", "can_self_approve": "true"}); err == nil {
+		t.Error("simulateRouting() with an unknown change_type succeeded, want an error")
+	}
+}
+
+func TestVariationInputs(t *testing.T) {
+	config := &WorkflowTestConfig{DefaultInputs: map[string]string{"dryrun": "true", "code_owners": "owner"}}
+	variation := WorkflowVariation{
+		ChangeType:      "terraform",
+		ContributorType: "External",
+		CanSelfApprove:  "false",
+		Inputs:          map[string]string{"dryrun": "false", "change_type": "non-terraform"},
+	}
+
+	expected := map[string]string{
+		"dryrun":           "false",
+		"code_owners":      "owner",
+		"change_type":      "terraform",
+		"contributor_type": "External",
+		"can_self_approve": "false",
+	}
+	if inputs := variationInputs(variation, config); !reflect.DeepEqual(inputs, expected) {
+		t.Errorf("variationInputs() = %v, want %v", inputs, expected)
+	}
+}
+
+func TestWorkflowEnvironments(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "workflow.yml")
+	if err := os.WriteFile(path, []byte(testWorkflow), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	jobs, err := workflowEnvironments(path)
+	if err != nil {
+		t.Fatalf("workflowEnvironments() failed: %v", err)
+	}
+	expected := map[string]string{"build": "", "deploy": "production"}
+	if !reflect.DeepEqual(jobs, expected) {
+		t.Errorf("workflowEnvironments() = %v, want %v", jobs, expected)
+	}
+
+	if _, err := workflowEnvironments(filepath.Join(t.TempDir(), "missing.yml")); err == nil {
+		t.Error("workflowEnvironments() with a missing file succeeded, want an error")
+	}
+}
+
+func TestCheckOutcomeAgainstWorkflow(t *testing.T) {
+	jobs := map[string]string{"build": "", "deploy": "production"}
+
+	tests := []struct {
+		name     string
+		outcome  *RoutingOutcome
+		problems int
+	}{
+		{"Matching", &RoutingOutcome{Jobs: []string{"build", "deploy"}, Environments: []string{"production"}}, 0},
+		{"Missing job", &RoutingOutcome{Jobs: []string{"build", "release"}, Environments: []string{"production"}}, 1},
+		{"Other environment", &RoutingOutcome{Jobs: []string{"build", "deploy"}, Environments: []string{"staging"}}, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if problems := checkOutcomeAgainstWorkflow(tt.outcome, jobs); len(problems) != tt.problems {
+				t.Errorf("checkOutcomeAgainstWorkflow() = %v, want %d problem(s)", problems, tt.problems)
+			}
+		})
+	}
+}
+
+// TestDecisionTableMatchesWorkflow guards against main-validation.yml
+// changing without the decision table
+func TestDecisionTableMatchesWorkflow(t *testing.T) {
+	jobs, err := workflowEnvironments("../../.github/workflows/main-validation.yml")
+	if err != nil {
+		t.Fatalf("workflowEnvironments() failed: %v", err)
+	}
+
+	for _, route := range mergeRoutes {
+		for _, dryrun := range []string{"true", "false"} {
+			outcome, err := simulateRouting(map[string]string{
+				"change_type":      route.changeType,
+				"contributor_type": route.contributorType,
+				"can_self_approve": route.canSelfApprove,
+				"dryrun":           dryrun,
+			})
+			if err != nil {
+				t.Fatalf("simulateRouting() failed: %v", err)
+			}
+			if problems := checkOutcomeAgainstWorkflow(outcome, jobs); len(problems) > 0 {
+				t.Errorf("%s with dryrun=%s: %v", route.job, dryrun, problems)
+			}
+		}
+	}
+}
+
+// TestConfiguredVariations checks the expected outcomes of the variations
+// in monorepo-config.json
+func TestConfiguredVariations(t *testing.T) {
+	config, err := loadConfig("../../monorepo-config.json")
+	if err != nil {
+		t.Fatalf("loadConfig() failed: %v", err)
+	}
+	if config.WorkflowTests == nil {
+		t.Fatal("monorepo-config.json has no workflow_tests")
+	}
+
+	failed, err := simulateVariations(config.WorkflowTests, "../../.github/workflows/main-validation.yml")
+	if err != nil {
+		t.Fatalf("simulateVariations() failed: %v", err)
+	}
+	if failed > 0 {
+		t.Errorf("%d variation(s) do not match their expected routing", failed)
+	}
+}
+
+func TestSimulateVariations(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "workflow.yml")
+	if err := os.WriteFile(path, []byte(testWorkflow), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	outcome, err := simulateRouting(map[string]string{"change_type": "terraform", "contributor_type": "Internal", "can_self_approve": "true"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := &WorkflowTestConfig{
+		Variations: []WorkflowVariation{
+			{Name: "Without expected", ChangeType: "terraform", ContributorType: "Internal", CanSelfApprove: "true"},
+			{Name: "Wrong approval", ChangeType: "terraform", ContributorType: "Internal", CanSelfApprove: "false", Expected: outcome},
+			{Name: "Jobs not in workflow", ChangeType: "terraform", ContributorType: "Internal", CanSelfApprove: "true", Expected: outcome},
+		},
+	}
+
+	failed, err := simulateVariations(config, path)
+	if err != nil {
+		t.Fatalf("simulateVariations() failed: %v", err)
+	}
+	if failed != 3 {
+		t.Errorf("simulateVariations() = %d failed, want 3", failed)
+	}
+
+	if _, err := simulateVariations(config, filepath.Join(t.TempDir(), "missing.yml")); err == nil {
+		t.Error("simulateVariations() with a missing workflow succeeded, want an error")
+	}
+}
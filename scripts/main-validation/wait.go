@@ -0,0 +1,276 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// completed reports whether the run of a dispatch has completed
+func (r *dispatchedRun) completed() bool {
+	return r.Run != nil && r.Run.Status == "completed"
+}
+
+// succeeded reports whether the run of a dispatch has completed without
+// failing
+func (r *dispatchedRun) succeeded() bool {
+	if !r.completed() {
+		return false
+	}
+	switch r.Run.Conclusion {
+	case "success", "neutral", "skipped":
+		return true
+	}
+	return false
+}
+
+// waitAndReport waits for the runs until they complete or the timeout,
+// prints the status of their jobs, and returns an error when a run did not
+// succeed. With cancelOnExit, the runs still in progress are cancelled
+// when the wait stops early.
+func waitAndReport(ctx context.Context, d WorkflowDispatcher, runs []dispatchedRun, timeout time.Duration, cancelOnExit bool) error {
+	fmt.Printf("%s⏳ Waiting up to %s for %d runs to complete...%s\n", Blue, timeout, len(runs), NC)
+	waitCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	waitErr := waitForRuns(waitCtx, d, runs, pollInterval)
+
+	printRunMatrix(os.Stdout, runs)
+
+	if waitErr != nil {
+		if cancelOnExit {
+			cancelRuns(d, runs)
+		}
+		if errors.Is(waitErr, context.DeadlineExceeded) {
+			return fmt.Errorf("timed out after %s waiting for the runs", timeout)
+		}
+		return fmt.Errorf("stopped waiting for the runs: %w", waitErr)
+	}
+
+	var failed []string
+	for _, r := range runs {
+		if !r.succeeded() {
+			failed = append(failed, r.Variation.Name)
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("%d out of %d runs did not succeed: %s", len(failed), len(runs), strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// waitForRuns polls the runs until they all complete or ctx is done,
+// keeping the run and jobs of each up to date. API errors are reported
+// and polled again, as the wait outlasts a brief GitHub outage.
+func waitForRuns(ctx context.Context, d WorkflowDispatcher, runs []dispatchedRun, interval time.Duration) error {
+	waiting := make(map[string]string) // What each run last waited on, by pr_number
+	for {
+		done := true
+		for i := range runs {
+			err := pollRun(ctx, d, &runs[i], waiting)
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if err != nil {
+				fmt.Printf("%s⚠️  %s: %v%s\n", Yellow, runs[i].Variation.Name, err, NC)
+			}
+			if !runs[i].completed() {
+				done = false
+			}
+		}
+		if done {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(interval):
+		}
+	}
+}
+
+// pollRun refreshes the run and jobs of a dispatch, finding the run first
+// when GitHub did not list it at dispatch time, and prints when the run
+// starts waiting for approval or completes
+func pollRun(ctx context.Context, d WorkflowDispatcher, r *dispatchedRun, waiting map[string]string) error {
+	if r.completed() {
+		return nil
+	}
+	if r.Run == nil {
+		run, err := d.FindRunByInput(ctx, mainValidationWorkflow, r.PRNumber, r.Since)
+		if errors.Is(err, errRunNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%s🔗 %s: %s%s\n", Blue, r.Variation.Name, run.HTMLURL, NC)
+		r.Run = run
+	}
+
+	run, err := d.RunStatus(ctx, r.Run.ID)
+	if err != nil {
+		return err
+	}
+	jobs, err := d.RunJobs(ctx, run.ID)
+	if err != nil {
+		return err
+	}
+	r.Run = run
+	r.Jobs = jobs
+
+	if r.completed() {
+		if r.succeeded() {
+			fmt.Printf("%s✅ %s completed: %s%s\n", Green, r.Variation.Name, run.Conclusion, NC)
+		} else {
+			fmt.Printf("%s❌ %s completed: %s: %s%s\n", Red, r.Variation.Name, run.Conclusion, run.HTMLURL, NC)
+		}
+		return nil
+	}
+
+	pending := ""
+	if run.Status == "waiting" {
+		deployments, err := d.PendingDeployments(ctx, run.ID)
+		if err != nil {
+			return err
+		}
+		pending = describePending(deployments, jobs)
+	}
+	if pending != waiting[r.PRNumber] {
+		waiting[r.PRNumber] = pending
+		if pending != "" {
+			fmt.Printf("%s🟡 %s is waiting for approval of %s: %s%s\n", Yellow, r.Variation.Name, pending, run.HTMLURL, NC)
+		}
+	}
+	return nil
+}
+
+// describePending describes the environments a run waits on and the jobs
+// waiting for them
+func describePending(deployments []PendingDeployment, jobs []WorkflowJob) string {
+	if len(deployments) == 0 {
+		return ""
+	}
+
+	var environments []string
+	canApprove := false
+	for _, deployment := range deployments {
+		environments = append(environments, deployment.Environment.Name)
+		canApprove = canApprove || deployment.CurrentUserCanApprove
+	}
+	var waitingJobs []string
+	for _, job := range jobs {
+		if job.Status == "waiting" {
+			waitingJobs = append(waitingJobs, job.Name)
+		}
+	}
+
+	description := "environment " + strings.Join(environments, ", ")
+	if len(waitingJobs) > 0 {
+		description += " (" + strings.Join(waitingJobs, ", ") + ")"
+	}
+	if canApprove {
+		description += ", which you can approve"
+	}
+	return description
+}
+
+// printRunMatrix prints the status of every job of the runs, with a row
+// per job and a column per variation, numbered as in their pr_number
+func printRunMatrix(w io.Writer, runs []dispatchedRun) {
+	fmt.Fprintf(w, "\n%s=== Run Results ===%s\n", Blue, NC)
+	for _, r := range runs {
+		fmt.Fprintf(w, "%d: %s (pr_number %s)\n", r.Number, r.Variation.Name, r.PRNumber)
+	}
+	fmt.Fprintln(w)
+
+	// Jobs in the order the runs list them
+	var names []string
+	seen := make(map[string]bool)
+	for _, r := range runs {
+		for _, job := range r.Jobs {
+			if !seen[job.Name] {
+				seen[job.Name] = true
+				names = append(names, job.Name)
+			}
+		}
+	}
+
+	table := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	header := []string{"JOB"}
+	for _, r := range runs {
+		header = append(header, strconv.Itoa(r.Number))
+	}
+	fmt.Fprintln(table, strings.Join(header, "\t"))
+	for _, name := range names {
+		row := []string{name}
+		for _, r := range runs {
+			row = append(row, jobCell(r.Jobs, name))
+		}
+		fmt.Fprintln(table, strings.Join(row, "\t"))
+	}
+	row := []string{"RUN"}
+	for _, r := range runs {
+		row = append(row, runCell(r))
+	}
+	fmt.Fprintln(table, strings.Join(row, "\t"))
+	table.Flush()
+}
+
+// jobCell returns the status of the named job of a run for the matrix
+func jobCell(jobs []WorkflowJob, name string) string {
+	for _, job := range jobs {
+		if job.Name != name {
+			continue
+		}
+		if job.Status == "completed" && job.Conclusion != "" {
+			return job.Conclusion
+		}
+		return job.Status
+	}
+	return "-"
+}
+
+// runCell returns the status of a run for the matrix
+func runCell(r dispatchedRun) string {
+	switch {
+	case r.Run == nil:
+		return "not found"
+	case r.completed() && r.Run.Conclusion != "":
+		return r.Run.Conclusion
+	}
+	return r.Run.Status
+}
+
+// cancelRuns cancels the runs that have not completed
+func cancelRuns(d WorkflowDispatcher, runs []dispatchedRun) {
+	// The context of the wait is done by now
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	for _, r := range runs {
+		if r.Run == nil {
+			// The run may have been listed since the last poll
+			run, err := d.FindRunByInput(ctx, mainValidationWorkflow, r.PRNumber, r.Since)
+			if err != nil {
+				fmt.Printf("%s⚠️  Failed to cancel %s: %v%s\n", Yellow, r.Variation.Name, err, NC)
+				continue
+			}
+			r.Run = run
+		}
+		if r.completed() {
+			continue
+		}
+		if err := d.Cancel(ctx, r.Run.ID); err != nil {
+			fmt.Printf("%s⚠️  Failed to cancel %s: %v%s\n", Yellow, r.Variation.Name, err, NC)
+			continue
+		}
+		fmt.Printf("%s🚫 Cancelled %s: %s%s\n", Yellow, r.Variation.Name, r.Run.HTMLURL, NC)
+	}
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+// triggerTestRuns dispatches the variations of testWorkflowConfig to a
+// fake GitHub API
+func triggerTestRuns(t *testing.T, fake *fakeGitHub) (*githubClient, []dispatchedRun) {
+	t.Helper()
+	withFastPolling(t)
+	client := newTestClient(t, fake)
+	config := testWorkflowConfig()
+	if err := selectVariations(config, ""); err != nil {
+		t.Fatal(err)
+	}
+	runs := triggerVariations(context.Background(), client, config, "main", "20240101-120000")
+	if len(runs) != len(config.Variations) {
+		t.Fatalf("triggerVariations() = %d runs, want %d", len(runs), len(config.Variations))
+	}
+	return client, runs
+}
+
+// testJobs returns the jobs of a run with the given merge and release
+// job states, as status or status:conclusion
+func testJobs(merge, release string) []WorkflowJob {
+	jobs := []WorkflowJob{
+		{Name: "Merge Approval Routing", Status: "completed", Conclusion: "success"},
+		{Name: "Merge Approval (Terraform Internal)"},
+		{Name: "Release Approval (Dry Run)"},
+	}
+	jobs[1].Status, jobs[1].Conclusion, _ = strings.Cut(merge, ":")
+	jobs[2].Status, jobs[2].Conclusion, _ = strings.Cut(release, ":")
+	return jobs
+}
+
+func TestWaitAndReport(t *testing.T) {
+	var deployment PendingDeployment
+	deployment.Environment.Name = "merge-approval"
+	deployment.CurrentUserCanApprove = true
+	fake := &fakeGitHub{
+		progress: map[int64][]string{
+			1: {"waiting", "in_progress", "completed:success"},
+			2: {"in_progress", "completed:failure"},
+			3: {"completed:success"},
+		},
+		jobs: map[int64][]WorkflowJob{
+			1: testJobs("completed:success", "completed:success"),
+			2: testJobs("completed:success", "queued"),
+			3: testJobs("completed:success", "completed:success"),
+		},
+		pending: map[int64][]PendingDeployment{1: {deployment}},
+	}
+	client, runs := triggerTestRuns(t, fake)
+	// The third run is only listed by GitHub while waiting
+	runs[2].Run = nil
+
+	err := waitAndReport(context.Background(), client, runs, 5*time.Second, true)
+	if err == nil || !strings.Contains(err.Error(), "1 out of 3 runs did not succeed: External") {
+		t.Errorf("waitAndReport() = %v, want External to fail", err)
+	}
+	for _, r := range runs {
+		if !r.completed() || len(r.Jobs) != 3 {
+			t.Errorf("run of %s = %+v with %d jobs, want completed with 3 jobs", r.Variation.Name, r.Run, len(r.Jobs))
+		}
+	}
+	if len(fake.cancelled) > 0 {
+		t.Errorf("cancelled = %v, want no run cancelled once all completed", fake.cancelled)
+	}
+
+	fake.progress[2] = []string{"completed:success"}
+	for i := range runs {
+		runs[i].Run.Status = "in_progress"
+	}
+	if err := waitAndReport(context.Background(), client, runs, 5*time.Second, true); err != nil {
+		t.Errorf("waitAndReport() of successful runs = %v, want nil", err)
+	}
+}
+
+func TestWaitAndReportTimeout(t *testing.T) {
+	fake := &fakeGitHub{
+		progress: map[int64][]string{
+			1: {"completed:success"},
+			2: {"waiting"},
+			3: {"in_progress"},
+		},
+	}
+	client, runs := triggerTestRuns(t, fake)
+
+	err := waitAndReport(context.Background(), client, runs, 100*time.Millisecond, false)
+	if err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Errorf("waitAndReport() = %v, want a timeout", err)
+	}
+	if len(fake.cancelled) > 0 {
+		t.Errorf("cancelled = %v without --cancel-on-exit, want none", fake.cancelled)
+	}
+
+	err = waitAndReport(context.Background(), client, runs, 100*time.Millisecond, true)
+	if err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Errorf("waitAndReport() = %v, want a timeout", err)
+	}
+	// The completed run is left alone
+	if len(fake.cancelled) != 2 || fake.cancelled[0] != 2 || fake.cancelled[1] != 3 {
+		t.Errorf("cancelled = %v, want runs 2 and 3", fake.cancelled)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := waitAndReport(ctx, client, runs, time.Minute, false); err == nil || !strings.Contains(err.Error(), "stopped waiting") {
+		t.Errorf("waitAndReport() when interrupted = %v, want it to stop", err)
+	}
+}
+
+func TestDescribePending(t *testing.T) {
+	var merge, release PendingDeployment
+	merge.Environment.Name = "merge-approval"
+	release.Environment.Name = "qa-certification"
+	release.CurrentUserCanApprove = true
+	jobs := []WorkflowJob{
+		{Name: "Merge Approval Routing", Status: "completed"},
+		{Name: "Merge Approval (Terraform Internal)", Status: "waiting"},
+	}
+
+	tests := []struct {
+		name        string
+		deployments []PendingDeployment
+		expected    string
+	}{
+		{"Nothing pending", nil, ""},
+		{"Merge approval", []PendingDeployment{merge}, "environment merge-approval (Merge Approval (Terraform Internal))"},
+		{"Approvable", []PendingDeployment{merge, release}, "environment merge-approval, qa-certification (Merge Approval (Terraform Internal)), which you can approve"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if description := describePending(tt.deployments, jobs); description != tt.expected {
+				t.Errorf("describePending() = %q, want %q", description, tt.expected)
+			}
+		})
+	}
+}
+
+func TestPrintRunMatrix(t *testing.T) {
+	runs := []dispatchedRun{
+		{
+			Variation: WorkflowVariation{Name: "Internal-Terraform-SelfApproval"},
+			Number:    4,
+			PRNumber:  "test4-20240101-120000",
+			Run:       &WorkflowRun{Status: "completed", Conclusion: "success"},
+			Jobs:      testJobs("completed:success", "completed:success"),
+		},
+		{
+			Variation: WorkflowVariation{Name: "External-Terraform-ManualApproval"},
+			Number:    6,
+			PRNumber:  "test6-20240101-120000",
+			Run:       &WorkflowRun{Status: "waiting"},
+			Jobs: []WorkflowJob{
+				{Name: "Merge Approval Routing", Status: "completed", Conclusion: "success"},
+				{Name: "Merge Approval (Terraform External)", Status: "waiting"},
+			},
+		},
+		{
+			Variation: WorkflowVariation{Name: "Internal-Terraform-ManualApproval"},
+			Number:    5,
+			PRNumber:  "test5-20240101-120000",
+		},
+	}
+
+	var out bytes.Buffer
+	printRunMatrix(&out, runs)
+	for _, line := range []string{
+		"4: Internal-Terraform-SelfApproval (pr_number test4-20240101-120000)",
+		"JOB                                  4        6        5\n",
+		"Merge Approval Routing               success  success  -\n",
+		"Merge Approval (Terraform Internal)  success  -        -\n",
+		"Release Approval (Dry Run)           success  -        -\n",
+		"Merge Approval (Terraform External)  -        waiting  -\n",
+		"RUN                                  success  waiting  not found\n",
+	} {
+		if !strings.Contains(out.String(), line) {
+			t.Errorf("printRunMatrix() output has no line %q:\n%s", line, out.String())
+		}
+	}
+}
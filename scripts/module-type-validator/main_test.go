@@ -1,7 +1,6 @@
 package main
 
 import (
-	"io/ioutil"
 	"os"
 	"path/filepath"
 	"testing"
@@ -17,7 +16,7 @@ func TestLoadConfig(t *testing.T) {
 		}
 	}`
 
-	tmpFile, err := ioutil.TempFile("", "config-*.json")
+	tmpFile, err := os.CreateTemp("", "config-*.json")
 	if err != nil {
 		t.Fatalf("Failed to create temp file: %v", err)
 	}
@@ -97,7 +96,7 @@ func TestGlobToRegex(t *testing.T) {
 
 func TestDetectModuleType(t *testing.T) {
 	// Create a temporary directory structure
-	tmpDir, err := ioutil.TempDir("", "module-test")
+	tmpDir, err := os.MkdirTemp("", "module-test")
 	if err != nil {
 		t.Fatalf("Failed to create temp dir: %v", err)
 	}
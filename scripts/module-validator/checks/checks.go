@@ -0,0 +1,356 @@
+// Package checks holds the Go-native module checks shared by module-validator
+// and the monorepo language server, so that both report the same violations.
+package checks
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+)
+
+// Check is a Go-native module check evaluated alongside the Rego policies.
+// Checks cover analysis that is awkward to express in Rego, such as
+// cross-file reference resolution or parsing code blocks out of Markdown.
+type Check interface {
+	// ID returns the identifier used to select the check in monorepo-config.json
+	ID() string
+	// Applies reports whether the check is relevant for a module type
+	Applies(moduleType string) bool
+	// Run evaluates the check and returns any violations found
+	Run(ctx context.Context, input *ModuleInput) []Violation
+}
+
+// Violation is the result model shared by Rego policies and Go checks. File
+// and Line locate the violation when the check knows where it is.
+type Violation struct {
+	Policy     string `json:"policy,omitempty"`
+	Severity   string `json:"severity,omitempty"`
+	Message    string `json:"message"`
+	Details    string `json:"details,omitempty"`
+	Resolution string `json:"resolution,omitempty"`
+	File       string `json:"file,omitempty"` // Input file key, rooted at the module name
+	Line       int    `json:"line,omitempty"` // 1-based line in File
+}
+
+// ModuleInput is the module-scoped document evaluated by policies and checks.
+// File keys are rooted at the module name rather than the repository path.
+type ModuleInput struct {
+	ModulePath string            `json:"module_path"`
+	RepoPath   string            `json:"repo_path"`
+	Files      map[string]string `json:"files"`
+}
+
+// RootFiles returns the sorted paths of files directly in the module root
+// that have the given extension
+func (m *ModuleInput) RootFiles(ext string) []string {
+	var paths []string
+	prefix := m.ModulePath + "/"
+	for path := range m.Files {
+		if !strings.HasPrefix(path, prefix) || !strings.HasSuffix(path, ext) {
+			continue
+		}
+		if strings.Contains(strings.TrimPrefix(path, prefix), "/") {
+			continue
+		}
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// registry holds all available checks keyed by ID
+var registry = map[string]Check{}
+
+// Register adds a check to the registry
+func Register(check Check) {
+	if _, exists := registry[check.ID()]; exists {
+		panic(fmt.Sprintf("check %q registered twice", check.ID()))
+	}
+	registry[check.ID()] = check
+}
+
+// Lookup returns the registered check with the given ID
+func Lookup(id string) (Check, bool) {
+	check, ok := registry[id]
+	return check, ok
+}
+
+// Run runs a check and fills in defaults for the shared violation model
+func Run(ctx context.Context, check Check, input *ModuleInput) []Violation {
+	violations := check.Run(ctx, input)
+	for i := range violations {
+		if violations[i].Policy == "" {
+			violations[i].Policy = check.ID()
+		}
+		if violations[i].Severity == "" {
+			violations[i].Severity = "error"
+		}
+	}
+	return violations
+}
+
+func init() {
+	Register(variableReferencesCheck{})
+	Register(readmeHCLBlocksCheck{})
+}
+
+//
+// ---------- variable_references ----------
+//
+
+// variableReferencesCheck verifies that every var.<name> used in the module
+// root is declared by a variable block in one of the root .tf files. The
+// files are parsed as HCL, so comments, strings and heredocs that mention
+// var.<name> are not references.
+type variableReferencesCheck struct{}
+
+func (variableReferencesCheck) ID() string { return "variable_references" }
+
+func (variableReferencesCheck) Applies(moduleType string) bool { return true }
+
+func (c variableReferencesCheck) Run(ctx context.Context, input *ModuleInput) []Violation {
+	var violations []Violation
+	bodies := make(map[string]*hclsyntax.Body)
+	declared := make(map[string]bool)
+	for _, path := range input.RootFiles(".tf") {
+		file, diags := hclsyntax.ParseConfig([]byte(input.Files[path]), path, hcl.InitialPos)
+		if diags.HasErrors() {
+			violation := Violation{
+				Message:    "Terraform file does not parse",
+				Details:    diags.Error(),
+				Resolution: "Fix the HCL syntax so the references of the file can be checked",
+				File:       path,
+			}
+			if subject := diags[0].Subject; subject != nil {
+				violation.Line = subject.Start.Line
+			}
+			violations = append(violations, violation)
+			continue
+		}
+		body := file.Body.(*hclsyntax.Body)
+		bodies[path] = body
+		for _, block := range body.Blocks {
+			if block.Type == "variable" && len(block.Labels) == 1 {
+				declared[block.Labels[0]] = true
+			}
+		}
+	}
+
+	for _, path := range input.RootFiles(".tf") {
+		body, ok := bodies[path]
+		if !ok {
+			continue
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		reported := make(map[string]bool)
+		for _, traversal := range variableTraversals(body) {
+			name, ok := variableName(traversal)
+			if !ok || declared[name] || reported[name] {
+				continue
+			}
+			reported[name] = true
+			line := traversal.SourceRange().Start.Line
+			violations = append(violations, Violation{
+				Message:    "Reference to undeclared variable",
+				Details:    fmt.Sprintf("File '%s' references var.%s on line %d but no variable \"%s\" block is declared in the module root", path, name, line, name),
+				Resolution: fmt.Sprintf("Declare variable \"%s\" in variables.tf or remove the reference", name),
+				File:       path,
+				Line:       line,
+			})
+		}
+	}
+	return violations
+}
+
+// variableTraversals returns the variable traversals of every expression
+// in a body and its nested blocks, in source order
+func variableTraversals(body *hclsyntax.Body) []hcl.Traversal {
+	var traversals []hcl.Traversal
+	var collect func(body *hclsyntax.Body)
+	collect = func(body *hclsyntax.Body) {
+		for _, attribute := range body.Attributes {
+			traversals = append(traversals, attribute.Expr.Variables()...)
+		}
+		for _, block := range body.Blocks {
+			collect(block.Body)
+		}
+	}
+	collect(body)
+
+	// Attributes are a map, so their order is restored from the source
+	sort.SliceStable(traversals, func(i, j int) bool {
+		return traversals[i].SourceRange().Start.Byte < traversals[j].SourceRange().Start.Byte
+	})
+	return traversals
+}
+
+// variableName returns the name of the input variable a traversal such
+// as var.name.attribute refers to
+func variableName(traversal hcl.Traversal) (string, bool) {
+	if traversal.RootName() != "var" || len(traversal) < 2 {
+		return "", false
+	}
+	attr, ok := traversal[1].(hcl.TraverseAttr)
+	if !ok {
+		return "", false
+	}
+	return attr.Name, true
+}
+
+//
+// ---------- readme_hcl_blocks ----------
+//
+
+// readmeHCLBlocksCheck verifies that HCL code blocks in the module README are
+// closed and have balanced braces, brackets and parentheses
+type readmeHCLBlocksCheck struct{}
+
+func (readmeHCLBlocksCheck) ID() string { return "readme_hcl_blocks" }
+
+func (readmeHCLBlocksCheck) Applies(moduleType string) bool { return true }
+
+func (c readmeHCLBlocksCheck) Run(ctx context.Context, input *ModuleInput) []Violation {
+	readmePath := input.ModulePath + "/README.md"
+	content, ok := input.Files[readmePath]
+	if !ok {
+		return nil
+	}
+
+	var violations []Violation
+	for _, block := range extractCodeBlocks(content) {
+		if !isHCLLanguage(block.Language) {
+			continue
+		}
+		if !block.Closed {
+			violations = append(violations, Violation{
+				Message:    "Unterminated HCL code block in README",
+				Details:    fmt.Sprintf("Code block starting at %s:%d is never closed", readmePath, block.StartLine),
+				Resolution: "Close the code block with a matching ``` fence",
+				File:       readmePath,
+				Line:       block.StartLine,
+			})
+			continue
+		}
+		if problem := checkBalanced(block.Body); problem != "" {
+			violations = append(violations, Violation{
+				Message:    "Malformed HCL code block in README",
+				Details:    fmt.Sprintf("Code block starting at %s:%d: %s", readmePath, block.StartLine, problem),
+				Resolution: "Fix the example so it is valid Terraform that users can copy",
+				File:       readmePath,
+				Line:       block.StartLine,
+			})
+		}
+	}
+	return violations
+}
+
+// codeBlock is a fenced code block extracted from Markdown
+type codeBlock struct {
+	Language  string
+	Body      string
+	StartLine int
+	Closed    bool
+}
+
+// extractCodeBlocks returns the fenced code blocks in a Markdown document
+func extractCodeBlocks(content string) []codeBlock {
+	var blocks []codeBlock
+	var current *codeBlock
+	var body []string
+
+	for i, line := range strings.Split(content, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if !strings.HasPrefix(trimmed, "```") {
+			if current != nil {
+				body = append(body, line)
+			}
+			continue
+		}
+		if current == nil {
+			current = &codeBlock{
+				Language:  strings.TrimSpace(strings.TrimPrefix(trimmed, "```")),
+				StartLine: i + 1,
+			}
+			body = nil
+			continue
+		}
+		current.Body = strings.Join(body, "\n")
+		current.Closed = true
+		blocks = append(blocks, *current)
+		current = nil
+	}
+
+	if current != nil {
+		current.Body = strings.Join(body, "\n")
+		blocks = append(blocks, *current)
+	}
+	return blocks
+}
+
+// isHCLLanguage reports whether a code fence language denotes Terraform
+func isHCLLanguage(language string) bool {
+	fields := strings.Fields(language)
+	if len(fields) == 0 {
+		return false
+	}
+	switch strings.ToLower(fields[0]) {
+	case "hcl", "terraform", "tf":
+		return true
+	}
+	return false
+}
+
+// checkBalanced returns a description of the first unbalanced delimiter in an
+// HCL snippet, ignoring string literals and comments, or "" if balanced
+func checkBalanced(body string) string {
+	pairs := map[rune]rune{'}': '{', ']': '[', ')': '('}
+	var stack []rune
+	var lines []int
+
+	for lineNo, line := range strings.Split(body, "\n") {
+		inString := false
+		escaped := false
+		runes := []rune(line)
+		for i := 0; i < len(runes); i++ {
+			r := runes[i]
+			if inString {
+				switch {
+				case escaped:
+					escaped = false
+				case r == '\\':
+					escaped = true
+				case r == '"':
+					inString = false
+				}
+				continue
+			}
+			if r == '#' || (r == '/' && i+1 < len(runes) && runes[i+1] == '/') {
+				break
+			}
+			switch r {
+			case '"':
+				inString = true
+			case '{', '[', '(':
+				stack = append(stack, r)
+				lines = append(lines, lineNo+1)
+			case '}', ']', ')':
+				if len(stack) == 0 || stack[len(stack)-1] != pairs[r] {
+					return fmt.Sprintf("unexpected '%c' on line %d of the block", r, lineNo+1)
+				}
+				stack = stack[:len(stack)-1]
+				lines = lines[:len(lines)-1]
+			}
+		}
+	}
+
+	if len(stack) > 0 {
+		return fmt.Sprintf("unclosed '%c' opened on line %d of the block", stack[len(stack)-1], lines[len(lines)-1])
+	}
+	return ""
+}
@@ -0,0 +1,162 @@
+package checks
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestVariableReferencesCheck(t *testing.T) {
+	tests := []struct {
+		name     string
+		files    map[string]string
+		expected int
+	}{
+		{
+			name: "All references declared",
+			files: map[string]string{
+				"mod/main.tf":      "resource \"x\" \"y\" {\n  name = var.name\n}",
+				"mod/variables.tf": "variable \"name\" {\n  type = string\n}",
+			},
+			expected: 0,
+		},
+		{
+			name: "Undeclared reference",
+			files: map[string]string{
+				"mod/main.tf":      "resource \"x\" \"y\" {\n  name = var.name\n  size = var.size\n}",
+				"mod/variables.tf": "variable \"name\" {}",
+			},
+			expected: 1,
+		},
+		{
+			name: "Commented reference ignored",
+			files: map[string]string{
+				"mod/main.tf": "# uses var.missing\n// var.other",
+			},
+			expected: 0,
+		},
+		{
+			name: "Block and trailing comments ignored",
+			files: map[string]string{
+				"mod/main.tf": "/*\nvariable \"missing\" {}\nname = var.other\n*/\nlocals {\n  a = 1 # var.trailing\n  b = 2 /* var.inline */\n}",
+			},
+			expected: 0,
+		},
+		{
+			name: "Commented declaration does not declare",
+			files: map[string]string{
+				"mod/main.tf": "/* variable \"name\" {} */\nlocals {\n  a = var.name\n}",
+			},
+			expected: 1,
+		},
+		{
+			name: "Strings and heredocs",
+			files: map[string]string{
+				"mod/main.tf": "locals {\n  a = \"var.literal\"\n  b = <<-EOT\n    var.heredoc\n  EOT\n  c = \"${var.interpolated}\"\n  d = <<-EOT\n    ${var.in_heredoc}\n  EOT\n}",
+			},
+			expected: 2,
+		},
+		{
+			name: "References in nested blocks and expressions",
+			files: map[string]string{
+				"mod/main.tf":      "resource \"x\" \"y\" {\n  dynamic \"rule\" {\n    for_each = var.rules\n    content {\n      name = rule.value.name\n      tags = [for k, v in var.tags : upper(v)]\n    }\n  }\n}",
+				"mod/variables.tf": "variable \"rules\" {}",
+			},
+			expected: 1,
+		},
+		{
+			name: "Unparseable file",
+			files: map[string]string{
+				"mod/main.tf": "resource \"x\" \"y\" {\n  name = var.name\n",
+			},
+			expected: 1,
+		},
+		{
+			name: "Example files ignored",
+			files: map[string]string{
+				"mod/examples/basic/main.tf": "name = var.missing",
+			},
+			expected: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := &ModuleInput{ModulePath: "mod", Files: tt.files}
+			violations := Run(context.Background(), variableReferencesCheck{}, input)
+			if len(violations) != tt.expected {
+				t.Fatalf("Expected %d violations, got %d: %+v", tt.expected, len(violations), violations)
+			}
+			for _, v := range violations {
+				if v.Policy != "variable_references" || v.Severity != "error" {
+					t.Errorf("Expected defaults to be filled in, got %+v", v)
+				}
+				if v.File != "mod/main.tf" || v.Line == 0 {
+					t.Errorf("Expected the violation to be located in mod/main.tf, got %+v", v)
+				}
+			}
+		})
+	}
+}
+
+func TestReadmeHCLBlocksCheck(t *testing.T) {
+	tests := []struct {
+		name     string
+		readme   string
+		expected int
+		contains string
+	}{
+		{
+			name:     "Valid block",
+			readme:   "# Title\n```hcl\nmodule \"x\" {\n  tags = { a = \"}\" }\n}\n```\n",
+			expected: 0,
+		},
+		{
+			name:     "Unbalanced block",
+			readme:   "```terraform\nmodule \"x\" {\n  source = \"./\"\n```\n",
+			expected: 1,
+			contains: "unclosed '{'",
+		},
+		{
+			name:     "Unterminated block",
+			readme:   "```hcl\nmodule \"x\" {}\n",
+			expected: 1,
+			contains: "never closed",
+		},
+		{
+			name:     "Non-HCL block ignored",
+			readme:   "```bash\necho {\n```\n",
+			expected: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := &ModuleInput{ModulePath: "mod", Files: map[string]string{"mod/README.md": tt.readme}}
+			violations := readmeHCLBlocksCheck{}.Run(context.Background(), input)
+			if len(violations) != tt.expected {
+				t.Fatalf("Expected %d violations, got %d: %+v", tt.expected, len(violations), violations)
+			}
+			if tt.contains != "" && !strings.Contains(violations[0].Details, tt.contains) {
+				t.Errorf("Expected details to contain %q, got %q", tt.contains, violations[0].Details)
+			}
+		})
+	}
+}
+
+func TestCheckRegistry(t *testing.T) {
+	for _, id := range []string{"variable_references", "readme_hcl_blocks"} {
+		check, ok := Lookup(id)
+		if !ok {
+			t.Errorf("Expected check %s to be registered", id)
+			continue
+		}
+		if check.ID() != id {
+			t.Errorf("Expected ID %s, got %s", id, check.ID())
+		}
+	}
+
+	if _, ok := Lookup("does_not_exist"); ok {
+		t.Errorf("Expected unknown check lookup to fail")
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+
+	"github.com/open-policy-agent/opa/ast"
+	"github.com/open-policy-agent/opa/rego"
+
+	"github.com/terraform-modules/scripts/module-validator/policies"
+)
+
+// ruleEvaluator evaluates a single rule of a policy against the module input
+// and returns the rule value. Undefined rules return nil.
+type ruleEvaluator func(source PolicySource, rule string) (interface{}, error)
+
+// newOPAEvaluator returns an evaluator that runs `opa eval` for each rule.
+// The input is written to a temporary file, removed by the returned cleanup.
+func newOPAEvaluator(input *ModuleInput) (ruleEvaluator, func(), error) {
+	// Write the modified input to a temporary file for OPA
+	inputFile, err := os.CreateTemp("", "modified-input-*.json")
+	if err != nil {
+		return nil, nil, fmt.Errorf("error creating modified input file: %w", err)
+	}
+	inputFile.Close()
+	cleanup := func() { os.Remove(inputFile.Name()) }
+	logMessage(LevelDebug, "Created modified input file: %s", inputFile.Name())
+
+	modifiedInputData, err := json.MarshalIndent(input, "", "  ")
+	if err != nil {
+		cleanup()
+		return nil, nil, fmt.Errorf("error marshaling modified input: %w", err)
+	}
+	if err := os.WriteFile(inputFile.Name(), modifiedInputData, 0644); err != nil {
+		cleanup()
+		return nil, nil, fmt.Errorf("error writing modified input: %w", err)
+	}
+	logMessage(LevelTrace, "Modified input JSON:\n%s", string(modifiedInputData))
+
+	evaluate := func(source PolicySource, rule string) (interface{}, error) {
+		opaArgs := []string{
+			"eval",
+			"--format", "json",
+			"--data", source.File,
+			"data." + source.Package + "." + rule,
+			"--input", inputFile.Name(),
+		}
+
+		logMessage(LevelDebug, "Running OPA with args: %v", opaArgs)
+		cmd := exec.Command("opa", opaArgs...)
+		output, err := cmd.CombinedOutput()
+
+		// Only show OPA output in trace mode (more detailed than debug)
+		logMessage(LevelTrace, "OPA output for rule %s: %s", ruleDisplayName(rule), string(output))
+
+		if err != nil {
+			return nil, fmt.Errorf("%v\nOPA output: %s", err, string(output))
+		}
+
+		value, err := parseEvalValue(output)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing rule results: %w", err)
+		}
+		return value, nil
+	}
+
+	return evaluate, cleanup, nil
+}
+
+// newSDKEvaluator returns an evaluator that uses the embedded OPA SDK, so no
+// opa binary or temporary files are needed. Like `opa eval --data`, each
+// policy file is evaluated on its own.
+func newSDKEvaluator(ctx context.Context, input *ModuleInput) (ruleEvaluator, error) {
+	inputValue, err := ast.InterfaceToValue(input)
+	if err != nil {
+		return nil, fmt.Errorf("error converting module input: %w", err)
+	}
+
+	evaluate := func(source PolicySource, rule string) (interface{}, error) {
+		data, err := os.ReadFile(source.File)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read policy %s: %w", source.File, err)
+		}
+
+		r := rego.New(
+			rego.Query("data."+source.Package+"."+rule),
+			rego.Module(source.File, string(data)),
+			rego.ParsedInput(inputValue),
+			rego.SetRegoVersion(policies.RegoVersion),
+		)
+		rs, err := r.Eval(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if len(rs) == 0 || len(rs[0].Expressions) == 0 {
+			// Undefined rules produce no results, which means a pass
+			return nil, nil
+		}
+		return rs[0].Expressions[0].Value, nil
+	}
+
+	return evaluate, nil
+}
@@ -0,0 +1,306 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/open-policy-agent/opa/ast"
+	"github.com/open-policy-agent/opa/rego"
+	"github.com/open-policy-agent/opa/topdown"
+	"github.com/open-policy-agent/opa/topdown/lineage"
+
+	"github.com/terraform-modules/scripts/module-validator/policies"
+)
+
+// maxExplainValueLength limits how much of a bound value is printed
+const maxExplainValueLength = 120
+
+// regexBuiltins are the builtins whose matches are shown in explain output
+var regexBuiltins = map[string]bool{
+	"regex.match": true,
+	"re_match":    true,
+}
+
+// Explanation describes why a rule produced violations
+type Explanation struct {
+	Notes    string        // Pretty-printed trace() notes
+	Bindings []RuleBinding // Variable bindings for each successful rule body
+	Regexes  []RegexMatch  // Regex helper calls that matched
+	Results  int           // Number of values produced by the rule
+}
+
+// RuleBinding is the set of named variables bound when a rule body succeeded
+type RuleBinding struct {
+	Location string
+	Vars     map[string]string
+}
+
+// RegexMatch is a regex builtin call that matched part of an input value
+type RegexMatch struct {
+	Location string // Location of the regex call in the policy
+	Pattern  string
+	Match    string // Matching substring
+	Source   string // Input file containing the match, if known
+	Line     int    // Line of the match within Source
+}
+
+// explainRule evaluates a single rule with tracing enabled
+func explainRule(ctx context.Context, policyFile, packageName, rule string, input *ModuleInput) (*Explanation, error) {
+	data, err := os.ReadFile(policyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read policy %s: %w", policyFile, err)
+	}
+
+	tracer := topdown.NewBufferTracer()
+	r := rego.New(
+		rego.Query("data."+packageName+"."+rule),
+		rego.Module(policyFile, string(data)),
+		rego.Input(input),
+		rego.QueryTracer(tracer),
+		rego.SetRegoVersion(policies.RegoVersion),
+	)
+
+	rs, err := r.Eval(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to evaluate %s.%s: %w", packageName, rule, err)
+	}
+
+	explanation := &Explanation{}
+	if len(rs) > 0 && len(rs[0].Expressions) > 0 {
+		explanation.Results = len(policies.ViolationsFromValue(rs[0].Expressions[0].Value))
+	}
+
+	// Equivalent of `opa eval --explain=notes`
+	if notes := lineage.Notes(*tracer); len(notes) > 0 {
+		var buf bytes.Buffer
+		topdown.PrettyTraceWithLocation(&buf, notes)
+		explanation.Notes = buf.String()
+	}
+
+	explanation.Bindings = ruleBindings(*tracer, policyFile, rule)
+	explanation.Regexes = regexMatches(*tracer, input)
+
+	return explanation, nil
+}
+
+// ruleBindings returns the named variables bound each time the body of the
+// rule succeeded
+func ruleBindings(events []*topdown.Event, policyFile, rule string) []RuleBinding {
+	var bindings []RuleBinding
+	seen := make(map[string]bool)
+
+	for _, event := range events {
+		if event.Op != topdown.ExitOp {
+			continue
+		}
+		r, ok := event.Node.(*ast.Rule)
+		if !ok || policies.RuleName(r) != rule || r.Location == nil || r.Location.File != policyFile {
+			continue
+		}
+
+		vars := make(map[string]string)
+		event.Locals.Iter(func(k, v ast.Value) bool {
+			name := localName(event, k)
+			if name == "" || name == "result" || name == "_" {
+				return false
+			}
+			vars[name] = summarizeValue(v)
+			return false
+		})
+		if len(vars) == 0 {
+			continue
+		}
+
+		binding := RuleBinding{
+			Location: fmt.Sprintf("%s:%d", r.Location.File, r.Location.Row),
+			Vars:     vars,
+		}
+		key := binding.Location + formatVars(vars)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		bindings = append(bindings, binding)
+	}
+
+	return bindings
+}
+
+// regexMatches re-applies each traced regex builtin call to its operands and
+// returns the calls that matched, with the matching substring
+func regexMatches(events []*topdown.Event, input *ModuleInput) []RegexMatch {
+	var matches []RegexMatch
+	seen := make(map[string]bool)
+
+	for _, event := range events {
+		if event.Op != topdown.EvalOp {
+			continue
+		}
+		expr, ok := event.Node.(*ast.Expr)
+		if !ok || !expr.IsCall() || !regexBuiltins[expr.Operator().String()] {
+			continue
+		}
+		operands := expr.Operands()
+		if len(operands) < 2 {
+			continue
+		}
+
+		pattern, ok := resolveString(event, operands[0])
+		if !ok {
+			continue
+		}
+		value, ok := resolveString(event, operands[1])
+		if !ok {
+			continue
+		}
+
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			continue
+		}
+		loc := re.FindStringIndex(value)
+		if loc == nil {
+			continue
+		}
+
+		match := RegexMatch{
+			Pattern: pattern,
+			Match:   truncate(value[loc[0]:loc[1]]),
+		}
+		if expr.Location != nil {
+			match.Location = fmt.Sprintf("%s:%d", expr.Location.File, expr.Location.Row)
+		}
+		match.Source, match.Line = locateInInput(input, value, loc[0])
+
+		key := match.Location + "\x00" + match.Source + "\x00" + match.Match
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		matches = append(matches, match)
+	}
+
+	return matches
+}
+
+// resolveString returns the string value of a term, looking up local variables
+func resolveString(event *topdown.Event, term *ast.Term) (string, bool) {
+	value := term.Value
+	if v, ok := value.(ast.Var); ok && event.Locals != nil {
+		bound := event.Locals.Get(v)
+		if bound == nil {
+			return "", false
+		}
+		value = bound
+	}
+	s, ok := value.(ast.String)
+	return string(s), ok
+}
+
+// localName maps a compiler-generated local back to its name in the policy
+func localName(event *topdown.Event, v ast.Value) string {
+	name, ok := v.(ast.Var)
+	if !ok {
+		return ""
+	}
+	if event.LocalMetadata != nil {
+		if meta, ok := event.LocalMetadata[name]; ok {
+			name = meta.Name
+		}
+	}
+	if name.IsGenerated() || strings.HasPrefix(string(name), "__") {
+		return ""
+	}
+	return string(name)
+}
+
+// locateInInput finds the input file whose content contains the value and the
+// line of the given offset within it
+func locateInInput(input *ModuleInput, value string, offset int) (string, int) {
+	line := strings.Count(value[:offset], "\n") + 1
+
+	var paths []string
+	for path, content := range input.Files {
+		if content == value {
+			paths = append(paths, path)
+		}
+	}
+	if len(paths) == 0 {
+		return "", line
+	}
+	sort.Strings(paths)
+	return paths[0], line
+}
+
+// summarizeValue renders a bound value compactly for display
+func summarizeValue(v ast.Value) string {
+	switch value := v.(type) {
+	case ast.String:
+		return truncate(fmt.Sprintf("%q", string(value)))
+	case ast.Set:
+		return fmt.Sprintf("set(%d) %s", value.Len(), truncate(value.String()))
+	case ast.Object:
+		return fmt.Sprintf("object(%d) %s", value.Len(), truncate(value.String()))
+	case *ast.Array:
+		return fmt.Sprintf("array(%d) %s", value.Len(), truncate(value.String()))
+	default:
+		return truncate(v.String())
+	}
+}
+
+// truncate shortens a string to maxExplainValueLength
+func truncate(s string) string {
+	if len(s) <= maxExplainValueLength {
+		return s
+	}
+	return s[:maxExplainValueLength] + "…"
+}
+
+// formatVars renders bindings in a stable order
+func formatVars(vars map[string]string) string {
+	names := make([]string, 0, len(vars))
+	for name := range vars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for _, name := range names {
+		fmt.Fprintf(&b, "%s = %s\n", name, vars[name])
+	}
+	return b.String()
+}
+
+// printExplanation prints an explanation below a failing rule
+func printExplanation(name string, explanation *Explanation) {
+	fmt.Printf("    %s🔎 Explain %s (%d results)%s\n", ColorCyan, name, explanation.Results, ColorReset)
+
+	if explanation.Notes != "" {
+		fmt.Printf("      %sNotes:%s\n", ColorGray, ColorReset)
+		for _, line := range strings.Split(strings.TrimRight(explanation.Notes, "\n"), "\n") {
+			fmt.Printf("        %s\n", line)
+		}
+	} else {
+		fmt.Printf("      %sNotes: none (add trace() calls to the policy to emit notes)%s\n", ColorGray, ColorReset)
+	}
+
+	for i, binding := range explanation.Bindings {
+		fmt.Printf("      %sMatch %d%s at %s:\n", ColorGray, i+1, ColorReset, binding.Location)
+		for _, line := range strings.Split(strings.TrimRight(formatVars(binding.Vars), "\n"), "\n") {
+			fmt.Printf("        %s\n", line)
+		}
+	}
+
+	for _, match := range explanation.Regexes {
+		where := ""
+		if match.Source != "" {
+			where = fmt.Sprintf(" in %s:%d", match.Source, match.Line)
+		}
+		fmt.Printf("      %sRegex%s %s `%s` matched %q%s\n", ColorGray, ColorReset, match.Location, match.Pattern, match.Match, where)
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExplainRuleHardcodedPolicy(t *testing.T) {
+	policyFile := filepath.Join("..", "..", "policies", "opa", "terraform", "module", "hardcoded_values_policy.rego")
+	input := &ModuleInput{
+		ModulePath: "my-module",
+		Files: map[string]string{
+			"my-module/main.tf":      "locals {\n  name = var.name\n}\n\nresource \"aws_s3_bucket\" \"this\" {\n  force_destroy = true\n}\n",
+			"my-module/variables.tf": "variable \"name\" {\n  type = string\n}\n",
+		},
+	}
+
+	explanation, err := explainRule(context.Background(), policyFile, "terraform.module.hardcoded", "violation", input)
+	if err != nil {
+		t.Fatalf("explainRule failed: %v", err)
+	}
+
+	if explanation.Results != 1 {
+		t.Errorf("expected 1 result, got %d", explanation.Results)
+	}
+
+	if len(explanation.Bindings) == 0 {
+		t.Fatalf("expected rule bindings, got none")
+	}
+	if got := explanation.Bindings[0].Vars["file"]; got != `"my-module/main.tf"` {
+		t.Errorf("expected file binding for main.tf, got %q", got)
+	}
+
+	found := false
+	for _, match := range explanation.Regexes {
+		if match.Match == "force_destroy = true" {
+			found = true
+			if match.Source != "my-module/main.tf" || match.Line != 6 {
+				t.Errorf("expected match in my-module/main.tf:6, got %s:%d", match.Source, match.Line)
+			}
+		}
+		if strings.Contains(match.Source, "variables.tf") {
+			t.Errorf("variables.tf is excluded by the policy but was reported: %+v", match)
+		}
+	}
+	if !found {
+		t.Errorf("expected boolean regex match, got %+v", explanation.Regexes)
+	}
+}
+
+func TestExplainRuleNotes(t *testing.T) {
+	policyFile := filepath.Join(t.TempDir(), "notes.rego")
+	policy := `package test.notes
+
+import future.keywords.contains
+import future.keywords.if
+
+violation contains msg if {
+	trace("checking module")
+	input.module_path == "bad"
+	msg := "bad module"
+}
+`
+	if err := os.WriteFile(policyFile, []byte(policy), 0644); err != nil {
+		t.Fatalf("failed to write policy: %v", err)
+	}
+
+	explanation, err := explainRule(context.Background(), policyFile, "test.notes", "violation", &ModuleInput{ModulePath: "bad"})
+	if err != nil {
+		t.Fatalf("explainRule failed: %v", err)
+	}
+	if !strings.Contains(explanation.Notes, "checking module") {
+		t.Errorf("expected trace note in output, got %q", explanation.Notes)
+	}
+	if explanation.Results != 1 {
+		t.Errorf("expected 1 result, got %d", explanation.Results)
+	}
+}
+
+func TestSummarizeValueTruncates(t *testing.T) {
+	long := strings.Repeat("a", maxExplainValueLength*2)
+	got := truncate(long)
+	if len(got) <= maxExplainValueLength || !strings.HasSuffix(got, "…") {
+		t.Errorf("expected truncated value, got %d bytes", len(got))
+	}
+	if truncate("short") != "short" {
+		t.Errorf("short values should not be truncated")
+	}
+}
@@ -0,0 +1,279 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/terraform-modules/scripts/module-validator/policies"
+)
+
+// goldenFileName is the expected-violations file carried by each fixture
+const goldenFileName = "expected-violations.json"
+
+var update = flag.Bool("update", false, "Regenerate the expected-violations.json golden files of the policy fixtures")
+
+// repoRoot is the repository root relative to this package
+var repoRoot = filepath.Join("..", "..")
+
+// fixtureRoots are searched for golden files
+var fixtureRoots = []string{filepath.Join("tests", "opa", "test-fixture")}
+
+// goldenFile describes a fixture module and the violations it must produce
+type goldenFile struct {
+	ModuleType string              `json:"module_type"`
+	ModulePath string              `json:"module_path,omitempty"` // Relative to the repository root; defaults to the golden file's directory
+	Violations []expectedViolation `json:"violations"`
+}
+
+// expectedViolation identifies a violation by where it came from rather than
+// by its wording
+type expectedViolation struct {
+	Policy string `json:"policy"`         // Rego package, or check:<id> for Go checks
+	Rule   string `json:"rule"`           // Rule name, main for the violation rule and Go checks
+	File   string `json:"file,omitempty"` // Module file named by the violation, relative to the module
+}
+
+func (e expectedViolation) String() string {
+	if e.File == "" {
+		return fmt.Sprintf("%s.%s", e.Policy, e.Rule)
+	}
+	return fmt.Sprintf("%s.%s (%s)", e.Policy, e.Rule, e.File)
+}
+
+func TestPolicyFixtures(t *testing.T) {
+	config, err := loadConfig(filepath.Join(repoRoot, "monorepo-config.json"))
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+
+	var goldenPaths []string
+	for _, root := range fixtureRoots {
+		filepath.Walk(filepath.Join(repoRoot, root), func(path string, info os.FileInfo, err error) error {
+			if err == nil && !info.IsDir() && info.Name() == goldenFileName {
+				goldenPaths = append(goldenPaths, path)
+			}
+			return nil
+		})
+	}
+	if len(goldenPaths) == 0 {
+		t.Fatalf("No %s files found under %v", goldenFileName, fixtureRoots)
+	}
+
+	// Keep the validator output to warnings and errors
+	level := debugLevel
+	debugLevel = LevelWarn
+	t.Cleanup(func() { debugLevel = level })
+
+	for _, goldenPath := range goldenPaths {
+		goldenPath := goldenPath
+		name, _ := filepath.Rel(repoRoot, filepath.Dir(goldenPath))
+		t.Run(filepath.ToSlash(name), func(t *testing.T) {
+			t.Parallel()
+			runPolicyFixture(t, config, goldenPath)
+		})
+	}
+}
+
+// runPolicyFixture validates a fixture module in-process and compares the
+// reported violations with its golden file
+func runPolicyFixture(t *testing.T, config map[string]interface{}, goldenPath string) {
+	data, err := os.ReadFile(goldenPath)
+	if err != nil {
+		t.Fatalf("Failed to read golden file: %v", err)
+	}
+	var golden goldenFile
+	if err := json.Unmarshal(data, &golden); err != nil {
+		t.Fatalf("Failed to parse golden file %s: %v", goldenPath, err)
+	}
+	if golden.ModuleType == "" {
+		t.Fatalf("Golden file %s has no module_type", goldenPath)
+	}
+
+	modulePath := filepath.Dir(goldenPath)
+	if golden.ModulePath != "" {
+		modulePath = filepath.Join(repoRoot, filepath.FromSlash(golden.ModulePath))
+	}
+
+	input, err := collectModuleInputInProcess(modulePath, collectorSettingsFromConfig(config))
+	if err != nil {
+		t.Fatalf("Failed to collect module: %v", err)
+	}
+	// The golden file is test metadata, not part of the module
+	delete(input.Files, input.ModulePath+"/"+goldenFileName)
+
+	policyDirs, err := policies.ModuleDirs(config, golden.ModuleType)
+	if err != nil {
+		t.Fatalf("Failed to resolve policy directories: %v", err)
+	}
+	for i := range policyDirs {
+		policyDirs[i].Path = filepath.Join(repoRoot, policyDirs[i].Path)
+	}
+	checkIDs := policies.ModuleChecks(config, golden.ModuleType)
+	sources, checks := applyFilter(loadPolicySources(policyDirs), checkIDs, PolicyFilter{})
+
+	result := runValidation(input, sources, checks, validationOptions{ModuleType: golden.ModuleType, InProcess: true})
+
+	// Errors are never expected; they mean a policy could not be evaluated
+	if result.ErrorRules > 0 {
+		t.Errorf("%d rules could not be evaluated", result.ErrorRules)
+	}
+	for _, source := range sources {
+		if source.Err != nil {
+			t.Errorf("Policy %s failed to parse: %v", source.File, source.Err)
+		}
+	}
+
+	actual := make([]expectedViolation, 0, len(result.Violations))
+	for _, v := range result.Violations {
+		actual = append(actual, expectedViolation{Policy: v.Package, Rule: v.Rule, File: violationFile(v.Violation, input)})
+	}
+	sortExpectedViolations(actual)
+
+	if *update {
+		golden.Violations = actual
+		data, err := json.MarshalIndent(golden, "", "  ")
+		if err != nil {
+			t.Fatalf("Failed to marshal golden file: %v", err)
+		}
+		if err := os.WriteFile(goldenPath, append(data, '\n'), 0644); err != nil {
+			t.Fatalf("Failed to write golden file: %v", err)
+		}
+		t.Logf("Updated %s with %d violations", goldenPath, len(actual))
+		return
+	}
+
+	missing, unexpected := diffExpectedViolations(golden.Violations, actual)
+	for _, v := range missing {
+		t.Errorf("Expected violation not reported: %s", v)
+	}
+	for _, v := range unexpected {
+		t.Errorf("Unexpected violation: %s", v)
+	}
+	if len(missing) > 0 || len(unexpected) > 0 {
+		t.Logf("If the change is intended, regenerate the golden file with: go test -run TestPolicyFixtures . -update")
+	}
+}
+
+// violationFile returns the module file a violation refers to, relative to
+// the module. The longest file named in the message or details wins, either
+// with the module name prefix or as a standalone relative path.
+func violationFile(v Violation, input *ModuleInput) string {
+	text := v.Message + "\n" + v.Details
+	best := ""
+	for key := range input.Files {
+		rel := strings.TrimPrefix(key, input.ModulePath+"/")
+		if rel == key || len(rel) <= len(best) {
+			continue
+		}
+		if strings.Contains(text, key) || containsPath(text, rel) {
+			best = rel
+		}
+	}
+	return best
+}
+
+// containsPath reports whether path appears in text as a whole path rather
+// than as part of a longer one
+func containsPath(text, path string) bool {
+	for offset := 0; ; {
+		i := strings.Index(text[offset:], path)
+		if i < 0 {
+			return false
+		}
+		start := offset + i
+		end := start + len(path)
+		if (start == 0 || !isPathChar(text[start-1])) && (end == len(text) || !isPathChar(text[end])) {
+			return true
+		}
+		offset = start + 1
+	}
+}
+
+// isPathChar reports whether c can be part of a file path
+func isPathChar(c byte) bool {
+	return c == '/' || c == '.' || c == '-' || c == '_' ||
+		('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') || ('0' <= c && c <= '9')
+}
+
+// sortExpectedViolations orders violations by policy, rule and file
+func sortExpectedViolations(violations []expectedViolation) {
+	sort.Slice(violations, func(i, j int) bool {
+		a, b := violations[i], violations[j]
+		if a.Policy != b.Policy {
+			return a.Policy < b.Policy
+		}
+		if a.Rule != b.Rule {
+			return a.Rule < b.Rule
+		}
+		return a.File < b.File
+	})
+}
+
+// diffExpectedViolations compares expected and actual violations as
+// multisets, so a violation reported twice must be expected twice
+func diffExpectedViolations(expected, actual []expectedViolation) (missing, unexpected []expectedViolation) {
+	counts := make(map[expectedViolation]int)
+	for _, v := range actual {
+		counts[v]++
+	}
+	for _, v := range expected {
+		if counts[v] > 0 {
+			counts[v]--
+			continue
+		}
+		missing = append(missing, v)
+	}
+	for _, v := range actual {
+		if counts[v] > 0 {
+			counts[v]--
+			unexpected = append(unexpected, v)
+		}
+	}
+	return missing, unexpected
+}
+
+func TestDiffExpectedViolations(t *testing.T) {
+	a := expectedViolation{Policy: "terraform.module.naming", Rule: "main", File: "main.tf"}
+	b := expectedViolation{Policy: "check:variable_references", Rule: "main"}
+
+	missing, unexpected := diffExpectedViolations([]expectedViolation{a, a, b}, []expectedViolation{a, b, b})
+	if len(missing) != 1 || missing[0] != a {
+		t.Errorf("Expected one missing %v, got %v", a, missing)
+	}
+	if len(unexpected) != 1 || unexpected[0] != b {
+		t.Errorf("Expected one unexpected %v, got %v", b, unexpected)
+	}
+}
+
+func TestViolationFile(t *testing.T) {
+	input := &ModuleInput{
+		ModulePath: "my-module",
+		Files: map[string]string{
+			"my-module/main.tf":                "",
+			"my-module/examples/basic/main.tf": "",
+			"my-module/tests":                  "directory",
+		},
+	}
+
+	tests := []struct {
+		violation Violation
+		expected  string
+	}{
+		{Violation{Details: "File 'my-module/examples/basic/main.tf' hard-codes values"}, "examples/basic/main.tf"},
+		{Violation{Details: "Resource in main.tf has no name"}, "main.tf"},
+		{Violation{Message: "Module is missing README.md"}, ""},
+		{Violation{Details: "Found nested/main.tfvars"}, ""},
+	}
+
+	for _, tt := range tests {
+		if got := violationFile(tt.violation, input); got != tt.expected {
+			t.Errorf("violationFile(%+v) = %q, want %q", tt.violation, got, tt.expected)
+		}
+	}
+}
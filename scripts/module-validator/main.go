@@ -1,14 +1,19 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"flag"
 	"fmt"
-	"io/ioutil"
 	"os"
 	"os/exec"
+	"os/signal"
 	"path/filepath"
 	"strings"
+	"syscall"
+
+	"github.com/terraform-modules/scripts/module-validator/checks"
+	"github.com/terraform-modules/scripts/module-validator/policies"
 )
 
 // Colors for terminal output
@@ -33,6 +38,21 @@ const (
 
 var debugLevel = LevelInfo // Default debug level
 
+// Violation is the result model shared by Rego policies and Go checks
+type Violation = checks.Violation
+
+// ModuleInput is the module-scoped document evaluated by policies and checks
+type ModuleInput = checks.ModuleInput
+
+// RuleResult tracks the outcome of a single rule or check
+type RuleResult struct {
+	PolicyFile string
+	RuleName   string
+	Passed     bool
+	HasError   bool
+	Violations int
+}
+
 // logMessage logs a message at the specified level
 func logMessage(level int, format string, args ...interface{}) {
 	if level > debugLevel {
@@ -61,6 +81,16 @@ func main() {
 	moduleType := flag.String("module-type", "", "Type of the Terraform module (utility, collection, reference, etc.)")
 	configPath := flag.String("config", "", "Path to the monorepo configuration file")
 	verbose := flag.Bool("verbose", false, "Enable verbose output")
+	listPolicies := flag.Bool("list-policies", false, "List the resolved policies and checks for the module type without evaluating them")
+	watch := flag.Bool("watch", false, "Watch the module and policy directories and re-run validation on changes")
+	inputPath := flag.String("input", "", "Evaluate a saved input document instead of collecting the module")
+	dumpInputPath := flag.String("dump-input", "", "Write the input document evaluated by policies and checks to this file")
+	inProcess := flag.Bool("in-process", false, "Collect files and evaluate policies in-process with the embedded OPA SDK, without go run or the opa binary")
+	explain := flag.Bool("explain", false, "Trace rules that report violations and show the notes, bindings and regex matches behind them")
+	var filter PolicyFilter
+	flag.Var((*stringSliceFlag)(&filter.Policies), "policy", "Only evaluate policies whose package or file matches this glob (repeatable)")
+	flag.Var((*stringSliceFlag)(&filter.SkipPolicies), "skip-policy", "Skip policies whose package or file matches this glob (repeatable)")
+	flag.Var((*stringSliceFlag)(&filter.Rules), "rule", "Only evaluate rules with this name (repeatable)")
 	flag.Parse()
 
 	// Set debug level based on verbose flag
@@ -69,11 +99,16 @@ func main() {
 		fmt.Println("Verbose mode enabled")
 	}
 
-	if *modulePath == "" {
+	if *modulePath == "" && *inputPath == "" && !*listPolicies {
 		logMessage(LevelError, "Module path is required")
 		os.Exit(1)
 	}
 
+	if *inputPath != "" && *watch {
+		logMessage(LevelError, "--watch cannot be used with --input")
+		os.Exit(1)
+	}
+
 	if *moduleType == "" {
 		logMessage(LevelError, "Module type is required")
 		os.Exit(1)
@@ -84,8 +119,6 @@ func main() {
 		os.Exit(1)
 	}
 
-	logMessage(LevelInfo, "Starting module validation for %s module at %s", *moduleType, *modulePath)
-
 	// Load configuration
 	config, err := loadConfig(*configPath)
 	if err != nil {
@@ -94,166 +127,171 @@ func main() {
 	}
 	logMessage(LevelDebug, "Configuration loaded from %s", *configPath)
 
-	// Get scripts configuration
-	scripts, ok := config["scripts"].(map[string]interface{})
-	if !ok {
-		logMessage(LevelError, "Scripts configuration not found")
+	// Determine policy directories to evaluate
+	policyDirs, err := policies.ModuleDirs(config, *moduleType)
+	if err != nil {
+		logMessage(LevelError, "%v", err)
 		os.Exit(1)
 	}
-
-	// Get temp file pattern
-	tempFilePattern, ok := scripts["temp_file_pattern"].(string)
-	if !ok {
-		tempFilePattern = "terraform-files-*.json" // Fallback
-		logMessage(LevelWarn, "Temp file pattern not found in config, using default: %s", tempFilePattern)
-	} else {
-		logMessage(LevelDebug, "Using temp file pattern: %s", tempFilePattern)
+	if len(policyDirs) == 0 || policyDirs[0].Origin != policies.OriginModuleType {
+		logMessage(LevelWarn, "No policy directory defined for module type: %s", *moduleType)
 	}
-
-	// Get terraform file collector script
-	tfCollectorScript, ok := scripts["terraform_file_collector"].(string)
-	if !ok {
-		logMessage(LevelError, "terraform_file_collector script not found in config")
-		os.Exit(1)
+	for _, dir := range policyDirs {
+		logMessage(LevelInfo, "Added policy directory from %s: %s", dir.Origin, dir.Path)
 	}
-	logMessage(LevelDebug, "Using terraform file collector script: %s", tfCollectorScript)
 
-	// Create temporary file for Terraform files
-	tempFile, err := ioutil.TempFile("", tempFilePattern)
-	if err != nil {
-		logMessage(LevelError, "Error creating temporary file: %v", err)
-		os.Exit(1)
+	// Get Go-native checks selected for this module type
+	checkIDs := policies.ModuleChecks(config, *moduleType)
+	for _, id := range checkIDs {
+		logMessage(LevelInfo, "Added Go check: %s", id)
 	}
-	defer os.Remove(tempFile.Name())
-	tempFile.Close()
-	logMessage(LevelDebug, "Created temporary file: %s", tempFile.Name())
-
-	// Collect Terraform files
-	var cmd *exec.Cmd
-	var scriptPath string
 
-	// Check if the collector script is a relative path or just a name
-	if strings.HasPrefix(tfCollectorScript, "./") || strings.HasPrefix(tfCollectorScript, "/") {
-		// It's a path, use it directly
-		scriptPath = tfCollectorScript
-	} else {
-		// It's just a name, assume it's in the scripts directory
-		scriptPath = filepath.Join("./scripts", tfCollectorScript, "main.go")
-	}
-
-	logMessage(LevelDebug, "Running terraform file collector: %s", scriptPath)
-	logMessage(LevelDebug, "Module path: %s", *modulePath)
-	logMessage(LevelDebug, "Output file: %s", tempFile.Name())
-	logMessage(LevelDebug, "Config path: %s", *configPath)
-
-	cmd = exec.Command("go", "run", scriptPath,
-		"--module-path", *modulePath,
-		"--output", tempFile.Name(),
-		"--config", *configPath)
+	// Collect all policy files from all directories
+	policySources := loadPolicySources(policyDirs)
 
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	if err := cmd.Run(); err != nil {
-		logMessage(LevelError, "Error collecting Terraform files: %v", err)
-		os.Exit(1)
+	if *listPolicies {
+		printPolicyList(*moduleType, policySources, checkIDs, filter)
+		return
 	}
-	logMessage(LevelInfo, "Terraform files collected successfully")
 
-	// Determine policy directories to evaluate
-	var policyDirs []string
+	// Apply policy, rule and check selection
+	selectedSources, selectedChecks := applyFilter(policySources, checkIDs, filter)
 
-	// Get module type specific policy directory
-	moduleTypes, ok := config["module_types"].(map[string]interface{})
-	if !ok {
-		logMessage(LevelError, "module_types not found in config")
-		os.Exit(1)
+	if len(selectedSources) == 0 && len(selectedChecks) == 0 {
+		logMessage(LevelWarn, "No policy files or checks found to evaluate")
+		os.Exit(0)
 	}
+	logMessage(LevelInfo, "Found %d policy directories to evaluate", len(policyDirs))
+	logMessage(LevelInfo, "Found %d total policy files to evaluate", len(selectedSources))
 
-	typeConfig, ok := moduleTypes[*moduleType].(map[string]interface{})
-	if !ok {
-		logMessage(LevelWarn, "No specific policies for module type: %s", *moduleType)
+	var input *ModuleInput
+	if *inputPath != "" {
+		// Replay a saved input document instead of collecting the module
+		logMessage(LevelInfo, "Starting module validation for %s module from input %s", *moduleType, *inputPath)
+		input, err = readModuleInput(*inputPath)
+		if err != nil {
+			logMessage(LevelError, "Error reading module input: %v", err)
+			os.Exit(1)
+		}
 	} else {
-		policyDir, ok := typeConfig["policy_dir"].(string)
-		if ok {
-			policyDirs = append(policyDirs, policyDir)
-			logMessage(LevelInfo, "Added module type specific policy directory: %s", policyDir)
+		logMessage(LevelInfo, "Starting module validation for %s module at %s", *moduleType, *modulePath)
+		if *inProcess {
+			input, err = collectModuleInputInProcess(*modulePath, collectorSettingsFromConfig(config))
 		} else {
-			logMessage(LevelWarn, "No policy directory defined for module type: %s", *moduleType)
+			input, err = collectModuleInput(config, *configPath, *modulePath)
 		}
+		if err != nil {
+			logMessage(LevelError, "%v", err)
+			os.Exit(1)
+		}
+	}
+	logMessage(LevelDebug, "Git repo path: %s", input.RepoPath)
+	logMessage(LevelDebug, "Testing module: %s", input.ModulePath)
+	logMessage(LevelDebug, "Found %d files in the module", len(input.Files))
+	for filePath := range input.Files {
+		logMessage(LevelTrace, "File: %s", filePath)
 	}
 
-	// Get additional policy directories from config
-	additionalPolicyDirs, ok := config["module_validator_additional_policies"].([]interface{})
-	if ok {
-		logMessage(LevelDebug, "Found additional policy directories configuration")
-		for _, dirKey := range additionalPolicyDirs {
-			if dirKeyStr, ok := dirKey.(string); ok {
-				// Look up the policy directory in rego_policy_dirs
-				regoPolicyDirs, ok := config["rego_policy_dirs"].(map[string]interface{})
-				if ok {
-					if policyDir, ok := regoPolicyDirs[dirKeyStr].(string); ok {
-						policyDirs = append(policyDirs, policyDir)
-						logMessage(LevelInfo, "Added additional policy directory: %s", policyDir)
-					} else {
-						logMessage(LevelWarn, "Policy directory not found for key: %s", dirKeyStr)
-					}
-				} else {
-					logMessage(LevelWarn, "rego_policy_dirs not found in config")
-				}
-			}
+	if *dumpInputPath != "" {
+		if err := writeModuleInput(*dumpInputPath, input); err != nil {
+			logMessage(LevelError, "Error dumping module input: %v", err)
+			os.Exit(1)
 		}
-	} else {
-		logMessage(LevelWarn, "No additional policy directories configured")
+		logMessage(LevelInfo, "Module input written to %s", *dumpInputPath)
 	}
 
-	if len(policyDirs) == 0 {
-		logMessage(LevelWarn, "No policy directories found to evaluate")
-		os.Exit(0)
+	opts := validationOptions{ModuleType: *moduleType, Filter: filter, Explain: *explain, InProcess: *inProcess}
+	result := runValidation(input, selectedSources, selectedChecks, opts)
+
+	if *watch {
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		if err := watchModule(ctx, watchOptions{
+			ModulePath: *modulePath,
+			PolicyDirs: policyDirs,
+			CheckIDs:   checkIDs,
+			Collector:  collectorSettingsFromConfig(config),
+			Validation: opts,
+		}, input, result); err != nil {
+			logMessage(LevelError, "Watch failed: %v", err)
+			os.Exit(1)
+		}
+		return
 	}
-	logMessage(LevelInfo, "Found %d policy directories to evaluate", len(policyDirs))
 
-	// Run OPA evaluation
-	fmt.Printf("\n%s=== Evaluating policies for %s module ===%s\n", ColorBlue, *moduleType, ColorReset)
+	if result.Failed {
+		os.Exit(1)
+	}
+}
 
-	// Collect all policy files from all directories
-	var allPolicyFiles []string
-	for _, dir := range policyDirs {
-		logMessage(LevelInfo, "Looking for policies in: %s", dir)
-		policyFiles, err := filepath.Glob(filepath.Join(dir, "*.rego"))
-		if err != nil {
-			logMessage(LevelError, "Error finding policy files in %s: %v", dir, err)
-			continue
-		}
-		logMessage(LevelDebug, "Found %d policy files in %s", len(policyFiles), dir)
-		for _, file := range policyFiles {
-			logMessage(LevelTrace, "Found policy file: %s", file)
-		}
-		allPolicyFiles = append(allPolicyFiles, policyFiles...)
+// ValidationResult is the outcome of a single validation run
+type ValidationResult struct {
+	Failed     bool
+	Violations []ReportedViolation
+	ErrorRules int // Rules and checks that could not be evaluated
+}
+
+// ReportedViolation is a violation together with the rule or check that reported it
+type ReportedViolation struct {
+	Source  string // <policy file>.<rule> or check:<id>
+	Package string // Policy package, or check:<id> for Go checks
+	Rule    string // Rule display name, main for the violation rule and Go checks
+	Violation
+}
+
+// Key identifies a violation across validation runs
+func (r ReportedViolation) Key() string {
+	return r.Source + "\x00" + r.Message + "\x00" + r.Details
+}
+
+// record adds the violations reported by a rule or check to the result
+func (r *ValidationResult) record(source, packageName, rule string, violations []Violation) {
+	for _, v := range violations {
+		r.Violations = append(r.Violations, ReportedViolation{Source: source, Package: packageName, Rule: rule, Violation: v})
 	}
+}
 
-	if len(allPolicyFiles) == 0 {
-		logMessage(LevelWarn, "No policy files found in any policy directories")
-		os.Exit(0)
+// validationOptions controls how a validation run evaluates and reports
+type validationOptions struct {
+	ModuleType string
+	Filter     PolicyFilter
+	Explain    bool
+	InProcess  bool // Evaluate policies with the embedded OPA SDK instead of the opa binary
+}
+
+// runValidation evaluates the selected policies and checks against the module
+// input and prints the per-rule results and summary
+func runValidation(input *ModuleInput, selectedSources []PolicySource, selectedChecks []string, opts validationOptions) ValidationResult {
+	// Prepare the rule evaluator
+	var evaluate ruleEvaluator
+	var err error
+	if opts.InProcess {
+		evaluate, err = newSDKEvaluator(context.Background(), input)
+	} else {
+		var cleanup func()
+		evaluate, cleanup, err = newOPAEvaluator(input)
+		if cleanup != nil {
+			defer cleanup()
+		}
 	}
-	logMessage(LevelInfo, "Found %d total policy files to evaluate", len(allPolicyFiles))
+	if err != nil {
+		logMessage(LevelError, "%v", err)
+		return ValidationResult{Failed: true}
+	}
+
+	// Run OPA evaluation
+	fmt.Printf("\n%s=== Evaluating policies for %s module ===%s\n", ColorBlue, opts.ModuleType, ColorReset)
 
 	// Prepare for evaluation
+	var result ValidationResult
 	violations := false
 	policyFileResults := make(map[string]bool) // Track pass/fail for each policy file
 	policyFileErrors := make(map[string]bool)  // Track execution errors for each policy file
-
-	// Track individual rules
-	type RuleResult struct {
-		PolicyFile string
-		RuleName   string
-		Passed     bool
-		HasError   bool
-		Violations int
-	}
 	var allRuleResults []RuleResult
 
 	// Evaluate each policy file
-	for i, policyFile := range allPolicyFiles {
+	for i, source := range selectedSources {
+		policyFile := source.File
 		policyName := filepath.Base(policyFile)
 
 		if i > 0 {
@@ -267,122 +305,22 @@ func main() {
 		fmt.Printf("\n%s🔍 Evaluating policy:%s %s\n", ColorBlue, ColorReset, policyName)
 
 		// Determine package name from policy file
-		packageName := getPackageName(policyFile)
-		if packageName == "" {
-			fmt.Printf("%sError: Could not determine package name for %s%s\n", ColorRed, policyFile, ColorReset)
-			violations = true
-			policyFileErrors[policyName] = true
-			continue
-		}
-
-		// Modify the input data to include the module_path
-		inputData, err := ioutil.ReadFile(tempFile.Name())
-		if err != nil {
-			logMessage(LevelError, "Error reading input file: %v", err)
-			violations = true
-			policyFileErrors[policyName] = true
-			continue
-		}
-
-		var inputJSON map[string]interface{}
-		if err := json.Unmarshal(inputData, &inputJSON); err != nil {
-			logMessage(LevelError, "Error parsing input JSON: %v", err)
-			violations = true
-			policyFileErrors[policyName] = true
-			continue
-		}
-
-		// Process module paths
-		cleanModulePath := strings.TrimSuffix(*modulePath, "/")
-		moduleName := filepath.Base(cleanModulePath)
-
-		// Add both paths to the input JSON
-		inputJSON["module_path"] = moduleName    // Just the module name for policy evaluation
-		inputJSON["repo_path"] = cleanModulePath // Full path for display
-
-		logMessage(LevelDebug, "Git repo path: %s", cleanModulePath)
-		logMessage(LevelDebug, "Testing module: %s", moduleName)
-
-		// Transform file paths to use module name as root
-		if files, ok := inputJSON["files"].(map[string]interface{}); ok {
-			transformedFiles := make(map[string]interface{})
-			for filePath, content := range files {
-				// If the file path starts with the repo path, replace it with the module name
-				if strings.HasPrefix(filePath, cleanModulePath+"/") {
-					newPath := strings.Replace(filePath, cleanModulePath, moduleName, 1)
-					transformedFiles[newPath] = content
-				} else {
-					transformedFiles[filePath] = content
-				}
-			}
-			inputJSON["files"] = transformedFiles
-		}
-
-		// Log files found by the collector
-		if files, ok := inputJSON["files"].(map[string]interface{}); ok {
-			logMessage(LevelDebug, "Found %d files in the module", len(files))
-			for filePath := range files {
-				logMessage(LevelTrace, "File: %s", filePath)
+		packageName := source.Package
+		if packageName == "" || source.Err != nil {
+			if source.Err != nil {
+				fmt.Printf("%sError: %v%s\n", ColorRed, source.Err, ColorReset)
+			} else {
+				fmt.Printf("%sError: Could not determine package name for %s%s\n", ColorRed, policyFile, ColorReset)
 			}
-		}
-
-		// Write the modified input back to a temporary file
-		modifiedInputFile, err := ioutil.TempFile("", "modified-input-*.json")
-		if err != nil {
-			logMessage(LevelError, "Error creating modified input file: %v", err)
 			violations = true
 			policyFileErrors[policyName] = true
 			continue
 		}
-		defer os.Remove(modifiedInputFile.Name())
-		logMessage(LevelDebug, "Created modified input file: %s", modifiedInputFile.Name())
 
-		modifiedInputData, err := json.MarshalIndent(inputJSON, "", "  ")
-		if err != nil {
-			logMessage(LevelError, "Error marshaling modified input: %v", err)
-			violations = true
-			policyFileErrors[policyName] = true
-			continue
-		}
-
-		if err := ioutil.WriteFile(modifiedInputFile.Name(), modifiedInputData, 0644); err != nil {
-			logMessage(LevelError, "Error writing modified input: %v", err)
-			violations = true
-			policyFileErrors[policyName] = true
-			continue
-		}
-		logMessage(LevelTrace, "Modified input JSON:\n%s", string(modifiedInputData))
-
-		// First, discover all rules in the policy file
-		listRulesArgs := []string{
-			"eval",
-			"--format", "json",
-			"--data", policyFile,
-			"data." + packageName,
-			"--input", modifiedInputFile.Name(),
-		}
-
-		cmd := exec.Command("opa", listRulesArgs...)
-		rulesOutput, _ := cmd.CombinedOutput()
-
-		// Default to just "violation" if we can't determine rules
-		rules := []string{"violation"}
-
-		// Try to parse the rules output
-		var rulesResult map[string]interface{}
-		if err := json.Unmarshal(rulesOutput, &rulesResult); err == nil {
-			if result, ok := rulesResult["result"].(map[string]interface{}); ok {
-				// Extract rule names
-				ruleNames := []string{}
-				for ruleName := range result {
-					if ruleName != "debug_info" && !strings.HasPrefix(ruleName, "__") {
-						ruleNames = append(ruleNames, ruleName)
-					}
-				}
-				if len(ruleNames) > 0 {
-					rules = ruleNames
-				}
-			}
+		// Default to just "violation" if the policy defines no violation rules
+		rules := selectedRules(source, opts.Filter)
+		if len(source.ViolationRules()) == 0 {
+			rules = []string{"violation"}
 		}
 
 		// Track policy file results
@@ -391,43 +329,19 @@ func main() {
 
 		// Evaluate each rule
 		for _, rule := range rules {
-			// Skip non-violation rules
-			if rule != "violation" && !strings.HasSuffix(rule, "violation") {
-				continue
-			}
+			ruleName := ruleDisplayName(rule)
 
-			ruleName := rule
-			if rule == "violation" {
-				ruleName = "main"
-			}
-
-			// Run OPA evaluation for this rule
-			opaArgs := []string{
-				"eval",
-				"--format", "json",
-				"--data", policyFile,
-				"data." + packageName + "." + rule,
-				"--input", modifiedInputFile.Name(),
-			}
-
-			logMessage(LevelDebug, "Running OPA with args: %v", opaArgs)
-			cmd := exec.Command("opa", opaArgs...)
-			output, err := cmd.CombinedOutput()
-
-			// Only show OPA output in trace mode (more detailed than debug)
-			logMessage(LevelTrace, "OPA output for rule %s: %s", ruleName, string(output))
+			// Evaluate this rule
+			value, err := evaluate(source, rule)
 
 			ruleResult := RuleResult{
 				PolicyFile: policyName,
 				RuleName:   ruleName,
 				Passed:     true,
-				HasError:   false,
-				Violations: 0,
 			}
 
 			if err != nil {
 				logMessage(LevelError, "Error evaluating rule %s in policy %s: %v", ruleName, policyName, err)
-				logMessage(LevelError, "OPA output: %s", string(output))
 				violations = true
 				policyFileHasErrors = true
 				ruleResult.HasError = true
@@ -436,275 +350,24 @@ func main() {
 				continue
 			}
 
-			// Parse results
-			var result map[string]interface{}
-			if err := json.Unmarshal(output, &result); err != nil {
-				logMessage(LevelError, "Error parsing rule results: %v", err)
-				policyFileHasErrors = true
-				ruleResult.HasError = true
-				ruleResult.Passed = false
-				allRuleResults = append(allRuleResults, ruleResult)
+			ruleViolations := policies.ViolationsFromValue(value)
+			result.record(policyName+"."+ruleName, packageName, ruleName, ruleViolations)
+			logMessage(LevelDebug, "Rule %s returned %d violations", ruleName, len(ruleViolations))
+
+			if policies.ReportViolations(policyName+"."+ruleName, ruleViolations) {
 				violations = true
-				continue
+				policyFileHasViolations = true
+				ruleResult.Passed = false
 			}
+			ruleResult.Violations = len(ruleViolations)
 
-			// Check for violations
-			if results, ok := result["result"].([]interface{}); ok && len(results) > 0 {
-				// Check if the result is actually empty (which means a pass)
-				hasViolations := false
-
-				for _, r := range results {
-					logMessage(LevelDebug, "Processing result: %T, Keys: %v", r, func() []string {
-						if m, ok := r.(map[string]interface{}); ok {
-							keys := make([]string, 0, len(m))
-							for k := range m {
-								keys = append(keys, k)
-							}
-							return keys
-						}
-						return nil
-					}())
-
-					if rMap, ok := r.(map[string]interface{}); ok {
-						// Check for expressions structure first (OPA eval format)
-						if expressions, ok := rMap["expressions"].([]interface{}); ok {
-							logMessage(LevelDebug, "Found expressions field with %d expressions", len(expressions))
-							for _, expr := range expressions {
-								if exprMap, ok := expr.(map[string]interface{}); ok {
-									if value, vok := exprMap["value"]; vok {
-										logMessage(LevelDebug, "Found value in expression: %T", value)
-										// Check for non-empty maps of any type
-										switch v := value.(type) {
-										case map[string]interface{}:
-											logMessage(LevelDebug, "Value is map[string]interface{} with %d keys", len(v))
-											if len(v) > 0 {
-												hasViolations = true
-											}
-										case map[string]bool:
-											logMessage(LevelDebug, "Value is map[string]bool with %d keys", len(v))
-											if len(v) > 0 {
-												hasViolations = true
-											}
-										case map[string]string:
-											logMessage(LevelDebug, "Value is map[string]string with %d keys", len(v))
-											if len(v) > 0 {
-												hasViolations = true
-											}
-										case []interface{}:
-											logMessage(LevelDebug, "Value is []interface{} with %d elements", len(v))
-											if len(v) > 0 {
-												hasViolations = true
-											}
-										}
-										if hasViolations {
-											logMessage(LevelDebug, "hasViolations set to true, breaking")
-											break
-										}
-									}
-								}
-								if hasViolations {
-									break
-								}
-							}
-						} else if value, ok := rMap["value"].(map[string]interface{}); ok && len(value) > 0 {
-							// Check for direct value field (direct violation format)
-							logMessage(LevelDebug, "Found violation in direct value field")
-							hasViolations = true
-						}
-					}
-					if hasViolations {
-						break
-					}
-				}
-
-				if hasViolations {
-					violations = true
-					policyFileHasViolations = true
-					ruleResult.Passed = false
-
-					// Count violations
-					violationCount := 0
-					for _, r := range results {
-						if violation, ok := r.(map[string]interface{}); ok {
-							if value, ok := violation["value"].(map[string]interface{}); ok {
-								violationCount += len(value)
-							} else {
-								violationCount++
-							}
-						} else if expressions, ok := r.(map[string]interface{})["expressions"].([]interface{}); ok {
-							for _, expr := range expressions {
-								if exprMap, ok := expr.(map[string]interface{}); ok {
-									if value, vok := exprMap["value"]; vok {
-										// Count violations from different map types
-										switch v := value.(type) {
-										case map[string]interface{}:
-											violationCount += len(v)
-										case map[string]bool:
-											violationCount += len(v)
-										case map[string]string:
-											violationCount += len(v)
-										case []interface{}:
-											violationCount += len(v)
-										default:
-											if violationCount == 0 {
-												violationCount = 1
-											}
-										}
-									}
-								}
-							}
-							if violationCount == 0 {
-								violationCount = 1 // At least one violation
-							}
-						} else {
-							violationCount++
-						}
-					}
-					ruleResult.Violations = violationCount
-
-					fmt.Printf("  %s❌ FAIL: %s.%s%s\n", ColorRed, policyName, ruleName, ColorReset)
+			if opts.Explain && len(ruleViolations) > 0 {
+				explanation, err := explainRule(context.Background(), policyFile, packageName, rule, input)
+				if err != nil {
+					logMessage(LevelWarn, "Unable to explain rule %s: %v", ruleName, err)
 				} else {
-					// Empty result means pass
-					fmt.Printf("  %s✅ PASS: %s.%s%s\n", ColorGreen, policyName, ruleName, ColorReset)
-				}
-
-				// Log the raw result structure
-				resultBytes, _ := json.MarshalIndent(result, "", "  ")
-				logMessage(LevelDebug, "Raw result: %s", string(resultBytes))
-
-				// Only log "Violation detected" if there are actual violations
-				if hasViolations {
-					logMessage(LevelDebug, "Violation detected")
+					printExplanation(policyName+"."+ruleName, explanation)
 				}
-
-				for _, r := range results {
-					// Log the type and content of each result
-					logMessage(LevelDebug, "Result type: %T, Value: %v", r, r)
-
-					if violation, ok := r.(map[string]interface{}); ok {
-						// Check if the violation has the expected fields
-						if message, ok := violation["message"]; ok && message != nil {
-							fmt.Printf("    %s%v%s\n", ColorRed, message, ColorReset)
-						} else {
-							// Check for expressions field which might contain the violation
-							hasViolationData := false
-							if expressions, ok := r.(map[string]interface{})["expressions"].([]interface{}); ok && len(expressions) > 0 {
-								if expr, ok := expressions[0].(map[string]interface{}); ok {
-									// Check for non-empty map or non-empty array
-									if value, vok := expr["value"]; vok {
-										switch v := value.(type) {
-										case map[string]interface{}:
-											if len(v) > 0 {
-												hasViolationData = true
-												// Try to parse JSON-encoded keys for violation details
-												for k := range v {
-													var violationDetails map[string]interface{}
-													if err := json.Unmarshal([]byte(k), &violationDetails); err == nil {
-														if msg, ok := violationDetails["message"]; ok {
-															fmt.Printf("    %s%v%s\n", ColorRed, msg, ColorReset)
-															if details, ok := violationDetails["details"]; ok {
-																fmt.Printf("    Details: %v\n", details)
-															}
-															if resolution, ok := violationDetails["resolution"]; ok {
-																fmt.Printf("    Resolution: %v\n", resolution)
-															}
-															continue
-														}
-													}
-													// If not JSON, print the key as-is
-													fmt.Printf("    %s%s%s\n", ColorRed, k, ColorReset)
-												}
-											}
-										case map[string]bool:
-											if len(v) > 0 {
-												hasViolationData = true
-												// Try to parse JSON-encoded keys for violation details
-												for k := range v {
-													var violationDetails map[string]interface{}
-													if err := json.Unmarshal([]byte(k), &violationDetails); err == nil {
-														if msg, ok := violationDetails["message"]; ok {
-															fmt.Printf("    %s%v%s\n", ColorRed, msg, ColorReset)
-															if details, ok := violationDetails["details"]; ok {
-																fmt.Printf("    Details: %v\n", details)
-															}
-															if resolution, ok := violationDetails["resolution"]; ok {
-																fmt.Printf("    Resolution: %v\n", resolution)
-															}
-															continue
-														}
-													}
-													// If not JSON, print the key as-is
-													fmt.Printf("    %s%s%s\n", ColorRed, k, ColorReset)
-												}
-											}
-										case map[string]string:
-											if len(v) > 0 {
-												hasViolationData = true
-												// Try to parse JSON-encoded keys for violation details
-												for k := range v {
-													var violationDetails map[string]interface{}
-													if err := json.Unmarshal([]byte(k), &violationDetails); err == nil {
-														if msg, ok := violationDetails["message"]; ok {
-															fmt.Printf("    %s%v%s\n", ColorRed, msg, ColorReset)
-															if details, ok := violationDetails["details"]; ok {
-																fmt.Printf("    Details: %v\n", details)
-															}
-															if resolution, ok := violationDetails["resolution"]; ok {
-																fmt.Printf("    Resolution: %v\n", resolution)
-															}
-															continue
-														}
-													}
-													// If not JSON, print the key as-is
-													fmt.Printf("    %s%s%s\n", ColorRed, k, ColorReset)
-												}
-											}
-										case []interface{}:
-											if len(v) > 0 {
-												hasViolationData = true
-											}
-										}
-									}
-								}
-							}
-							// Only print generic violation message if no specific violation details were found
-							if hasViolations && hasViolationData {
-								// Check if we already printed specific violation details above
-								needsGenericMessage := true
-								if expressions, ok := r.(map[string]interface{})["expressions"].([]interface{}); ok && len(expressions) > 0 {
-									if expr, ok := expressions[0].(map[string]interface{}); ok {
-										if value, vok := expr["value"]; vok {
-											switch value.(type) {
-											case map[string]interface{}, map[string]bool, map[string]string:
-												needsGenericMessage = false // We already printed specific details above
-											}
-										}
-									}
-								}
-								if needsGenericMessage {
-									fmt.Printf("    %sViolation detected%s\n", ColorRed, ColorReset)
-								}
-							}
-						}
-
-						if details, ok := violation["details"]; ok && details != nil {
-							fmt.Printf("    Details: %v\n", details)
-						}
-
-						if resolution, ok := violation["resolution"]; ok && resolution != nil {
-							fmt.Printf("    Resolution: %v\n", resolution)
-						}
-					} else if str, ok := r.(string); ok {
-						// Handle string violations
-						fmt.Printf("    %s%s%s\n", ColorRed, str, ColorReset)
-					} else {
-						// Handle other types of violations
-						fmt.Printf("    %sViolation detected%s\n", ColorRed, ColorReset)
-					}
-				}
-			} else {
-				// No results means pass
-				fmt.Printf("  %s✅ PASS: %s.%s%s\n", ColorGreen, policyName, ruleName, ColorReset)
 			}
 
 			allRuleResults = append(allRuleResults, ruleResult)
@@ -715,6 +378,50 @@ func main() {
 		policyFileErrors[policyName] = policyFileHasErrors
 	}
 
+	// Run Go-native checks against the same input
+	if len(selectedChecks) > 0 {
+		fmt.Printf("\n%s=== Running Go checks for %s module ===%s\n", ColorBlue, opts.ModuleType, ColorReset)
+	}
+	for _, id := range selectedChecks {
+		checkName := "check:" + id
+		fmt.Printf("\n%s🔍 Running check:%s %s\n", ColorBlue, ColorReset, id)
+
+		ruleResult := RuleResult{
+			PolicyFile: checkName,
+			RuleName:   "main",
+			Passed:     true,
+		}
+
+		check, ok := checks.Lookup(id)
+		if !ok {
+			logMessage(LevelError, "Unknown check %q configured for module type %s", id, opts.ModuleType)
+			violations = true
+			ruleResult.HasError = true
+			ruleResult.Passed = false
+			policyFileErrors[checkName] = true
+			policyFileResults[checkName] = false
+			allRuleResults = append(allRuleResults, ruleResult)
+			continue
+		}
+
+		if !check.Applies(opts.ModuleType) {
+			logMessage(LevelInfo, "Check %s does not apply to %s modules, skipping", id, opts.ModuleType)
+			continue
+		}
+
+		checkViolations := checks.Run(context.Background(), check, input)
+		result.record(checkName, checkName, "main", checkViolations)
+		ruleResult.Violations = len(checkViolations)
+		if policies.ReportViolations(checkName, checkViolations) {
+			violations = true
+			ruleResult.Passed = false
+		}
+
+		policyFileResults[checkName] = !ruleResult.Passed
+		policyFileErrors[checkName] = false
+		allRuleResults = append(allRuleResults, ruleResult)
+	}
+
 	// Calculate statistics
 	var passedPolicyFiles, failedPolicyFiles, errorPolicyFiles int
 	var passedRules, failedRules, errorRules int
@@ -744,19 +451,247 @@ func main() {
 	fmt.Printf("%s✅ Passed:%s %d policy files (%d rules)\n", ColorGreen, ColorReset, passedPolicyFiles, passedRules)
 	fmt.Printf("%s❌ Failed:%s %d policy files (%d rules)\n", ColorRed, ColorReset, failedPolicyFiles, failedRules)
 	fmt.Printf("%s⚠️ Errors:%s %d policy files (%d rules)\n", ColorYellow, ColorReset, errorPolicyFiles, errorRules)
-	fmt.Printf("%s🔍 Total:%s %d policy files (%d rules)\n", ColorCyan, ColorReset, len(allPolicyFiles), len(allRuleResults))
+	fmt.Printf("%s🔍 Total:%s %d policy files (%d rules)\n", ColorCyan, ColorReset, len(policyFileResults), len(allRuleResults))
 
+	result.Failed = violations
+	result.ErrorRules = errorRules
 	if violations {
 		fmt.Printf("\n%s❌ Module validation FAILED%s\n", ColorRed, ColorReset)
-		os.Exit(1)
 	} else {
 		fmt.Printf("\n%s✅ Module validation PASSED%s\n", ColorGreen, ColorReset)
 	}
+
+	return result
+}
+
+// collectModuleInput runs the terraform file collector against the module
+// and builds the input document shared by all policies and checks
+func collectModuleInput(config map[string]interface{}, configPath, modulePath string) (*ModuleInput, error) {
+	// Get scripts configuration
+	scripts, ok := config["scripts"].(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("scripts configuration not found")
+	}
+
+	// Get temp file pattern
+	tempFilePattern, ok := scripts["temp_file_pattern"].(string)
+	if !ok {
+		tempFilePattern = "terraform-files-*.json" // Fallback
+		logMessage(LevelWarn, "Temp file pattern not found in config, using default: %s", tempFilePattern)
+	} else {
+		logMessage(LevelDebug, "Using temp file pattern: %s", tempFilePattern)
+	}
+
+	// Get terraform file collector script
+	tfCollectorScript, ok := scripts["terraform_file_collector"].(string)
+	if !ok {
+		return nil, fmt.Errorf("terraform_file_collector script not found in config")
+	}
+	logMessage(LevelDebug, "Using terraform file collector script: %s", tfCollectorScript)
+
+	// Create temporary file for Terraform files
+	tempFile, err := os.CreateTemp("", tempFilePattern)
+	if err != nil {
+		return nil, fmt.Errorf("error creating temporary file: %w", err)
+	}
+	defer os.Remove(tempFile.Name())
+	tempFile.Close()
+	logMessage(LevelDebug, "Created temporary file: %s", tempFile.Name())
+
+	// Collect Terraform files
+	var scriptPath string
+
+	// Check if the collector script is a relative path or just a name
+	if strings.HasPrefix(tfCollectorScript, "./") || strings.HasPrefix(tfCollectorScript, "/") {
+		// It's a path, use it directly
+		scriptPath = tfCollectorScript
+	} else {
+		// It's just a name, assume it's in the scripts directory
+		scriptPath = filepath.Join("./scripts", tfCollectorScript, "main.go")
+	}
+
+	logMessage(LevelDebug, "Running terraform file collector: %s", scriptPath)
+	logMessage(LevelDebug, "Module path: %s", modulePath)
+	logMessage(LevelDebug, "Output file: %s", tempFile.Name())
+	logMessage(LevelDebug, "Config path: %s", configPath)
+
+	cmd := exec.Command("go", "run", scriptPath,
+		"--module-path", modulePath,
+		"--output", tempFile.Name(),
+		"--config", configPath)
+
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("error collecting Terraform files: %w", err)
+	}
+	logMessage(LevelInfo, "Terraform files collected successfully")
+
+	// Build the input document shared by all policies and checks
+	input, err := buildModuleInput(tempFile.Name(), modulePath)
+	if err != nil {
+		return nil, fmt.Errorf("error building module input: %w", err)
+	}
+	return input, nil
+}
+
+// collectModuleInputInProcess builds the input document without running the
+// collector, following the same excluded_dirs, important_dirs and
+// directory_marker rules
+func collectModuleInputInProcess(modulePath string, settings collectorSettings) (*ModuleInput, error) {
+	entries, err := os.ReadDir(modulePath)
+	if err != nil {
+		return nil, fmt.Errorf("error reading module directory: %w", err)
+	}
+
+	cleanModulePath := strings.TrimSuffix(modulePath, "/")
+	input := &ModuleInput{
+		ModulePath: filepath.Base(cleanModulePath),
+		RepoPath:   cleanModulePath,
+		Files:      make(map[string]string),
+	}
+
+	paths := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() && settings.isExcluded(entry.Name()) {
+			continue
+		}
+		paths = append(paths, filepath.Join(cleanModulePath, entry.Name()))
+	}
+	applyFileChanges(input, cleanModulePath, paths, settings)
+
+	return input, nil
+}
+
+// readModuleInput loads an input document saved with --dump-input
+func readModuleInput(path string) (*ModuleInput, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read input file: %w", err)
+	}
+
+	var input ModuleInput
+	if err := json.Unmarshal(data, &input); err != nil {
+		return nil, fmt.Errorf("failed to parse input file: %w", err)
+	}
+	if input.ModulePath == "" {
+		return nil, fmt.Errorf("input file %s has no module_path", path)
+	}
+	if input.Files == nil {
+		input.Files = make(map[string]string)
+	}
+	if input.RepoPath == "" {
+		input.RepoPath = input.ModulePath
+	}
+
+	return &input, nil
+}
+
+// writeModuleInput saves the input document in the same form that is passed
+// to OPA, so it can be replayed with --input
+func writeModuleInput(path string, input *ModuleInput) error {
+	data, err := json.MarshalIndent(input, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal input: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write input file: %w", err)
+	}
+	return nil
+}
+
+// buildModuleInput reads the collector output and rewrites it into the
+// module-scoped document evaluated by policies and checks
+func buildModuleInput(collectedFile, modulePath string) (*ModuleInput, error) {
+	data, err := os.ReadFile(collectedFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read collected files: %w", err)
+	}
+
+	var collected struct {
+		Files map[string]string `json:"files"`
+	}
+	if err := json.Unmarshal(data, &collected); err != nil {
+		return nil, fmt.Errorf("failed to parse collected files: %w", err)
+	}
+
+	// Process module paths
+	cleanModulePath := strings.TrimSuffix(modulePath, "/")
+	moduleName := filepath.Base(cleanModulePath)
+
+	input := &ModuleInput{
+		ModulePath: moduleName,      // Just the module name for policy evaluation
+		RepoPath:   cleanModulePath, // Full path for display
+		Files:      make(map[string]string, len(collected.Files)),
+	}
+
+	// Transform file paths to use module name as root
+	for filePath, content := range collected.Files {
+		if strings.HasPrefix(filePath, cleanModulePath+"/") {
+			filePath = strings.Replace(filePath, cleanModulePath, moduleName, 1)
+		}
+		input.Files[filePath] = content
+	}
+
+	return input, nil
+}
+
+// applyFilter returns the policies and checks selected by the filter
+func applyFilter(sources []PolicySource, checkIDs []string, filter PolicyFilter) ([]PolicySource, []string) {
+	var selectedSources []PolicySource
+	for _, source := range sources {
+		if !filter.SelectsPolicy(source) {
+			logMessage(LevelDebug, "Skipping policy not selected by filter: %s", source.File)
+			continue
+		}
+		if len(filter.Rules) > 0 && source.Err == nil && len(selectedRules(source, filter)) == 0 {
+			logMessage(LevelDebug, "Skipping policy without selected rules: %s", source.File)
+			continue
+		}
+		selectedSources = append(selectedSources, source)
+	}
+	var selectedChecks []string
+	for _, id := range checkIDs {
+		if filter.SelectsCheck(id) {
+			selectedChecks = append(selectedChecks, id)
+		}
+	}
+	return selectedSources, selectedChecks
+}
+
+// selectedRules returns the violation rules of a policy that pass the filter
+func selectedRules(source PolicySource, filter PolicyFilter) []string {
+	var rules []string
+	for _, rule := range source.ViolationRules() {
+		if filter.SelectsRule(rule) {
+			rules = append(rules, rule)
+		}
+	}
+	return rules
+}
+
+// parseEvalValue extracts the value of the first expression from `opa eval --format json` output
+func parseEvalValue(output []byte) (interface{}, error) {
+	var result struct {
+		Result []struct {
+			Expressions []struct {
+				Value interface{} `json:"value"`
+			} `json:"expressions"`
+		} `json:"result"`
+	}
+	if err := json.Unmarshal(output, &result); err != nil {
+		return nil, err
+	}
+	if len(result.Result) == 0 || len(result.Result[0].Expressions) == 0 {
+		// Undefined rules produce no results, which means a pass
+		return nil, nil
+	}
+	return result.Result[0].Expressions[0].Value, nil
 }
 
 // loadConfig loads the configuration from a JSON file
 func loadConfig(path string) (map[string]interface{}, error) {
-	data, err := ioutil.ReadFile(path)
+	data, err := os.ReadFile(path)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read config file: %w", err)
 	}
@@ -771,7 +706,7 @@ func loadConfig(path string) (map[string]interface{}, error) {
 
 // getPackageName extracts the package name from a Rego file
 func getPackageName(policyFile string) string {
-	data, err := ioutil.ReadFile(policyFile)
+	data, err := os.ReadFile(policyFile)
 	if err != nil {
 		return ""
 	}
@@ -1,8 +1,9 @@
 package main
 
 import (
-	"io/ioutil"
 	"os"
+	"path/filepath"
+	"reflect"
 	"testing"
 )
 
@@ -20,7 +21,7 @@ func TestLoadConfig(t *testing.T) {
 		}
 	}`
 
-	tmpFile, err := ioutil.TempFile("", "config-*.json")
+	tmpFile, err := os.CreateTemp("", "config-*.json")
 	if err != nil {
 		t.Fatalf("Failed to create temp file: %v", err)
 	}
@@ -86,7 +87,7 @@ violation[result] {
     # Rule implementation
 }`
 
-	tmpFile, err := ioutil.TempFile("", "policy-*.rego")
+	tmpFile, err := os.CreateTemp("", "policy-*.rego")
 	if err != nil {
 		t.Fatalf("Failed to create temp file: %v", err)
 	}
@@ -116,7 +117,7 @@ violation[result] {
     # Rule implementation
 }`
 
-	tmpFile2, err := ioutil.TempFile("", "policy-no-package-*.rego")
+	tmpFile2, err := os.CreateTemp("", "policy-no-package-*.rego")
 	if err != nil {
 		t.Fatalf("Failed to create temp file: %v", err)
 	}
@@ -141,3 +142,148 @@ violation[result] {
 		t.Errorf("getPackageName() for non-existent file = %s, want empty string", packageName)
 	}
 }
+
+func TestBuildModuleInput(t *testing.T) {
+	tmpFile, err := os.CreateTemp("", "collected-*.json")
+	if err != nil {
+		t.Fatalf("Failed to create temp file: %v", err)
+	}
+	defer os.Remove(tmpFile.Name())
+
+	content := `{"files": {"skeletons/my-module/main.tf": "# main", "skeletons/my-module/tests": "directory"}}`
+	if _, err := tmpFile.Write([]byte(content)); err != nil {
+		t.Fatalf("Failed to write to temp file: %v", err)
+	}
+	tmpFile.Close()
+
+	input, err := buildModuleInput(tmpFile.Name(), "skeletons/my-module/")
+	if err != nil {
+		t.Fatalf("buildModuleInput() error = %v", err)
+	}
+
+	if input.ModulePath != "my-module" {
+		t.Errorf("Expected module_path my-module, got %s", input.ModulePath)
+	}
+	if input.RepoPath != "skeletons/my-module" {
+		t.Errorf("Expected repo_path skeletons/my-module, got %s", input.RepoPath)
+	}
+	if input.Files["my-module/main.tf"] != "# main" {
+		t.Errorf("Expected rewritten file key, got %v", input.Files)
+	}
+	if input.Files["my-module/tests"] != "directory" {
+		t.Errorf("Expected directory marker to be preserved, got %v", input.Files)
+	}
+}
+
+func TestModuleInputRoundTrip(t *testing.T) {
+	input := &ModuleInput{
+		ModulePath: "my-module",
+		RepoPath:   "skeletons/my-module",
+		Files:      map[string]string{"my-module/main.tf": "# main", "my-module/tests": "directory"},
+	}
+
+	path := filepath.Join(t.TempDir(), "input.json")
+	if err := writeModuleInput(path, input); err != nil {
+		t.Fatalf("writeModuleInput() error = %v", err)
+	}
+	replayed, err := readModuleInput(path)
+	if err != nil {
+		t.Fatalf("readModuleInput() error = %v", err)
+	}
+	if !reflect.DeepEqual(replayed, input) {
+		t.Errorf("readModuleInput() = %+v, want %+v", replayed, input)
+	}
+
+	// Documents without a module path cannot be evaluated
+	if err := os.WriteFile(path, []byte(`{"files": {}}`), 0644); err != nil {
+		t.Fatalf("Failed to write input: %v", err)
+	}
+	if _, err := readModuleInput(path); err == nil {
+		t.Errorf("Expected error for input without module_path")
+	}
+}
+
+func TestRunValidationSeverity(t *testing.T) {
+	policyFile := filepath.Join(t.TempDir(), "severity.rego")
+	policy := `package test.severity
+
+import rego.v1
+
+violation contains result if {
+	some severity in split(input.files["mod/severities"], ",")
+	result := {"severity": severity, "message": sprintf("%s violation", [severity])}
+}
+`
+	if err := os.WriteFile(policyFile, []byte(policy), 0644); err != nil {
+		t.Fatal(err)
+	}
+	sources := []PolicySource{parsePolicySource(policyFile)}
+
+	tests := []struct {
+		name       string
+		severities string // Comma-separated, or none for no violations
+		violations int
+		failed     bool
+	}{
+		{"No violations", "none", 0, false},
+		{"Warnings and info only", "warning,WARN,info", 3, false},
+		{"Error", "warning,error", 2, true},
+		{"No severity", "", 1, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := &ModuleInput{ModulePath: "mod", Files: map[string]string{}}
+			if tt.severities != "none" {
+				input.Files["mod/severities"] = tt.severities
+			}
+
+			result := runValidation(input, sources, nil, validationOptions{ModuleType: "test", InProcess: true})
+			if result.ErrorRules > 0 {
+				t.Fatalf("%d rules could not be evaluated", result.ErrorRules)
+			}
+			if len(result.Violations) != tt.violations {
+				t.Errorf("Expected %d violations, got %+v", tt.violations, result.Violations)
+			}
+			if result.Failed != tt.failed {
+				t.Errorf("Failed = %v, want %v", result.Failed, tt.failed)
+			}
+		})
+	}
+}
+
+func TestRunValidationRegoV1(t *testing.T) {
+	dir := t.TempDir()
+
+	// Rego v1 needs no imports for contains and if
+	v1File := filepath.Join(dir, "v1.rego")
+	v1Policy := `package test.v1
+
+violation contains result if {
+	not input.files["mod/README.md"]
+	result := {"message": "README.md is missing"}
+}
+`
+	if err := os.WriteFile(v1File, []byte(v1Policy), 0644); err != nil {
+		t.Fatal(err)
+	}
+	source := parsePolicySource(v1File)
+	if source.Err != nil {
+		t.Fatalf("parsePolicySource() error = %v", source.Err)
+	}
+
+	input := &ModuleInput{ModulePath: "mod", Files: map[string]string{}}
+	result := runValidation(input, []PolicySource{source}, nil, validationOptions{ModuleType: "test", InProcess: true})
+	if result.ErrorRules > 0 || len(result.Violations) != 1 {
+		t.Errorf("Expected 1 violation and no errors, got %d errors and %+v", result.ErrorRules, result.Violations)
+	}
+
+	// Rego v0 rule bodies without if are rejected
+	v0File := filepath.Join(dir, "v0.rego")
+	if err := os.WriteFile(v0File, []byte("package test.v0\n\nviolation[msg] {\n\tmsg := \"v0\"\n}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if source := parsePolicySource(v0File); source.Err == nil {
+		t.Errorf("Expected a parse error for Rego v0 syntax")
+	}
+}
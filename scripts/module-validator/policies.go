@@ -0,0 +1,265 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/open-policy-agent/opa/ast"
+
+	"github.com/terraform-modules/scripts/module-validator/checks"
+	"github.com/terraform-modules/scripts/module-validator/policies"
+)
+
+// PolicySource is a parsed Rego policy file
+type PolicySource struct {
+	File    string   // Path to the .rego file
+	Dir     string   // Policy directory the file was found in
+	Origin  string   // Config setting that selected the directory
+	Package string   // Package name without the data. prefix
+	Rules   []string // Unique rule names in definition order
+	Err     error    // Parse error, reported when the policy is evaluated
+}
+
+// ViolationRules returns the rules that module-validator evaluates
+func (p PolicySource) ViolationRules() []string {
+	var rules []string
+	for _, rule := range p.Rules {
+		if policies.IsViolationRule(rule) {
+			rules = append(rules, rule)
+		}
+	}
+	return rules
+}
+
+// ruleDisplayName returns the name used for a rule in the output
+func ruleDisplayName(rule string) string {
+	if rule == "violation" {
+		return "main"
+	}
+	return rule
+}
+
+// PolicyFilter selects which policies, rules and checks are evaluated
+type PolicyFilter struct {
+	Policies     []string // Package or file globs to include (all if empty)
+	SkipPolicies []string // Package or file globs to exclude
+	Rules        []string // Rule names to include (all if empty)
+}
+
+// SelectsPolicy reports whether a policy file passes the filter
+func (f PolicyFilter) SelectsPolicy(p PolicySource) bool {
+	for _, selector := range f.SkipPolicies {
+		if matchesPolicySelector(selector, p.File, p.Package) {
+			return false
+		}
+	}
+	if len(f.Policies) == 0 {
+		return true
+	}
+	for _, selector := range f.Policies {
+		if matchesPolicySelector(selector, p.File, p.Package) {
+			return true
+		}
+	}
+	return false
+}
+
+// SelectsCheck reports whether a Go check passes the filter. Checks are
+// matched by ID or by check:<id>.
+func (f PolicyFilter) SelectsCheck(id string) bool {
+	matches := func(selector string) bool {
+		return globMatch(selector, id) || globMatch(selector, "check:"+id)
+	}
+	for _, selector := range f.SkipPolicies {
+		if matches(selector) {
+			return false
+		}
+	}
+	if len(f.Rules) > 0 {
+		return false
+	}
+	if len(f.Policies) == 0 {
+		return true
+	}
+	for _, selector := range f.Policies {
+		if matches(selector) {
+			return true
+		}
+	}
+	return false
+}
+
+// SelectsRule reports whether a rule passes the filter. Rules can be
+// selected by their Rego name or by their display name.
+func (f PolicyFilter) SelectsRule(rule string) bool {
+	if len(f.Rules) == 0 {
+		return true
+	}
+	for _, selector := range f.Rules {
+		if globMatch(selector, rule) || globMatch(selector, ruleDisplayName(rule)) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesPolicySelector matches a selector against a package name or file path
+func matchesPolicySelector(selector, file, packageName string) bool {
+	if globMatch(selector, packageName) {
+		return true
+	}
+	// Selecting a package also selects its subpackages
+	if strings.HasPrefix(packageName, selector+".") {
+		return true
+	}
+	if globMatch(selector, filepath.ToSlash(file)) || globMatch(selector, filepath.Base(file)) {
+		return true
+	}
+	return false
+}
+
+// globMatch matches a glob pattern, treating invalid patterns as literals
+func globMatch(pattern, name string) bool {
+	matched, err := path.Match(pattern, name)
+	if err != nil {
+		return pattern == name
+	}
+	return matched
+}
+
+// stringSliceFlag collects repeated and comma-separated flag values
+type stringSliceFlag []string
+
+func (s *stringSliceFlag) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringSliceFlag) Set(value string) error {
+	for _, part := range strings.Split(value, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			*s = append(*s, part)
+		}
+	}
+	return nil
+}
+
+// loadPolicySources finds and parses all policy files in the given directories
+func loadPolicySources(policyDirs []policies.Dir) []PolicySource {
+	var sources []PolicySource
+	for _, dir := range policyDirs {
+		logMessage(LevelInfo, "Looking for policies in: %s", dir.Path)
+		policyFiles, err := filepath.Glob(filepath.Join(dir.Path, "*.rego"))
+		if err != nil {
+			logMessage(LevelError, "Error finding policy files in %s: %v", dir.Path, err)
+			continue
+		}
+		logMessage(LevelDebug, "Found %d policy files in %s", len(policyFiles), dir.Path)
+
+		for _, file := range policyFiles {
+			logMessage(LevelTrace, "Found policy file: %s", file)
+			source := parsePolicySource(file)
+			source.Dir = dir.Path
+			source.Origin = dir.Origin
+			sources = append(sources, source)
+		}
+	}
+	return sources
+}
+
+// parsePolicySource parses a Rego file into its package and rule names.
+// Parse errors are recorded on the source rather than returned so they are
+// reported alongside the other policy results.
+func parsePolicySource(file string) PolicySource {
+	source := PolicySource{File: file}
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		source.Err = fmt.Errorf("failed to read policy %s: %w", file, err)
+		return source
+	}
+
+	module, err := ast.ParseModuleWithOpts(file, string(data), ast.ParserOptions{RegoVersion: policies.RegoVersion})
+	if err != nil {
+		source.Package = getPackageName(file)
+		source.Err = fmt.Errorf("failed to parse policy %s: %w", file, err)
+		return source
+	}
+	source.Package = strings.TrimPrefix(module.Package.Path.String(), "data.")
+
+	seen := make(map[string]bool)
+	for _, rule := range module.Rules {
+		name := policies.RuleName(rule)
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		source.Rules = append(source.Rules, name)
+	}
+
+	return source
+}
+
+// printPolicyList prints the resolved policy set without evaluating anything
+func printPolicyList(moduleType string, sources []PolicySource, checkIDs []string, filter PolicyFilter) {
+	fmt.Printf("\n%s=== Policies for %s module ===%s\n", ColorBlue, moduleType, ColorReset)
+
+	policyCount := 0
+	ruleCount := 0
+	currentDir := ""
+	for _, source := range sources {
+		if !filter.SelectsPolicy(source) {
+			continue
+		}
+		rules := selectedRules(source, filter)
+		if len(rules) == 0 && source.Err == nil {
+			continue
+		}
+
+		if source.Dir != currentDir {
+			currentDir = source.Dir
+			fmt.Printf("\n%s📁 %s%s %s(%s)%s\n", ColorCyan, source.Dir, ColorReset, ColorGray, source.Origin, ColorReset)
+		}
+		fmt.Printf("  %s\n", filepath.Base(source.File))
+		fmt.Printf("    package: %s\n", source.Package)
+		if source.Err != nil {
+			fmt.Printf("    %serror:   %v%s\n", ColorRed, source.Err, ColorReset)
+		}
+		fmt.Printf("    rules:   %s\n", strings.Join(rules, ", "))
+
+		var helpers []string
+		for _, rule := range source.Rules {
+			if !policies.IsViolationRule(rule) {
+				helpers = append(helpers, rule)
+			}
+		}
+		if len(helpers) > 0 {
+			sort.Strings(helpers)
+			fmt.Printf("    %shelpers: %s%s\n", ColorGray, strings.Join(helpers, ", "), ColorReset)
+		}
+
+		policyCount++
+		ruleCount += len(rules)
+	}
+
+	checkCount := 0
+	for _, id := range checkIDs {
+		if !filter.SelectsCheck(id) {
+			continue
+		}
+		if checkCount == 0 {
+			fmt.Printf("\n%s🔧 Go checks%s %s(module_types.%s.checks)%s\n", ColorCyan, ColorReset, ColorGray, moduleType, ColorReset)
+		}
+		status := ""
+		if _, ok := checks.Lookup(id); !ok {
+			status = fmt.Sprintf(" %s(unknown check)%s", ColorRed, ColorReset)
+		}
+		fmt.Printf("  check:%s%s\n", id, status)
+		checkCount++
+	}
+
+	fmt.Printf("\n%s🔍 Total:%s %d policy files (%d rules), %d checks\n", ColorCyan, ColorReset, policyCount, ruleCount, checkCount)
+}
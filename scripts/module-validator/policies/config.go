@@ -0,0 +1,86 @@
+package policies
+
+import "fmt"
+
+// Origins of policy directories
+const (
+	OriginModuleType = "policy_dir"
+	OriginAdditional = "module_validator_additional_policies"
+)
+
+// Dir is a directory of Rego policies and the config setting it came from
+type Dir struct {
+	Path   string
+	Origin string
+}
+
+// ModuleDirs returns the policy directories evaluated for a module type: the
+// type's policy_dir followed by module_validator_additional_policies. Keys of
+// rego_policy_dirs that are not found are skipped.
+func ModuleDirs(config map[string]interface{}, moduleType string) ([]Dir, error) {
+	if _, ok := config["module_types"].(map[string]interface{}); !ok {
+		return nil, fmt.Errorf("module_types not found in config")
+	}
+
+	var dirs []Dir
+	if policyDir, ok := TypeConfig(config, moduleType)["policy_dir"].(string); ok {
+		dirs = append(dirs, Dir{Path: policyDir, Origin: OriginModuleType})
+	}
+
+	additional, _ := config["module_validator_additional_policies"].([]interface{})
+	regoPolicyDirs, _ := config["rego_policy_dirs"].(map[string]interface{})
+	for _, key := range additional {
+		keyStr, ok := key.(string)
+		if !ok {
+			continue
+		}
+		if policyDir, ok := regoPolicyDirs[keyStr].(string); ok {
+			dirs = append(dirs, Dir{Path: policyDir, Origin: OriginAdditional})
+		}
+	}
+
+	return dirs, nil
+}
+
+// PRDirs returns the policy directories listed in pr_validator_policies,
+// which name keys of rego_policy_dirs
+func PRDirs(config map[string]interface{}) ([]string, error) {
+	keys, ok := config["pr_validator_policies"].([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("pr_validator_policies not found in config")
+	}
+	regoPolicyDirs, _ := config["rego_policy_dirs"].(map[string]interface{})
+
+	var dirs []string
+	for _, key := range keys {
+		keyStr, ok := key.(string)
+		if !ok {
+			continue
+		}
+		policyDir, ok := regoPolicyDirs[keyStr].(string)
+		if !ok {
+			return nil, fmt.Errorf("policy directory not found in rego_policy_dirs for key: %s", keyStr)
+		}
+		dirs = append(dirs, policyDir)
+	}
+	return dirs, nil
+}
+
+// TypeConfig returns the module_types entry for a module type
+func TypeConfig(config map[string]interface{}, moduleType string) map[string]interface{} {
+	moduleTypes, _ := config["module_types"].(map[string]interface{})
+	typeConfig, _ := moduleTypes[moduleType].(map[string]interface{})
+	return typeConfig
+}
+
+// ModuleChecks returns the Go check IDs configured for a module type
+func ModuleChecks(config map[string]interface{}, moduleType string) []string {
+	var ids []string
+	configured, _ := TypeConfig(config, moduleType)["checks"].([]interface{})
+	for _, check := range configured {
+		if id, ok := check.(string); ok && id != "" {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
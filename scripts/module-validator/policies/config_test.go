@@ -0,0 +1,70 @@
+package policies
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestModuleDirs(t *testing.T) {
+	config := map[string]interface{}{
+		"module_types": map[string]interface{}{
+			"skeleton": map[string]interface{}{
+				"policy_dir": "policies/skeleton",
+				"checks":     []interface{}{"variable_references"},
+			},
+		},
+		"rego_policy_dirs": map[string]interface{}{
+			"tests/module": "policies/module",
+		},
+		"module_validator_additional_policies": []interface{}{"tests/module", "tests/missing"},
+	}
+
+	dirs, err := ModuleDirs(config, "skeleton")
+	if err != nil {
+		t.Fatalf("ModuleDirs() error = %v", err)
+	}
+	expected := []Dir{
+		{Path: "policies/skeleton", Origin: OriginModuleType},
+		{Path: "policies/module", Origin: OriginAdditional},
+	}
+	if !reflect.DeepEqual(dirs, expected) {
+		t.Errorf("ModuleDirs() = %v, want %v", dirs, expected)
+	}
+
+	ids := ModuleChecks(config, "skeleton")
+	if !reflect.DeepEqual(ids, []string{"variable_references"}) {
+		t.Errorf("Unexpected checks: %v", ids)
+	}
+	if len(ModuleChecks(config, "unknown")) != 0 {
+		t.Errorf("Expected no checks for unknown module type")
+	}
+
+	// Unknown module types only get the additional policies
+	dirs, err = ModuleDirs(config, "unknown")
+	if err != nil || !reflect.DeepEqual(dirs, []Dir{{Path: "policies/module", Origin: OriginAdditional}}) {
+		t.Errorf("ModuleDirs() of an unknown type = %v, %v; want only the additional policies", dirs, err)
+	}
+
+	if _, err := ModuleDirs(map[string]interface{}{}, "skeleton"); err == nil {
+		t.Errorf("Expected an error without module_types")
+	}
+}
+
+func TestPRDirs(t *testing.T) {
+	config := map[string]interface{}{
+		"rego_policy_dirs":      map[string]interface{}{"tests/opa/unit/global": "policies/opa/global"},
+		"pr_validator_policies": []interface{}{"tests/opa/unit/global"},
+	}
+	dirs, err := PRDirs(config)
+	if err != nil {
+		t.Fatalf("PRDirs() error = %v", err)
+	}
+	if !reflect.DeepEqual(dirs, []string{"policies/opa/global"}) {
+		t.Errorf("PRDirs() = %v", dirs)
+	}
+
+	config["pr_validator_policies"] = []interface{}{"unknown"}
+	if _, err := PRDirs(config); err == nil {
+		t.Errorf("Expected an error for an unknown policy key")
+	}
+}
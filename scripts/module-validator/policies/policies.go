@@ -0,0 +1,114 @@
+// Package policies holds the handling of Rego policies shared by
+// module-validator and the monorepo tool: which rules report violations,
+// how their values convert into violations and which violations block.
+package policies
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/open-policy-agent/opa/ast"
+
+	"github.com/terraform-modules/scripts/module-validator/checks"
+)
+
+// RegoVersion is the Rego syntax policies are parsed and evaluated with. It
+// matches the default of the OPA 1.x CLI pinned in .tool-versions, so the
+// policies behave the same here as in opa test and opa fmt.
+const RegoVersion = ast.RegoV1
+
+// RuleName returns the name of a rule, including partial set and object rules
+func RuleName(rule *ast.Rule) string {
+	if rule.Head.Name != "" {
+		return rule.Head.Name.String()
+	}
+	ref := rule.Head.Ref()
+	if len(ref) == 0 {
+		return ""
+	}
+	if v, ok := ref[0].Value.(ast.Var); ok {
+		return string(v)
+	}
+	return ref[0].String()
+}
+
+// IsViolationRule reports whether a rule produces violations
+func IsViolationRule(rule string) bool {
+	return rule == "violation" || strings.HasSuffix(rule, "violation")
+}
+
+// ViolationsFromValue converts a rule value into violations. Sets are
+// encoded as arrays by OPA; objects keyed by JSON-encoded violations are
+// also supported.
+func ViolationsFromValue(value interface{}) []checks.Violation {
+	var result []checks.Violation
+
+	switch v := value.(type) {
+	case []interface{}:
+		for _, item := range v {
+			result = append(result, violationFromItem(item))
+		}
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			var details map[string]interface{}
+			if err := json.Unmarshal([]byte(k), &details); err == nil {
+				result = append(result, violationFromItem(details))
+			} else {
+				result = append(result, checks.Violation{Message: k})
+			}
+		}
+	}
+
+	return result
+}
+
+// violationFromItem converts a single violation element into a Violation
+func violationFromItem(item interface{}) checks.Violation {
+	switch v := item.(type) {
+	case map[string]interface{}:
+		violation := checks.Violation{
+			Policy:     stringField(v, "policy"),
+			Severity:   stringField(v, "severity"),
+			Message:    stringField(v, "message"),
+			Details:    stringField(v, "details"),
+			Resolution: stringField(v, "resolution"),
+		}
+		if violation.Message == "" {
+			violation.Message = "Violation detected"
+		}
+		return violation
+	case string:
+		return checks.Violation{Message: v}
+	default:
+		return checks.Violation{Message: "Violation detected"}
+	}
+}
+
+// stringField returns a field from a violation object as a string
+func stringField(m map[string]interface{}, key string) string {
+	value, ok := m[key]
+	if !ok || value == nil {
+		return ""
+	}
+	if s, ok := value.(string); ok {
+		return s
+	}
+	return fmt.Sprintf("%v", value)
+}
+
+// IsBlockingSeverity reports whether a violation severity fails validation.
+// Violations without a severity are treated as errors.
+func IsBlockingSeverity(severity string) bool {
+	switch strings.ToLower(severity) {
+	case "warning", "warn", "info":
+		return false
+	}
+	return true
+}
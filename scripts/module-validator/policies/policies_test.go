@@ -0,0 +1,57 @@
+package policies
+
+import "testing"
+
+func TestViolationsFromValue(t *testing.T) {
+	// Sets of violation objects are encoded as arrays
+	value := []interface{}{
+		map[string]interface{}{
+			"policy":     "test_policy",
+			"severity":   "warning",
+			"message":    "Something is wrong",
+			"details":    "Details here",
+			"resolution": "Fix it",
+		},
+		"plain string violation",
+	}
+
+	violations := ViolationsFromValue(value)
+	if len(violations) != 2 {
+		t.Fatalf("Expected 2 violations, got %d", len(violations))
+	}
+	if violations[0].Policy != "test_policy" || violations[0].Severity != "warning" || violations[0].Resolution != "Fix it" {
+		t.Errorf("Unexpected first violation: %+v", violations[0])
+	}
+	if violations[1].Message != "plain string violation" {
+		t.Errorf("Unexpected second violation: %+v", violations[1])
+	}
+
+	// Objects keyed by JSON-encoded violations
+	keyed := map[string]interface{}{
+		`{"message":"Keyed violation"}`: true,
+	}
+	violations = ViolationsFromValue(keyed)
+	if len(violations) != 1 || violations[0].Message != "Keyed violation" {
+		t.Errorf("Unexpected keyed violations: %+v", violations)
+	}
+
+	// Empty and undefined values are passes
+	if len(ViolationsFromValue([]interface{}{})) != 0 || len(ViolationsFromValue(nil)) != 0 {
+		t.Errorf("Expected no violations for empty values")
+	}
+}
+
+func TestIsBlockingSeverity(t *testing.T) {
+	tests := map[string]bool{
+		"error":   true,
+		"":        true,
+		"warning": false,
+		"WARN":    false,
+		"info":    false,
+	}
+	for severity, expected := range tests {
+		if result := IsBlockingSeverity(severity); result != expected {
+			t.Errorf("IsBlockingSeverity(%q) = %v, want %v", severity, result, expected)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package policies
+
+import (
+	"fmt"
+
+	"github.com/terraform-modules/scripts/module-validator/checks"
+)
+
+// Colors for terminal output
+const (
+	colorReset  = "\033[0m"
+	colorRed    = "\033[31m"
+	colorGreen  = "\033[32m"
+	colorYellow = "\033[33m"
+)
+
+// ReportViolations prints the outcome of a rule or check and returns true if
+// any violation is blocking
+func ReportViolations(name string, violations []checks.Violation) bool {
+	blocking := false
+	for _, v := range violations {
+		if IsBlockingSeverity(v.Severity) {
+			blocking = true
+			break
+		}
+	}
+
+	switch {
+	case blocking:
+		fmt.Printf("  %s❌ FAIL: %s%s\n", colorRed, name, colorReset)
+	case len(violations) > 0:
+		fmt.Printf("  %s⚠️ WARN: %s%s\n", colorYellow, name, colorReset)
+	default:
+		fmt.Printf("  %s✅ PASS: %s%s\n", colorGreen, name, colorReset)
+	}
+
+	for _, v := range violations {
+		color := colorRed
+		if !IsBlockingSeverity(v.Severity) {
+			color = colorYellow
+		}
+		fmt.Printf("    %s%s%s\n", color, v.Message, colorReset)
+		if v.Details != "" {
+			fmt.Printf("    Details: %s\n", v.Details)
+		}
+		if v.Resolution != "" {
+			fmt.Printf("    Resolution: %s\n", v.Resolution)
+		}
+	}
+
+	return blocking
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestPolicyFilterSelectsPolicy(t *testing.T) {
+	source := PolicySource{
+		File:    "policies/opa/terraform/module/hardcoded_values_policy.rego",
+		Package: "terraform.module.hardcoded",
+	}
+
+	tests := []struct {
+		name     string
+		filter   PolicyFilter
+		expected bool
+	}{
+		{"No filter", PolicyFilter{}, true},
+		{"Exact package", PolicyFilter{Policies: []string{"terraform.module.hardcoded"}}, true},
+		{"Parent package", PolicyFilter{Policies: []string{"terraform.module"}}, true},
+		{"Package glob", PolicyFilter{Policies: []string{"terraform.module.hard*"}}, true},
+		{"File glob", PolicyFilter{Policies: []string{"hardcoded_*.rego"}}, true},
+		{"Path glob", PolicyFilter{Policies: []string{"policies/opa/terraform/module/*.rego"}}, true},
+		{"Other package", PolicyFilter{Policies: []string{"terraform.module.naming"}}, false},
+		{"Skipped package", PolicyFilter{SkipPolicies: []string{"terraform.module.hardcoded"}}, false},
+		{"Skip wins over select", PolicyFilter{Policies: []string{"terraform.module"}, SkipPolicies: []string{"hardcoded_values_policy.rego"}}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := tt.filter.SelectsPolicy(source); result != tt.expected {
+				t.Errorf("SelectsPolicy() = %v, want %v", result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestPolicyFilterSelectsRuleAndCheck(t *testing.T) {
+	filter := PolicyFilter{Rules: []string{"main"}}
+	if !filter.SelectsRule("violation") {
+		t.Errorf("Expected display name main to select the violation rule")
+	}
+	if filter.SelectsRule("deny_violation") {
+		t.Errorf("Expected deny_violation not to be selected")
+	}
+	if filter.SelectsCheck("variable_references") {
+		t.Errorf("Expected checks to be skipped when filtering by rule")
+	}
+
+	filter = PolicyFilter{Policies: []string{"check:variable_*"}}
+	if !filter.SelectsCheck("variable_references") {
+		t.Errorf("Expected check glob to select variable_references")
+	}
+	if filter.SelectsCheck("readme_hcl_blocks") {
+		t.Errorf("Expected readme_hcl_blocks not to be selected")
+	}
+}
+
+func TestStringSliceFlag(t *testing.T) {
+	var values stringSliceFlag
+	values.Set("a, b")
+	values.Set("c")
+	if !reflect.DeepEqual([]string(values), []string{"a", "b", "c"}) {
+		t.Errorf("Unexpected values: %v", values)
+	}
+}
+
+func TestParsePolicySource(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "policy.rego")
+	content := `package terraform.module.example
+
+import future.keywords.if
+
+violation[result] if {
+	helper
+	result := {"message": "first"}
+}
+
+violation[result] if {
+	result := {"message": "second"}
+}
+
+deny_violation[msg] if {
+	msg := "denied"
+}
+
+helper if {
+	true
+}
+`
+	if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write policy: %v", err)
+	}
+
+	source := parsePolicySource(file)
+	if source.Err != nil {
+		t.Fatalf("parsePolicySource() error = %v", source.Err)
+	}
+	if source.Package != "terraform.module.example" {
+		t.Errorf("Expected package terraform.module.example, got %s", source.Package)
+	}
+	if !reflect.DeepEqual(source.Rules, []string{"violation", "deny_violation", "helper"}) {
+		t.Errorf("Unexpected rules: %v", source.Rules)
+	}
+	if !reflect.DeepEqual(source.ViolationRules(), []string{"violation", "deny_violation"}) {
+		t.Errorf("Unexpected violation rules: %v", source.ViolationRules())
+	}
+
+	// Invalid policies keep the package name and record the error
+	if err := os.WriteFile(file, []byte("package broken\n\nviolation[x] {"), 0644); err != nil {
+		t.Fatalf("Failed to write policy: %v", err)
+	}
+	source = parsePolicySource(file)
+	if source.Err == nil {
+		t.Errorf("Expected parse error for invalid policy")
+	}
+	if source.Package != "broken" {
+		t.Errorf("Expected fallback package broken, got %s", source.Package)
+	}
+}
@@ -0,0 +1,368 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+
+	"github.com/terraform-modules/scripts/module-validator/policies"
+)
+
+// watchDebounce is how long to wait after the last change before re-running
+const watchDebounce = 300 * time.Millisecond
+
+// collectorSettings mirrors the terraform-file-collector configuration so
+// changed files can be re-collected without re-running the collector
+type collectorSettings struct {
+	ExcludedDirs    []string
+	ImportantDirs   []string
+	DirectoryMarker string
+}
+
+// collectorSettingsFromConfig reads the collector settings from the scripts section
+func collectorSettingsFromConfig(config map[string]interface{}) collectorSettings {
+	settings := collectorSettings{DirectoryMarker: "directory"}
+
+	scripts, ok := config["scripts"].(map[string]interface{})
+	if !ok {
+		return settings
+	}
+	if dirs, ok := scripts["excluded_dirs"].([]interface{}); ok {
+		for _, dir := range dirs {
+			if dirStr, ok := dir.(string); ok {
+				settings.ExcludedDirs = append(settings.ExcludedDirs, dirStr)
+			}
+		}
+	}
+	if dirs, ok := scripts["important_dirs"].([]interface{}); ok {
+		for _, dir := range dirs {
+			if dirStr, ok := dir.(string); ok {
+				settings.ImportantDirs = append(settings.ImportantDirs, dirStr)
+			}
+		}
+	}
+	if marker, ok := scripts["directory_marker"].(string); ok {
+		settings.DirectoryMarker = marker
+	}
+	return settings
+}
+
+// isExcluded reports whether a directory name is excluded from collection
+func (s collectorSettings) isExcluded(name string) bool {
+	for _, dir := range s.ExcludedDirs {
+		if name == dir {
+			return true
+		}
+	}
+	return false
+}
+
+// isImportant reports whether a directory name is recorded in the input
+func (s collectorSettings) isImportant(name string) bool {
+	for _, dir := range s.ImportantDirs {
+		if name == dir {
+			return true
+		}
+	}
+	return false
+}
+
+// watchOptions configures a watch session
+type watchOptions struct {
+	ModulePath string
+	PolicyDirs []policies.Dir
+	CheckIDs   []string
+	Collector  collectorSettings
+	Validation validationOptions
+}
+
+// watchModule re-runs validation whenever files in the module or policy
+// directories change, until the context is cancelled
+func watchModule(ctx context.Context, opts watchOptions, input *ModuleInput, last ValidationResult) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to create watcher: %w", err)
+	}
+	defer watcher.Close()
+
+	if err := addModuleWatches(watcher, opts.ModulePath, opts.Collector); err != nil {
+		return err
+	}
+	for _, dir := range opts.PolicyDirs {
+		if err := watcher.Add(dir.Path); err != nil {
+			logMessage(LevelWarn, "Unable to watch policy directory %s: %v", dir.Path, err)
+		}
+	}
+
+	fmt.Printf("\n%s👀 Watching %s and %d policy directories (Ctrl+C to stop)%s\n", ColorCyan, opts.ModulePath, len(opts.PolicyDirs), ColorReset)
+
+	pending := make(map[string]bool)
+	var debounce <-chan time.Time
+
+	for {
+		select {
+		case <-ctx.Done():
+			fmt.Printf("\n%sStopped watching%s\n", ColorGray, ColorReset)
+			return nil
+
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			logMessage(LevelWarn, "Watch error: %v", err)
+
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			if event.Op == fsnotify.Chmod {
+				continue
+			}
+			logMessage(LevelTrace, "Watch event: %s", event)
+
+			// Watch directories created inside the module
+			if event.Op&fsnotify.Create != 0 && isModulePath(opts.ModulePath, event.Name) {
+				if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+					if err := addModuleWatches(watcher, event.Name, opts.Collector); err != nil {
+						logMessage(LevelWarn, "%v", err)
+					}
+				}
+			}
+
+			pending[event.Name] = true
+			debounce = time.After(watchDebounce)
+
+		case <-debounce:
+			debounce = nil
+			var changed []string
+			for path := range pending {
+				changed = append(changed, path)
+			}
+			pending = make(map[string]bool)
+			sort.Strings(changed)
+
+			last = rerunValidation(opts, input, changed, last)
+			fmt.Printf("\n%s👀 Watching for changes...%s\n", ColorCyan, ColorReset)
+		}
+	}
+}
+
+// rerunValidation applies a batch of changed paths and re-runs validation,
+// printing which violations were fixed or introduced since the previous run
+func rerunValidation(opts watchOptions, input *ModuleInput, changed []string, last ValidationResult) ValidationResult {
+	var modulePaths []string
+	policiesChanged := false
+	for _, path := range changed {
+		switch {
+		case isModulePath(opts.ModulePath, path):
+			modulePaths = append(modulePaths, path)
+		case strings.HasSuffix(path, ".rego"):
+			policiesChanged = true
+		}
+	}
+
+	updated := applyFileChanges(input, opts.ModulePath, modulePaths, opts.Collector)
+	if len(updated) == 0 && !policiesChanged {
+		logMessage(LevelDebug, "No relevant changes in %d events", len(changed))
+		return last
+	}
+
+	fmt.Printf("\n%s🔄 Change detected, re-running validation%s\n", ColorBlue, ColorReset)
+	for _, key := range updated {
+		fmt.Printf("  %s%s%s\n", ColorGray, key, ColorReset)
+	}
+	if policiesChanged {
+		fmt.Printf("  %spolicies reloaded%s\n", ColorGray, ColorReset)
+	}
+
+	// Policies are re-read every run so edits to Rego files are picked up
+	sources := loadPolicySources(opts.PolicyDirs)
+	selectedSources, selectedChecks := applyFilter(sources, opts.CheckIDs, opts.Validation.Filter)
+
+	result := runValidation(input, selectedSources, selectedChecks, opts.Validation)
+	fixed, introduced := diffViolations(last, result)
+	printViolationDiff(fixed, introduced)
+	return result
+}
+
+// addModuleWatches watches a directory and its subdirectories, skipping
+// directories the collector excludes
+func addModuleWatches(watcher *fsnotify.Watcher, root string, settings collectorSettings) error {
+	return filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			return nil
+		}
+		if path != root && settings.isExcluded(info.Name()) {
+			return filepath.SkipDir
+		}
+		if err := watcher.Add(path); err != nil {
+			return fmt.Errorf("failed to watch %s: %w", path, err)
+		}
+		return nil
+	})
+}
+
+// isModulePath reports whether a path is inside the module directory
+func isModulePath(modulePath, path string) bool {
+	rel, err := filepath.Rel(filepath.Clean(modulePath), filepath.Clean(path))
+	return err == nil && rel != "." && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// applyFileChanges re-collects the changed paths into the module input using
+// the same rules as the terraform-file-collector, and returns the input keys
+// that were added, updated or removed
+func applyFileChanges(input *ModuleInput, modulePath string, paths []string, settings collectorSettings) []string {
+	cleanModulePath := filepath.Clean(strings.TrimSuffix(modulePath, "/"))
+	moduleName := filepath.Base(cleanModulePath)
+	updated := make(map[string]bool)
+
+	for _, path := range paths {
+		rel, err := filepath.Rel(cleanModulePath, filepath.Clean(path))
+		if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+			continue
+		}
+
+		// Skip anything below an excluded directory
+		excluded := false
+		for _, dir := range strings.Split(filepath.ToSlash(filepath.Dir(rel)), "/") {
+			if settings.isExcluded(dir) {
+				excluded = true
+				break
+			}
+		}
+		if excluded {
+			continue
+		}
+
+		key := moduleName + "/" + filepath.ToSlash(rel)
+		info, err := os.Stat(path)
+		if err != nil {
+			// Removed or renamed: drop the path and anything below it
+			for existing := range input.Files {
+				if existing == key || strings.HasPrefix(existing, key+"/") {
+					delete(input.Files, existing)
+					updated[existing] = true
+				}
+			}
+			continue
+		}
+
+		if info.IsDir() {
+			if settings.isExcluded(info.Name()) {
+				continue
+			}
+			filepath.Walk(path, func(p string, fi os.FileInfo, err error) error {
+				if err != nil {
+					return nil
+				}
+				r, err := filepath.Rel(cleanModulePath, p)
+				if err != nil {
+					return nil
+				}
+				k := moduleName + "/" + filepath.ToSlash(r)
+				if fi.IsDir() {
+					if p != path && settings.isExcluded(fi.Name()) {
+						return filepath.SkipDir
+					}
+					if settings.isImportant(fi.Name()) && input.Files[k] != settings.DirectoryMarker {
+						input.Files[k] = settings.DirectoryMarker
+						updated[k] = true
+					}
+					return nil
+				}
+				if setFileContent(input, k, p) {
+					updated[k] = true
+				}
+				return nil
+			})
+			continue
+		}
+
+		if setFileContent(input, key, path) {
+			updated[key] = true
+		}
+	}
+
+	keys := make([]string, 0, len(updated))
+	for key := range updated {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// setFileContent reads a file into the input and reports whether it changed
+func setFileContent(input *ModuleInput, key, path string) bool {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		logMessage(LevelWarn, "Unable to read %s: %v", path, err)
+		return false
+	}
+	if existing, ok := input.Files[key]; ok && existing == string(content) {
+		return false
+	}
+	input.Files[key] = string(content)
+	return true
+}
+
+// diffViolations compares two validation runs and returns the violations that
+// disappeared and the ones that are new
+func diffViolations(previous, current ValidationResult) (fixed, introduced []ReportedViolation) {
+	before := make(map[string]int)
+	for _, v := range previous.Violations {
+		before[v.Key()]++
+	}
+	after := make(map[string]int)
+	for _, v := range current.Violations {
+		after[v.Key()]++
+	}
+
+	for _, v := range previous.Violations {
+		if after[v.Key()] > 0 {
+			after[v.Key()]--
+			continue
+		}
+		fixed = append(fixed, v)
+	}
+	for _, v := range current.Violations {
+		if before[v.Key()] > 0 {
+			before[v.Key()]--
+			continue
+		}
+		introduced = append(introduced, v)
+	}
+	return fixed, introduced
+}
+
+// printViolationDiff prints a compact summary of fixed and new violations
+func printViolationDiff(fixed, introduced []ReportedViolation) {
+	fmt.Printf("\n%s=== Changes since last run ===%s\n", ColorBlue, ColorReset)
+	if len(fixed) == 0 && len(introduced) == 0 {
+		fmt.Printf("  %sNo violations fixed or introduced%s\n", ColorGray, ColorReset)
+		return
+	}
+	for _, v := range fixed {
+		fmt.Printf("  %s✅ FIXED:%s %s: %s%s\n", ColorGreen, ColorReset, v.Source, v.Message, violationSuffix(v))
+	}
+	for _, v := range introduced {
+		fmt.Printf("  %s❌ NEW:%s %s: %s%s\n", ColorRed, ColorReset, v.Source, v.Message, violationSuffix(v))
+	}
+	fmt.Printf("  %s%d fixed, %d new%s\n", ColorGray, len(fixed), len(introduced), ColorReset)
+}
+
+// violationSuffix returns the details of a violation for single-line output
+func violationSuffix(v ReportedViolation) string {
+	if v.Details == "" {
+		return ""
+	}
+	return fmt.Sprintf(" %s(%s)%s", ColorGray, v.Details, ColorReset)
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestApplyFileChanges(t *testing.T) {
+	root := t.TempDir()
+	modulePath := filepath.Join(root, "my-module")
+	settings := collectorSettings{
+		ExcludedDirs:    []string{".terraform"},
+		ImportantDirs:   []string{"examples"},
+		DirectoryMarker: "directory",
+	}
+
+	write := func(rel, content string) {
+		path := filepath.Join(modulePath, rel)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("failed to create dir: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write %s: %v", rel, err)
+		}
+	}
+
+	write("main.tf", "new")
+	write("examples/basic/main.tf", "example")
+	write(".terraform/modules.json", "{}")
+
+	input := &ModuleInput{
+		ModulePath: "my-module",
+		Files: map[string]string{
+			"my-module/main.tf":    "old",
+			"my-module/outputs.tf": "outputs",
+			"my-module/README.md":  "readme",
+		},
+	}
+
+	updated := applyFileChanges(input, modulePath+"/", []string{
+		filepath.Join(modulePath, "main.tf"),
+		filepath.Join(modulePath, "outputs.tf"),
+		filepath.Join(modulePath, "README.md"),
+		filepath.Join(modulePath, "examples"),
+		filepath.Join(modulePath, ".terraform", "modules.json"),
+		filepath.Join(root, "elsewhere.tf"),
+	}, settings)
+
+	// README.md and outputs.tf no longer exist on disk so they are removed,
+	// while excluded directories and paths outside the module are ignored
+	expectedUpdated := []string{
+		"my-module/README.md",
+		"my-module/examples",
+		"my-module/examples/basic/main.tf",
+		"my-module/main.tf",
+		"my-module/outputs.tf",
+	}
+	if !reflect.DeepEqual(updated, expectedUpdated) {
+		t.Errorf("updated = %v, want %v", updated, expectedUpdated)
+	}
+
+	expectedFiles := map[string]string{
+		"my-module/main.tf":                "new",
+		"my-module/examples":               "directory",
+		"my-module/examples/basic/main.tf": "example",
+	}
+	if !reflect.DeepEqual(input.Files, expectedFiles) {
+		t.Errorf("files = %v, want %v", input.Files, expectedFiles)
+	}
+
+	// Re-applying the same changes is a no-op
+	if again := applyFileChanges(input, modulePath, []string{filepath.Join(modulePath, "main.tf")}, settings); len(again) != 0 {
+		t.Errorf("expected no updates for unchanged file, got %v", again)
+	}
+}
+
+func TestApplyFileChangesRemovedDirectory(t *testing.T) {
+	modulePath := filepath.Join(t.TempDir(), "my-module")
+	input := &ModuleInput{
+		ModulePath: "my-module",
+		Files: map[string]string{
+			"my-module/main.tf":                "main",
+			"my-module/examples":               "directory",
+			"my-module/examples/basic/main.tf": "example",
+		},
+	}
+
+	updated := applyFileChanges(input, modulePath, []string{filepath.Join(modulePath, "examples")}, collectorSettings{})
+	if len(updated) != 2 {
+		t.Errorf("expected 2 removed keys, got %v", updated)
+	}
+	if _, ok := input.Files["my-module/main.tf"]; !ok || len(input.Files) != 1 {
+		t.Errorf("expected only main.tf to remain, got %v", input.Files)
+	}
+}
+
+func TestDiffViolations(t *testing.T) {
+	v := func(source, message, details string) ReportedViolation {
+		return ReportedViolation{Source: source, Violation: Violation{Message: message, Details: details}}
+	}
+
+	previous := ValidationResult{Violations: []ReportedViolation{
+		v("hardcoded.rego.main", "Hard-coded values", "main.tf"),
+		v("hardcoded.rego.main", "Hard-coded values", "data.tf"),
+		v("check:variable_references", "Undeclared variable", "var.a"),
+	}}
+	current := ValidationResult{Violations: []ReportedViolation{
+		v("hardcoded.rego.main", "Hard-coded values", "main.tf"),
+		v("check:variable_references", "Undeclared variable", "var.a"),
+		v("check:variable_references", "Undeclared variable", "var.b"),
+	}}
+
+	fixed, introduced := diffViolations(previous, current)
+	if len(fixed) != 1 || fixed[0].Details != "data.tf" {
+		t.Errorf("fixed = %+v, want data.tf", fixed)
+	}
+	if len(introduced) != 1 || introduced[0].Details != "var.b" {
+		t.Errorf("introduced = %+v, want var.b", introduced)
+	}
+
+	fixed, introduced = diffViolations(current, current)
+	if len(fixed) != 0 || len(introduced) != 0 {
+		t.Errorf("expected no differences, got fixed=%v introduced=%v", fixed, introduced)
+	}
+}
+
+func TestIsModulePath(t *testing.T) {
+	tests := []struct {
+		path     string
+		expected bool
+	}{
+		{"modules/foo/main.tf", true},
+		{"modules/foo/examples/basic", true},
+		{"modules/foo", false},
+		{"modules/foobar/main.tf", false},
+		{"policies/opa/terraform/module/naming.rego", false},
+	}
+
+	for _, tt := range tests {
+		if got := isModulePath("modules/foo/", tt.path); got != tt.expected {
+			t.Errorf("isModulePath(%q) = %v, want %v", tt.path, got, tt.expected)
+		}
+	}
+}
+
+func TestCollectorSettingsFromConfig(t *testing.T) {
+	config := map[string]interface{}{
+		"scripts": map[string]interface{}{
+			"excluded_dirs":    []interface{}{".terraform", ".git"},
+			"important_dirs":   []interface{}{"examples"},
+			"directory_marker": "DIR",
+		},
+	}
+
+	settings := collectorSettingsFromConfig(config)
+	if !settings.isExcluded(".git") || settings.isExcluded("examples") {
+		t.Errorf("unexpected excluded dirs: %v", settings.ExcludedDirs)
+	}
+	if !settings.isImportant("examples") {
+		t.Errorf("unexpected important dirs: %v", settings.ImportantDirs)
+	}
+	if settings.DirectoryMarker != "DIR" {
+		t.Errorf("expected directory marker DIR, got %s", settings.DirectoryMarker)
+	}
+
+	if defaults := collectorSettingsFromConfig(map[string]interface{}{}); defaults.DirectoryMarker != "directory" {
+		t.Errorf("expected default directory marker, got %s", defaults.DirectoryMarker)
+	}
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/open-policy-agent/opa/ast"
+	"github.com/open-policy-agent/opa/rego"
+
+	"github.com/terraform-modules/scripts/module-validator/checks"
+	"github.com/terraform-modules/scripts/module-validator/policies"
+)
+
+// RuleViolations are the violations reported by one rule of a policy file
+type RuleViolations struct {
+	PolicyFile string
+	Package    string
+	Rule       string
+	Violations []checks.Violation
+	Err        error
+}
+
+// Name returns the <file>.<rule> name used in output, matching module-validator
+func (r RuleViolations) Name() string {
+	rule := r.Rule
+	if rule == "violation" {
+		rule = "main"
+	}
+	return filepath.Base(r.PolicyFile) + "." + rule
+}
+
+// preparedPolicy is a parsed policy file with a prepared query per violation rule
+type preparedPolicy struct {
+	modTime time.Time
+	pkg     string
+	rules   []string
+	queries map[string]rego.PreparedEvalQuery
+	err     error
+}
+
+// policyEngine evaluates Rego policy files in-process. Each file is compiled
+// on its own, like `opa eval --data <file>` in module-validator, and cached
+// until it changes on disk.
+type policyEngine struct {
+	mu    sync.Mutex
+	cache map[string]*preparedPolicy
+}
+
+// newPolicyEngine creates an engine with an empty cache
+func newPolicyEngine() *policyEngine {
+	return &policyEngine{cache: make(map[string]*preparedPolicy)}
+}
+
+// policyFiles returns the .rego files directly inside the given directories
+func policyFiles(rootDir string, policyDirs []string) []string {
+	var files []string
+	for _, dir := range policyDirs {
+		matches, err := filepath.Glob(filepath.Join(rootDir, dir, "*.rego"))
+		if err != nil {
+			continue
+		}
+		files = append(files, matches...)
+	}
+	return files
+}
+
+// Evaluate runs every violation rule of the given policy files against the input
+func (e *policyEngine) Evaluate(ctx context.Context, files []string, input interface{}) []RuleViolations {
+	var results []RuleViolations
+	for _, file := range files {
+		policy := e.prepare(ctx, file)
+		if policy.err != nil {
+			results = append(results, RuleViolations{PolicyFile: file, Package: policy.pkg, Err: policy.err})
+			continue
+		}
+		for _, rule := range policy.rules {
+			result := RuleViolations{PolicyFile: file, Package: policy.pkg, Rule: rule}
+			rs, err := policy.queries[rule].Eval(ctx, rego.EvalInput(input))
+			if err != nil {
+				result.Err = fmt.Errorf("failed to evaluate %s.%s: %w", policy.pkg, rule, err)
+			} else if len(rs) > 0 && len(rs[0].Expressions) > 0 {
+				result.Violations = policies.ViolationsFromValue(rs[0].Expressions[0].Value)
+			}
+			results = append(results, result)
+		}
+	}
+	return results
+}
+
+// prepare returns the cached policy for a file, recompiling it if it changed
+func (e *policyEngine) prepare(ctx context.Context, file string) *preparedPolicy {
+	info, statErr := os.Stat(file)
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if cached, ok := e.cache[file]; ok && statErr == nil && cached.modTime.Equal(info.ModTime()) {
+		return cached
+	}
+
+	policy := &preparedPolicy{queries: make(map[string]rego.PreparedEvalQuery)}
+	if statErr == nil {
+		policy.modTime = info.ModTime()
+	}
+	e.cache[file] = policy
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		policy.err = fmt.Errorf("failed to read policy %s: %w", file, err)
+		return policy
+	}
+
+	module, err := ast.ParseModuleWithOpts(file, string(data), ast.ParserOptions{RegoVersion: policies.RegoVersion})
+	if err != nil {
+		policy.err = fmt.Errorf("failed to parse policy %s: %w", file, err)
+		return policy
+	}
+	policy.pkg = strings.TrimPrefix(module.Package.Path.String(), "data.")
+
+	seen := make(map[string]bool)
+	for _, rule := range module.Rules {
+		name := policies.RuleName(rule)
+		if !policies.IsViolationRule(name) || seen[name] {
+			continue
+		}
+		seen[name] = true
+
+		query, err := rego.New(
+			rego.Query("data."+policy.pkg+"."+name),
+			rego.ParsedModule(module),
+			rego.SetRegoVersion(policies.RegoVersion),
+		).PrepareForEval(ctx)
+		if err != nil {
+			policy.err = fmt.Errorf("failed to compile policy %s: %w", file, err)
+			return policy
+		}
+		policy.rules = append(policy.rules, name)
+		policy.queries[name] = query
+	}
+
+	return policy
+}
@@ -0,0 +1,696 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf16"
+
+	"github.com/terraform-modules/scripts/module-validator/checks"
+	"github.com/terraform-modules/scripts/module-validator/policies"
+)
+
+// JSON-RPC error codes used by the server
+const (
+	rpcMethodNotFound = -32601
+	rpcInvalidParams  = -32602
+	rpcInternalError  = -32603
+)
+
+// LSP diagnostic severities
+const (
+	severityError       = 1
+	severityWarning     = 2
+	severityInformation = 3
+)
+
+// diagnosticSource is shown by editors next to each diagnostic
+const diagnosticSource = "monorepo"
+
+// runLSP starts the language server on stdin and stdout
+func runLSP(args []string) error {
+	flags := flag.NewFlagSet("lsp", flag.ContinueOnError)
+	configPath := flags.String("config", "monorepo-config.json", "Path to the monorepo configuration file, relative to the workspace root")
+	rootDir := flags.String("root", "", "Repository root (defaults to the workspace root sent by the editor, then the current directory)")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	server := newLSPServer(os.Stdin, os.Stdout, *rootDir, *configPath)
+	return server.Serve()
+}
+
+//
+// ---------- Protocol Types ----------
+//
+
+// rpcMessage is an incoming JSON-RPC request or notification
+type rpcMessage struct {
+	ID     json.RawMessage `json:"id,omitempty"`
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params,omitempty"`
+}
+
+// rpcResponse is an outgoing JSON-RPC response
+type rpcResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+// rpcNotification is an outgoing JSON-RPC notification
+type rpcNotification struct {
+	JSONRPC string      `json:"jsonrpc"`
+	Method  string      `json:"method"`
+	Params  interface{} `json:"params"`
+}
+
+// rpcError is a JSON-RPC error object
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// Position is a zero-based line and UTF-16 character offset
+type Position struct {
+	Line      int `json:"line"`
+	Character int `json:"character"`
+}
+
+// Range is a span in a text document
+type Range struct {
+	Start Position `json:"start"`
+	End   Position `json:"end"`
+}
+
+// Diagnostic is a policy violation shown in the editor
+type Diagnostic struct {
+	Range    Range           `json:"range"`
+	Severity int             `json:"severity"`
+	Code     string          `json:"code,omitempty"`
+	Source   string          `json:"source"`
+	Message  string          `json:"message"`
+	Data     *diagnosticData `json:"data,omitempty"`
+}
+
+// diagnosticData is carried on diagnostics so code actions can offer the resolution
+type diagnosticData struct {
+	Resolution string `json:"resolution,omitempty"`
+}
+
+// CodeAction is offered for diagnostics that have a resolution
+type CodeAction struct {
+	Title       string       `json:"title"`
+	Kind        string       `json:"kind"`
+	Diagnostics []Diagnostic `json:"diagnostics"`
+}
+
+// publishDiagnosticsParams is sent with textDocument/publishDiagnostics
+type publishDiagnosticsParams struct {
+	URI         string       `json:"uri"`
+	Diagnostics []Diagnostic `json:"diagnostics"`
+}
+
+//
+// ---------- Server ----------
+//
+
+// lspServer publishes policy diagnostics for files inside known modules
+type lspServer struct {
+	reader  *bufio.Reader
+	writer  io.Writer
+	writeMu sync.Mutex
+
+	rootDir    string
+	configPath string
+	config     map[string]interface{}
+	settings   collectorSettings
+	engine     *policyEngine
+
+	documents map[string]string          // Open buffers keyed by repository-relative path
+	published map[string]map[string]bool // URIs with diagnostics, keyed by module path
+	shutdown  bool
+}
+
+// newLSPServer creates a server reading requests from r and writing to w
+func newLSPServer(r io.Reader, w io.Writer, rootDir, configPath string) *lspServer {
+	return &lspServer{
+		reader:     bufio.NewReader(r),
+		writer:     w,
+		rootDir:    rootDir,
+		configPath: configPath,
+		engine:     newPolicyEngine(),
+		documents:  make(map[string]string),
+		published:  make(map[string]map[string]bool),
+	}
+}
+
+// Serve processes messages until the client sends exit or closes the stream
+func (s *lspServer) Serve() error {
+	for {
+		msg, err := s.readMessage()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if msg.Method == "exit" {
+			if !s.shutdown {
+				return fmt.Errorf("exit received before shutdown")
+			}
+			return nil
+		}
+
+		result, rpcErr := s.handle(msg)
+		if len(msg.ID) == 0 {
+			// Notifications have no response
+			if rpcErr != nil {
+				s.logf("%s: %s", msg.Method, rpcErr.Message)
+			}
+			continue
+		}
+		if err := s.respond(msg.ID, result, rpcErr); err != nil {
+			return err
+		}
+	}
+}
+
+// handle dispatches a single message and returns the response result
+func (s *lspServer) handle(msg *rpcMessage) (interface{}, *rpcError) {
+	switch msg.Method {
+	case "initialize":
+		return s.initialize(msg.Params)
+	case "initialized":
+		return nil, nil
+	case "shutdown":
+		s.shutdown = true
+		return nil, nil
+	case "textDocument/didOpen":
+		var params struct {
+			TextDocument struct {
+				URI  string `json:"uri"`
+				Text string `json:"text"`
+			} `json:"textDocument"`
+		}
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, &rpcError{Code: rpcInvalidParams, Message: err.Error()}
+		}
+		s.updateDocument(params.TextDocument.URI, &params.TextDocument.Text)
+		return nil, nil
+	case "textDocument/didChange":
+		var params struct {
+			TextDocument struct {
+				URI string `json:"uri"`
+			} `json:"textDocument"`
+			ContentChanges []struct {
+				Text string `json:"text"`
+			} `json:"contentChanges"`
+		}
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, &rpcError{Code: rpcInvalidParams, Message: err.Error()}
+		}
+		if len(params.ContentChanges) == 0 {
+			return nil, nil
+		}
+		// Full document sync: the last change holds the whole buffer
+		text := params.ContentChanges[len(params.ContentChanges)-1].Text
+		s.updateDocument(params.TextDocument.URI, &text)
+		return nil, nil
+	case "textDocument/didSave":
+		var params struct {
+			TextDocument struct {
+				URI string `json:"uri"`
+			} `json:"textDocument"`
+		}
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, &rpcError{Code: rpcInvalidParams, Message: err.Error()}
+		}
+		if strings.HasSuffix(params.TextDocument.URI, ".rego") {
+			// Policies are recompiled when they change on disk
+			s.revalidateOpenModules()
+		}
+		return nil, nil
+	case "textDocument/didClose":
+		var params struct {
+			TextDocument struct {
+				URI string `json:"uri"`
+			} `json:"textDocument"`
+		}
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, &rpcError{Code: rpcInvalidParams, Message: err.Error()}
+		}
+		s.updateDocument(params.TextDocument.URI, nil)
+		return nil, nil
+	case "textDocument/codeAction":
+		var params struct {
+			Context struct {
+				Diagnostics []Diagnostic `json:"diagnostics"`
+			} `json:"context"`
+		}
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, &rpcError{Code: rpcInvalidParams, Message: err.Error()}
+		}
+		return codeActions(params.Context.Diagnostics), nil
+	}
+
+	if len(msg.ID) > 0 {
+		return nil, &rpcError{Code: rpcMethodNotFound, Message: "method not supported: " + msg.Method}
+	}
+	return nil, nil
+}
+
+// initialize resolves the repository root and loads the configuration
+func (s *lspServer) initialize(raw json.RawMessage) (interface{}, *rpcError) {
+	var params struct {
+		RootURI  string `json:"rootUri"`
+		RootPath string `json:"rootPath"`
+	}
+	if len(raw) > 0 {
+		if err := json.Unmarshal(raw, &params); err != nil {
+			return nil, &rpcError{Code: rpcInvalidParams, Message: err.Error()}
+		}
+	}
+
+	if s.rootDir == "" {
+		if path, ok := uriToPath(params.RootURI); ok {
+			s.rootDir = path
+		} else if params.RootPath != "" {
+			s.rootDir = params.RootPath
+		} else if cwd, err := os.Getwd(); err == nil {
+			s.rootDir = cwd
+		}
+	}
+
+	configPath := s.configPath
+	if !filepath.IsAbs(configPath) {
+		configPath = filepath.Join(s.rootDir, configPath)
+	}
+	config, err := loadConfig(configPath)
+	if err != nil {
+		return nil, &rpcError{Code: rpcInternalError, Message: err.Error()}
+	}
+	s.config = config
+	s.settings = collectorSettingsFromConfig(config)
+	s.logf("Using repository root %s and config %s", s.rootDir, configPath)
+
+	return map[string]interface{}{
+		"capabilities": map[string]interface{}{
+			"textDocumentSync": map[string]interface{}{
+				"openClose": true,
+				"change":    1, // Full
+				"save":      map[string]interface{}{"includeText": false},
+			},
+			"codeActionProvider": map[string]interface{}{
+				"codeActionKinds": []string{"quickfix"},
+			},
+		},
+		"serverInfo": map[string]interface{}{
+			"name": "monorepo",
+		},
+	}, nil
+}
+
+// updateDocument records an open buffer (or drops it when text is nil) and
+// re-validates the module containing it
+func (s *lspServer) updateDocument(uri string, text *string) {
+	relPath, ok := s.relPath(uri)
+	if !ok {
+		return
+	}
+	if text != nil {
+		s.documents[relPath] = *text
+	} else {
+		delete(s.documents, relPath)
+	}
+
+	module, ok := detectModule(relPath, s.config)
+	if !ok {
+		return
+	}
+	s.validateModule(module, relPath)
+}
+
+// revalidateOpenModules re-runs validation for every module with an open buffer
+func (s *lspServer) revalidateOpenModules() {
+	modules := make(map[string]string)
+	var paths []string
+	for relPath := range s.documents {
+		if module, ok := detectModule(relPath, s.config); ok {
+			if _, seen := modules[module.Path]; !seen {
+				modules[module.Path] = relPath
+				paths = append(paths, relPath)
+			}
+		}
+	}
+	sort.Strings(paths)
+	for _, relPath := range paths {
+		module, _ := detectModule(relPath, s.config)
+		s.validateModule(module, relPath)
+	}
+}
+
+// validateModule evaluates the module's policies and publishes diagnostics
+// for every file in the module that has violations
+func (s *lspServer) validateModule(module Module, triggerPath string) {
+	input, err := collectModuleInput(s.rootDir, module, s.settings, s.documents)
+	if err != nil {
+		s.logf("%v", err)
+		return
+	}
+
+	// module_types is present, or no module would have been detected
+	dirs, _ := policies.ModuleDirs(s.config, module.Type)
+	var policyDirs []string
+	for _, dir := range dirs {
+		policyDirs = append(policyDirs, dir.Path)
+	}
+	files := policyFiles(s.rootDir, policyDirs)
+	results := s.engine.Evaluate(context.Background(), files, input)
+
+	triggerKey := module.Name() + "/"
+	if rel, ok := moduleRelPath(module, triggerPath); ok {
+		triggerKey += rel
+	}
+
+	byURI := make(map[string][]Diagnostic)
+	addDiagnostic := func(key string, diagnostic Diagnostic) {
+		rel := strings.TrimPrefix(key, module.Name()+"/")
+		uri := pathToURI(filepath.Join(s.rootDir, filepath.FromSlash(module.Path), filepath.FromSlash(rel)))
+		byURI[uri] = append(byURI[uri], diagnostic)
+	}
+	for _, result := range results {
+		if result.Err != nil {
+			s.logf("%v", result.Err)
+			continue
+		}
+		for _, v := range result.Violations {
+			key, rng := locateViolation(v, input, triggerKey)
+			addDiagnostic(key, newDiagnostic(v, result, rng))
+		}
+	}
+
+	// Go checks run through the same registry as module-validator and are
+	// reported under the same check:<id> name
+	for _, id := range policies.ModuleChecks(s.config, module.Type) {
+		check, ok := checks.Lookup(id)
+		if !ok {
+			s.logf("unknown check %q configured for module type %s", id, module.Type)
+			continue
+		}
+		if !check.Applies(module.Type) {
+			continue
+		}
+		for _, v := range checks.Run(context.Background(), check, input) {
+			v.Policy = "check:" + id
+			key, rng := locateCheckViolation(v, input, triggerKey)
+			addDiagnostic(key, newDiagnostic(v, RuleViolations{}, rng))
+		}
+	}
+
+	// Clear diagnostics for files that no longer have violations
+	previous := s.published[module.Path]
+	current := make(map[string]bool)
+	for uri := range byURI {
+		current[uri] = true
+	}
+	triggerURI := pathToURI(filepath.Join(s.rootDir, filepath.FromSlash(triggerPath)))
+	uris := []string{triggerURI}
+	for uri := range previous {
+		uris = append(uris, uri)
+	}
+	for uri := range current {
+		uris = append(uris, uri)
+	}
+	sort.Strings(uris)
+
+	sent := make(map[string]bool)
+	for _, uri := range uris {
+		if sent[uri] {
+			continue
+		}
+		sent[uri] = true
+		diagnostics := byURI[uri]
+		if diagnostics == nil {
+			diagnostics = []Diagnostic{}
+		}
+		s.notify("textDocument/publishDiagnostics", publishDiagnosticsParams{URI: uri, Diagnostics: diagnostics})
+	}
+	s.published[module.Path] = current
+}
+
+// newDiagnostic converts a violation into an LSP diagnostic
+func newDiagnostic(v checks.Violation, result RuleViolations, rng Range) Diagnostic {
+	severity := severityError
+	switch strings.ToLower(v.Severity) {
+	case "warning", "warn":
+		severity = severityWarning
+	case "info":
+		severity = severityInformation
+	}
+
+	code := v.Policy
+	if code == "" {
+		code = result.Name()
+	}
+
+	message := v.Message
+	if v.Details != "" {
+		message += "\n" + v.Details
+	}
+
+	diagnostic := Diagnostic{
+		Range:    rng,
+		Severity: severity,
+		Code:     code,
+		Source:   diagnosticSource,
+		Message:  message,
+	}
+	if v.Resolution != "" {
+		diagnostic.Data = &diagnosticData{Resolution: v.Resolution}
+	}
+	return diagnostic
+}
+
+// codeActions offers each diagnostic's resolution as a quick-fix hint
+func codeActions(diagnostics []Diagnostic) []CodeAction {
+	actions := []CodeAction{}
+	for _, d := range diagnostics {
+		if d.Source != diagnosticSource || d.Data == nil || d.Data.Resolution == "" {
+			continue
+		}
+		actions = append(actions, CodeAction{
+			Title:       "Resolution: " + d.Data.Resolution,
+			Kind:        "quickfix",
+			Diagnostics: []Diagnostic{d},
+		})
+	}
+	return actions
+}
+
+//
+// ---------- Violation Location ----------
+//
+
+var (
+	quotedTokenPattern   = regexp.MustCompile("'([^']+)'|\"([^\"]+)\"|`([^`]+)`")
+	variableTokenPattern = regexp.MustCompile(`\bvar\.[A-Za-z_][A-Za-z0-9_-]*`)
+)
+
+// locateViolation picks the module file a violation refers to and the range
+// to highlight. Policies name files in their details; quoted names and
+// var.<name> references are then searched for in that file. Violations that
+// name no file are attached to the fallback file, and violations without a
+// searchable token highlight the first line.
+func locateViolation(v checks.Violation, input *checks.ModuleInput, fallback string) (string, Range) {
+	text := v.Message + "\n" + v.Details
+
+	key := fallback
+	longest := 0
+	for path, content := range input.Files {
+		if content == "" || len(path) <= longest || !strings.Contains(text, path) {
+			continue
+		}
+		key = path
+		longest = len(path)
+	}
+
+	content := input.Files[key]
+	lines := strings.Split(content, "\n")
+
+	var tokens []string
+	for _, match := range quotedTokenPattern.FindAllStringSubmatch(text, -1) {
+		for _, group := range match[1:] {
+			if group != "" && group != key && !strings.HasSuffix(key, "/"+group) {
+				tokens = append(tokens, group)
+			}
+		}
+	}
+	tokens = append(tokens, variableTokenPattern.FindAllString(text, -1)...)
+
+	for _, token := range tokens {
+		for lineNo, line := range lines {
+			col := strings.Index(line, token)
+			if col < 0 {
+				continue
+			}
+			start := utf16Len(line[:col])
+			return key, Range{
+				Start: Position{Line: lineNo, Character: start},
+				End:   Position{Line: lineNo, Character: start + utf16Len(token)},
+			}
+		}
+	}
+
+	return key, Range{
+		Start: Position{Line: 0, Character: 0},
+		End:   Position{Line: 0, Character: utf16Len(lines[0])},
+	}
+}
+
+// locateCheckViolation highlights the line a Go check reported, from its
+// first non-blank character to the end. Violations without a location in a
+// known file are located like policy violations.
+func locateCheckViolation(v checks.Violation, input *checks.ModuleInput, fallback string) (string, Range) {
+	content, ok := input.Files[v.File]
+	if !ok || v.Line < 1 {
+		return locateViolation(v, input, fallback)
+	}
+
+	lines := strings.Split(content, "\n")
+	if v.Line > len(lines) {
+		return locateViolation(v, input, fallback)
+	}
+	line := strings.TrimRight(lines[v.Line-1], "\r")
+	indent := len(line) - len(strings.TrimLeft(line, " \t"))
+	return v.File, Range{
+		Start: Position{Line: v.Line - 1, Character: utf16Len(line[:indent])},
+		End:   Position{Line: v.Line - 1, Character: utf16Len(line)},
+	}
+}
+
+// utf16Len returns the length of a string in UTF-16 code units, which is how
+// LSP measures character offsets
+func utf16Len(s string) int {
+	return len(utf16.Encode([]rune(s)))
+}
+
+//
+// ---------- Transport ----------
+//
+
+// readMessage reads a Content-Length framed JSON-RPC message
+func (s *lspServer) readMessage() (*rpcMessage, error) {
+	length := -1
+	for {
+		line, err := s.reader.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" {
+			break
+		}
+		if name, value, ok := strings.Cut(line, ":"); ok && strings.EqualFold(strings.TrimSpace(name), "Content-Length") {
+			length, err = strconv.Atoi(strings.TrimSpace(value))
+			if err != nil {
+				return nil, fmt.Errorf("invalid Content-Length: %w", err)
+			}
+		}
+	}
+	if length < 0 {
+		return nil, fmt.Errorf("missing Content-Length header")
+	}
+
+	body := make([]byte, length)
+	if _, err := io.ReadFull(s.reader, body); err != nil {
+		return nil, err
+	}
+
+	var msg rpcMessage
+	if err := json.Unmarshal(body, &msg); err != nil {
+		return nil, fmt.Errorf("invalid message: %w", err)
+	}
+	return &msg, nil
+}
+
+// respond sends the response to a request
+func (s *lspServer) respond(id json.RawMessage, result interface{}, rpcErr *rpcError) error {
+	response := rpcResponse{JSONRPC: "2.0", ID: id, Error: rpcErr}
+	if rpcErr == nil {
+		data, err := json.Marshal(result)
+		if err != nil {
+			return err
+		}
+		response.Result = data
+	}
+	return s.write(response)
+}
+
+// notify sends a notification to the client
+func (s *lspServer) notify(method string, params interface{}) {
+	if err := s.write(rpcNotification{JSONRPC: "2.0", Method: method, Params: params}); err != nil {
+		s.logf("failed to send %s: %v", method, err)
+	}
+}
+
+// write sends a Content-Length framed message
+func (s *lspServer) write(msg interface{}) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	if _, err := fmt.Fprintf(s.writer, "Content-Length: %d\r\n\r\n", len(data)); err != nil {
+		return err
+	}
+	_, err = s.writer.Write(data)
+	return err
+}
+
+// logf writes a log line to stderr, which editors show in the server log
+func (s *lspServer) logf(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, "monorepo lsp: %s\n", fmt.Sprintf(format, args...))
+}
+
+// relPath converts a document URI into a repository-relative path
+func (s *lspServer) relPath(uri string) (string, bool) {
+	path, ok := uriToPath(uri)
+	if !ok {
+		return "", false
+	}
+	rel, err := filepath.Rel(s.rootDir, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	return filepath.ToSlash(rel), true
+}
+
+// uriToPath converts a file:// URI into a filesystem path
+func uriToPath(uri string) (string, bool) {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" || u.Path == "" {
+		return "", false
+	}
+	return filepath.FromSlash(u.Path), true
+}
+
+// pathToURI converts a filesystem path into a file:// URI
+func pathToURI(path string) string {
+	return (&url.URL{Scheme: "file", Path: filepath.ToSlash(path)}).String()
+}
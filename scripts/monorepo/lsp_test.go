@@ -0,0 +1,399 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/terraform-modules/scripts/module-validator/checks"
+)
+
+const testPolicy = `package terraform.module.hardcoded
+
+import future.keywords.contains
+import future.keywords.if
+import future.keywords.in
+
+violation contains result if {
+	some file, content in input.files
+	endswith(file, ".tf")
+	some name in ["force_destroy"]
+	contains(content, sprintf("%s = true", [name]))
+	result := {
+		"policy": "terraform_module_hardcoded_values_policy",
+		"severity": "error",
+		"message": "Terraform file contains hard-coded values",
+		"details": sprintf("File '%s' hard-codes '%s'", [file, name]),
+		"resolution": "Replace hard-coded values with variables",
+	}
+}
+
+readme_violation contains "Module has no README" if {
+	not input.files[sprintf("%s/README.md", [input.module_path])]
+}
+`
+
+// lspClient drives an in-process server over pipes
+type lspClient struct {
+	t      *testing.T
+	writer io.WriteCloser
+	reader *bufio.Reader
+	done   chan error
+	nextID int
+}
+
+func newLSPClient(t *testing.T, rootDir string) *lspClient {
+	clientReader, serverWriter := io.Pipe()
+	serverReader, clientWriter := io.Pipe()
+
+	server := newLSPServer(serverReader, serverWriter, "", "monorepo-config.json")
+	done := make(chan error, 1)
+	go func() {
+		done <- server.Serve()
+		serverWriter.Close()
+	}()
+
+	c := &lspClient{t: t, writer: clientWriter, reader: bufio.NewReader(clientReader), done: done}
+	c.request("initialize", map[string]interface{}{"rootUri": pathToURI(rootDir)})
+	c.notify("initialized", map[string]interface{}{})
+	return c
+}
+
+func (c *lspClient) send(msg map[string]interface{}) {
+	msg["jsonrpc"] = "2.0"
+	data, err := json.Marshal(msg)
+	if err != nil {
+		c.t.Fatalf("failed to marshal message: %v", err)
+	}
+	if _, err := fmt.Fprintf(c.writer, "Content-Length: %d\r\n\r\n%s", len(data), data); err != nil {
+		c.t.Fatalf("failed to send message: %v", err)
+	}
+}
+
+func (c *lspClient) notify(method string, params interface{}) {
+	c.send(map[string]interface{}{"method": method, "params": params})
+}
+
+// request sends a request and returns its response, skipping notifications
+func (c *lspClient) request(method string, params interface{}) map[string]interface{} {
+	c.nextID++
+	id := c.nextID
+	c.send(map[string]interface{}{"id": id, "method": method, "params": params})
+	for {
+		msg := c.read()
+		if msg["id"] == float64(id) {
+			return msg
+		}
+	}
+}
+
+// read returns the next message from the server
+func (c *lspClient) read() map[string]interface{} {
+	type result struct {
+		msg map[string]interface{}
+		err error
+	}
+	ch := make(chan result, 1)
+	go func() {
+		length := 0
+		for {
+			line, err := c.reader.ReadString('\n')
+			if err != nil {
+				ch <- result{err: err}
+				return
+			}
+			line = strings.TrimSpace(line)
+			if line == "" {
+				break
+			}
+			if strings.HasPrefix(line, "Content-Length:") {
+				length, _ = strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "Content-Length:")))
+			}
+		}
+		body := make([]byte, length)
+		if _, err := io.ReadFull(c.reader, body); err != nil {
+			ch <- result{err: err}
+			return
+		}
+		var msg map[string]interface{}
+		err := json.Unmarshal(body, &msg)
+		ch <- result{msg: msg, err: err}
+	}()
+
+	select {
+	case r := <-ch:
+		if r.err != nil {
+			c.t.Fatalf("failed to read message: %v", r.err)
+		}
+		return r.msg
+	case <-time.After(10 * time.Second):
+		c.t.Fatalf("timed out waiting for server message")
+		return nil
+	}
+}
+
+// diagnostics reads notifications until diagnostics for the URI are published
+func (c *lspClient) diagnostics(uri string) []Diagnostic {
+	for {
+		msg := c.read()
+		if msg["method"] != "textDocument/publishDiagnostics" {
+			continue
+		}
+		data, _ := json.Marshal(msg["params"])
+		var params publishDiagnosticsParams
+		if err := json.Unmarshal(data, &params); err != nil {
+			c.t.Fatalf("invalid diagnostics: %v", err)
+		}
+		if params.URI == uri {
+			return params.Diagnostics
+		}
+	}
+}
+
+func (c *lspClient) close() {
+	c.request("shutdown", nil)
+	c.notify("exit", nil)
+	if err := <-c.done; err != nil {
+		c.t.Errorf("server exited with error: %v", err)
+	}
+}
+
+func writeTestRepo(t *testing.T) string {
+	root := t.TempDir()
+	files := map[string]string{
+		"monorepo-config.json": `{
+			"module_types": {
+				"skeleton": {
+					"path_patterns": ["skeletons/*"],
+					"policy_dir": "policies/skeleton",
+					"checks": ["variable_references"]
+				}
+			}
+		}`,
+		"policies/skeleton/hardcoded.rego": testPolicy,
+		"skeletons/my-module/main.tf":      "resource \"aws_s3_bucket\" \"this\" {\n  bucket = var.name\n}\n",
+		"skeletons/my-module/variables.tf": "variable \"name\" {}\n",
+	}
+	for rel, content := range files {
+		path := filepath.Join(root, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("failed to create dir: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write %s: %v", rel, err)
+		}
+	}
+	return root
+}
+
+func TestLSPPublishesDiagnostics(t *testing.T) {
+	root := writeTestRepo(t)
+	client := newLSPClient(t, root)
+	defer client.close()
+
+	uri := pathToURI(filepath.Join(root, "skeletons", "my-module", "main.tf"))
+	text := "resource \"aws_s3_bucket\" \"this\" {\n  bucket = var.name\n  force_destroy = true\n}\n"
+	client.notify("textDocument/didOpen", map[string]interface{}{
+		"textDocument": map[string]interface{}{"uri": uri, "languageId": "terraform", "version": 1, "text": text},
+	})
+
+	diagnostics := client.diagnostics(uri)
+	if len(diagnostics) != 2 {
+		t.Fatalf("expected 2 diagnostics, got %+v", diagnostics)
+	}
+
+	var hardcoded, readme *Diagnostic
+	for i := range diagnostics {
+		switch diagnostics[i].Code {
+		case "terraform_module_hardcoded_values_policy":
+			hardcoded = &diagnostics[i]
+		case "hardcoded.rego.readme_violation":
+			readme = &diagnostics[i]
+		}
+	}
+	if hardcoded == nil || readme == nil {
+		t.Fatalf("unexpected diagnostic codes: %+v", diagnostics)
+	}
+
+	expectedRange := Range{Start: Position{Line: 2, Character: 2}, End: Position{Line: 2, Character: 15}}
+	if hardcoded.Range != expectedRange {
+		t.Errorf("range = %+v, want %+v", hardcoded.Range, expectedRange)
+	}
+	if hardcoded.Severity != severityError || readme.Severity != severityError {
+		t.Errorf("unexpected severities: %d, %d", hardcoded.Severity, readme.Severity)
+	}
+	if hardcoded.Data == nil || hardcoded.Data.Resolution != "Replace hard-coded values with variables" {
+		t.Errorf("expected resolution in diagnostic data, got %+v", hardcoded.Data)
+	}
+
+	// The resolution is offered as a code action
+	response := client.request("textDocument/codeAction", map[string]interface{}{
+		"textDocument": map[string]interface{}{"uri": uri},
+		"range":        hardcoded.Range,
+		"context":      map[string]interface{}{"diagnostics": []Diagnostic{*hardcoded}},
+	})
+	actions, _ := response["result"].([]interface{})
+	if len(actions) != 1 {
+		t.Fatalf("expected 1 code action, got %v", response)
+	}
+	if title := actions[0].(map[string]interface{})["title"]; title != "Resolution: Replace hard-coded values with variables" {
+		t.Errorf("unexpected code action title: %v", title)
+	}
+
+	// Fixing the buffer clears the hard-coded value diagnostic
+	client.notify("textDocument/didChange", map[string]interface{}{
+		"textDocument":   map[string]interface{}{"uri": uri, "version": 2},
+		"contentChanges": []map[string]interface{}{{"text": "resource \"aws_s3_bucket\" \"this\" {\n  bucket = var.name\n}\n"}},
+	})
+	diagnostics = client.diagnostics(uri)
+	if len(diagnostics) != 1 || diagnostics[0].Code != "hardcoded.rego.readme_violation" {
+		t.Errorf("expected only the README diagnostic after the fix, got %+v", diagnostics)
+	}
+}
+
+func TestLSPRunsChecks(t *testing.T) {
+	root := writeTestRepo(t)
+	client := newLSPClient(t, root)
+	defer client.close()
+
+	uri := pathToURI(filepath.Join(root, "skeletons", "my-module", "main.tf"))
+	text := "resource \"aws_s3_bucket\" \"this\" {\n  bucket = var.name\n}\n"
+	client.notify("textDocument/didOpen", map[string]interface{}{
+		"textDocument": map[string]interface{}{"uri": uri, "languageId": "terraform", "version": 1, "text": text},
+	})
+	for _, diagnostic := range client.diagnostics(uri) {
+		if strings.HasPrefix(diagnostic.Code, "check:") {
+			t.Errorf("unexpected check diagnostic before the change: %+v", diagnostic)
+		}
+	}
+
+	// Referencing an undeclared variable in the buffer is reported by the check
+	text = "resource \"aws_s3_bucket\" \"this\" {\n  bucket = var.name\n  tags   = var.tags\n}\n"
+	client.notify("textDocument/didChange", map[string]interface{}{
+		"textDocument":   map[string]interface{}{"uri": uri, "version": 2},
+		"contentChanges": []map[string]interface{}{{"text": text}},
+	})
+
+	var undeclared *Diagnostic
+	diagnostics := client.diagnostics(uri)
+	for i := range diagnostics {
+		if diagnostics[i].Code == "check:variable_references" {
+			undeclared = &diagnostics[i]
+		}
+	}
+	if undeclared == nil {
+		t.Fatalf("expected a check:variable_references diagnostic, got %+v", diagnostics)
+	}
+
+	expectedRange := Range{Start: Position{Line: 2, Character: 2}, End: Position{Line: 2, Character: 19}}
+	if undeclared.Range != expectedRange {
+		t.Errorf("range = %+v, want %+v", undeclared.Range, expectedRange)
+	}
+	if undeclared.Severity != severityError || !strings.Contains(undeclared.Message, "var.tags on line 3") {
+		t.Errorf("unexpected diagnostic: %+v", undeclared)
+	}
+
+	response := client.request("textDocument/codeAction", map[string]interface{}{
+		"textDocument": map[string]interface{}{"uri": uri},
+		"range":        undeclared.Range,
+		"context":      map[string]interface{}{"diagnostics": []Diagnostic{*undeclared}},
+	})
+	actions, _ := response["result"].([]interface{})
+	if len(actions) != 1 {
+		t.Fatalf("expected 1 code action, got %v", response)
+	}
+	expectedTitle := "Resolution: Declare variable \"tags\" in variables.tf or remove the reference"
+	if title := actions[0].(map[string]interface{})["title"]; title != expectedTitle {
+		t.Errorf("code action title = %v, want %q", title, expectedTitle)
+	}
+}
+
+func TestLSPIgnoresFilesOutsideModules(t *testing.T) {
+	root := writeTestRepo(t)
+	client := newLSPClient(t, root)
+	defer client.close()
+
+	client.notify("textDocument/didOpen", map[string]interface{}{
+		"textDocument": map[string]interface{}{"uri": pathToURI(filepath.Join(root, "README.md")), "text": "force_destroy = true"},
+	})
+
+	// A request round-trip proves no diagnostics were published before it
+	response := client.request("textDocument/codeAction", map[string]interface{}{
+		"context": map[string]interface{}{"diagnostics": []Diagnostic{}},
+	})
+	if _, ok := response["result"].([]interface{}); !ok {
+		t.Errorf("expected empty code action list, got %v", response)
+	}
+}
+
+func TestLSPUnknownRequest(t *testing.T) {
+	root := writeTestRepo(t)
+	client := newLSPClient(t, root)
+	defer client.close()
+
+	response := client.request("textDocument/hover", map[string]interface{}{})
+	rpcErr, ok := response["error"].(map[string]interface{})
+	if !ok || rpcErr["code"] != float64(rpcMethodNotFound) {
+		t.Errorf("expected method not found error, got %v", response)
+	}
+}
+
+func TestLocateViolation(t *testing.T) {
+	input := &checks.ModuleInput{
+		ModulePath: "my-module",
+		Files: map[string]string{
+			"my-module/main.tf":      "locals {\n  a = var.missing\n}\n",
+			"my-module/main.tf.bak":  "",
+			"my-module/variables.tf": "variable \"name\" {}\n",
+			"my-module/examples":     "directory",
+		},
+	}
+
+	tests := []struct {
+		name          string
+		violation     checks.Violation
+		expectedFile  string
+		expectedRange Range
+	}{
+		{
+			name:          "variable reference",
+			violation:     checks.Violation{Details: "File 'my-module/main.tf' references var.missing"},
+			expectedFile:  "my-module/main.tf",
+			expectedRange: Range{Start: Position{Line: 1, Character: 6}, End: Position{Line: 1, Character: 17}},
+		},
+		{
+			name:          "quoted token in other file",
+			violation:     checks.Violation{Details: "Variable 'name' in my-module/variables.tf has no description"},
+			expectedFile:  "my-module/variables.tf",
+			expectedRange: Range{Start: Position{Line: 0, Character: 10}, End: Position{Line: 0, Character: 14}},
+		},
+		{
+			name:          "no file named",
+			violation:     checks.Violation{Message: "Module must contain a README.md"},
+			expectedFile:  "my-module/main.tf",
+			expectedRange: Range{Start: Position{Line: 0, Character: 0}, End: Position{Line: 0, Character: 8}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file, rng := locateViolation(tt.violation, input, "my-module/main.tf")
+			if file != tt.expectedFile || rng != tt.expectedRange {
+				t.Errorf("locateViolation() = %s %+v, want %s %+v", file, rng, tt.expectedFile, tt.expectedRange)
+			}
+		})
+	}
+}
+
+func TestUTF16Len(t *testing.T) {
+	if got := utf16Len("a😀b"); got != 4 {
+		t.Errorf("utf16Len() = %d, want 4", got)
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// Colors for terminal output
+const (
+	ColorReset  = "\033[0m"
+	ColorRed    = "\033[31m"
+	ColorGreen  = "\033[32m"
+	ColorYellow = "\033[33m"
+	ColorBlue   = "\033[34m"
+	ColorCyan   = "\033[36m"
+	ColorGray   = "\033[90m"
+)
+
+// command is a monorepo subcommand
+type command struct {
+	Name        string
+	Description string
+	Run         func(args []string) error
+}
+
+// commands lists the available subcommands in the order they are shown in usage
+var commands = []command{
+	{Name: "lsp", Description: "Run a language server that publishes policy diagnostics over stdio", Run: runLSP},
+	{Name: "pr-validate", Description: "Evaluate the global PR-level policies against a pull request", Run: runPRValidate},
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		printUsage()
+		os.Exit(1)
+	}
+
+	name := os.Args[1]
+	if name == "help" || name == "-h" || name == "--help" {
+		printUsage()
+		return
+	}
+
+	for _, cmd := range commands {
+		if cmd.Name != name {
+			continue
+		}
+		if err := cmd.Run(os.Args[2:]); err != nil {
+			if err != errValidationFailed {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			}
+			os.Exit(1)
+		}
+		return
+	}
+
+	fmt.Fprintf(os.Stderr, "Error: unknown command %q\n\n", name)
+	printUsage()
+	os.Exit(1)
+}
+
+// printUsage prints the available subcommands
+func printUsage() {
+	fmt.Fprintln(os.Stderr, "Usage: monorepo <command> [options]")
+	fmt.Fprintln(os.Stderr, "\nCommands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-14s %s\n", cmd.Name, cmd.Description)
+	}
+	fmt.Fprintln(os.Stderr, "\nRun 'monorepo <command> -h' for command options.")
+}
+
+// loadConfig loads the configuration from a JSON file
+func loadConfig(path string) (map[string]interface{}, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	var config map[string]interface{}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse config file: %w", err)
+	}
+
+	return config, nil
+}
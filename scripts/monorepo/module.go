@@ -0,0 +1,192 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/terraform-modules/scripts/module-validator/checks"
+)
+
+// Module is a Terraform module located through module_types path patterns
+type Module struct {
+	Path string // Path relative to the repository root
+	Type string // Module type from monorepo-config.json
+}
+
+// Name returns the module name used as the root of input file keys
+func (m Module) Name() string {
+	return filepath.Base(m.Path)
+}
+
+// detectModule finds the module containing a repository-relative file path
+// using the module_types path patterns. Types are checked in sorted order so
+// overlapping patterns resolve deterministically.
+func detectModule(relPath string, config map[string]interface{}) (Module, bool) {
+	moduleTypes, ok := config["module_types"].(map[string]interface{})
+	if !ok {
+		return Module{}, false
+	}
+
+	typeNames := make([]string, 0, len(moduleTypes))
+	for typeName := range moduleTypes {
+		typeNames = append(typeNames, typeName)
+	}
+	sort.Strings(typeNames)
+
+	relPath = filepath.ToSlash(relPath)
+	for _, typeName := range typeNames {
+		typeConfig, ok := moduleTypes[typeName].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		pathPatterns, ok := typeConfig["path_patterns"].([]interface{})
+		if !ok {
+			continue
+		}
+		for _, pattern := range pathPatterns {
+			patternStr, ok := pattern.(string)
+			if !ok {
+				continue
+			}
+			if matched, modulePath := matchesPattern(relPath, patternStr); matched {
+				return Module{Path: modulePath, Type: typeName}, true
+			}
+		}
+	}
+
+	return Module{}, false
+}
+
+// matchesPattern checks if a file path matches a pattern and returns the module path
+func matchesPattern(filePath, pattern string) (bool, string) {
+	// Convert glob pattern to path components
+	patternParts := strings.Split(pattern, "/")
+	fileParts := strings.Split(filePath, "/")
+
+	// The file must be inside the module, not the module directory itself
+	if len(fileParts) <= len(patternParts) {
+		return false, ""
+	}
+
+	// Check each pattern component
+	var moduleParts []string
+	for i, part := range patternParts {
+		if part != "*" && fileParts[i] != part {
+			return false, ""
+		}
+		moduleParts = append(moduleParts, fileParts[i])
+	}
+
+	return true, strings.Join(moduleParts, "/")
+}
+
+// collectorSettings mirrors the terraform-file-collector configuration
+type collectorSettings struct {
+	ExcludedDirs    []string
+	ImportantDirs   []string
+	DirectoryMarker string
+}
+
+// collectorSettingsFromConfig reads the collector settings from the scripts section
+func collectorSettingsFromConfig(config map[string]interface{}) collectorSettings {
+	settings := collectorSettings{DirectoryMarker: "directory"}
+
+	scripts, ok := config["scripts"].(map[string]interface{})
+	if !ok {
+		return settings
+	}
+	settings.ExcludedDirs = stringList(scripts["excluded_dirs"])
+	settings.ImportantDirs = stringList(scripts["important_dirs"])
+	if marker, ok := scripts["directory_marker"].(string); ok {
+		settings.DirectoryMarker = marker
+	}
+	return settings
+}
+
+// stringList converts a JSON array into a slice of its string elements
+func stringList(value interface{}) []string {
+	items, _ := value.([]interface{})
+	var result []string
+	for _, item := range items {
+		if s, ok := item.(string); ok {
+			result = append(result, s)
+		}
+	}
+	return result
+}
+
+// contains reports whether a slice contains a string
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// collectModuleInput walks a module the same way terraform-file-collector
+// does and builds the module-scoped input document in memory. Overlay
+// contents, keyed by repository-relative path, replace what is on disk so
+// unsaved editor buffers are evaluated.
+func collectModuleInput(rootDir string, module Module, settings collectorSettings, overlay map[string]string) (*checks.ModuleInput, error) {
+	moduleDir := filepath.Join(rootDir, filepath.FromSlash(module.Path))
+	input := &checks.ModuleInput{
+		ModulePath: module.Name(),
+		RepoPath:   module.Path,
+		Files:      make(map[string]string),
+	}
+
+	err := filepath.Walk(moduleDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		relPath, err := filepath.Rel(moduleDir, path)
+		if err != nil {
+			return err
+		}
+		key := module.Name() + "/" + filepath.ToSlash(relPath)
+
+		if info.IsDir() {
+			if contains(settings.ExcludedDirs, info.Name()) {
+				return filepath.SkipDir
+			}
+			if relPath != "." && contains(settings.ImportantDirs, info.Name()) {
+				input.Files[key] = settings.DirectoryMarker
+			}
+			return nil
+		}
+
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		input.Files[key] = string(content)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to collect module %s: %w", module.Path, err)
+	}
+
+	for path, content := range overlay {
+		if rel, ok := moduleRelPath(module, path); ok {
+			input.Files[module.Name()+"/"+rel] = content
+		}
+	}
+
+	return input, nil
+}
+
+// moduleRelPath returns a repository-relative path relative to the module
+func moduleRelPath(module Module, path string) (string, bool) {
+	path = filepath.ToSlash(path)
+	prefix := module.Path + "/"
+	if !strings.HasPrefix(path, prefix) {
+		return "", false
+	}
+	return strings.TrimPrefix(path, prefix), true
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+var testConfig = map[string]interface{}{
+	"module_types": map[string]interface{}{
+		"primitive": map[string]interface{}{
+			"path_patterns": []interface{}{"providers/*/primitives/*"},
+			"policy_dir":    "policies/primitive",
+		},
+		"skeleton": map[string]interface{}{
+			"path_patterns": []interface{}{"skeletons/*"},
+			"policy_dir":    "policies/skeleton",
+		},
+	},
+	"rego_policy_dirs": map[string]interface{}{
+		"tests/module": "policies/module",
+	},
+	"module_validator_additional_policies": []interface{}{"tests/module", "tests/missing"},
+	"scripts": map[string]interface{}{
+		"excluded_dirs":    []interface{}{".terraform"},
+		"important_dirs":   []interface{}{"examples"},
+		"directory_marker": "directory",
+	},
+}
+
+func TestDetectModule(t *testing.T) {
+	tests := []struct {
+		path     string
+		expected Module
+		found    bool
+	}{
+		{"skeletons/generic-skeleton/main.tf", Module{Path: "skeletons/generic-skeleton", Type: "skeleton"}, true},
+		{"skeletons/generic-skeleton/examples/basic/main.tf", Module{Path: "skeletons/generic-skeleton", Type: "skeleton"}, true},
+		{"providers/aws/primitives/s3-bucket/variables.tf", Module{Path: "providers/aws/primitives/s3-bucket", Type: "primitive"}, true},
+		{"providers/aws/collections/vpc/main.tf", Module{}, false},
+		{"skeletons/generic-skeleton", Module{}, false},
+		{"README.md", Module{}, false},
+	}
+
+	for _, tt := range tests {
+		module, found := detectModule(tt.path, testConfig)
+		if found != tt.found || module != tt.expected {
+			t.Errorf("detectModule(%q) = %+v, %v; want %+v, %v", tt.path, module, found, tt.expected, tt.found)
+		}
+	}
+}
+
+func TestCollectModuleInput(t *testing.T) {
+	root := t.TempDir()
+	module := Module{Path: "skeletons/my-module", Type: "skeleton"}
+	files := map[string]string{
+		"main.tf":                 "on disk",
+		"variables.tf":            "variables",
+		"examples/basic/main.tf":  "example",
+		".terraform/modules.json": "{}",
+	}
+	for rel, content := range files {
+		path := filepath.Join(root, module.Path, rel)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("failed to create dir: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write file: %v", err)
+		}
+	}
+
+	overlay := map[string]string{
+		"skeletons/my-module/main.tf": "unsaved",
+		"skeletons/my-module/new.tf":  "new buffer",
+		"skeletons/other-module/x.tf": "ignored",
+	}
+
+	input, err := collectModuleInput(root, module, collectorSettingsFromConfig(testConfig), overlay)
+	if err != nil {
+		t.Fatalf("collectModuleInput failed: %v", err)
+	}
+
+	if input.ModulePath != "my-module" || input.RepoPath != "skeletons/my-module" {
+		t.Errorf("unexpected module paths: %s, %s", input.ModulePath, input.RepoPath)
+	}
+
+	expected := map[string]string{
+		"my-module/main.tf":                "unsaved",
+		"my-module/new.tf":                 "new buffer",
+		"my-module/variables.tf":           "variables",
+		"my-module/examples":               "directory",
+		"my-module/examples/basic/main.tf": "example",
+	}
+	if !reflect.DeepEqual(input.Files, expected) {
+		t.Errorf("files = %v, want %v", input.Files, expected)
+	}
+}
@@ -0,0 +1,272 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/terraform-modules/scripts/module-validator/policies"
+)
+
+// errValidationFailed is returned when policies report blocking violations.
+// The results have already been printed, so main exits without an error message.
+var errValidationFailed = errors.New("validation failed")
+
+// execCommand is used to run git, replaceable in tests
+var execCommand = exec.Command
+
+// PRInput is the document evaluated by the global PR-level policies
+type PRInput struct {
+	PR           PRMetadata        `json:"pr"`
+	ChangedFiles []ChangedFile     `json:"changed_files"`
+	Files        map[string]string `json:"files"` // Contents of touched files that still exist, keyed by repository path
+}
+
+// PRMetadata describes the pull request being validated
+type PRMetadata struct {
+	Number     int    `json:"number,omitempty"`
+	Title      string `json:"title"`
+	Author     string `json:"author,omitempty"`
+	AuthorType string `json:"author_type,omitempty"` // GitHub user type, e.g. User or Bot
+	BaseRef    string `json:"base_ref,omitempty"`
+	HeadRef    string `json:"head_ref,omitempty"`
+}
+
+// ChangedFile is a file touched by the pull request
+type ChangedFile struct {
+	Path         string `json:"path"`
+	Status       string `json:"status"` // added, modified, removed, renamed or copied
+	PreviousPath string `json:"previous_path,omitempty"`
+}
+
+// runPRValidate evaluates the global PR-level policies
+func runPRValidate(args []string) error {
+	flags := flag.NewFlagSet("pr-validate", flag.ContinueOnError)
+	configPath := flags.String("config", "monorepo-config.json", "Path to the monorepo configuration file")
+	metadataPath := flags.String("pr-metadata", "", "JSON file with PR metadata, either the flat format or a GitHub pull_request event payload")
+	base := flags.String("base", "", "Base ref to diff against when the metadata does not list changed files")
+	head := flags.String("head", "HEAD", "Head ref to diff when the metadata does not list changed files")
+	rootDir := flags.String("root", ".", "Repository root")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	config, err := loadConfig(*configPath)
+	if err != nil {
+		return err
+	}
+
+	policyDirs, err := policies.PRDirs(config)
+	if err != nil {
+		return err
+	}
+
+	var metadata PRMetadata
+	var changed []ChangedFile
+	if *metadataPath != "" {
+		metadata, changed, err = loadPRMetadata(*metadataPath)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Changed files listed in the metadata take precedence over git
+	if changed == nil {
+		if *base == "" {
+			return fmt.Errorf("--base is required when --pr-metadata does not list changed_files")
+		}
+		changed, err = changedFilesFromGit(*rootDir, *base, *head)
+		if err != nil {
+			return err
+		}
+		if metadata.BaseRef == "" {
+			metadata.BaseRef = *base
+		}
+	}
+
+	input, err := buildPRInput(*rootDir, metadata, changed)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%sPR:%s %s\n", ColorCyan, ColorReset, describePR(metadata))
+	fmt.Printf("%sChanged files:%s %d\n", ColorCyan, ColorReset, len(input.ChangedFiles))
+	for _, file := range input.ChangedFiles {
+		fmt.Printf("  %s%-8s%s %s\n", ColorGray, file.Status, ColorReset, file.Path)
+	}
+
+	fmt.Printf("\n%s=== Evaluating global policies for PR ===%s\n", ColorBlue, ColorReset)
+	results := newPolicyEngine().Evaluate(context.Background(), policyFiles(*rootDir, policyDirs), input)
+	if len(results) == 0 {
+		fmt.Printf("%sWARN%s: No policy files found in %s\n", ColorYellow, ColorReset, strings.Join(policyDirs, ", "))
+	}
+
+	if reportResults("PR", results) {
+		return errValidationFailed
+	}
+	return nil
+}
+
+// loadPRMetadata reads PR metadata from a JSON file. The file is either the
+// flat PRMetadata format with an optional changed_files list, or a GitHub
+// pull_request event payload such as $GITHUB_EVENT_PATH. Changed files are
+// nil when the file does not list them.
+func loadPRMetadata(path string) (PRMetadata, []ChangedFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return PRMetadata{}, nil, fmt.Errorf("failed to read PR metadata: %w", err)
+	}
+
+	var event struct {
+		PullRequest *struct {
+			Number int    `json:"number"`
+			Title  string `json:"title"`
+			User   struct {
+				Login string `json:"login"`
+				Type  string `json:"type"`
+			} `json:"user"`
+			Base struct {
+				Ref string `json:"ref"`
+			} `json:"base"`
+			Head struct {
+				Ref string `json:"ref"`
+			} `json:"head"`
+		} `json:"pull_request"`
+	}
+	if err := json.Unmarshal(data, &event); err != nil {
+		return PRMetadata{}, nil, fmt.Errorf("failed to parse PR metadata: %w", err)
+	}
+	if pr := event.PullRequest; pr != nil {
+		return PRMetadata{
+			Number:     pr.Number,
+			Title:      pr.Title,
+			Author:     pr.User.Login,
+			AuthorType: pr.User.Type,
+			BaseRef:    pr.Base.Ref,
+			HeadRef:    pr.Head.Ref,
+		}, nil, nil
+	}
+
+	// An explicit empty changed_files list decodes to an empty slice and
+	// describes an empty PR; a missing list stays nil
+	var flat struct {
+		PRMetadata
+		ChangedFiles []ChangedFile `json:"changed_files"`
+	}
+	if err := json.Unmarshal(data, &flat); err != nil {
+		return PRMetadata{}, nil, fmt.Errorf("failed to parse PR metadata: %w", err)
+	}
+	for i := range flat.ChangedFiles {
+		if flat.ChangedFiles[i].Status == "" {
+			flat.ChangedFiles[i].Status = "modified"
+		}
+	}
+	return flat.PRMetadata, flat.ChangedFiles, nil
+}
+
+// changedFilesFromGit lists the files changed between the merge base of base
+// and head, matching the three-dot diff GitHub shows for a pull request
+func changedFilesFromGit(rootDir, base, head string) ([]ChangedFile, error) {
+	cmd := execCommand("git", "diff", "--name-status", "-M", base+"..."+head)
+	cmd.Dir = rootDir
+	output, err := cmd.Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return nil, fmt.Errorf("git diff failed: %s", strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return nil, fmt.Errorf("git diff failed: %w", err)
+	}
+	return parseNameStatus(string(output)), nil
+}
+
+// parseNameStatus parses `git diff --name-status` output
+func parseNameStatus(output string) []ChangedFile {
+	changed := []ChangedFile{}
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Split(strings.TrimRight(line, "\r"), "\t")
+		if len(fields) < 2 || fields[0] == "" {
+			continue
+		}
+
+		file := ChangedFile{Path: fields[len(fields)-1]}
+		switch fields[0][0] {
+		case 'A':
+			file.Status = "added"
+		case 'D':
+			file.Status = "removed"
+		case 'R':
+			file.Status = "renamed"
+			file.PreviousPath = fields[1]
+		case 'C':
+			file.Status = "copied"
+			file.PreviousPath = fields[1]
+		default:
+			file.Status = "modified"
+		}
+		changed = append(changed, file)
+	}
+	return changed
+}
+
+// buildPRInput assembles the PR document, reading the touched files that
+// still exist from the working tree
+func buildPRInput(rootDir string, metadata PRMetadata, changed []ChangedFile) (*PRInput, error) {
+	sort.Slice(changed, func(i, j int) bool { return changed[i].Path < changed[j].Path })
+
+	input := &PRInput{
+		PR:           metadata,
+		ChangedFiles: changed,
+		Files:        make(map[string]string),
+	}
+
+	for _, file := range changed {
+		if file.Status == "removed" {
+			continue
+		}
+		content, err := os.ReadFile(filepath.Join(rootDir, filepath.FromSlash(file.Path)))
+		if err != nil {
+			if os.IsNotExist(err) {
+				// The working tree may not be at the PR head; skip missing files
+				fmt.Printf("%sWARN%s: Changed file not found in working tree: %s\n", ColorYellow, ColorReset, file.Path)
+				continue
+			}
+			return nil, fmt.Errorf("failed to read changed file %s: %w", file.Path, err)
+		}
+		input.Files[file.Path] = string(content)
+	}
+
+	return input, nil
+}
+
+// describePR returns a one-line description of the PR for output
+func describePR(metadata PRMetadata) string {
+	var parts []string
+	if metadata.Number > 0 {
+		parts = append(parts, fmt.Sprintf("#%d", metadata.Number))
+	}
+	if metadata.Title != "" {
+		parts = append(parts, fmt.Sprintf("%q", metadata.Title))
+	}
+	if metadata.Author != "" {
+		author := metadata.Author
+		if metadata.AuthorType != "" {
+			author += " (" + metadata.AuthorType + ")"
+		}
+		parts = append(parts, "by "+author)
+	}
+	if metadata.BaseRef != "" {
+		parts = append(parts, "into "+metadata.BaseRef)
+	}
+	if len(parts) == 0 {
+		return "(no metadata)"
+	}
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+const testPRPolicy = `package terraform.global.empty_pr
+
+import future.keywords.contains
+import future.keywords.if
+
+violation contains result if {
+	count(input.changed_files) == 0
+	result := {
+		"policy": "empty_pr",
+		"severity": "error",
+		"message": "PR does not change any files",
+	}
+}
+`
+
+func writeTestFile(t *testing.T, root, rel, content string) string {
+	path := filepath.Join(root, filepath.FromSlash(rel))
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("failed to create dir: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write %s: %v", rel, err)
+	}
+	return path
+}
+
+func TestParseNameStatus(t *testing.T) {
+	output := "A\tnew.tf\nM\tmain.tf\nD\told.tf\nR087\ta.tf\tb.tf\nC100\tc.tf\td.tf\n\n"
+	expected := []ChangedFile{
+		{Path: "new.tf", Status: "added"},
+		{Path: "main.tf", Status: "modified"},
+		{Path: "old.tf", Status: "removed"},
+		{Path: "b.tf", Status: "renamed", PreviousPath: "a.tf"},
+		{Path: "d.tf", Status: "copied", PreviousPath: "c.tf"},
+	}
+	if got := parseNameStatus(output); !reflect.DeepEqual(got, expected) {
+		t.Errorf("parseNameStatus() = %+v, want %+v", got, expected)
+	}
+}
+
+func TestLoadPRMetadata(t *testing.T) {
+	root := t.TempDir()
+
+	tests := []struct {
+		name             string
+		content          string
+		expectedMetadata PRMetadata
+		expectedChanged  []ChangedFile
+	}{
+		{
+			name:             "flat format",
+			content:          `{"title": "Add module", "author_type": "Bot", "changed_files": [{"path": "a.tf"}, {"path": "b.tf", "status": "added"}]}`,
+			expectedMetadata: PRMetadata{Title: "Add module", AuthorType: "Bot"},
+			expectedChanged:  []ChangedFile{{Path: "a.tf", Status: "modified"}, {Path: "b.tf", Status: "added"}},
+		},
+		{
+			name:             "explicit empty changed files",
+			content:          `{"title": "Empty", "changed_files": []}`,
+			expectedMetadata: PRMetadata{Title: "Empty"},
+			expectedChanged:  []ChangedFile{},
+		},
+		{
+			name:             "missing changed files",
+			content:          `{"title": "No files"}`,
+			expectedMetadata: PRMetadata{Title: "No files"},
+		},
+		{
+			name: "GitHub event payload",
+			content: `{"action": "opened", "pull_request": {"number": 7, "title": "Fix", "user": {"login": "dependabot[bot]", "type": "Bot"},
+				"base": {"ref": "main"}, "head": {"ref": "deps"}}}`,
+			expectedMetadata: PRMetadata{Number: 7, Title: "Fix", Author: "dependabot[bot]", AuthorType: "Bot", BaseRef: "main", HeadRef: "deps"},
+		},
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeTestFile(t, root, fmt.Sprintf("meta/%d.json", i), tt.content)
+			metadata, changed, err := loadPRMetadata(path)
+			if err != nil {
+				t.Fatalf("loadPRMetadata() error = %v", err)
+			}
+			if metadata != tt.expectedMetadata {
+				t.Errorf("metadata = %+v, want %+v", metadata, tt.expectedMetadata)
+			}
+			if !reflect.DeepEqual(changed, tt.expectedChanged) {
+				t.Errorf("changed files = %#v, want %#v", changed, tt.expectedChanged)
+			}
+		})
+	}
+}
+
+func TestBuildPRInput(t *testing.T) {
+	root := t.TempDir()
+	writeTestFile(t, root, "modules/a/main.tf", "resource {}\n")
+
+	changed := []ChangedFile{
+		{Path: "modules/a/old.tf", Status: "removed"},
+		{Path: "modules/a/main.tf", Status: "modified"},
+		{Path: "modules/a/missing.tf", Status: "added"},
+	}
+	input, err := buildPRInput(root, PRMetadata{Title: "Test"}, changed)
+	if err != nil {
+		t.Fatalf("buildPRInput() error = %v", err)
+	}
+
+	if input.ChangedFiles[0].Path != "modules/a/main.tf" {
+		t.Errorf("expected changed files sorted by path, got %+v", input.ChangedFiles)
+	}
+	expectedFiles := map[string]string{"modules/a/main.tf": "resource {}\n"}
+	if !reflect.DeepEqual(input.Files, expectedFiles) {
+		t.Errorf("files = %v, want %v", input.Files, expectedFiles)
+	}
+}
+
+func TestRunPRValidate(t *testing.T) {
+	root := t.TempDir()
+	configPath := writeTestFile(t, root, "monorepo-config.json", `{
+		"rego_policy_dirs": {"tests/global": "policies/global"},
+		"pr_validator_policies": ["tests/global"]
+	}`)
+	writeTestFile(t, root, "policies/global/empty_pr.rego", testPRPolicy)
+	writeTestFile(t, root, "README.md", "# Repo\n")
+
+	emptyPR := writeTestFile(t, root, "empty.json", `{"title": "Empty", "changed_files": []}`)
+	err := runPRValidate([]string{"--config", configPath, "--root", root, "--pr-metadata", emptyPR})
+	if err != errValidationFailed {
+		t.Errorf("expected validation failure for an empty PR, got %v", err)
+	}
+
+	docsPR := writeTestFile(t, root, "docs.json", `{"title": "Docs", "changed_files": [{"path": "README.md"}]}`)
+	if err := runPRValidate([]string{"--config", configPath, "--root", root, "--pr-metadata", docsPR}); err != nil {
+		t.Errorf("expected validation to pass, got %v", err)
+	}
+
+	noFiles := writeTestFile(t, root, "nofiles.json", `{"title": "No files"}`)
+	if err := runPRValidate([]string{"--config", configPath, "--root", root, "--pr-metadata", noFiles}); err == nil {
+		t.Errorf("expected error when neither changed_files nor --base is given")
+	}
+}
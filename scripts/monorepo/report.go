@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/terraform-modules/scripts/module-validator/policies"
+)
+
+// reportResults prints per-policy results and the validation summary in the
+// module-validator format, and returns true if validation failed
+func reportResults(subject string, results []RuleViolations) bool {
+	failed := false
+	fileFailed := make(map[string]bool)
+	fileErrored := make(map[string]bool)
+	var files []string
+	var passedRules, failedRules, errorRules int
+
+	current := ""
+	for _, result := range results {
+		if result.PolicyFile != current {
+			current = result.PolicyFile
+			files = append(files, current)
+			fmt.Printf("\n%s🔍 Evaluating policy:%s %s\n", ColorBlue, ColorReset, filepath.Base(current))
+		}
+
+		if result.Err != nil {
+			fmt.Printf("%sError: %v%s\n", ColorRed, result.Err, ColorReset)
+			fileErrored[current] = true
+			errorRules++
+			failed = true
+			continue
+		}
+
+		if policies.ReportViolations(result.Name(), result.Violations) {
+			fileFailed[current] = true
+			failedRules++
+			failed = true
+		} else {
+			passedRules++
+		}
+	}
+
+	var passedFiles, failedFiles, errorFiles int
+	for _, file := range files {
+		switch {
+		case fileErrored[file]:
+			errorFiles++
+		case fileFailed[file]:
+			failedFiles++
+		default:
+			passedFiles++
+		}
+	}
+
+	fmt.Printf("\n%s=== %s Validation Summary ===%s\n", ColorBlue, subject, ColorReset)
+	fmt.Printf("%s✅ Passed:%s %d policy files (%d rules)\n", ColorGreen, ColorReset, passedFiles, passedRules)
+	fmt.Printf("%s❌ Failed:%s %d policy files (%d rules)\n", ColorRed, ColorReset, failedFiles, failedRules)
+	fmt.Printf("%s⚠️ Errors:%s %d policy files (%d rules)\n", ColorYellow, ColorReset, errorFiles, errorRules)
+	fmt.Printf("%s🔍 Total:%s %d policy files (%d rules)\n", ColorCyan, ColorReset, len(files), passedRules+failedRules+errorRules)
+
+	if failed {
+		fmt.Printf("\n%s❌ %s validation FAILED%s\n", ColorRed, subject, ColorReset)
+	} else {
+		fmt.Printf("\n%s✅ %s validation PASSED%s\n", ColorGreen, subject, ColorReset)
+	}
+
+	return failed
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/terraform-modules/scripts/go-discovery"
+)
+
+//
+// ---------- Affected Tests ----------
+//
+
+// AffectedTests configures the selection of test directories by a change set.
+type AffectedTests struct {
+	RegoAlwaysRun []string `json:"rego_always_run"` // Files or directories whose change runs every directory
+}
+
+// affectedSuite is a rego_tests directory selected by a change set.
+type affectedSuite struct {
+	TestPath string
+	Trigger  string // First changed file in the tests or their policies
+}
+
+// readChangedFiles returns the changed files listed in path, one per line,
+// or those git reports against base when path is empty.
+func readChangedFiles(path, base string) ([]string, error) {
+	var data []byte
+	var err error
+	if path != "" {
+		data, err = os.ReadFile(path)
+	} else {
+		// Against the merge base, including uncommitted changes such as a
+		// merge made with --no-commit
+		data, err = exec.Command("git", "diff", "--name-only", "--merge-base", base).Output()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	for _, line := range strings.Split(string(data), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			files = append(files, discovery.CleanPath(line))
+		}
+	}
+	return files, nil
+}
+
+// selectAffected returns the rego_tests directories whose tests or mapped
+// policies changed. The helpers are loaded by every directory, so a change
+// to them, as to one of the alwaysRun paths, selects every directory and
+// returns that file.
+func selectAffected(config *Config, changed, alwaysRun []string) ([]affectedSuite, string) {
+	shared := append([]string{}, alwaysRun...)
+	if config.RegoHelpersDir != "" {
+		shared = append(shared, config.RegoHelpersDir)
+	}
+	for _, file := range changed {
+		for _, path := range shared {
+			if underPath(file, discovery.CleanPath(path)) {
+				selected := make([]affectedSuite, 0, len(config.RegoTests))
+				for _, testPath := range config.RegoTests {
+					selected = append(selected, affectedSuite{TestPath: testPath, Trigger: file})
+				}
+				return selected, file
+			}
+		}
+	}
+
+	var selected []affectedSuite
+	for _, testPath := range config.RegoTests {
+		dirs := []string{discovery.CleanPath(testPath)}
+		if policyDir := config.getPolicyDir(testPath); policyDir != "" {
+			dirs = append(dirs, discovery.CleanPath(policyDir))
+		}
+	search:
+		for _, file := range changed {
+			for _, dir := range dirs {
+				if underPath(file, dir) {
+					selected = append(selected, affectedSuite{TestPath: testPath, Trigger: file})
+					break search
+				}
+			}
+		}
+	}
+	return selected, ""
+}
+
+// filterAffected narrows config.RegoTests to the directories affected by the
+// change set and reports whether any remain and whether some were left out.
+func filterAffected(config *Config, configPath, changedFilesPath, base string) (bool, bool, error) {
+	changed, err := readChangedFiles(changedFilesPath, base)
+	if err != nil && changedFilesPath != "" {
+		return false, false, err
+	}
+	if err != nil {
+		// Testing too much beats missing a broken test
+		fmt.Fprintf(os.Stderr, "⚠️  Could not list the files changed against %s (%v), testing all directories\n", base, err)
+		return true, false, nil
+	}
+
+	alwaysRun := append([]string{configPath}, config.AffectedTests.RegoAlwaysRun...)
+	selected, shared := selectAffected(config, changed, alwaysRun)
+	if len(selected) == 0 {
+		fmt.Fprintf(os.Stderr, "✅ None of the %d changed files affect a Rego test directory, nothing to test\n", len(changed))
+		return false, false, nil
+	}
+
+	if shared != "" {
+		fmt.Fprintf(os.Stderr, "🔎 Shared file %s changed, testing all %d directories\n", shared, len(config.RegoTests))
+	} else {
+		fmt.Fprintf(os.Stderr, "🔎 %d changed files affect %d of %d Rego test directories:\n", len(changed), len(selected), len(config.RegoTests))
+		for _, suite := range selected {
+			fmt.Fprintf(os.Stderr, "  %s (%s)\n", suite.TestPath, suite.Trigger)
+		}
+	}
+
+	partial := len(selected) < len(config.RegoTests)
+	testPaths := make([]string, 0, len(selected))
+	for _, suite := range selected {
+		testPaths = append(testPaths, suite.TestPath)
+	}
+	config.RegoTests = testPaths
+	return true, partial, nil
+}
+
+// partialThresholds returns the coverage minimums that apply when only the
+// config.RegoTests directories run: the total of some directories says
+// nothing about the global minimum, and files loaded by none of them are
+// not covered.
+func partialThresholds(config *Config) *CoverageThresholds {
+	thresholds := *config.CoverageThresholds
+	if thresholds.Global > 0 {
+		fmt.Fprintln(os.Stderr, "⚠️  Only the affected directories ran, not checking the global coverage minimum")
+		thresholds.Global = 0
+	}
+
+	var loaded []string
+	for _, testPath := range config.RegoTests {
+		loaded = append(loaded, discovery.CleanPath(testPath))
+		if policyDir := config.getPolicyDir(testPath); policyDir != "" {
+			loaded = append(loaded, discovery.CleanPath(policyDir))
+		}
+	}
+	if config.RegoHelpersDir != "" {
+		loaded = append(loaded, discovery.CleanPath(config.RegoHelpersDir))
+	}
+	files := make(map[string]float64, len(thresholds.Files))
+	for file, minimum := range thresholds.Files {
+		for _, dir := range loaded {
+			if underPath(discovery.CleanPath(file), dir) {
+				files[file] = minimum
+				break
+			}
+		}
+	}
+	thresholds.Files = files
+	return &thresholds
+}
+
+// underPath reports whether file is dir or inside it.
+func underPath(file, dir string) bool {
+	return dir == "." || file == dir || strings.HasPrefix(file, dir+"/")
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func testAffectedConfig() *Config {
+	return &Config{
+		RegoTests: []string{"tests/opa/unit/global", "tests/opa/unit/terraform/module", "tests/opa/unit/terraform/module_types"},
+		RegoPolicyDirs: map[string]string{
+			"tests/opa/unit/global":                 "policies/opa/global",
+			"tests/opa/unit/terraform/module":       "policies/opa/terraform/module",
+			"tests/opa/unit/terraform/module_types": "policies/opa/terraform/module_types",
+		},
+		RegoHelpersDir: "tests/opa/unit/helpers",
+	}
+}
+
+func TestSelectAffected(t *testing.T) {
+	alwaysRun := []string{"monorepo-config.json", "scripts/rego-unit-test/"}
+
+	tests := []struct {
+		name     string
+		changed  []string
+		expected []string
+		shared   string
+	}{
+		{
+			name:     "test file",
+			changed:  []string{"tests/opa/unit/global/global_test.rego"},
+			expected: []string{"tests/opa/unit/global"},
+		},
+		{
+			name:     "policy file",
+			changed:  []string{"policies/opa/terraform/module_types/skeleton/checks.rego", "README.md"},
+			expected: []string{"tests/opa/unit/terraform/module_types"},
+		},
+		{
+			name:     "prefix of another directory",
+			changed:  []string{"policies/opa/terraform/module/main.rego"},
+			expected: []string{"tests/opa/unit/terraform/module"},
+		},
+		{
+			name:     "helpers",
+			changed:  []string{"tests/opa/unit/helpers/helpers.rego"},
+			expected: []string{"tests/opa/unit/global", "tests/opa/unit/terraform/module", "tests/opa/unit/terraform/module_types"},
+			shared:   "tests/opa/unit/helpers/helpers.rego",
+		},
+		{
+			name:     "shared config",
+			changed:  []string{"monorepo-config.json"},
+			expected: []string{"tests/opa/unit/global", "tests/opa/unit/terraform/module", "tests/opa/unit/terraform/module_types"},
+			shared:   "monorepo-config.json",
+		},
+		{
+			name:     "unrelated",
+			changed:  []string{"scripts/go-lint/main.go"},
+			expected: nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			selected, shared := selectAffected(testAffectedConfig(), tt.changed, alwaysRun)
+			var testPaths []string
+			for _, suite := range selected {
+				testPaths = append(testPaths, suite.TestPath)
+			}
+			if !reflect.DeepEqual(testPaths, tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, testPaths)
+			}
+			if shared != tt.shared {
+				t.Errorf("Expected shared file %q, got %q", tt.shared, shared)
+			}
+		})
+	}
+}
+
+func TestFilterAffected(t *testing.T) {
+	dir := t.TempDir()
+	changedPath := filepath.Join(dir, "changed.txt")
+
+	tests := []struct {
+		name      string
+		changed   string
+		affected  bool
+		partial   bool
+		testPaths []string
+	}{
+		{
+			name:      "policy change",
+			changed:   "./policies/opa/global/naming.rego\n\n",
+			affected:  true,
+			partial:   true,
+			testPaths: []string{"tests/opa/unit/global"},
+		},
+		{
+			name:      "config change",
+			changed:   "config.json\n",
+			affected:  true,
+			testPaths: []string{"tests/opa/unit/global", "tests/opa/unit/terraform/module", "tests/opa/unit/terraform/module_types"},
+		},
+		{
+			name:      "nothing affected",
+			changed:   "docs/README.md\n",
+			testPaths: []string{"tests/opa/unit/global", "tests/opa/unit/terraform/module", "tests/opa/unit/terraform/module_types"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := os.WriteFile(changedPath, []byte(tt.changed), 0644); err != nil {
+				t.Fatal(err)
+			}
+			config := testAffectedConfig()
+			affected, partial, err := filterAffected(config, "./config.json", changedPath, "")
+			if err != nil {
+				t.Fatalf("filterAffected() error = %v", err)
+			}
+			if affected != tt.affected || partial != tt.partial {
+				t.Errorf("Expected affected %v and partial %v, got %v and %v", tt.affected, tt.partial, affected, partial)
+			}
+			if !reflect.DeepEqual(config.RegoTests, tt.testPaths) {
+				t.Errorf("Expected rego_tests %v, got %v", tt.testPaths, config.RegoTests)
+			}
+		})
+	}
+
+	if _, _, err := filterAffected(testAffectedConfig(), "config.json", filepath.Join(dir, "missing.txt"), ""); err == nil {
+		t.Errorf("Expected an error for a missing changed files list")
+	}
+}
+
+func TestPartialThresholds(t *testing.T) {
+	config := testAffectedConfig()
+	config.RegoTests = []string{"tests/opa/unit/global"}
+	config.CoverageThresholds = &CoverageThresholds{
+		Global:    90,
+		Directory: 75,
+		Files: map[string]float64{
+			"policies/opa/global/naming.rego":                   100,
+			"policies/opa/terraform/module/main.rego":           100,
+			"tests/opa/unit/helpers/helpers.rego":               80,
+			"policies/opa/terraform/provider/restrictions.rego": 100,
+		},
+	}
+
+	thresholds := partialThresholds(config)
+	if thresholds.Global != 0 || thresholds.Directory != 75 {
+		t.Errorf("Expected no global minimum and the directory minimum kept, got %+v", thresholds)
+	}
+	expected := map[string]float64{"policies/opa/global/naming.rego": 100, "tests/opa/unit/helpers/helpers.rego": 80}
+	if !reflect.DeepEqual(thresholds.Files, expected) {
+		t.Errorf("Expected file minimums %v, got %v", expected, thresholds.Files)
+	}
+	if config.CoverageThresholds.Global != 90 || len(config.CoverageThresholds.Files) != 4 {
+		t.Errorf("Expected the configured minimums unchanged")
+	}
+}
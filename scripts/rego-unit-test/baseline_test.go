@@ -0,0 +1,120 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSummarizeCoverage(t *testing.T) {
+	report := summarizeCoverage([]DirCoverage{
+		{TestPath: "tests/a", Data: CoverageData{CoveredLines: 9, NotCoveredLines: 1, Coverage: 90}},
+		{TestPath: "tests/b", Data: CoverageData{CoveredLines: 21, NotCoveredLines: 9, Coverage: 70}},
+	})
+	if report.Total != 75 || len(report.Modules) != 2 || report.Modules[1].Statements != 30 {
+		t.Errorf("Unexpected summary %+v", report)
+	}
+}
+
+func TestCompareCoverage(t *testing.T) {
+	baseline := JSONReport{
+		Modules: []ModuleCoverage{{Name: "tests/a", Coverage: 90}, {Name: "tests/old", Coverage: 50}},
+		Total:   80,
+	}
+	current := JSONReport{
+		Modules: []ModuleCoverage{{Name: "tests/a", Coverage: 92}, {Name: "tests/new", Coverage: 60}},
+		Total:   80.04,
+	}
+
+	delta := compareCoverage(baseline, current)
+	if delta.Drop() != 0 {
+		t.Errorf("Expected no drop after rounding, got %v", delta.Drop())
+	}
+	expected := []ModuleDelta{
+		{Name: "tests/a", Baseline: 90, Current: 92},
+		{Name: "tests/new", Current: 60, New: true},
+		{Name: "tests/old", Baseline: 50, Removed: true},
+	}
+	if len(delta.Modules) != len(expected) {
+		t.Fatalf("Expected %d modules, got %+v", len(expected), delta.Modules)
+	}
+	for i := range expected {
+		if delta.Modules[i] != expected[i] {
+			t.Errorf("Module %d: expected %+v, got %+v", i, expected[i], delta.Modules[i])
+		}
+	}
+}
+
+func TestRestrictBaseline(t *testing.T) {
+	baseline := JSONReport{
+		Modules: []ModuleCoverage{
+			{Name: "tests/a", Coverage: 80, Statements: 100},
+			{Name: "tests/b", Coverage: 20, Statements: 300},
+			{Name: "tests/c", Coverage: 50, Statements: 100},
+		},
+		Total: 32,
+	}
+	report := JSONReport{Modules: []ModuleCoverage{{Name: "tests/a"}, {Name: "tests/c"}}}
+
+	restricted := restrictBaseline(baseline, report)
+	if len(restricted.Modules) != 2 || restricted.Modules[1].Name != "tests/c" || restricted.Total != 65 {
+		t.Errorf("Unexpected restricted baseline %+v", restricted)
+	}
+}
+
+func TestWriteTrend(t *testing.T) {
+	dir := t.TempDir()
+	baselinePath := filepath.Join(dir, "baseline.json")
+	if err := os.WriteFile(baselinePath, []byte(`{"modules":[{"name":"tests/a","coverage":95,"statements":20}],"total":95,"errors":0}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	report := JSONReport{Modules: []ModuleCoverage{{Name: "tests/a", Coverage: 93.5, Statements: 20}}, Total: 93.5}
+
+	allowed := 2.0
+	tight := 1.0
+	tests := []struct {
+		name     string
+		trend    trendOutputs
+		maxDrop  *float64
+		expected bool
+	}{
+		{name: "badge only", trend: trendOutputs{Badge: filepath.Join(dir, "badge.svg")}, maxDrop: &tight, expected: true},
+		{name: "within limit", trend: trendOutputs{Baseline: baselinePath}, maxDrop: &allowed, expected: true},
+		{name: "over limit", trend: trendOutputs{Baseline: baselinePath, DeltaMarkdown: filepath.Join(dir, "delta.md")}, maxDrop: &tight, expected: false},
+		{name: "no limit", trend: trendOutputs{Baseline: baselinePath}, expected: true},
+		{name: "missing baseline", trend: trendOutputs{Baseline: filepath.Join(dir, "missing.json")}, maxDrop: &tight, expected: true},
+		{name: "badge below a file", trend: trendOutputs{Badge: filepath.Join(baselinePath, "badge.svg")}, expected: false},
+		{name: "no badge for a partial run", trend: trendOutputs{Badge: filepath.Join(baselinePath, "badge.svg"), Partial: true}, expected: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := writeTrend(tt.trend, report, tt.maxDrop); got != tt.expected {
+				t.Errorf("writeTrend() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+
+	badge, err := os.ReadFile(filepath.Join(dir, "badge.svg"))
+	if err != nil {
+		t.Fatalf("Expected a badge: %v", err)
+	}
+	if !strings.Contains(string(badge), `fill="#4c1"`) || !strings.Contains(string(badge), ">93.5%<") {
+		t.Errorf("Unexpected badge:\n%s", badge)
+	}
+
+	markdown, err := os.ReadFile(filepath.Join(dir, "delta.md"))
+	if err != nil {
+		t.Fatalf("Expected a Markdown table: %v", err)
+	}
+	for _, want := range []string{
+		"### Rego coverage\n",
+		"| tests/a | 95.0% | 93.5% | -1.5 🔴 |",
+		"| **Total** | **95.0%** | **93.5%** | **-1.5 🔴** |",
+		"❌ Total coverage dropped by 1.5 points, more than the allowed 1.0.",
+	} {
+		if !strings.Contains(string(markdown), want) {
+			t.Errorf("Expected Markdown to contain %q, got:\n%s", want, markdown)
+		}
+	}
+}
@@ -1,15 +1,16 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"flag"
 	"fmt"
-	"io/ioutil"
 	"os"
-	"os/exec"
 	"path/filepath"
+	"runtime"
 	"sort"
 	"strings"
+	"time"
 )
 
 //
@@ -18,9 +19,21 @@ import (
 
 // Config holds test directories, policy mapping, and helpers path.
 type Config struct {
-	RegoTests      []string          `json:"rego_tests"`       // List of unit test directories
-	RegoPolicyDirs map[string]string `json:"rego_policy_dirs"` // Mapping of test dir → policy dir
-	RegoHelpersDir string            `json:"rego_helpers_dir"` // Path to helpers.rego
+	RegoTests          []string            `json:"rego_tests"`               // List of unit test directories
+	RegoPolicyDirs     map[string]string   `json:"rego_policy_dirs"`         // Mapping of test dir → policy dir
+	RegoHelpersDir     string              `json:"rego_helpers_dir"`         // Path to helpers.rego
+	CoverageThresholds *CoverageThresholds `json:"rego_coverage_thresholds"` // Minimum coverage, optional
+	MaxCoverageDrop    *float64            `json:"max_coverage_drop"`        // Allowed drop of the total against a baseline, optional
+	AffectedTests      AffectedTests       `json:"affected_tests"`           // Selection of directories by a change set
+}
+
+// CoverageThresholds holds minimum coverage percentages (0–100).
+// A zero or missing value means no minimum.
+type CoverageThresholds struct {
+	Global      float64            `json:"global"`      // Minimum total coverage
+	Directory   float64            `json:"directory"`   // Default minimum for each rego_tests directory
+	Directories map[string]float64 `json:"directories"` // Per-directory minimums, overriding the default
+	Files       map[string]float64 `json:"files"`       // Per-policy-file minimums
 }
 
 // CoverageData represents the root of OPA JSON coverage output.
@@ -60,9 +73,33 @@ type ModuleCoverage struct {
 
 // JSONReport defines the structured JSON summary format.
 type JSONReport struct {
-	Modules []ModuleCoverage `json:"modules"` // Per-module summaries
-	Total   float64          `json:"total"`   // Overall percent coverage
-	Errors  int              `json:"errors"`  // Count of failures (always 0 here)
+	Modules           []ModuleCoverage  `json:"modules"`                      // Per-module summaries
+	Total             float64           `json:"total"`                        // Overall percent coverage
+	Errors            int               `json:"errors"`                       // Test directories with failing tests or unreadable coverage
+	ThresholdFailures []ThresholdResult `json:"threshold_failures,omitempty"` // Coverage minimums that were not met
+}
+
+// DirCoverage is the parsed coverage of one rego_tests directory.
+type DirCoverage struct {
+	TestPath string
+	Data     CoverageData
+}
+
+// ThresholdResult is the outcome of one coverage minimum.
+type ThresholdResult struct {
+	Scope     string          `json:"scope"`               // global, directory or file
+	Name      string          `json:"name,omitempty"`      // Directory or file path
+	Coverage  float64         `json:"coverage"`            // Actual percent covered
+	Threshold float64         `json:"threshold"`           // Required percent
+	Passed    bool            `json:"-"`                   // Whether the minimum was met
+	Uncovered []UncoveredFile `json:"uncovered,omitempty"` // Files below the minimum
+}
+
+// UncoveredFile lists the uncovered lines of a file below its minimum.
+type UncoveredFile struct {
+	File     string   `json:"file"`     // Path to the .rego file
+	Coverage float64  `json:"coverage"` // File-level percent coverage
+	Lines    []string `json:"lines"`    // Uncovered line ranges, e.g. 12-15
 }
 
 //
@@ -75,6 +112,14 @@ func main() {
 	coverageText := flag.Bool("coverage-text", false, "Output coverage in text format")
 	coverageJSON := flag.Bool("coverage-json", false, "Output coverage in JSON format")
 	dataPath := flag.String("data-path", ".", "Path to the data directory")
+	jobs := flag.Int("jobs", runtime.NumCPU(), "Number of test directories to run concurrently")
+	junitPath := flag.String("junit", "", "Write per-test results as JUnit XML to this file")
+	resultsJSONPath := flag.String("results-json", "", "Write per-test results as JSON to this file")
+	baselinePath := flag.String("baseline", "", "Compare coverage with a --coverage-json report, typically from the main branch")
+	deltaMarkdownPath := flag.String("delta-markdown", "", "Write the coverage change against --baseline as a Markdown table to this file")
+	badgePath := flag.String("badge", "", "Write an SVG coverage badge to this file")
+	changedFilesPath := flag.String("changed-files", "", "Only test the directories affected by the files listed in this file, one per line")
+	baseRef := flag.String("base", "", "Only test the directories affected by the changes against this git ref")
 	flag.Parse()
 
 	// Expect config file path as final argument
@@ -92,8 +137,29 @@ func main() {
 		os.Exit(1)
 	}
 
+	// Keep the directories affected by the change set, if given
+	partial := false
+	if *changedFilesPath != "" || *baseRef != "" {
+		var affected bool
+		affected, partial, err = filterAffected(config, configPath, *changedFilesPath, *baseRef)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading changed files: %v\n", err)
+			os.Exit(1)
+		}
+		if !affected {
+			return
+		}
+	}
+
+	// Only the minimums of the directories that run apply
+	if partial && !*noCoverage && config.CoverageThresholds != nil {
+		config.CoverageThresholds = partialThresholds(config)
+	}
+
 	// Run test logic
-	success := runTests(config, *dataPath, *noCoverage, *coverageText, *coverageJSON)
+	success := runTests(config, *dataPath, *noCoverage, *coverageText, *coverageJSON, *jobs,
+		resultOutputs{JUnit: *junitPath, JSON: *resultsJSONPath},
+		trendOutputs{Baseline: *baselinePath, DeltaMarkdown: *deltaMarkdownPath, Badge: *badgePath, Partial: partial})
 	if !success {
 		os.Exit(1)
 	}
@@ -105,7 +171,7 @@ func main() {
 
 // readConfig loads and unmarshals the monorepo-config.json file.
 func readConfig(path string) (*Config, error) {
-	data, err := ioutil.ReadFile(path)
+	data, err := os.ReadFile(path)
 	if err != nil {
 		return nil, err
 	}
@@ -119,78 +185,75 @@ func readConfig(path string) (*Config, error) {
 //
 
 // runTests executes tests and handles output generation (JSON/text).
-func runTests(config *Config, dataPath string, noCoverage, coverageText, coverageJSON bool) bool {
+func runTests(config *Config, dataPath string, noCoverage, coverageText, coverageJSON bool, jobs int, outputs resultOutputs, trend trendOutputs) bool {
 	allSuccess := true
-	var coverageFiles []string
+	var coverages []DirCoverage
+	testErrors := 0
 
-	// Full path to helpers directory
-	helpersDir := filepath.Join(dataPath, config.RegoHelpersDir)
+	withCoverage := !noCoverage && (coverageText || coverageJSON)
+	suites := runSuites(context.Background(), config, dataPath, withCoverage, jobs)
 
-	for _, testPath := range config.RegoTests {
-		fmt.Fprintf(os.Stderr, "Running Rego tests in %s...\n", testPath)
+	for _, suite := range suites {
+		fmt.Fprintf(os.Stderr, "Running Rego tests in %s...\n", suite.TestPath)
 
-		// Determine associated policy directory
-		policyDir := config.getPolicyDir(testPath)
-
-		// Build `opa test` arguments
-		args := []string{"test"}
-		if noCoverage {
-			args = append(args, "-v")
-		} else {
-			if coverageText {
-				args = append(args, "--coverage")
-			} else if coverageJSON {
-				args = append(args, "--coverage", "--format=json")
-			}
-		}
-		args = append(args, testPath, policyDir, helpersDir)
-
-		// Run the command
-		cmd := exec.Command("opa", args...)
-		var output []byte
-		var err error
-
-		// Capture or display output depending on mode
+		// List every test without coverage, only failures otherwise
 		if noCoverage {
-			cmd.Stdout = os.Stdout
-			cmd.Stderr = os.Stderr
-			err = cmd.Run()
-		} else {
-			output, err = cmd.CombinedOutput()
+			printSuiteResult(os.Stdout, suite, true)
+		} else if suite.Failed() {
+			printSuiteResult(os.Stderr, suite, false)
 		}
 
 		// Handle test failures
-		if err != nil {
-			if !noCoverage {
-				fmt.Fprintln(os.Stderr, string(output))
-			}
-			fmt.Fprintf(os.Stderr, "Error running tests in %s: %v\n", testPath, err)
+		if suite.Failed() {
+			fmt.Fprintf(os.Stderr, "Error running tests in %s (%s)\n", suite.TestPath, suite.Duration.Round(time.Millisecond))
 			allSuccess = false
+			testErrors++
 		}
 
 		// Save raw coverage report
-		if !noCoverage && (coverageText || coverageJSON) {
-			coverageFile := filepath.Join("tmp", "coverage",
-				fmt.Sprintf("rego-coverage-%s.%s", strings.ReplaceAll(testPath, "/", "-"), getFileExtension(coverageJSON)))
-			err = ioutil.WriteFile(coverageFile, output, 0644)
-			if err != nil {
-				fmt.Fprintf(os.Stderr, "Error writing coverage file %s: %v\n", coverageFile, err)
+		if withCoverage && suite.Coverage != nil {
+			if err := writeCoverageFile(suite, coverageJSON); err != nil {
+				fmt.Fprintf(os.Stderr, "Error writing coverage file: %v\n", err)
 			}
-			coverageFiles = append(coverageFiles, coverageFile)
+			coverages = append(coverages, DirCoverage{TestPath: suite.TestPath, Data: *suite.Coverage})
 		}
 	}
 
-	// Emit text summary if requested
-	if coverageText && len(coverageFiles) > 0 {
-		err := GenerateTextCoverageReport(coverageFiles)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error generating coverage report: %v\n", err)
+	// Write per-test results if requested
+	if !writeResults(outputs, buildResultsReport(suites)) {
+		allSuccess = false
+	}
+
+	// Check coverage minimums
+	var thresholdResults, thresholdFailures []ThresholdResult
+	if config.CoverageThresholds != nil && len(coverages) > 0 {
+		thresholdResults = checkCoverageThresholds(config.CoverageThresholds, coverages, dataPath)
+		for _, result := range thresholdResults {
+			if !result.Passed {
+				thresholdFailures = append(thresholdFailures, result)
+				allSuccess = false
+			}
 		}
 	}
 
+	// Emit text summary if requested
+	if coverageText && len(coverages) > 0 {
+		GenerateTextCoverageReport(coverages)
+	}
+
+	// Report coverage minimums after the summary
+	if len(thresholdResults) > 0 {
+		printThresholdResults(thresholdResults)
+	}
+
+	// Write the badge and compare with the baseline
+	if len(coverages) > 0 && !writeTrend(trend, summarizeCoverage(coverages), config.MaxCoverageDrop) {
+		allSuccess = false
+	}
+
 	// Emit structured JSON summary if requested
-	if coverageJSON && len(coverageFiles) > 0 {
-		err := GenerateJSONCoverageReport(coverageFiles)
+	if coverageJSON && len(coverages) > 0 {
+		err := GenerateJSONCoverageReport(coverages, testErrors, thresholdFailures)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error generating JSON summary: %v\n", err)
 		}
@@ -199,6 +262,47 @@ func runTests(config *Config, dataPath string, noCoverage, coverageText, coverag
 	return allSuccess
 }
 
+// resultOutputs holds the optional per-test result files.
+type resultOutputs struct {
+	JUnit string // --junit path
+	JSON  string // --results-json path
+}
+
+// writeResults writes the requested result files and reports whether all
+// of them were written.
+func writeResults(outputs resultOutputs, report ResultsReport) bool {
+	ok := true
+	if outputs.JUnit != "" {
+		if err := writeJUnit(outputs.JUnit, "rego-unit-test", report); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing JUnit report %s: %v\n", outputs.JUnit, err)
+			ok = false
+		}
+	}
+	if outputs.JSON != "" {
+		if err := writeResultsJSON(outputs.JSON, report); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing test results %s: %v\n", outputs.JSON, err)
+			ok = false
+		}
+	}
+	return ok
+}
+
+// writeCoverageFile saves the raw coverage of a suite under tmp/coverage,
+// creating the directory if needed.
+func writeCoverageFile(suite SuiteResult, isJSON bool) error {
+	dir := filepath.Join("tmp", "coverage")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(suite.Coverage, "", "  ")
+	if err != nil {
+		return err
+	}
+	coverageFile := filepath.Join(dir,
+		fmt.Sprintf("rego-coverage-%s.%s", strings.ReplaceAll(suite.TestPath, "/", "-"), getFileExtension(isJSON)))
+	return os.WriteFile(coverageFile, data, 0644)
+}
+
 //
 // ---------- Utility Helpers ----------
 //
@@ -224,24 +328,14 @@ func getFileExtension(isJSON bool) string {
 //
 
 // GenerateTextCoverageReport prints human-readable summary to stderr.
-func GenerateTextCoverageReport(coverageFiles []string) error {
+func GenerateTextCoverageReport(coverages []DirCoverage) {
 	var totalCoveredLines, totalNotCoveredLines int
 	var moduleCoverages []ModuleCoverage
 
-	for _, file := range coverageFiles {
-		// Read and parse raw coverage file
-		data, err := ioutil.ReadFile(file)
-		if err != nil {
-			return fmt.Errorf("error reading coverage file %s: %v", file, err)
-		}
-		var coverage CoverageData
-		err = json.Unmarshal(data, &coverage)
-		if err != nil {
-			return fmt.Errorf("error parsing coverage data from %s: %v", file, err)
-		}
-
+	for _, dc := range coverages {
 		// Aggregate totals
-		moduleName := extractModuleName(file)
+		moduleName := dc.TestPath
+		coverage := dc.Data
 		totalCoveredLines += coverage.CoveredLines
 		totalNotCoveredLines += coverage.NotCoveredLines
 
@@ -285,8 +379,6 @@ func GenerateTextCoverageReport(coverageFiles []string) error {
 		fmt.Fprintf(os.Stderr, "  %-38s %9.1f%% %10d\n", mc.Name, mc.Coverage, mc.Statements)
 	}
 	fmt.Fprintf(os.Stderr, "%-40s %9.1f%% %10d\n", "Total", totalCoverage, totalLines)
-
-	return nil
 }
 
 //
@@ -294,31 +386,32 @@ func GenerateTextCoverageReport(coverageFiles []string) error {
 //
 
 // GenerateJSONCoverageReport emits a structured summary to stdout.
-func GenerateJSONCoverageReport(coverageFiles []string) error {
+func GenerateJSONCoverageReport(coverages []DirCoverage, errors int, thresholdFailures []ThresholdResult) error {
+	report := summarizeCoverage(coverages)
+	report.Errors = errors
+	report.ThresholdFailures = thresholdFailures
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(report)
+}
+
+// summarizeCoverage returns the per-directory coverage and the total,
+// weighted by statements.
+func summarizeCoverage(coverages []DirCoverage) JSONReport {
 	var totalCoveredLines, totalNotCoveredLines int
 	var moduleCoverages []ModuleCoverage
 
-	for _, file := range coverageFiles {
-		// Read and parse raw file
-		data, err := ioutil.ReadFile(file)
-		if err != nil {
-			return fmt.Errorf("error reading coverage file %s: %v", file, err)
-		}
-		var coverage CoverageData
-		err = json.Unmarshal(data, &coverage)
-		if err != nil {
-			return fmt.Errorf("error parsing coverage data from %s: %v", file, err)
-		}
-
+	for _, dc := range coverages {
 		// Add module data
 		moduleCoverages = append(moduleCoverages, ModuleCoverage{
-			Name:       extractModuleName(file),
-			Coverage:   coverage.Coverage,
-			Statements: coverage.CoveredLines + coverage.NotCoveredLines,
+			Name:       dc.TestPath,
+			Coverage:   dc.Data.Coverage,
+			Statements: dc.Data.CoveredLines + dc.Data.NotCoveredLines,
 		})
 
-		totalCoveredLines += coverage.CoveredLines
-		totalNotCoveredLines += coverage.NotCoveredLines
+		totalCoveredLines += dc.Data.CoveredLines
+		totalNotCoveredLines += dc.Data.NotCoveredLines
 	}
 
 	// Final summary
@@ -328,27 +421,168 @@ func GenerateJSONCoverageReport(coverageFiles []string) error {
 		totalCoverage = float64(totalCoveredLines) / float64(totalStatements) * 100
 	}
 
-	report := JSONReport{
-		Modules: moduleCoverages,
-		Total:   totalCoverage,
-		Errors:  0,
-	}
-
-	enc := json.NewEncoder(os.Stdout)
-	enc.SetIndent("", "  ")
-	return enc.Encode(report)
+	return JSONReport{Modules: moduleCoverages, Total: totalCoverage}
 }
 
 //
-// ---------- Module Name Utility ----------
+// ---------- Coverage Thresholds ----------
 //
 
-// extractModuleName turns a file path like rego-coverage-foo-bar.json
-// into a readable module name like foo/bar
-func extractModuleName(filePath string) string {
-	base := filepath.Base(filePath)
-	name := strings.TrimSuffix(base, filepath.Ext(base))
-	name = strings.TrimPrefix(name, "rego-coverage-")
-	name = strings.ReplaceAll(name, "-", "/")
-	return name
+// checkCoverageThresholds compares coverage against the configured minimums.
+// Results are returned for every configured minimum, in the order global,
+// directories, files.
+func checkCoverageThresholds(thresholds *CoverageThresholds, coverages []DirCoverage, dataPath string) []ThresholdResult {
+	var results []ThresholdResult
+
+	// Global minimum, weighted by statements like the summary total
+	if thresholds.Global > 0 {
+		var covered, notCovered int
+		for _, dc := range coverages {
+			covered += dc.Data.CoveredLines
+			notCovered += dc.Data.NotCoveredLines
+		}
+		var total float64
+		if covered+notCovered > 0 {
+			total = float64(covered) / float64(covered+notCovered) * 100
+		}
+		result := ThresholdResult{Scope: "global", Coverage: total, Threshold: thresholds.Global, Passed: total >= thresholds.Global}
+		if !result.Passed {
+			seen := make(map[string]bool)
+			for _, dc := range coverages {
+				for _, uncovered := range filesBelow(dc.Data, thresholds.Global, dataPath) {
+					if !seen[uncovered.File] {
+						seen[uncovered.File] = true
+						result.Uncovered = append(result.Uncovered, uncovered)
+					}
+				}
+			}
+		}
+		results = append(results, result)
+	}
+
+	// Per-directory minimums
+	for _, dc := range coverages {
+		minimum := thresholds.Directory
+		if dirMinimum, ok := thresholds.Directories[filepath.Clean(dc.TestPath)]; ok {
+			minimum = dirMinimum
+		} else if dirMinimum, ok := thresholds.Directories[dc.TestPath]; ok {
+			minimum = dirMinimum
+		}
+		if minimum <= 0 {
+			continue
+		}
+		result := ThresholdResult{Scope: "directory", Name: dc.TestPath, Coverage: dc.Data.Coverage, Threshold: minimum, Passed: dc.Data.Coverage >= minimum}
+		if !result.Passed {
+			result.Uncovered = filesBelow(dc.Data, minimum, dataPath)
+		}
+		results = append(results, result)
+	}
+
+	// Per-file minimums. A file loaded by several test directories (such as
+	// the helpers) is judged by its best coverage.
+	var files []string
+	for file := range thresholds.Files {
+		files = append(files, file)
+	}
+	sort.Strings(files)
+	for _, file := range files {
+		minimum := thresholds.Files[file]
+		if minimum <= 0 {
+			continue
+		}
+		var best FileCoverage
+		found := false
+		for _, dc := range coverages {
+			for path, fc := range dc.Data.Files {
+				if relativePath(path, dataPath) != filepath.Clean(file) {
+					continue
+				}
+				if !found || fc.Coverage > best.Coverage {
+					best = fc
+					found = true
+				}
+			}
+		}
+		if !found {
+			// Not loaded by any test directory, so nothing covers it
+			results = append(results, ThresholdResult{Scope: "file", Name: file, Threshold: minimum})
+			continue
+		}
+		result := ThresholdResult{Scope: "file", Name: file, Coverage: best.Coverage, Threshold: minimum, Passed: best.Coverage >= minimum}
+		if !result.Passed {
+			result.Uncovered = []UncoveredFile{{File: file, Coverage: best.Coverage, Lines: formatLineRanges(best.NotCovered)}}
+		}
+		results = append(results, result)
+	}
+
+	return results
+}
+
+// filesBelow returns the files of a coverage report below a minimum with
+// their uncovered line ranges, sorted by path
+func filesBelow(coverage CoverageData, minimum float64, dataPath string) []UncoveredFile {
+	var paths []string
+	for path := range coverage.Files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	var files []UncoveredFile
+	for _, path := range paths {
+		fc := coverage.Files[path]
+		if fc.NotCoveredLines == 0 || fc.Coverage >= minimum {
+			continue
+		}
+		files = append(files, UncoveredFile{
+			File:     relativePath(path, dataPath),
+			Coverage: fc.Coverage,
+			Lines:    formatLineRanges(fc.NotCovered),
+		})
+	}
+	return files
+}
+
+// formatLineRanges renders line ranges as "12-15" or "20" for single lines
+func formatLineRanges(ranges []LineRange) []string {
+	lines := make([]string, 0, len(ranges))
+	for _, r := range ranges {
+		if r.End.Row <= r.Start.Row {
+			lines = append(lines, fmt.Sprintf("%d", r.Start.Row))
+		} else {
+			lines = append(lines, fmt.Sprintf("%d-%d", r.Start.Row, r.End.Row))
+		}
+	}
+	return lines
+}
+
+// relativePath returns a coverage file path relative to the data path, so
+// absolute paths (such as the helpers) match config entries
+func relativePath(path, dataPath string) string {
+	if filepath.IsAbs(path) {
+		if absData, err := filepath.Abs(dataPath); err == nil {
+			if rel, err := filepath.Rel(absData, path); err == nil && !strings.HasPrefix(rel, "..") {
+				return rel
+			}
+		}
+	}
+	return filepath.Clean(path)
+}
+
+// printThresholdResults prints the outcome of each coverage minimum to stderr.
+func printThresholdResults(results []ThresholdResult) {
+	fmt.Fprintf(os.Stderr, "\n\nCoverage thresholds:\n")
+	for _, result := range results {
+		name := result.Name
+		if result.Scope == "global" {
+			name = "Total"
+		}
+		if result.Passed {
+			fmt.Fprintf(os.Stderr, "  ✅ %-50s %6.1f%% >= %5.1f%% (%s)\n", name, result.Coverage, result.Threshold, result.Scope)
+			continue
+		}
+		fmt.Fprintf(os.Stderr, "  ❌ %-50s %6.1f%% <  %5.1f%% (%s)\n", name, result.Coverage, result.Threshold, result.Scope)
+		for _, file := range result.Uncovered {
+			fmt.Fprintf(os.Stderr, "       %s %.1f%%, not covered: %s\n", file.File, file.Coverage, strings.Join(file.Lines, ", "))
+		}
+	}
 }
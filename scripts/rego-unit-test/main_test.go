@@ -1,7 +1,6 @@
 package main
 
 import (
-	"io/ioutil"
 	"os"
 	"path/filepath"
 	"testing"
@@ -9,7 +8,7 @@ import (
 
 func TestReadConfig(t *testing.T) {
 	// Create a temporary config file
-	tmpDir, err := ioutil.TempDir("", "test")
+	tmpDir, err := os.MkdirTemp("", "test")
 	if err != nil {
 		t.Fatalf("Failed to create temp dir: %v", err)
 	}
@@ -23,7 +22,7 @@ func TestReadConfig(t *testing.T) {
 		]
 	}`
 
-	err = ioutil.WriteFile(configPath, []byte(configData), 0644)
+	err = os.WriteFile(configPath, []byte(configData), 0644)
 	if err != nil {
 		t.Fatalf("Failed to write config file: %v", err)
 	}
@@ -54,3 +53,121 @@ func TestGetFileExtension(t *testing.T) {
 		t.Errorf("Expected 'txt', got '%s'", ext)
 	}
 }
+
+func TestFormatLineRanges(t *testing.T) {
+	ranges := []LineRange{
+		{Start: Position{Row: 12}, End: Position{Row: 15}},
+		{Start: Position{Row: 20}, End: Position{Row: 20}},
+	}
+	lines := formatLineRanges(ranges)
+	if len(lines) != 2 || lines[0] != "12-15" || lines[1] != "20" {
+		t.Errorf("Expected [12-15 20], got %v", lines)
+	}
+}
+
+func TestCheckCoverageThresholds(t *testing.T) {
+	dataPath := "/repo"
+	coverages := []DirCoverage{
+		{
+			TestPath: "tests/opa/unit/global",
+			Data: CoverageData{
+				CoveredLines:    60,
+				NotCoveredLines: 40,
+				Coverage:        60,
+				Files: map[string]FileCoverage{
+					"policies/opa/global/license_policy.rego": {
+						NotCovered:      []LineRange{{Start: Position{Row: 5}, End: Position{Row: 8}}},
+						CoveredLines:    9,
+						NotCoveredLines: 5,
+						Coverage:        64.3,
+					},
+					"/repo/tests/opa/unit/helpers/helpers.rego": {
+						NotCovered:      []LineRange{{Start: Position{Row: 3}, End: Position{Row: 3}}},
+						CoveredLines:    2,
+						NotCoveredLines: 1,
+						Coverage:        66.7,
+					},
+				},
+			},
+		},
+		{
+			TestPath: "tests/opa/unit/terraform/module",
+			Data: CoverageData{
+				CoveredLines:    300,
+				NotCoveredLines: 0,
+				Coverage:        100,
+				Files: map[string]FileCoverage{
+					"/repo/tests/opa/unit/helpers/helpers.rego": {CoveredLines: 3, Coverage: 100},
+				},
+			},
+		},
+	}
+	thresholds := &CoverageThresholds{
+		Global:      85,
+		Directory:   70,
+		Directories: map[string]float64{"tests/opa/unit/terraform/module": 95},
+		Files: map[string]float64{
+			"policies/opa/global/license_policy.rego": 80,
+			"tests/opa/unit/helpers/helpers.rego":     90,
+		},
+	}
+
+	results := checkCoverageThresholds(thresholds, coverages, dataPath)
+	if len(results) != 5 {
+		t.Fatalf("Expected 5 results, got %d: %+v", len(results), results)
+	}
+
+	// 360 of 400 lines covered
+	if results[0].Scope != "global" || !results[0].Passed || results[0].Coverage != 90 {
+		t.Errorf("Expected global threshold to pass at 90%%, got %+v", results[0])
+	}
+
+	global := results[1]
+	if global.Name != "tests/opa/unit/global" || global.Passed {
+		t.Errorf("Expected tests/opa/unit/global to fail the default directory minimum, got %+v", global)
+	}
+	if len(global.Uncovered) != 2 || global.Uncovered[0].File != "tests/opa/unit/helpers/helpers.rego" || global.Uncovered[0].Lines[0] != "3" {
+		t.Errorf("Expected uncovered files with relative paths, got %+v", global.Uncovered)
+	}
+
+	if module := results[2]; !module.Passed || module.Threshold != 95 {
+		t.Errorf("Expected directory override to apply and pass, got %+v", module)
+	}
+
+	license := results[3]
+	if license.Name != "policies/opa/global/license_policy.rego" || license.Passed {
+		t.Errorf("Expected license policy to fail its file minimum, got %+v", license)
+	}
+	if len(license.Uncovered) != 1 || license.Uncovered[0].Lines[0] != "5-8" {
+		t.Errorf("Expected uncovered range 5-8, got %+v", license.Uncovered)
+	}
+
+	// The helpers are fully covered by the module tests
+	if helpers := results[4]; !helpers.Passed || helpers.Coverage != 100 {
+		t.Errorf("Expected helpers to be judged by their best coverage, got %+v", helpers)
+	}
+}
+
+func TestReadConfigCoverageThresholds(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.json")
+	configData := `{
+		"rego_tests": ["tests/opa/unit/global"],
+		"rego_coverage_thresholds": {
+			"global": 90,
+			"directories": {"tests/opa/unit/global": 75},
+			"files": {"policies/opa/global/license_policy.rego": 60}
+		}
+	}`
+	if err := os.WriteFile(configPath, []byte(configData), 0644); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+
+	config, err := readConfig(configPath)
+	if err != nil {
+		t.Fatalf("Failed to read config: %v", err)
+	}
+	thresholds := config.CoverageThresholds
+	if thresholds == nil || thresholds.Global != 90 || thresholds.Directories["tests/opa/unit/global"] != 75 || thresholds.Files["policies/opa/global/license_policy.rego"] != 60 {
+		t.Errorf("Unexpected thresholds: %+v", thresholds)
+	}
+}
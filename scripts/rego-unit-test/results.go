@@ -0,0 +1,223 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+//
+// ---------- Test Results Report ----------
+//
+
+// ResultCounts holds the number of tests per outcome.
+type ResultCounts struct {
+	Tests   int `json:"tests"`   // Total number of tests
+	Passed  int `json:"passed"`  // Tests that passed
+	Failed  int `json:"failed"`  // Tests that were false or undefined
+	Errors  int `json:"errors"`  // Tests that could not be evaluated
+	Skipped int `json:"skipped"` // todo_ tests
+}
+
+// add counts one test with the given status.
+func (c *ResultCounts) add(status string) {
+	c.Tests++
+	switch status {
+	case StatusPass:
+		c.Passed++
+	case StatusFail:
+		c.Failed++
+	case StatusError:
+		c.Errors++
+	case StatusSkip:
+		c.Skipped++
+	}
+}
+
+// ResultsReport is the --results-json document.
+type ResultsReport struct {
+	ResultCounts
+	Duration float64       `json:"duration"` // Seconds, summed over suites
+	Suites   []SuiteReport `json:"suites"`   // One per rego_tests directory
+}
+
+// SuiteReport holds the results of one rego_tests directory.
+type SuiteReport struct {
+	Name string `json:"name"` // rego_tests entry
+	ResultCounts
+	Duration float64      `json:"duration"`        // Seconds
+	Error    string       `json:"error,omitempty"` // Loading or compilation error
+	Cases    []CaseReport `json:"cases"`           // Individual tests
+}
+
+// CaseReport holds the result of one test.
+type CaseReport struct {
+	Classname string  `json:"classname"`         // Rego package
+	Name      string  `json:"name"`              // Test rule name
+	File      string  `json:"file,omitempty"`    // File defining the test
+	Status    string  `json:"status"`            // pass, fail, error or skip
+	Duration  float64 `json:"duration"`          // Seconds
+	Message   string  `json:"message,omitempty"` // Evaluation error
+	Output    string  `json:"output,omitempty"`  // Captured print() output
+}
+
+// buildResultsReport converts suite results into a report.
+func buildResultsReport(suites []SuiteResult) ResultsReport {
+	report := ResultsReport{Suites: make([]SuiteReport, 0, len(suites))}
+	for _, suite := range suites {
+		sr := SuiteReport{
+			Name:     suite.TestPath,
+			Duration: suite.Duration.Seconds(),
+			Cases:    make([]CaseReport, 0, len(suite.Tests)),
+		}
+		if suite.Err != nil {
+			sr.Error = suite.Err.Error()
+		}
+		for _, test := range suite.Tests {
+			sr.add(test.Status)
+			report.add(test.Status)
+			sr.Cases = append(sr.Cases, CaseReport{
+				Classname: test.Package,
+				Name:      test.Name,
+				File:      test.File,
+				Status:    test.Status,
+				Duration:  test.Duration.Seconds(),
+				Message:   test.Error,
+				Output:    test.Output,
+			})
+		}
+		report.Duration += sr.Duration
+		report.Suites = append(report.Suites, sr)
+	}
+	return report
+}
+
+// writeResultsJSON writes the report as indented JSON.
+func writeResultsJSON(path string, report ResultsReport) error {
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error marshaling test results: %w", err)
+	}
+	return writeReportFile(path, data)
+}
+
+// writeReportFile writes a report, creating its directory if needed.
+func writeReportFile(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
+
+//
+// ---------- JUnit Report ----------
+//
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Errors   int              `xml:"errors,attr"`
+	Skipped  int              `xml:"skipped,attr"`
+	Time     string           `xml:"time,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name     string          `xml:"name,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Errors   int             `xml:"errors,attr"`
+	Skipped  int             `xml:"skipped,attr"`
+	Time     string          `xml:"time,attr"`
+	Cases    []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Classname string        `xml:"classname,attr"`
+	Name      string        `xml:"name,attr"`
+	File      string        `xml:"file,attr,omitempty"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Error     *junitMessage `xml:"error,omitempty"`
+	Skipped   *junitMessage `xml:"skipped,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr,omitempty"`
+	Body    string `xml:",chardata"`
+}
+
+// newJUnitReport converts a report into JUnit XML elements. A suite that
+// could not run is reported as a single errored test named after the suite.
+func newJUnitReport(name string, report ResultsReport) junitTestSuites {
+	junit := junitTestSuites{Name: name, Time: junitTime(report.Duration)}
+	for _, suite := range report.Suites {
+		js := junitTestSuite{
+			Name:     suite.Name,
+			Tests:    suite.Tests,
+			Failures: suite.Failed,
+			Errors:   suite.Errors,
+			Skipped:  suite.Skipped,
+			Time:     junitTime(suite.Duration),
+		}
+		for _, c := range suite.Cases {
+			jc := junitTestCase{Classname: c.Classname, Name: c.Name, File: c.File, Time: junitTime(c.Duration)}
+			switch c.Status {
+			case StatusFail:
+				jc.Failure = &junitMessage{Message: "test failed", Body: c.Output}
+			case StatusError:
+				jc.Error = &junitMessage{Message: firstLine(c.Message), Body: c.Message}
+			case StatusSkip:
+				jc.Skipped = &junitMessage{}
+			}
+			if jc.Failure == nil {
+				jc.SystemOut = c.Output
+			}
+			js.Cases = append(js.Cases, jc)
+		}
+		if suite.Error != "" {
+			js.Tests++
+			js.Errors++
+			js.Cases = append(js.Cases, junitTestCase{
+				Classname: suite.Name,
+				Name:      suite.Name,
+				Time:      junitTime(suite.Duration),
+				Error:     &junitMessage{Message: firstLine(suite.Error), Body: suite.Error},
+			})
+		}
+		junit.Tests += js.Tests
+		junit.Failures += js.Failures
+		junit.Errors += js.Errors
+		junit.Skipped += js.Skipped
+		junit.Suites = append(junit.Suites, js)
+	}
+	return junit
+}
+
+// writeJUnit writes the report as a JUnit XML file.
+func writeJUnit(path, name string, report ResultsReport) error {
+	data, err := xml.MarshalIndent(newJUnitReport(name, report), "", "  ")
+	if err != nil {
+		return fmt.Errorf("error marshaling JUnit report: %w", err)
+	}
+	return writeReportFile(path, append([]byte(xml.Header), data...))
+}
+
+// junitTime formats seconds the way JUnit consumers expect.
+func junitTime(seconds float64) string {
+	return fmt.Sprintf("%.3f", seconds)
+}
+
+// firstLine returns the first line of s.
+func firstLine(s string) string {
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		return s[:i]
+	}
+	return s
+}
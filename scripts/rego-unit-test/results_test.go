@@ -0,0 +1,107 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testSuiteResults() []SuiteResult {
+	return []SuiteResult{
+		{
+			TestPath: "tests/global",
+			Duration: 2 * time.Second,
+			Tests: []TestResult{
+				{Package: "global.test", Name: "test_ok", File: "tests/global/a_test.rego", Status: StatusPass, Duration: time.Millisecond},
+				{Package: "global.test", Name: "test_bad", File: "tests/global/a_test.rego", Status: StatusFail, Output: "got 2\n"},
+				{Package: "global.test", Name: "test_err", File: "tests/global/a_test.rego", Status: StatusError, Error: "eval_type_error: count: operand 1\ndetails"},
+				{Package: "global.test", Name: "todo_test_later", File: "tests/global/a_test.rego", Status: StatusSkip},
+			},
+		},
+		{
+			TestPath: "tests/broken",
+			Duration: time.Second,
+			Err:      errors.New("error loading tests/broken: rego_parse_error"),
+		},
+	}
+}
+
+func TestBuildResultsReport(t *testing.T) {
+	report := buildResultsReport(testSuiteResults())
+
+	expected := ResultCounts{Tests: 4, Passed: 1, Failed: 1, Errors: 1, Skipped: 1}
+	if report.ResultCounts != expected {
+		t.Errorf("Expected counts %+v, got %+v", expected, report.ResultCounts)
+	}
+	if report.Duration != 3 {
+		t.Errorf("Expected duration 3, got %v", report.Duration)
+	}
+	if len(report.Suites) != 2 {
+		t.Fatalf("Expected 2 suites, got %d", len(report.Suites))
+	}
+	if report.Suites[1].Error == "" || len(report.Suites[1].Cases) != 0 {
+		t.Errorf("Expected the broken suite to have an error and no cases, got %+v", report.Suites[1])
+	}
+	if c := report.Suites[0].Cases[0]; c.Classname != "global.test" || c.Duration != 0.001 {
+		t.Errorf("Unexpected first case %+v", c)
+	}
+}
+
+func TestWriteResults(t *testing.T) {
+	dir := t.TempDir()
+	outputs := resultOutputs{JUnit: filepath.Join(dir, "junit.xml"), JSON: filepath.Join(dir, "results.json")}
+	if !writeResults(outputs, buildResultsReport(testSuiteResults())) {
+		t.Fatalf("Expected results to be written")
+	}
+
+	data, err := os.ReadFile(outputs.JUnit)
+	if err != nil {
+		t.Fatalf("Failed to read JUnit report: %v", err)
+	}
+	var junit junitTestSuites
+	if err := xml.Unmarshal(data, &junit); err != nil {
+		t.Fatalf("Failed to parse JUnit report: %v", err)
+	}
+	// The broken suite adds one errored test
+	if junit.Tests != 5 || junit.Failures != 1 || junit.Errors != 2 || junit.Skipped != 1 {
+		t.Errorf("Unexpected JUnit totals %+v", junit)
+	}
+	cases := junit.Suites[0].Cases
+	if cases[1].Failure == nil || !strings.Contains(cases[1].Failure.Body, "got 2") {
+		t.Errorf("Expected failure with output, got %+v", cases[1])
+	}
+	if cases[2].Error == nil || cases[2].Error.Message != "eval_type_error: count: operand 1" {
+		t.Errorf("Expected error with first line as message, got %+v", cases[2])
+	}
+	if cases[3].Skipped == nil {
+		t.Errorf("Expected skipped test, got %+v", cases[3])
+	}
+	if broken := junit.Suites[1].Cases; len(broken) != 1 || broken[0].Name != "tests/broken" || broken[0].Error == nil {
+		t.Errorf("Expected one errored test for the broken suite, got %+v", broken)
+	}
+
+	data, err = os.ReadFile(outputs.JSON)
+	if err != nil {
+		t.Fatalf("Failed to read results JSON: %v", err)
+	}
+	var report ResultsReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		t.Fatalf("Failed to parse results JSON: %v", err)
+	}
+	if report.Tests != 4 || report.Suites[0].Cases[3].Status != StatusSkip {
+		t.Errorf("Unexpected results JSON %+v", report)
+	}
+
+	// Missing directories are created, unwritable paths are reported
+	if !writeResults(resultOutputs{JSON: filepath.Join(dir, "reports", "results.json")}, ResultsReport{}) {
+		t.Errorf("Expected the reports directory to be created")
+	}
+	if writeResults(resultOutputs{JSON: filepath.Join(outputs.JUnit, "results.json")}, ResultsReport{}) {
+		t.Errorf("Expected writing below a file to fail")
+	}
+}
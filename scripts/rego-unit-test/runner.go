@@ -0,0 +1,274 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/open-policy-agent/opa/ast"
+	"github.com/open-policy-agent/opa/cover"
+	"github.com/open-policy-agent/opa/loader"
+	"github.com/open-policy-agent/opa/storage/inmem"
+	"github.com/open-policy-agent/opa/tester"
+)
+
+//
+// ---------- Suite Results ----------
+//
+
+// regoVersion matches the default of the OPA 1.x CLI pinned in .tool-versions
+var regoVersion = ast.RegoV1
+
+// Test statuses
+const (
+	StatusPass  = "pass"
+	StatusFail  = "fail"
+	StatusError = "error"
+	StatusSkip  = "skip"
+)
+
+// TestResult is the outcome of a single Rego test rule.
+type TestResult struct {
+	Package  string        // Package without the data. prefix
+	Name     string        // Test rule name
+	File     string        // File defining the test
+	Status   string        // pass, fail, error or skip
+	Duration time.Duration // Time spent evaluating the test
+	Error    string        // Evaluation error, if any
+	Output   string        // Captured print() output
+}
+
+// SuiteResult is the outcome of one rego_tests directory.
+type SuiteResult struct {
+	TestPath string        // rego_tests entry
+	Tests    []TestResult  // Results in file and definition order
+	Coverage *CoverageData // Nil when coverage is disabled or the suite failed to load
+	Err      error         // Loading or compilation error
+	Duration time.Duration // Wall time for the whole suite
+}
+
+// Counts returns the number of tests per status.
+func (s SuiteResult) Counts() map[string]int {
+	counts := make(map[string]int)
+	for _, test := range s.Tests {
+		counts[test.Status]++
+	}
+	return counts
+}
+
+// Failed reports whether the suite could not run or has failing tests.
+func (s SuiteResult) Failed() bool {
+	counts := s.Counts()
+	return s.Err != nil || counts[StatusFail] > 0 || counts[StatusError] > 0
+}
+
+//
+// ---------- Suite Runner ----------
+//
+
+// runSuites runs the suite of every rego_tests directory, at most jobs at a
+// time, and returns the results in config order.
+func runSuites(ctx context.Context, config *Config, dataPath string, withCoverage bool, jobs int) []SuiteResult {
+	if jobs < 1 {
+		jobs = 1
+	}
+
+	// Full path to helpers directory
+	helpersDir := ""
+	if config.RegoHelpersDir != "" {
+		helpersDir = filepath.Join(dataPath, config.RegoHelpersDir)
+	}
+
+	results := make([]SuiteResult, len(config.RegoTests))
+	sem := make(chan struct{}, jobs)
+	var wg sync.WaitGroup
+	for i, testPath := range config.RegoTests {
+		wg.Add(1)
+		go func(i int, testPath string) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			// Load the tests, their policies and the shared helpers
+			paths := []string{testPath}
+			if policyDir := config.getPolicyDir(testPath); policyDir != "" {
+				paths = append(paths, policyDir)
+			}
+			if helpersDir != "" {
+				paths = append(paths, helpersDir)
+			}
+
+			results[i] = runSuite(ctx, testPath, paths, withCoverage)
+		}(i, testPath)
+	}
+	wg.Wait()
+
+	return results
+}
+
+// runSuite loads the given paths and runs their tests in-process, the
+// equivalent of `opa test [--coverage] <paths>`.
+func runSuite(ctx context.Context, testPath string, paths []string, withCoverage bool) SuiteResult {
+	start := time.Now()
+	suite := SuiteResult{TestPath: testPath}
+
+	loaded, err := loader.NewFileLoader().WithRegoVersion(regoVersion).Filtered(paths, nil)
+	if err != nil {
+		suite.Err = fmt.Errorf("error loading %s: %w", strings.Join(paths, ", "), err)
+		suite.Duration = time.Since(start)
+		return suite
+	}
+	modules := loaded.ParsedModules()
+
+	runner := tester.NewRunner().
+		SetStore(inmem.NewFromObject(loaded.Documents)).
+		CapturePrintOutput(true).
+		RaiseBuiltinErrors(true)
+
+	var coverage *cover.Cover
+	if withCoverage {
+		coverage = cover.New()
+		runner.SetCoverageQueryTracer(coverage)
+	}
+
+	ch, err := runner.Run(ctx, modules)
+	if err != nil {
+		suite.Err = fmt.Errorf("error compiling %s: %w", testPath, err)
+		suite.Duration = time.Since(start)
+		return suite
+	}
+
+	for result := range ch {
+		suite.Tests = append(suite.Tests, newTestResult(result))
+	}
+
+	if coverage != nil {
+		data, err := coverageData(coverage.Report(modules))
+		if err != nil {
+			suite.Err = fmt.Errorf("error building coverage for %s: %w", testPath, err)
+		} else {
+			suite.Coverage = data
+		}
+	}
+
+	suite.Duration = time.Since(start)
+	return suite
+}
+
+// newTestResult converts a tester result.
+func newTestResult(result *tester.Result) TestResult {
+	test := TestResult{
+		Package:  strings.TrimPrefix(result.Package, "data."),
+		Name:     result.Name,
+		Duration: result.Duration,
+		Output:   string(result.Output),
+	}
+	if result.Location != nil {
+		test.File = result.Location.File
+	}
+
+	switch {
+	case result.Error != nil:
+		test.Status = StatusError
+		test.Error = result.Error.Error()
+	case result.Skip:
+		test.Status = StatusSkip
+	case result.Fail:
+		test.Status = StatusFail
+	default:
+		test.Status = StatusPass
+	}
+	return test
+}
+
+// coverageData converts a cover report into the format written by
+// `opa test --coverage --format=json`.
+func coverageData(report cover.Report) (*CoverageData, error) {
+	data, err := json.Marshal(report)
+	if err != nil {
+		return nil, err
+	}
+	var coverage CoverageData
+	if err := json.Unmarshal(data, &coverage); err != nil {
+		return nil, err
+	}
+	return &coverage, nil
+}
+
+//
+// ---------- Suite Output ----------
+//
+
+// printSuiteResult prints a suite in the layout of `opa test`. Passing and
+// skipped tests are only listed when verbose is set.
+func printSuiteResult(w io.Writer, suite SuiteResult, verbose bool) {
+	if suite.Err != nil {
+		fmt.Fprintf(w, "%v\n", suite.Err)
+		return
+	}
+
+	// Group tests by file, in file order
+	var files []string
+	byFile := make(map[string][]TestResult)
+	for _, test := range suite.Tests {
+		if _, ok := byFile[test.File]; !ok {
+			files = append(files, test.File)
+		}
+		byFile[test.File] = append(byFile[test.File], test)
+	}
+	sort.Strings(files)
+
+	for _, file := range files {
+		var lines []string
+		for _, test := range byFile[file] {
+			if !verbose && (test.Status == StatusPass || test.Status == StatusSkip) {
+				continue
+			}
+			line := fmt.Sprintf("data.%s.%s: %s (%s)", test.Package, test.Name, strings.ToUpper(test.Status), test.Duration)
+			if test.Error != "" {
+				line += "\n  " + test.Error
+			}
+			if test.Output != "" {
+				line += "\n" + indent(strings.TrimRight(test.Output, "\n"), "  ")
+			}
+			lines = append(lines, line)
+		}
+		if len(lines) == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "%s:\n", file)
+		for _, line := range lines {
+			fmt.Fprintln(w, line)
+		}
+	}
+
+	counts := suite.Counts()
+	total := len(suite.Tests)
+	fmt.Fprintln(w, strings.Repeat("-", 80))
+	if counts[StatusPass] > 0 {
+		fmt.Fprintf(w, "PASS: %d/%d\n", counts[StatusPass], total)
+	}
+	if counts[StatusFail] > 0 {
+		fmt.Fprintf(w, "FAIL: %d/%d\n", counts[StatusFail], total)
+	}
+	if counts[StatusError] > 0 {
+		fmt.Fprintf(w, "ERROR: %d/%d\n", counts[StatusError], total)
+	}
+	if counts[StatusSkip] > 0 {
+		fmt.Fprintf(w, "SKIPPED: %d/%d\n", counts[StatusSkip], total)
+	}
+	if total == 0 {
+		fmt.Fprintln(w, "No tests found")
+	}
+}
+
+// indent prefixes every line of s.
+func indent(s, prefix string) string {
+	return prefix + strings.ReplaceAll(s, "\n", "\n"+prefix)
+}
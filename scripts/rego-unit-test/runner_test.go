@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testPolicy = `package example
+
+deny contains msg if {
+	input.name == ""
+	msg := "name is required"
+}
+
+deny contains msg if {
+	input.size > 10
+	msg := "size is too large"
+}
+`
+
+const testPolicyTests = `package example_test
+
+import data.example
+
+test_empty_name if {
+	example.deny["name is required"] with input as {"name": ""}
+}
+
+test_wrong_expectation if {
+	count(example.deny) == 1 with input as {"name": "ok", "size": 1}
+}
+
+todo_test_size if {
+	true
+}
+`
+
+// writeRegoFile writes a Rego file below root.
+func writeRegoFile(t *testing.T, root, rel, content string) {
+	path := filepath.Join(root, rel)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("Failed to create dir: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write %s: %v", rel, err)
+	}
+}
+
+func TestRunSuite(t *testing.T) {
+	root := t.TempDir()
+	writeRegoFile(t, root, "policies/example.rego", testPolicy)
+	writeRegoFile(t, root, "tests/example_test.rego", testPolicyTests)
+
+	paths := []string{filepath.Join(root, "tests"), filepath.Join(root, "policies")}
+	suite := runSuite(context.Background(), "tests", paths, true)
+	if suite.Err != nil {
+		t.Fatalf("runSuite() error = %v", suite.Err)
+	}
+
+	statuses := make(map[string]string)
+	for _, test := range suite.Tests {
+		statuses[test.Name] = test.Status
+		if test.Package != "example_test" {
+			t.Errorf("Expected package example_test, got %s", test.Package)
+		}
+		if !strings.HasSuffix(test.File, "example_test.rego") {
+			t.Errorf("Expected test file example_test.rego, got %s", test.File)
+		}
+	}
+	expected := map[string]string{
+		"test_empty_name":        StatusPass,
+		"test_wrong_expectation": StatusFail,
+		"todo_test_size":         StatusSkip,
+	}
+	for name, status := range expected {
+		if statuses[name] != status {
+			t.Errorf("Expected %s to be %s, got %q", name, status, statuses[name])
+		}
+	}
+	if !suite.Failed() {
+		t.Errorf("Expected suite with a failing test to be failed")
+	}
+
+	// The size rule is never evaluated with a large size
+	if suite.Coverage == nil {
+		t.Fatalf("Expected coverage data")
+	}
+	policyCoverage, ok := suite.Coverage.Files[filepath.Join(root, "policies", "example.rego")]
+	if !ok {
+		t.Fatalf("Expected coverage for example.rego, got files %v", suite.Coverage.Files)
+	}
+	if policyCoverage.NotCoveredLines == 0 || policyCoverage.Coverage >= 100 {
+		t.Errorf("Expected example.rego to be partially covered, got %+v", policyCoverage)
+	}
+}
+
+func TestRunSuiteLoadError(t *testing.T) {
+	root := t.TempDir()
+	writeRegoFile(t, root, "tests/broken_test.rego", "package broken\n\ntest_x if {\n")
+
+	suite := runSuite(context.Background(), "tests", []string{filepath.Join(root, "tests")}, false)
+	if suite.Err == nil {
+		t.Fatalf("Expected an error for a file that does not parse")
+	}
+	if !suite.Failed() {
+		t.Errorf("Expected suite with a load error to be failed")
+	}
+}
+
+func TestRunSuitesOrder(t *testing.T) {
+	root := t.TempDir()
+	config := &Config{RegoPolicyDirs: make(map[string]string)}
+	for _, name := range []string{"c", "a", "b"} {
+		dir := filepath.Join(root, name)
+		writeRegoFile(t, dir, "policy_test.rego", "package "+name+"_test\n\ntest_ok if {\n\ttrue\n}\n")
+		config.RegoTests = append(config.RegoTests, dir)
+	}
+
+	suites := runSuites(context.Background(), config, root, false, 2)
+	if len(suites) != 3 {
+		t.Fatalf("Expected 3 suites, got %d", len(suites))
+	}
+	for i, suite := range suites {
+		if suite.TestPath != config.RegoTests[i] {
+			t.Errorf("Expected suite %d to be %s, got %s", i, config.RegoTests[i], suite.TestPath)
+		}
+		if suite.Failed() || len(suite.Tests) != 1 {
+			t.Errorf("Expected one passing test in %s, got %+v (err %v)", suite.TestPath, suite.Tests, suite.Err)
+		}
+		if suite.Coverage != nil {
+			t.Errorf("Expected no coverage when disabled")
+		}
+	}
+}
+
+func TestPrintSuiteResult(t *testing.T) {
+	suite := SuiteResult{
+		TestPath: "tests",
+		Tests: []TestResult{
+			{Package: "example_test", Name: "test_ok", File: "tests/example_test.rego", Status: StatusPass},
+			{Package: "example_test", Name: "test_bad", File: "tests/example_test.rego", Status: StatusFail, Output: "got 2\n"},
+		},
+	}
+
+	var buf bytes.Buffer
+	printSuiteResult(&buf, suite, false)
+	output := buf.String()
+	if strings.Contains(output, "test_ok") {
+		t.Errorf("Expected passing tests to be hidden without verbose, got:\n%s", output)
+	}
+	for _, want := range []string{"tests/example_test.rego:", "data.example_test.test_bad: FAIL", "  got 2", "PASS: 1/2", "FAIL: 1/2"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("Expected output to contain %q, got:\n%s", want, output)
+		}
+	}
+
+	buf.Reset()
+	printSuiteResult(&buf, suite, true)
+	if !strings.Contains(buf.String(), "data.example_test.test_ok: PASS") {
+		t.Errorf("Expected passing tests in verbose output, got:\n%s", buf.String())
+	}
+}
@@ -4,27 +4,50 @@ import (
 	"encoding/json"
 	"flag"
 	"fmt"
-	"io/ioutil"
+	"io"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 )
 
+// logOutput receives progress messages. It is switched to stderr when the
+// result is written to stdout.
+var logOutput io.Writer = os.Stdout
+
 func main() {
 	modulePath := flag.String("module-path", "", "Path to the Terraform module")
 	outputPath := flag.String("output", "", "Path to output JSON file")
 	configPath := flag.String("config", "", "Path to the monorepo configuration file")
-	flag.Parse()
+	asRegoFixture := flag.Bool("as-rego-fixture", false, "Emit the module-validator input as a `with input as {...}` block for Rego unit tests (written to stdout unless --output is set)")
+	policyPackage := flag.String("package", "", "Package of the policy under test, such as terraform.module.license (required with --as-rego-fixture)")
+
+	// "collect" is accepted as an explicit subcommand name
+	args := os.Args[1:]
+	if len(args) > 0 && args[0] == "collect" {
+		args = args[1:]
+	}
+	flag.CommandLine.Parse(args)
 
 	if *modulePath == "" {
 		fmt.Println("Error: Module path is required")
 		os.Exit(1)
 	}
 
-	if *outputPath == "" {
+	if *outputPath == "" && !*asRegoFixture {
 		fmt.Println("Error: Output path is required")
 		os.Exit(1)
 	}
 
+	if *asRegoFixture && *policyPackage == "" {
+		fmt.Println("Error: Policy package is required with --as-rego-fixture")
+		os.Exit(1)
+	}
+
+	if *asRegoFixture && *outputPath == "" {
+		logOutput = os.Stderr
+	}
+
 	if *configPath == "" {
 		fmt.Println("Error: Configuration file path is required")
 		os.Exit(1)
@@ -74,6 +97,20 @@ func main() {
 		os.Exit(1)
 	}
 
+	if *asRegoFixture {
+		fixture := formatRegoFixture(*modulePath, *policyPackage, files)
+		if *outputPath == "" {
+			fmt.Print(fixture)
+			return
+		}
+		if err := os.WriteFile(*outputPath, []byte(fixture), 0644); err != nil {
+			fmt.Printf("Error writing output file: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Rego fixture written to %s\n", *outputPath)
+		return
+	}
+
 	// Create output structure
 	output := map[string]interface{}{
 		"files": files,
@@ -86,7 +123,7 @@ func main() {
 		os.Exit(1)
 	}
 
-	err = ioutil.WriteFile(*outputPath, outputJSON, 0644)
+	err = os.WriteFile(*outputPath, outputJSON, 0644)
 	if err != nil {
 		fmt.Printf("Error writing output file: %v\n", err)
 		os.Exit(1)
@@ -97,7 +134,7 @@ func main() {
 
 // loadConfig loads the configuration from a JSON file
 func loadConfig(path string) (map[string]interface{}, error) {
-	data, err := ioutil.ReadFile(path)
+	data, err := os.ReadFile(path)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read config file: %w", err)
 	}
@@ -124,7 +161,7 @@ func collectTerraformFiles(modulePath string, excludedDirs, importantDirs []stri
 			dirName := filepath.Base(path)
 			for _, excludedDir := range excludedDirs {
 				if dirName == excludedDir {
-					fmt.Printf("Skipping excluded directory: %s\n", path)
+					fmt.Fprintf(logOutput, "Skipping excluded directory: %s\n", path)
 					return filepath.SkipDir
 				}
 			}
@@ -158,7 +195,7 @@ func collectTerraformFiles(modulePath string, excludedDirs, importantDirs []stri
 		}
 
 		// Read file content
-		content, err := ioutil.ReadFile(path)
+		content, err := os.ReadFile(path)
 		if err != nil {
 			return err
 		}
@@ -172,9 +209,53 @@ func collectTerraformFiles(modulePath string, excludedDirs, importantDirs []stri
 		// Store with module path prefix
 		fullPath := fmt.Sprintf("%s/%s", modulePath, relPath)
 		files[fullPath] = string(content)
-		fmt.Printf("Collected file: %s\n", fullPath)
+		fmt.Fprintf(logOutput, "Collected file: %s\n", fullPath)
 		return nil
 	})
 
 	return files, err
 }
+
+// formatRegoFixture renders collected files as the input document built by
+// module-validator (module name as root of the file keys), formatted as a
+// `with input as {...}` block ready to paste into a test under tests/opa/unit.
+// The block is preceded by the import of policyPackage it evaluates.
+func formatRegoFixture(modulePath, policyPackage string, files map[string]string) string {
+	cleanModulePath := strings.TrimSuffix(modulePath, "/")
+	moduleName := filepath.Base(cleanModulePath)
+
+	keys := make([]string, 0, len(files))
+	rewritten := make(map[string]string, len(files))
+	for filePath, content := range files {
+		if strings.HasPrefix(filePath, cleanModulePath+"/") {
+			filePath = strings.Replace(filePath, cleanModulePath, moduleName, 1)
+		}
+		rewritten[filePath] = content
+		keys = append(keys, filePath)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "import data.%s as policy\n\n", strings.TrimPrefix(policyPackage, "data."))
+	fmt.Fprintf(&b, "# Input collected from %s\n", cleanModulePath)
+	b.WriteString("violations := policy.violation with input as {\n")
+	fmt.Fprintf(&b, "\t\"module_path\": %s,\n", regoString(moduleName))
+	fmt.Fprintf(&b, "\t\"repo_path\": %s,\n", regoString(cleanModulePath))
+	b.WriteString("\t\"files\": {\n")
+	for _, key := range keys {
+		fmt.Fprintf(&b, "\t\t%s: %s,\n", regoString(key), regoString(rewritten[key]))
+	}
+	b.WriteString("\t},\n")
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// regoString quotes a string as a Rego string literal. Rego strings use JSON
+// escaping.
+func regoString(s string) string {
+	var b strings.Builder
+	encoder := json.NewEncoder(&b)
+	encoder.SetEscapeHTML(false)
+	encoder.Encode(s)
+	return strings.TrimSuffix(b.String(), "\n")
+}
@@ -1,7 +1,6 @@
 package main
 
 import (
-	"io/ioutil"
 	"os"
 	"path/filepath"
 	"testing"
@@ -9,7 +8,7 @@ import (
 
 func TestCollectTerraformFiles(t *testing.T) {
 	// Create a temporary directory structure with Terraform files
-	tmpDir, err := ioutil.TempDir("", "terraform-test")
+	tmpDir, err := os.MkdirTemp("", "terraform-test")
 	if err != nil {
 		t.Fatalf("Failed to create temp dir: %v", err)
 	}
@@ -34,7 +33,7 @@ func TestCollectTerraformFiles(t *testing.T) {
 		}
 
 		// Write file
-		if err := ioutil.WriteFile(fullPath, []byte(content), 0644); err != nil {
+		if err := os.WriteFile(fullPath, []byte(content), 0644); err != nil {
 			t.Fatalf("Failed to write file %s: %v", fullPath, err)
 		}
 	}
@@ -69,3 +68,33 @@ func TestCollectTerraformFiles(t *testing.T) {
 		}
 	}
 }
+
+func TestFormatRegoFixture(t *testing.T) {
+	files := map[string]string{
+		"skeletons/my-module/main.tf": "resource \"null_resource\" \"this\" {}\n",
+		"skeletons/my-module/tests":   "directory",
+		"skeletons/my-module/doc.md":  "<b>bold</b>",
+	}
+
+	expected := `import data.terraform.module.license as policy
+
+# Input collected from skeletons/my-module
+violations := policy.violation with input as {
+	"module_path": "my-module",
+	"repo_path": "skeletons/my-module",
+	"files": {
+		"my-module/doc.md": "<b>bold</b>",
+		"my-module/main.tf": "resource \"null_resource\" \"this\" {}\n",
+		"my-module/tests": "directory",
+	},
+}
+`
+	if got := formatRegoFixture("skeletons/my-module/", "terraform.module.license", files); got != expected {
+		t.Errorf("formatRegoFixture() mismatch:\nExpected:\n%s\nGot:\n%s", expected, got)
+	}
+
+	// A data. prefix on the package is not repeated
+	if got := formatRegoFixture("skeletons/my-module/", "data.terraform.module.license", files); got != expected {
+		t.Errorf("formatRegoFixture() with data. prefix mismatch:\nExpected:\n%s\nGot:\n%s", expected, got)
+	}
+}
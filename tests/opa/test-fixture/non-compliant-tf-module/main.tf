@@ -55,4 +55,10 @@ module "external_module" {
 output "file_content" {
   description = "This should be in outputs.tf"
   value       = local_file.hardcoded_file.content
-}
\ No newline at end of file
+}
+
+# Reference to a variable that is never declared
+resource "local_file" "undeclared_reference" {
+  content  = var.undeclared_content
+  filename = "/tmp/undeclared.txt"  # Hardcoded value
+}